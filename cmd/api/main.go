@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
 	"os"
 	"strconv"
 	"time"
@@ -9,10 +11,15 @@ import (
 	_ "github.com/GunarsK-portfolio/files-api/docs"
 	"github.com/GunarsK-portfolio/files-api/internal/config"
 	"github.com/GunarsK-portfolio/files-api/internal/handlers"
+	"github.com/GunarsK-portfolio/files-api/internal/jobs"
+	"github.com/GunarsK-portfolio/files-api/internal/ratelimit"
+	"github.com/GunarsK-portfolio/files-api/internal/reconcile"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
 	"github.com/GunarsK-portfolio/files-api/internal/routes"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
 	"github.com/GunarsK-portfolio/files-api/internal/storage"
 	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonconfig "github.com/GunarsK-portfolio/portfolio-common/config"
 	commondb "github.com/GunarsK-portfolio/portfolio-common/database"
 	"github.com/GunarsK-portfolio/portfolio-common/health"
 	"github.com/GunarsK-portfolio/portfolio-common/logger"
@@ -20,6 +27,8 @@ import (
 	commonrepo "github.com/GunarsK-portfolio/portfolio-common/repository"
 	"github.com/GunarsK-portfolio/portfolio-common/server"
 	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
 )
 
 // @title Portfolio Files API
@@ -77,11 +86,75 @@ func main() {
 	// Health checks
 	healthAgg := health.NewAggregator(3 * time.Second)
 	healthAgg.Register(health.NewPostgresChecker(db))
-	healthAgg.Register(health.NewMinIOChecker(stor.Client(), cfg.ImagesBucket))
+	healthAgg.Register(health.NewMinIOChecker(stor.Client(), cfg.FileTypes.Buckets()[0]))
+
+	// Antivirus scanning (optional)
+	var handlerOpts []handlers.Option
+	if cfg.ClamdAddress != "" {
+		clamd, err := scanner.NewClamd(cfg.ClamdAddress, cfg.ClamdTimeout)
+		if err != nil {
+			appLogger.Error("Failed to initialize virus scanner", "error", err)
+			log.Fatal("Failed to initialize virus scanner:", err)
+		}
+		healthAgg.Register(scanner.NewClamdChecker(clamd))
+		handlerOpts = append(handlerOpts, handlers.WithScanner(clamd))
+		appLogger.Info("Virus scanning enabled", "address", cfg.ClamdAddress, "infected_action", cfg.InfectedFileAction)
+	}
+
+	// Upload rate limiting, shared by all replicas through Redis or kept per replica
+	if cfg.UploadRateLimit > 0 {
+		limit := ratelimit.PerMinute(cfg.UploadRateLimit, cfg.UploadRateBurst)
+		var limiter ratelimit.Limiter
+		if cfg.RateLimitBackend == config.RateLimitBackendRedis {
+			redisCfg := commonconfig.NewRedisConfig()
+			redisClient := redis.NewClient(&redis.Options{
+				Addr:     net.JoinHostPort(redisCfg.Host, strconv.Itoa(redisCfg.Port)),
+				Password: redisCfg.Password,
+			})
+			defer func() {
+				if closeErr := redisClient.Close(); closeErr != nil {
+					appLogger.Error("Failed to close Redis client", "error", closeErr)
+				}
+			}()
+			healthAgg.Register(health.NewRedisChecker(redisClient))
+			limiter = ratelimit.NewRedis(redisClient, limit, "files-api:ratelimit:")
+		} else {
+			limiter = ratelimit.NewMemory(limit)
+		}
+		handlerOpts = append(handlerOpts, handlers.WithUploadLimiter(limiter))
+		appLogger.Info("Upload rate limiting enabled", "per_minute", cfg.UploadRateLimit, "burst", cfg.UploadRateBurst, "backend", cfg.RateLimitBackend)
+	}
 
 	repo := repository.New(db)
 	actionLogRepo := commonrepo.NewActionLogRepository(db)
-	handler := handlers.New(repo, stor, cfg, actionLogRepo)
+
+	// Storage reconciliation, run periodically and on demand from the admin endpoint
+	reconciler := reconcile.New(repo, stor, reconcile.Config{
+		Buckets:     cfg.FileTypes.Buckets(),
+		Mode:        cfg.ReconcileMode,
+		GracePeriod: cfg.ReconcileGracePeriod,
+	}, prometheus.DefaultRegisterer, appLogger)
+	handlerOpts = append(handlerOpts, handlers.WithReconciler(reconciler))
+
+	handler := handlers.New(repo, stor, cfg, actionLogRepo, handlerOpts...)
+
+	// Background jobs (stopped when the server shuts down), each run by one replica at a time
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+	jobLocks := repository.NewJobLocks(db)
+	jobs.Start(jobsCtx, jobs.NewUploadCleanup(repo, stor, appLogger), cfg.UploadCleanupInterval, jobLocks, appLogger)
+	jobs.Start(jobsCtx, jobs.NewPendingUploadSweep(repo, cfg.PendingUploadTimeout, appLogger), cfg.UploadCleanupInterval, jobLocks, appLogger)
+	jobs.Start(jobsCtx, jobs.NewIdempotencyKeyCleanup(repo, appLogger), cfg.UploadCleanupInterval, jobLocks, appLogger)
+	jobs.Start(jobsCtx, jobs.NewTrashPurge(repo, cfg.TrashRetention, appLogger), cfg.TrashPurgeInterval, jobLocks, appLogger)
+	deletionRetry := jobs.DeletionRetry{
+		Backoff:    cfg.DeletionRetryBackoff,
+		MaxBackoff: cfg.DeletionMaxBackoff,
+		StuckAfter: cfg.DeletionStuckAfter,
+	}
+	jobs.Start(jobsCtx, jobs.NewFileDeletions(repo, stor, deletionRetry, prometheus.DefaultRegisterer, appLogger), cfg.DeletionWorkerInterval, jobLocks, appLogger)
+	jobs.Start(jobsCtx, reconciler, cfg.ReconcileInterval, jobLocks, appLogger)
+	integrityScrub := jobs.NewIntegrityScrub(repo, stor, cfg.ScrubBatchSize, cfg.ScrubReverifyAfter, prometheus.DefaultRegisterer, appLogger)
+	jobs.Start(jobsCtx, integrityScrub, cfg.ScrubInterval, jobLocks, appLogger)
 
 	router := gin.New()
 	router.Use(logger.Recovery(appLogger))
@@ -3,21 +3,133 @@ package config
 import (
 	"fmt"
 	"log"
+	"slices"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/go-playground/validator/v10"
 
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/images"
 	common "github.com/GunarsK-portfolio/portfolio-common/config"
 )
 
+// Stores for the token buckets of the upload rate limiter
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
+// Actions taken for uploads the antivirus scanner flags as infected
+const (
+	InfectedFileReject     = "reject"
+	InfectedFileQuarantine = "quarantine"
+)
+
 type Config struct {
 	common.DatabaseConfig
 	common.ServiceConfig
 	common.S3Config
-	JWTSecret        string   `validate:"required,min=32"`
-	MaxFileSize      int64    `validate:"gt=0"`
-	AllowedFileTypes []string `validate:"required,min=1,dive,required"`
+	JWTSecret string `validate:"required,min=32"`
+
+	// Accepted file types: their buckets, content, extensions, sizes and caching
+	FileTypes *filetypes.Registry `validate:"required"`
+
+	// Resumable uploads
+	TusUploadExpiry       time.Duration `validate:"gt=0"`
+	UploadCleanupInterval time.Duration `validate:"gt=0"`
+
+	// Multipart uploads still pending after PendingUploadTimeout are discarded
+	PendingUploadTimeout time.Duration `validate:"gt=0"`
+
+	// Batch uploads accept up to BatchUploadMaxFiles files, storing BatchUploadConcurrency
+	// of them at a time
+	BatchUploadMaxFiles    int `validate:"gt=0"`
+	BatchUploadConcurrency int `validate:"gt=0"`
+
+	// Deleted files stay in the trash for TrashRetention before being purged
+	TrashRetention     time.Duration `validate:"gt=0"`
+	TrashPurgeInterval time.Duration `validate:"gt=0"`
+
+	// Objects of purged files are deleted by a worker retrying failures with exponential
+	// backoff; deletions pending longer than DeletionStuckAfter are reported as stuck
+	DeletionWorkerInterval time.Duration `validate:"gt=0"`
+	DeletionRetryBackoff   time.Duration `validate:"gt=0"`
+	DeletionMaxBackoff     time.Duration `validate:"gtefield=DeletionRetryBackoff"`
+	DeletionStuckAfter     time.Duration `validate:"gt=0"`
+
+	// Storage reconciliation; repair mode deletes orphaned objects and marks files missing
+	ReconcileInterval    time.Duration `validate:"gt=0"`
+	ReconcileMode        string        `validate:"oneof=report repair"`
+	ReconcileGracePeriod time.Duration `validate:"gte=0"`
+
+	// The integrity scrubber re-hashes up to ScrubBatchSize objects per run, each at most
+	// once per ScrubReverifyAfter
+	ScrubInterval      time.Duration `validate:"gt=0"`
+	ScrubBatchSize     int           `validate:"gt=0"`
+	ScrubReverifyAfter time.Duration `validate:"gt=0"`
+
+	// Direct-to-S3 uploads
+	PresignedUploadExpiry time.Duration `validate:"gt=0,lte=168h"`
+	S3PublicEndpoint      string        `validate:"omitempty,url"`
+	S3Region              string        `validate:"required"`
+
+	// Signed download URLs for private files last SignedURLExpiry unless the caller asks
+	// for another lifetime up to SignedURLMaxExpiry. Without SignedURLSecret none are
+	// issued or accepted; private files are then only served with a token.
+	SignedURLSecret    string        `validate:"omitempty,min=32"`
+	SignedURLExpiry    time.Duration `validate:"gt=0,ltefield=SignedURLMaxExpiry"`
+	SignedURLMaxExpiry time.Duration `validate:"lte=168h"`
+
+	// Share links last ShareLinkExpiry unless created with another lifetime up to
+	// ShareLinkMaxExpiry; SharePasswordMaxAttempts wrong passwords in a row lock a
+	// password-protected link for SharePasswordLockout
+	ShareLinkExpiry          time.Duration `validate:"gt=0,ltefield=ShareLinkMaxExpiry"`
+	ShareLinkMaxExpiry       time.Duration `validate:"lte=8760h"`
+	SharePasswordMaxAttempts int           `validate:"gt=0"`
+	SharePasswordLockout     time.Duration `validate:"gt=0"`
+
+	// Storage each user may hold across all file types, in bytes and in files; 0 is
+	// unlimited. File types can set their own per-user quotas on top.
+	UserQuotaBytes int64 `validate:"gte=0"`
+	UserQuotaFiles int64 `validate:"gte=0"`
+
+	// Each user may start UploadRateLimit uploads per minute, up to UploadRateBurst at
+	// once; 0 disables the limit. Buckets are kept in memory per replica, or in Redis
+	// (REDIS_HOST, REDIS_PORT) to be shared by all replicas.
+	UploadRateLimit  int    `validate:"gte=0"`
+	UploadRateBurst  int    `validate:"required_unless=UploadRateLimit 0,gte=0"`
+	RateLimitBackend string `validate:"oneof=memory redis"`
+
+	// Responses to requests sent with an Idempotency-Key are replayed for retries within
+	// IdempotencyKeyTTL. A key whose request has not finished after IdempotencyLockTimeout
+	// is assumed abandoned and may be used again.
+	IdempotencyKeyTTL      time.Duration `validate:"gt=0"`
+	IdempotencyLockTimeout time.Duration `validate:"gt=0,ltefield=IdempotencyKeyTTL"`
+
+	// On-the-fly image transforms: widths and heights clients may request
+	ImageAllowedSizes []int `validate:"required,min=1,dive,gt=0,lte=4096"`
+
+	// Uploaded images exceeding these dimensions are rejected before decoding
+	ImageMaxWidth  int   `validate:"gt=0"`
+	ImageMaxHeight int   `validate:"gt=0"`
+	ImageMaxPixels int64 `validate:"gt=0"`
+
+	// Renditions generated for uploads of file types with variants enabled; empty disables them
+	ImageVariants []images.Variant
+
+	// cwebp binary producing WebP transforms and variants, run at most ImageWebPWorkers
+	// times at once; empty when WebP output is disabled
+	ImageWebPEncoder string
+	ImageWebPWorkers int
+
+	// Antivirus scanning with clamd; an empty ClamdAddress disables it.
+	// Infected uploads are rejected or moved to QuarantineBucket.
+	ClamdAddress       string        `validate:"omitempty,startswith=tcp://|startswith=unix://"`
+	ClamdTimeout       time.Duration `validate:"gt=0"`
+	InfectedFileAction string        `validate:"oneof=reject quarantine"`
+	QuarantineBucket   string        `validate:"required_if=InfectedFileAction quarantine"`
 }
 
 func Load() *Config {
@@ -33,13 +145,119 @@ func Load() *Config {
 		allowedTypes[i] = strings.TrimSpace(allowedTypes[i])
 	}
 
+	var keepMetadata []string
+	for _, fileType := range strings.Split(common.GetEnv("KEEP_IMAGE_METADATA", ""), ",") {
+		if fileType = strings.TrimSpace(fileType); fileType != "" {
+			keepMetadata = append(keepMetadata, fileType)
+		}
+	}
+
+	// FILE_TYPES_FILE replaces the built-in file types derived from the variables above
+	s3Config := common.NewS3Config()
+	var fileTypes *filetypes.Registry
+	if path := common.GetEnv("FILE_TYPES_FILE", ""); path != "" {
+		if len(keepMetadata) > 0 {
+			log.Fatal("KEEP_IMAGE_METADATA only applies to the built-in file types; set keepMetadata in FILE_TYPES_FILE instead")
+		}
+		fileTypes, err = filetypes.Load(path)
+	} else {
+		var types []filetypes.FileType
+		if types, err = defaultFileTypes(s3Config, allowedTypes, maxFileSize, keepMetadata); err == nil {
+			fileTypes, err = filetypes.NewRegistry(types)
+		}
+	}
+	if err != nil {
+		log.Fatalf("Invalid file types: %v", err)
+	}
+
+	allowedSizesStr := common.GetEnv("IMAGE_ALLOWED_SIZES", "160,320,640,1024,1280,1920")
+	var allowedSizes []int
+	for _, sizeStr := range strings.Split(allowedSizesStr, ",") {
+		size, err := strconv.Atoi(strings.TrimSpace(sizeStr))
+		if err != nil {
+			log.Fatalf("Invalid IMAGE_ALLOWED_SIZES value: %s", allowedSizesStr)
+		}
+		allowedSizes = append(allowedSizes, size)
+	}
+
+	// WebP output shells out to cwebp, so it is only enabled when configured
+	webpEncoder := common.GetEnv("IMAGE_WEBP_ENCODER", "")
+	webpWorkers := common.GetEnvInt("IMAGE_WEBP_WORKERS", 2)
+	if err := images.SetWebPEncoder(webpEncoder, webpWorkers); err != nil {
+		log.Fatalf("Invalid IMAGE_WEBP_ENCODER value: %v", err)
+	}
+
+	variantsStr := common.GetEnv("IMAGE_VARIANTS", "thumbnail:320x320:cover,medium:1024x1024,large:1920x1920")
+	variants, err := images.ParseVariants(variantsStr)
+	if err != nil {
+		log.Fatalf("Invalid IMAGE_VARIANTS value: %v", err)
+	}
+
 	cfg := &Config{
-		DatabaseConfig:   common.NewDatabaseConfig(),
-		ServiceConfig:    common.NewServiceConfig(8085),
-		S3Config:         common.NewS3Config(),
-		JWTSecret:        common.GetEnvRequired("JWT_SECRET"),
-		MaxFileSize:      maxFileSize,
-		AllowedFileTypes: allowedTypes,
+		DatabaseConfig: common.NewDatabaseConfig(),
+		ServiceConfig:  common.NewServiceConfig(8085),
+		S3Config:       s3Config,
+		JWTSecret:      common.GetEnvRequired("JWT_SECRET"),
+		FileTypes:      fileTypes,
+
+		TusUploadExpiry:       common.GetEnvDuration("TUS_UPLOAD_EXPIRY", 24*time.Hour),
+		UploadCleanupInterval: common.GetEnvDuration("UPLOAD_CLEANUP_INTERVAL", time.Hour),
+		PendingUploadTimeout:  common.GetEnvDuration("PENDING_UPLOAD_TIMEOUT", time.Hour),
+
+		BatchUploadMaxFiles:    common.GetEnvInt("BATCH_UPLOAD_MAX_FILES", 50),
+		BatchUploadConcurrency: common.GetEnvInt("BATCH_UPLOAD_CONCURRENCY", 4),
+
+		TrashRetention:     common.GetEnvDuration("TRASH_RETENTION", 30*24*time.Hour),
+		TrashPurgeInterval: common.GetEnvDuration("TRASH_PURGE_INTERVAL", time.Hour),
+
+		DeletionWorkerInterval: common.GetEnvDuration("DELETION_WORKER_INTERVAL", time.Minute),
+		DeletionRetryBackoff:   common.GetEnvDuration("DELETION_RETRY_BACKOFF", time.Minute),
+		DeletionMaxBackoff:     common.GetEnvDuration("DELETION_MAX_BACKOFF", 6*time.Hour),
+		DeletionStuckAfter:     common.GetEnvDuration("DELETION_STUCK_AFTER", time.Hour),
+
+		ReconcileInterval:    common.GetEnvDuration("RECONCILE_INTERVAL", 24*time.Hour),
+		ReconcileMode:        common.GetEnv("RECONCILE_MODE", "report"),
+		ReconcileGracePeriod: common.GetEnvDuration("RECONCILE_GRACE_PERIOD", time.Hour),
+
+		ScrubInterval:      common.GetEnvDuration("SCRUB_INTERVAL", time.Hour),
+		ScrubBatchSize:     common.GetEnvInt("SCRUB_BATCH_SIZE", 100),
+		ScrubReverifyAfter: common.GetEnvDuration("SCRUB_REVERIFY_AFTER", 7*24*time.Hour),
+
+		PresignedUploadExpiry: common.GetEnvDuration("PRESIGNED_UPLOAD_EXPIRY", 15*time.Minute),
+		S3PublicEndpoint:      common.GetEnv("S3_PUBLIC_ENDPOINT", ""),
+		S3Region:              common.GetEnv("S3_REGION", "us-east-1"),
+
+		SignedURLSecret:    common.GetEnv("SIGNED_URL_SECRET", ""),
+		SignedURLExpiry:    common.GetEnvDuration("SIGNED_URL_EXPIRY", 15*time.Minute),
+		SignedURLMaxExpiry: common.GetEnvDuration("SIGNED_URL_MAX_EXPIRY", 24*time.Hour),
+
+		ShareLinkExpiry:          common.GetEnvDuration("SHARE_LINK_EXPIRY", 7*24*time.Hour),
+		ShareLinkMaxExpiry:       common.GetEnvDuration("SHARE_LINK_MAX_EXPIRY", 90*24*time.Hour),
+		SharePasswordMaxAttempts: common.GetEnvInt("SHARE_PASSWORD_MAX_ATTEMPTS", 5),
+		SharePasswordLockout:     common.GetEnvDuration("SHARE_PASSWORD_LOCKOUT", 15*time.Minute),
+
+		UserQuotaBytes: common.GetEnvInt64("USER_QUOTA_BYTES", 0),
+		UserQuotaFiles: common.GetEnvInt64("USER_QUOTA_FILES", 0),
+
+		UploadRateLimit:  common.GetEnvInt("UPLOAD_RATE_LIMIT", 30),
+		UploadRateBurst:  common.GetEnvInt("UPLOAD_RATE_BURST", 10),
+		RateLimitBackend: common.GetEnv("RATE_LIMIT_BACKEND", RateLimitBackendMemory),
+
+		IdempotencyKeyTTL:      common.GetEnvDuration("IDEMPOTENCY_KEY_TTL", 24*time.Hour),
+		IdempotencyLockTimeout: common.GetEnvDuration("IDEMPOTENCY_LOCK_TIMEOUT", 10*time.Minute),
+
+		ImageAllowedSizes: allowedSizes,
+		ImageMaxWidth:     common.GetEnvInt("IMAGE_MAX_WIDTH", 10000),
+		ImageMaxHeight:    common.GetEnvInt("IMAGE_MAX_HEIGHT", 10000),
+		ImageMaxPixels:    common.GetEnvInt64("IMAGE_MAX_PIXELS", 40_000_000),
+		ImageVariants:     variants,
+		ImageWebPEncoder:  webpEncoder,
+		ImageWebPWorkers:  webpWorkers,
+
+		ClamdAddress:       common.GetEnv("CLAMD_ADDRESS", ""),
+		ClamdTimeout:       common.GetEnvDuration("CLAMD_TIMEOUT", 30*time.Second),
+		InfectedFileAction: common.GetEnv("INFECTED_FILE_ACTION", InfectedFileReject),
+		QuarantineBucket:   common.GetEnv("S3_QUARANTINE_BUCKET", ""),
 	}
 
 	// Validate service-specific fields
@@ -47,6 +265,57 @@ func Load() *Config {
 	if err := validate.Struct(cfg); err != nil {
 		panic(fmt.Sprintf("Invalid configuration: %v", err))
 	}
+	// Quarantined files must never be reachable through the download routes
+	if cfg.QuarantineBucket != "" && slices.Contains(cfg.FileTypes.Buckets(), cfg.QuarantineBucket) {
+		panic("Invalid configuration: S3_QUARANTINE_BUCKET must differ from the file buckets")
+	}
 
 	return cfg
 }
+
+// defaultFileTypes builds the built-in file types from the S3 bucket variables,
+// MAX_FILE_SIZE, ALLOWED_FILE_TYPES and KEEP_IMAGE_METADATA. Entries of allowedTypes
+// match MIME types by prefix; file types left without any MIME type are omitted.
+// keepMetadata names the file types that keep image metadata.
+func defaultFileTypes(s3 common.S3Config, allowedTypes []string, maxFileSize int64, keepMetadata []string) ([]filetypes.FileType, error) {
+	imageTypes := []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+	documentTypes := []string{
+		"application/pdf",
+		"application/msword",
+		"application/vnd.openxmlformats-officedocument.wordprocessingml.document",
+	}
+
+	candidates := []filetypes.FileType{
+		{Name: "portfolio-image", Bucket: s3.ImagesBucket, MimeTypes: imageTypes, Variants: true},
+		{Name: "miniature-image", Bucket: s3.MiniaturesBucket, MimeTypes: imageTypes},
+		{Name: "document", Bucket: s3.DocumentsBucket, MimeTypes: documentTypes, Versioned: true},
+	}
+
+	for _, name := range keepMetadata {
+		if !slices.ContainsFunc(candidates, func(fileType filetypes.FileType) bool { return fileType.Name == name }) {
+			return nil, fmt.Errorf("KEEP_IMAGE_METADATA entry %q is not a file type", name)
+		}
+	}
+
+	var types []filetypes.FileType
+	for _, fileType := range candidates {
+		var mimeTypes, extensions []string
+		for _, mimeType := range fileType.MimeTypes {
+			if slices.ContainsFunc(allowedTypes, func(allowed string) bool {
+				return allowed != "" && strings.HasPrefix(mimeType, allowed)
+			}) {
+				mimeTypes = append(mimeTypes, mimeType)
+				extensions = append(extensions, filetypes.Extensions(mimeType)...)
+			}
+		}
+		if len(mimeTypes) == 0 {
+			continue
+		}
+		fileType.MimeTypes = mimeTypes
+		fileType.Extensions = extensions
+		fileType.MaxSize = maxFileSize
+		fileType.KeepMetadata = slices.Contains(keepMetadata, fileType.Name)
+		types = append(types, fileType)
+	}
+	return types, nil
+}
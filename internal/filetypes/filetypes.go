@@ -0,0 +1,292 @@
+// Package filetypes defines the kinds of files the service accepts. Each file type
+// names the bucket it is stored in and the content, extensions and sizes allowed
+// for its uploads, so handlers never switch on file type names.
+package filetypes
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// DefaultCacheControl caches downloads for 1 year; stored files have unique UUID keys
+const DefaultCacheControl = "public, max-age=31536000, immutable"
+
+// RevalidateCacheControl is sent for the URLs of versioned files, whose content can change:
+// caches keep the file but check its ETag before every use
+const RevalidateCacheControl = "public, no-cache"
+
+// PrivateCacheControl is sent for private files, which must not be kept by any cache
+const PrivateCacheControl = "private, no-store"
+
+// ErrExtensionMismatch reports a filename extension that does not match the file content
+var ErrExtensionMismatch = errors.New("file extension does not match file content")
+
+// extensionsByMimeType lists the filename extensions known for each supported MIME type.
+// The first entry is the canonical extension used when the filename has none.
+var extensionsByMimeType = map[string][]string{
+	"image/jpeg":         {".jpg", ".jpeg"},
+	"image/png":          {".png"},
+	"image/gif":          {".gif"},
+	"image/webp":         {".webp"},
+	"application/pdf":    {".pdf"},
+	"application/msword": {".doc"},
+	"application/vnd.openxmlformats-officedocument.wordprocessingml.document": {".docx"},
+}
+
+// namePattern keeps file type names usable as URL path segments. Names start with a
+// letter, as all-digit segments after /files/ are file IDs.
+var namePattern = regexp.MustCompile(`^[a-z][a-z0-9]*(-[a-z0-9]+)*$`)
+
+// Extensions returns the known filename extensions for mimeType, canonical first
+func Extensions(mimeType string) []string {
+	return extensionsByMimeType[mimeType]
+}
+
+// FileType describes one kind of file: where it is stored and what may be uploaded as it
+type FileType struct {
+	Name   string `yaml:"name"`
+	Bucket string `yaml:"bucket"`
+	// MIME types accepted for uploads, matched against the detected content
+	MimeTypes []string `yaml:"mimeTypes"`
+	// Filename extensions accepted for uploads; each must belong to one of MimeTypes
+	Extensions []string `yaml:"extensions"`
+	// Largest accepted upload in bytes
+	MaxSize int64 `yaml:"maxSize"`
+	// Cache-Control sent with downloads; defaults to DefaultCacheControl. For versioned
+	// types it only applies to URLs pinned to the current version.
+	CacheControl string `yaml:"cacheControl"`
+	// Whether uploads get the configured image variants
+	Variants bool `yaml:"variants"`
+	// Whether uploaded images keep their EXIF/XMP metadata instead of being sanitized
+	KeepMetadata bool `yaml:"keepMetadata"`
+	// Whether new versions of a file's content can be uploaded under its URL
+	Versioned bool `yaml:"versioned"`
+	// Storage each user may hold of the file type, in bytes and in files; 0 is unlimited
+	QuotaBytes int64 `yaml:"quotaBytes"`
+	QuotaFiles int64 `yaml:"quotaFiles"`
+}
+
+// AllowsMimeType reports whether uploads of the file type may have mimeType
+func (t *FileType) AllowsMimeType(mimeType string) bool {
+	return slices.Contains(t.MimeTypes, mimeType)
+}
+
+// CheckMimeType returns an error naming the file type if it does not accept mimeType
+func (t *FileType) CheckMimeType(mimeType string) error {
+	if !t.AllowsMimeType(mimeType) {
+		return fmt.Errorf("%s does not accept %s content", t.Name, mimeType)
+	}
+	return nil
+}
+
+// IsImage reports whether the file type only stores images
+func (t *FileType) IsImage() bool {
+	for _, mimeType := range t.MimeTypes {
+		if !strings.HasPrefix(mimeType, "image/") {
+			return false
+		}
+	}
+	return len(t.MimeTypes) > 0
+}
+
+// ResolveExtension validates the filename extension against the detected MIME type and
+// the extensions the file type accepts. Files without an extension get the first
+// accepted extension for the detected type.
+func (t *FileType) ResolveExtension(fileName, mimeType string) (string, error) {
+	var allowed []string
+	for _, ext := range t.Extensions {
+		if slices.Contains(extensionsByMimeType[mimeType], ext) {
+			allowed = append(allowed, ext)
+		}
+	}
+	if len(allowed) == 0 {
+		return "", ErrExtensionMismatch
+	}
+
+	ext := strings.ToLower(filepath.Ext(fileName))
+	if ext == "" {
+		return allowed[0], nil
+	}
+	if slices.Contains(allowed, ext) {
+		return ext, nil
+	}
+	return "", ErrExtensionMismatch
+}
+
+// validate reports the first inconsistency in the definition
+func (t *FileType) validate() error {
+	if !namePattern.MatchString(t.Name) {
+		return fmt.Errorf("invalid name %q: use lowercase letters, digits and dashes, starting with a letter", t.Name)
+	}
+	if t.Bucket == "" {
+		return errors.New("bucket is required")
+	}
+	if t.MaxSize <= 0 {
+		return errors.New("maxSize must be positive")
+	}
+	if t.QuotaBytes < 0 || t.QuotaFiles < 0 {
+		return errors.New("quotaBytes and quotaFiles cannot be negative")
+	}
+	if len(t.MimeTypes) == 0 {
+		return errors.New("at least one MIME type is required")
+	}
+
+	for i, mimeType := range t.MimeTypes {
+		if _, ok := extensionsByMimeType[mimeType]; !ok {
+			return fmt.Errorf("unsupported MIME type %q", mimeType)
+		}
+		if slices.Contains(t.MimeTypes[:i], mimeType) {
+			return fmt.Errorf("duplicate MIME type %q", mimeType)
+		}
+	}
+	for i, ext := range t.Extensions {
+		if !t.matchesMimeType(ext) {
+			return fmt.Errorf("extension %q does not belong to any of its MIME types", ext)
+		}
+		if slices.Contains(t.Extensions[:i], ext) {
+			return fmt.Errorf("duplicate extension %q", ext)
+		}
+	}
+	// Every accepted MIME type needs an extension, or its uploads could never be stored
+	for _, mimeType := range t.MimeTypes {
+		if !slices.ContainsFunc(t.Extensions, func(ext string) bool {
+			return slices.Contains(extensionsByMimeType[mimeType], ext)
+		}) {
+			return fmt.Errorf("MIME type %q has no accepted extension", mimeType)
+		}
+	}
+
+	if t.Variants && !t.IsImage() {
+		return errors.New("variants require a file type that only accepts images")
+	}
+	if t.KeepMetadata && !t.IsImage() {
+		return errors.New("keepMetadata requires a file type that only accepts images")
+	}
+	// Variants are rendered on upload and would keep showing the replaced content
+	if t.Variants && t.Versioned {
+		return errors.New("variants cannot be combined with versioned")
+	}
+	return nil
+}
+
+func (t *FileType) matchesMimeType(ext string) bool {
+	for _, mimeType := range t.MimeTypes {
+		if slices.Contains(extensionsByMimeType[mimeType], ext) {
+			return true
+		}
+	}
+	return false
+}
+
+// Registry holds the configured file types in definition order.
+// A nil Registry has no file types.
+type Registry struct {
+	types  []FileType
+	byName map[string]*FileType
+}
+
+// NewRegistry validates the definitions and builds a registry from them.
+// Empty Cache-Control values are set to DefaultCacheControl.
+func NewRegistry(types []FileType) (*Registry, error) {
+	if len(types) == 0 {
+		return nil, errors.New("no file types defined")
+	}
+
+	r := &Registry{
+		types:  slices.Clone(types),
+		byName: make(map[string]*FileType, len(types)),
+	}
+	for i := range r.types {
+		t := &r.types[i]
+		t.MimeTypes = slices.Clone(t.MimeTypes)
+		t.Extensions = slices.Clone(t.Extensions)
+		if t.CacheControl == "" {
+			t.CacheControl = DefaultCacheControl
+		}
+		if err := t.validate(); err != nil {
+			return nil, fmt.Errorf("file type %q: %w", t.Name, err)
+		}
+		if _, ok := r.byName[t.Name]; ok {
+			return nil, fmt.Errorf("file type %q is defined more than once", t.Name)
+		}
+		r.byName[t.Name] = t
+	}
+	return r, nil
+}
+
+// Get returns the file type with the given name
+func (r *Registry) Get(name string) (*FileType, bool) {
+	if r == nil {
+		return nil, false
+	}
+	t, ok := r.byName[name]
+	return t, ok
+}
+
+// Types returns a copy of the definitions in definition order
+func (r *Registry) Types() []FileType {
+	if r == nil {
+		return nil
+	}
+	types := slices.Clone(r.types)
+	for i := range types {
+		types[i].MimeTypes = slices.Clone(types[i].MimeTypes)
+		types[i].Extensions = slices.Clone(types[i].Extensions)
+	}
+	return types
+}
+
+// Names returns the file type names in definition order
+func (r *Registry) Names() []string {
+	if r == nil {
+		return nil
+	}
+	names := make([]string, len(r.types))
+	for i, t := range r.types {
+		names[i] = t.Name
+	}
+	return names
+}
+
+// Buckets returns the distinct buckets used by the file types
+func (r *Registry) Buckets() []string {
+	if r == nil {
+		return nil
+	}
+	var buckets []string
+	for _, t := range r.types {
+		if !slices.Contains(buckets, t.Bucket) {
+			buckets = append(buckets, t.Bucket)
+		}
+	}
+	return buckets
+}
+
+// MaxSize returns the largest upload any file type accepts
+func (r *Registry) MaxSize() int64 {
+	if r == nil {
+		return 0
+	}
+	var size int64
+	for _, t := range r.types {
+		size = max(size, t.MaxSize)
+	}
+	return size
+}
+
+// AllowsMimeType reports whether any file type accepts mimeType
+func (r *Registry) AllowsMimeType(mimeType string) bool {
+	if r == nil {
+		return false
+	}
+	for i := range r.types {
+		if r.types[i].AllowsMimeType(mimeType) {
+			return true
+		}
+	}
+	return false
+}
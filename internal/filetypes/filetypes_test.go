@@ -0,0 +1,232 @@
+package filetypes
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func imageType() FileType {
+	return FileType{
+		Name:       "portfolio-image",
+		Bucket:     "images",
+		MimeTypes:  []string{"image/jpeg", "image/png"},
+		Extensions: []string{".jpg", ".jpeg", ".png"},
+		MaxSize:    1024,
+		Variants:   true,
+	}
+}
+
+func documentType() FileType {
+	return FileType{
+		Name:       "document",
+		Bucket:     "documents",
+		MimeTypes:  []string{"application/pdf"},
+		Extensions: []string{".pdf"},
+		MaxSize:    4096,
+	}
+}
+
+// =============================================================================
+// Registry Tests
+// =============================================================================
+
+func TestNewRegistry(t *testing.T) {
+	registry, err := NewRegistry([]FileType{imageType(), documentType()})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if names := registry.Names(); !slices.Equal(names, []string{"portfolio-image", "document"}) {
+		t.Errorf("expected names in definition order, got %v", names)
+	}
+	if buckets := registry.Buckets(); !slices.Equal(buckets, []string{"images", "documents"}) {
+		t.Errorf("unexpected buckets %v", buckets)
+	}
+	if registry.MaxSize() != 4096 {
+		t.Errorf("expected largest max size 4096, got %d", registry.MaxSize())
+	}
+	if !registry.AllowsMimeType("application/pdf") || registry.AllowsMimeType("image/gif") {
+		t.Error("expected MIME types to be accepted only when a file type lists them")
+	}
+
+	document, ok := registry.Get("document")
+	if !ok || document.Bucket != "documents" || document.IsImage() {
+		t.Errorf("unexpected document type %+v", document)
+	}
+	if document.CacheControl != DefaultCacheControl {
+		t.Errorf("expected default Cache-Control, got %q", document.CacheControl)
+	}
+	if _, ok := registry.Get("unknown"); ok {
+		t.Error("expected unknown file type not to be found")
+	}
+
+	// A nil registry behaves as an empty one
+	var empty *Registry
+	if _, ok := empty.Get("document"); ok || empty.MaxSize() != 0 || empty.AllowsMimeType("application/pdf") {
+		t.Error("expected nil registry to have no file types")
+	}
+}
+
+func TestNewRegistry_InvalidDefinitions(t *testing.T) {
+	testCases := []struct {
+		name    string
+		change  func(*FileType)
+		wantErr string
+	}{
+		{"missing name", func(ft *FileType) { ft.Name = "" }, "invalid name"},
+		{"unsafe name", func(ft *FileType) { ft.Name = "../images" }, "invalid name"},
+		{"numeric name", func(ft *FileType) { ft.Name = "123" }, "invalid name"},
+		{"name starting with a digit", func(ft *FileType) { ft.Name = "2-images" }, "invalid name"},
+		{"missing bucket", func(ft *FileType) { ft.Bucket = "" }, "bucket is required"},
+		{"zero max size", func(ft *FileType) { ft.MaxSize = 0 }, "maxSize must be positive"},
+		{"negative quota", func(ft *FileType) { ft.QuotaFiles = -1 }, "cannot be negative"},
+		{"no MIME types", func(ft *FileType) { ft.MimeTypes = nil }, "at least one MIME type"},
+		{"unsupported MIME type", func(ft *FileType) { ft.MimeTypes = append(ft.MimeTypes, "text/html") }, "unsupported MIME type"},
+		{"duplicate MIME type", func(ft *FileType) { ft.MimeTypes = append(ft.MimeTypes, "image/png") }, "duplicate MIME type"},
+		{"foreign extension", func(ft *FileType) { ft.Extensions = append(ft.Extensions, ".pdf") }, "does not belong"},
+		{"extension without dot", func(ft *FileType) { ft.Extensions = []string{"jpg", ".png"} }, "does not belong"},
+		{"duplicate extension", func(ft *FileType) { ft.Extensions = append(ft.Extensions, ".png") }, "duplicate extension"},
+		{"MIME type without extension", func(ft *FileType) { ft.Extensions = []string{".png"} }, `"image/jpeg" has no accepted extension`},
+		{"variants on documents", func(ft *FileType) {
+			ft.MimeTypes = append(ft.MimeTypes, "application/pdf")
+			ft.Extensions = append(ft.Extensions, ".pdf")
+		}, "variants require"},
+		{"versioned with variants", func(ft *FileType) { ft.Versioned = true }, "cannot be combined with versioned"},
+		{"keepMetadata on documents", func(ft *FileType) {
+			ft.Variants = false
+			ft.KeepMetadata = true
+			ft.MimeTypes = append(ft.MimeTypes, "application/pdf")
+			ft.Extensions = append(ft.Extensions, ".pdf")
+		}, "keepMetadata requires"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			fileType := imageType()
+			tc.change(&fileType)
+			_, err := NewRegistry([]FileType{fileType, documentType()})
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tc.wantErr, err)
+			}
+		})
+	}
+
+	if _, err := NewRegistry(nil); err == nil {
+		t.Error("expected error for empty definitions")
+	}
+	if _, err := NewRegistry([]FileType{documentType(), documentType()}); err == nil || !strings.Contains(err.Error(), "more than once") {
+		t.Errorf("expected duplicate name error, got %v", err)
+	}
+}
+
+// =============================================================================
+// File Type Tests
+// =============================================================================
+
+func TestFileType_ResolveExtension(t *testing.T) {
+	mixed := FileType{
+		MimeTypes:  []string{"image/jpeg", "application/pdf"},
+		Extensions: []string{".jpg", ".jpeg", ".pdf"},
+	}
+	jpegOnly := FileType{MimeTypes: []string{"image/jpeg"}, Extensions: []string{".jpeg"}}
+
+	testCases := []struct {
+		name        string
+		fileType    FileType
+		fileName    string
+		contentType string
+		wantExt     string
+		wantErr     bool
+	}{
+		{"jpg", mixed, "photo.jpg", "image/jpeg", ".jpg", false},
+		{"uppercase", mixed, "photo.JPEG", "image/jpeg", ".jpeg", false},
+		{"no extension", mixed, "photo", "image/jpeg", ".jpg", false},
+		{"pdf", mixed, "cv.pdf", "application/pdf", ".pdf", false},
+		{"wrong extension", mixed, "photo.png", "image/jpeg", "", true},
+		{"executable", mixed, "script.exe", "application/pdf", "", true},
+		{"unknown content", mixed, "notes.txt", "text/plain", "", true},
+		{"type restricts extensions", jpegOnly, "photo.jpg", "image/jpeg", "", true},
+		{"type default extension", jpegOnly, "photo", "image/jpeg", ".jpeg", false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			ext, err := tc.fileType.ResolveExtension(tc.fileName, tc.contentType)
+			if tc.wantErr {
+				if !errors.Is(err, ErrExtensionMismatch) {
+					t.Errorf("expected ErrExtensionMismatch, got extension %q, error %v", ext, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ext != tc.wantExt {
+				t.Errorf("expected extension %q, got %q", tc.wantExt, ext)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Load Tests
+// =============================================================================
+
+func TestLoad(t *testing.T) {
+	t.Setenv("TEST_DOCUMENTS_BUCKET", "cv-files")
+	path := filepath.Join(t.TempDir(), "filetypes.yaml")
+	definition := `
+fileTypes:
+  - name: document
+    bucket: ${TEST_DOCUMENTS_BUCKET}
+    mimeTypes: [application/pdf]
+    extensions: [.pdf]
+    maxSize: 2048
+    cacheControl: private, max-age=60
+    quotaBytes: 1048576
+    quotaFiles: 20
+  - name: miniature-image
+    bucket: miniatures
+    mimeTypes: [image/jpeg]
+    extensions: [.jpg]
+    maxSize: 2048
+    keepMetadata: true
+`
+	if err := os.WriteFile(path, []byte(definition), 0o600); err != nil {
+		t.Fatalf("failed to write definition: %v", err)
+	}
+
+	registry, err := Load(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	document, ok := registry.Get("document")
+	if !ok {
+		t.Fatal("expected document type to be defined")
+	}
+	if document.Bucket != "cv-files" || document.MaxSize != 2048 || document.CacheControl != "private, max-age=60" ||
+		document.QuotaBytes != 1048576 || document.QuotaFiles != 20 {
+		t.Errorf("unexpected definition %+v", document)
+	}
+	if miniature, ok := registry.Get("miniature-image"); !ok || !miniature.KeepMetadata || document.KeepMetadata {
+		t.Error("expected keepMetadata to be read per file type")
+	}
+
+	if _, err := Load(filepath.Join(t.TempDir(), "missing.yaml")); err == nil {
+		t.Error("expected error for missing file")
+	}
+	if _, err := Parse([]byte("fileTypes:\n  - name: document\n    buckets: docs\n")); err == nil {
+		t.Error("expected error for unknown field")
+	}
+	if _, err := Parse([]byte("fileTypes:\n  - name: document\n    bucket: docs\n")); err == nil {
+		t.Error("expected error for incomplete definition")
+	}
+}
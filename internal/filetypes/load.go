@@ -0,0 +1,36 @@
+package filetypes
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// document is the layout of a file types definition file
+type document struct {
+	FileTypes []FileType `yaml:"fileTypes"`
+}
+
+// Load reads file type definitions from a YAML file. ${VAR} references are
+// expanded from the environment so buckets can be set per deployment.
+func Load(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file types: %w", err)
+	}
+	return Parse(data)
+}
+
+// Parse builds a registry from YAML file type definitions, rejecting unknown fields
+func Parse(data []byte) (*Registry, error) {
+	decoder := yaml.NewDecoder(bytes.NewReader([]byte(os.ExpandEnv(string(data)))))
+	decoder.KnownFields(true)
+
+	var doc document
+	if err := decoder.Decode(&doc); err != nil {
+		return nil, fmt.Errorf("invalid file types definition: %w", err)
+	}
+	return NewRegistry(doc.FileTypes)
+}
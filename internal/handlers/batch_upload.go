@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// maxBatchFieldLength bounds the fileType and visibility fields of a batch upload
+const maxBatchFieldLength = 256
+
+// BatchUploadResult is the outcome of one file of a batch upload. Status is the status
+// POST /files would have answered the file with; File is set for stored files and Error
+// (with Quota for files over quota) for the others.
+type BatchUploadResult struct {
+	Index    int                    `json:"index"`
+	FileName string                 `json:"fileName"`
+	Status   int                    `json:"status"`
+	File     map[string]interface{} `json:"file,omitempty"`
+	Error    string                 `json:"error,omitempty"`
+	Quota    *quotaStatus           `json:"quota,omitempty"`
+}
+
+// BatchUploadResponse lists the outcome of every file of a batch upload in form order.
+// Error is set when the form broke off before its end.
+type BatchUploadResponse struct {
+	Results   []*BatchUploadResult `json:"results"`
+	Succeeded int                  `json:"succeeded"`
+	Failed    int                  `json:"failed"`
+	Error     string               `json:"error,omitempty"`
+}
+
+// batchQuota checks the files of one batch against their uploader's quotas. Usage is
+// read once per file type and the files of the batch accepted so far are added to it,
+// so files stored in parallel cannot each fit in what is left and together exceed it.
+// Uploads of other requests are caught when the file records are created.
+type batchQuota struct {
+	mu       sync.Mutex
+	usage    map[string]repository.StorageUsage
+	accepted map[string]repository.StorageUsage
+}
+
+func newBatchQuota() *batchQuota {
+	return &batchQuota{
+		usage:    make(map[string]repository.StorageUsage),
+		accepted: make(map[string]repository.StorageUsage),
+	}
+}
+
+// UploadFiles godoc
+// @Summary Upload several files
+// @Description Upload several files in one multipart form. Every file part is named "file". A "fileType" or "visibility" field applies to the file parts after it, so one field before all files sets a shared default and a field before a file sets its own. Every file takes a token from the upload rate limit and files over it are refused with 429. The form is read as a stream: each file is checked and stored like POST /files while the next one is received, up to BATCH_UPLOAD_CONCURRENCY at a time; only the files being stored are held, in temporary files. A part may carry its own Content-MD5 or X-Checksum-SHA256 header. Files are stored or refused one by one: the response lists every file in form order with the status POST /files would have answered it with, and is 200 when all files were stored and 207 otherwise. Forms with more than BATCH_UPLOAD_MAX_FILES files have the extra files refused.
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param file formData file true "Files to upload (repeated)"
+// @Param fileType formData string true "Configured file type of the files after it (defaults: portfolio-image, miniature-image, document)"
+// @Param visibility formData string false "public (default) or private, for the files after it" Enums(public, private)
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} BatchUploadResponse
+// @Success 207 {object} BatchUploadResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/batch [post]
+func (h *Handler) UploadFiles(c *gin.Context) {
+	reader, err := c.Request.MultipartReader()
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "multipart form required")
+		return
+	}
+
+	var (
+		response   BatchUploadResponse
+		fileType   string
+		visibility string
+		wg         sync.WaitGroup
+		// A slot is taken before a file is received and given back once it is stored
+		slots = make(chan struct{}, h.cfg.BatchUploadConcurrency)
+		quota = newBatchQuota()
+	)
+	for {
+		part, err := reader.NextPart()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			logger.GetLogger(c).Warn("Batch upload form broke off", "error", err, "files", len(response.Results))
+			response.Error = "invalid multipart form"
+			break
+		}
+
+		switch {
+		case part.FormName() == "file" && part.FileName() != "":
+			result := &BatchUploadResult{Index: len(response.Results), FileName: part.FileName()}
+			response.Results = append(response.Results, result)
+			if len(response.Results) > h.cfg.BatchUploadMaxFiles {
+				h.setBatchResult(c, result, nil, newUploadError(http.StatusBadRequest,
+					fmt.Sprintf("too many files (max %d per request)", h.cfg.BatchUploadMaxFiles)))
+				break
+			}
+			if uploadErr := h.takeUploadToken(c); uploadErr != nil {
+				h.setBatchResult(c, result, nil, uploadErr)
+				break
+			}
+
+			slots <- struct{}{}
+			upload, tmp, uploadErr := h.receiveBatchFile(c, part, fileType, visibility)
+			if uploadErr != nil {
+				<-slots
+				h.setBatchResult(c, result, nil, uploadErr)
+				break
+			}
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				defer func() { <-slots }()
+				defer removeTempFile(c, tmp)
+				h.storeBatchFile(c, quota, result, upload)
+			}()
+		case part.FormName() == "fileType":
+			fileType, err = readBatchField(part)
+		case part.FormName() == "visibility":
+			visibility, err = readBatchField(part)
+		}
+		_ = part.Close()
+		if err != nil {
+			response.Error = err.Error()
+			break
+		}
+	}
+	wg.Wait()
+
+	if len(response.Results) == 0 {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+	for _, result := range response.Results {
+		if result.File != nil {
+			response.Succeeded++
+		} else {
+			response.Failed++
+		}
+	}
+	status := http.StatusOK
+	if response.Failed > 0 || response.Error != "" {
+		status = http.StatusMultiStatus
+	}
+	c.JSON(status, response)
+}
+
+// receiveBatchFile checks a file part against the file type and visibility in effect
+// and copies it to a temporary file, so the rest of the form can be read while it is
+// stored. The caller removes the file.
+func (h *Handler) receiveBatchFile(c *gin.Context, part *multipart.Part, fileType, visibility string) (*multipartUpload, *os.File, *uploadError) {
+	if fileType == "" {
+		return nil, nil, newUploadError(http.StatusBadRequest, fmt.Sprintf("fileType is required (%s)", h.fileTypeNames()))
+	}
+	ft, err := h.fileType(fileType)
+	if err != nil {
+		return nil, nil, newUploadError(http.StatusBadRequest, err.Error())
+	}
+	parsedVisibility, err := parseVisibility(visibility)
+	if err != nil {
+		return nil, nil, newUploadError(http.StatusBadRequest, err.Error())
+	}
+
+	// Checksums the client computed over the file, sent as headers of its part
+	checksums, err := parseUploadChecksums(http.Header(part.Header))
+	if err != nil {
+		return nil, nil, newUploadError(http.StatusBadRequest, err.Error())
+	}
+	declaredType := part.Header.Get("Content-Type")
+	if !h.isAllowedContentType(declaredType) {
+		return nil, nil, newUploadError(http.StatusBadRequest, "invalid file type")
+	}
+
+	tmp, err := os.CreateTemp("", "files-api-batch-*")
+	if err != nil {
+		return nil, nil, loggedUploadError(http.StatusInternalServerError, err, "failed to receive file")
+	}
+	size, err := io.Copy(tmp, io.LimitReader(part, ft.MaxSize+1))
+	if err == nil {
+		_, err = tmp.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		removeTempFile(c, tmp)
+		return nil, nil, loggedUploadError(http.StatusBadRequest, err, "failed to read file")
+	}
+	if size > ft.MaxSize {
+		removeTempFile(c, tmp)
+		return nil, nil, newUploadError(http.StatusBadRequest, fmt.Sprintf("file too large (max %d bytes)", ft.MaxSize))
+	}
+
+	return &multipartUpload{
+		fileName:     part.FileName(),
+		declaredType: declaredType,
+		size:         size,
+		content:      tmp,
+		checksums:    checksums,
+		fileType:     ft,
+		visibility:   parsedVisibility,
+	}, tmp, nil
+}
+
+// storeBatchFile stores one received file of a batch and records its outcome. A panic
+// fails the file rather than the process, as it runs outside the request goroutine.
+func (h *Handler) storeBatchFile(c *gin.Context, quota *batchQuota, result *BatchUploadResult, upload *multipartUpload) {
+	stored := false
+	defer func() {
+		if r := recover(); r != nil {
+			h.setBatchResult(c, result, nil, loggedUploadError(http.StatusInternalServerError,
+				fmt.Errorf("panic: %v", r), "failed to upload file"))
+		}
+	}()
+
+	if uploadErr := h.reserveBatchQuota(c, quota, upload); uploadErr != nil {
+		h.setBatchResult(c, result, nil, uploadErr)
+		return
+	}
+	defer func() {
+		if !stored {
+			quota.release(upload)
+		}
+	}()
+	response, uploadErr := h.storeMultipartUpload(c, *upload)
+	stored = uploadErr == nil
+	h.setBatchResult(c, result, response, uploadErr)
+}
+
+// reserveBatchQuota accepts a file of a batch that fits in its uploader's quotas next
+// to the files of the batch accepted before it, refusing it like POST /files otherwise
+func (h *Handler) reserveBatchQuota(c *gin.Context, quota *batchQuota, upload *multipartUpload) *uploadError {
+	ft := upload.fileType
+	quota.mu.Lock()
+	defer quota.mu.Unlock()
+
+	if userID := audit.GetUserID(c); userID != nil && h.hasQuota(ft) {
+		usage, ok := quota.usage[ft.Name]
+		if !ok {
+			var err error
+			usage, err = h.repo.GetStorageUsage(c.Request.Context(), *userID, ft.Name, time.Now())
+			if err != nil {
+				return loggedUploadError(http.StatusInternalServerError, err, "failed to check storage quota")
+			}
+			quota.usage[ft.Name] = usage
+		}
+		for fileType, accepted := range quota.accepted {
+			usage.Files += accepted.Files
+			usage.Bytes += accepted.Bytes
+			if fileType == ft.Name {
+				usage.FileTypeFiles += accepted.Files
+				usage.FileTypeBytes += accepted.Bytes
+			}
+		}
+		if uploadErr := h.exceededQuota(ft, usage, upload.size, 1); uploadErr != nil {
+			return uploadErr
+		}
+	}
+
+	accepted := quota.accepted[ft.Name]
+	accepted.Files++
+	accepted.Bytes += upload.size
+	quota.accepted[ft.Name] = accepted
+	return nil
+}
+
+// release gives back what an accepted file that was not stored took from the quotas
+func (q *batchQuota) release(upload *multipartUpload) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	accepted := q.accepted[upload.fileType.Name]
+	accepted.Files--
+	accepted.Bytes -= upload.size
+	q.accepted[upload.fileType.Name] = accepted
+}
+
+// setBatchResult records the stored file or the refusal of one file of a batch, logging
+// failures that are not the client's
+func (h *Handler) setBatchResult(c *gin.Context, result *BatchUploadResult, file gin.H, uploadErr *uploadError) {
+	if uploadErr == nil {
+		result.Status = http.StatusOK
+		result.File = file
+		return
+	}
+	if uploadErr.err != nil {
+		logger.GetLogger(c).Error(uploadErr.message,
+			"error", uploadErr.err,
+			"status", uploadErr.status,
+			"filename", result.FileName,
+		)
+	}
+	result.Status = uploadErr.status
+	result.Error = uploadErr.message
+	result.Quota = uploadErr.quota
+}
+
+// readBatchField reads a form field of a batch upload
+func readBatchField(part *multipart.Part) (string, error) {
+	value, err := io.ReadAll(io.LimitReader(part, maxBatchFieldLength+1))
+	if err != nil {
+		return "", errors.New("invalid multipart form")
+	}
+	if len(value) > maxBatchFieldLength {
+		return "", fmt.Errorf("%s field too long", part.FormName())
+	}
+	return string(value), nil
+}
+
+// removeTempFile closes and deletes a temporary file, logging failures
+func removeTempFile(c *gin.Context, f *os.File) {
+	_ = f.Close()
+	if err := os.Remove(f.Name()); err != nil {
+		logger.GetLogger(c).Warn("Failed to remove temporary file", "error", err, "path", f.Name())
+	}
+}
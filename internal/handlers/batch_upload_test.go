@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/ratelimit"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// batchPart is a form part of a batch upload: a file when fileName is set, otherwise a
+// field
+type batchPart struct {
+	name        string
+	fileName    string
+	contentType string
+	content     []byte
+}
+
+func batchFile(fileName, contentType string, content []byte) batchPart {
+	return batchPart{name: "file", fileName: fileName, contentType: contentType, content: content}
+}
+
+func batchField(name, value string) batchPart {
+	return batchPart{name: name, content: []byte(value)}
+}
+
+// performBatchUpload posts the parts as one multipart form to /api/v1/files/batch
+func performBatchUpload(t *testing.T, router *gin.Engine, parts ...batchPart) (*BatchUploadResponse, int) {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for _, p := range parts {
+		var part io.Writer
+		var err error
+		if p.fileName == "" {
+			part, err = writer.CreateFormField(p.name)
+		} else {
+			part, err = writer.CreatePart(textproto.MIMEHeader{
+				"Content-Disposition": {`form-data; name="` + p.name + `"; filename="` + p.fileName + `"`},
+				"Content-Type":        {p.contentType},
+			})
+		}
+		if err != nil {
+			t.Fatalf("failed to create form part: %v", err)
+		}
+		if _, err := part.Write(p.content); err != nil {
+			t.Fatalf("failed to write form part: %v", err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close form: %v", err)
+	}
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/batch", body,
+		map[string]string{"Content-Type": writer.FormDataContentType()})
+	if w.Code != http.StatusOK && w.Code != http.StatusMultiStatus {
+		return nil, w.Code
+	}
+	var resp BatchUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	return &resp, w.Code
+}
+
+// batchRepository records created files; safe for concurrent uploads
+type batchRepository struct {
+	mu    sync.Mutex
+	files []*repository.StorageFile
+}
+
+func (r *batchRepository) mock() *mockRepository {
+	return &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.files = append(r.files, file)
+			file.ID = int64(len(r.files))
+			return nil
+		},
+	}
+}
+
+func setupBatchRouter(repo *mockRepository, store *mockStorage, cfg *config.Config, actionLogRepo *mockActionLogRepo) *gin.Engine {
+	handler := New(repo, store, cfg, actionLogRepo)
+	router := setupTestRouter()
+	router.POST("/api/v1/files/batch", asUser(testUserID, common.LevelEdit), handler.UploadFiles)
+	return router
+}
+
+// =============================================================================
+// Batch Upload Tests
+// =============================================================================
+
+func TestUploadFiles_StoresEachFile(t *testing.T) {
+	files := &batchRepository{}
+	var audited atomic.Int32
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(_ *commonRepo.ActionLog) error {
+			audited.Add(1)
+			return nil
+		},
+	}
+	router := setupBatchRouter(files.mock(), &mockStorage{}, createTestConfig(), actionLogRepo)
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("a.png", "image/png", testPNGData()),
+		batchFile("b.png", "image/png", testPNGData()),
+		batchField("fileType", "document"),
+		batchField("visibility", "private"),
+		batchFile("c.pdf", "application/pdf", testPDFData),
+	)
+
+	if code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if resp.Succeeded != 3 || resp.Failed != 0 || len(resp.Results) != 3 {
+		t.Fatalf("expected 3 stored files, got %+v", resp)
+	}
+	for i, name := range []string{"a.png", "b.png", "c.pdf"} {
+		result := resp.Results[i]
+		if result.Index != i || result.FileName != name || result.Status != http.StatusOK || result.File == nil {
+			t.Errorf("result %d: expected stored %s, got %+v", i, name, result)
+		}
+	}
+	if len(files.files) != 3 || audited.Load() != 3 {
+		t.Errorf("expected a record and audit entry per file, got %d records and %d entries", len(files.files), audited.Load())
+	}
+	types := map[string]string{}
+	for _, f := range files.files {
+		types[f.FileName] = f.FileType + "/" + f.Visibility
+	}
+	if types["a.png"] != "miniature-image/public" || types["c.pdf"] != "document/private" {
+		t.Errorf("expected fields to apply to the files after them, got %v", types)
+	}
+}
+
+func TestUploadFiles_PartialSuccess(t *testing.T) {
+	files := &batchRepository{}
+	router := setupBatchRouter(files.mock(), &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+
+	resp, code := performBatchUpload(t, router,
+		batchFile("early.png", "image/png", testPNGData()),
+		batchField("fileType", "miniature-image"),
+		batchFile("ok.png", "image/png", testPNGData()),
+		batchFile("fake.png", "image/png", []byte("not an image")),
+		batchFile("notes.txt", "text/plain", []byte("hello")),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	if resp.Succeeded != 1 || resp.Failed != 3 {
+		t.Errorf("expected 1 stored and 3 failed files, got %d and %d", resp.Succeeded, resp.Failed)
+	}
+	expected := []int{http.StatusBadRequest, http.StatusOK, http.StatusBadRequest, http.StatusBadRequest}
+	for i, status := range expected {
+		if resp.Results[i].Status != status {
+			t.Errorf("result %d: expected status %d, got %+v", i, status, resp.Results[i])
+		}
+		if (status == http.StatusOK) == (resp.Results[i].Error != "") {
+			t.Errorf("result %d: expected error only for failed files, got %+v", i, resp.Results[i])
+		}
+	}
+	if len(files.files) != 1 {
+		t.Errorf("expected one file record, got %d", len(files.files))
+	}
+}
+
+func TestUploadFiles_TooManyFiles(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.BatchUploadMaxFiles = 2
+	files := &batchRepository{}
+	router := setupBatchRouter(files.mock(), &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("a.png", "image/png", testPNGData()),
+		batchFile("b.png", "image/png", testPNGData()),
+		batchFile("c.png", "image/png", testPNGData()),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	if last := resp.Results[2]; last.Status != http.StatusBadRequest || last.Error != "too many files (max 2 per request)" {
+		t.Errorf("expected extra file refused, got %+v", last)
+	}
+	if len(files.files) != 2 {
+		t.Errorf("expected 2 file records, got %d", len(files.files))
+	}
+}
+
+func TestUploadFiles_BoundedConcurrency(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.BatchUploadConcurrency = 2
+	var inFlight, maxInFlight atomic.Int32
+	store := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for {
+				current := maxInFlight.Load()
+				if n <= current || maxInFlight.CompareAndSwap(current, n) {
+					break
+				}
+			}
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	}
+	files := &batchRepository{}
+	router := setupBatchRouter(files.mock(), store, cfg, &mockActionLogRepo{})
+
+	parts := []batchPart{batchField("fileType", "miniature-image")}
+	for i := 0; i < 6; i++ {
+		parts = append(parts, batchFile("photo.png", "image/png", testPNGData()))
+	}
+	resp, code := performBatchUpload(t, router, parts...)
+
+	if code != http.StatusOK || resp.Succeeded != 6 {
+		t.Fatalf("expected all 6 files stored, got status %d: %+v", code, resp)
+	}
+	if maxInFlight.Load() > 2 {
+		t.Errorf("expected at most 2 concurrent uploads, got %d", maxInFlight.Load())
+	}
+}
+
+func TestUploadFiles_QuotaPerFile(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UserQuotaFiles = 2
+	cfg.BatchUploadConcurrency = 1
+	files := &batchRepository{}
+	repo := files.mock()
+	repo.getStorageUsageFunc = func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+		files.mu.Lock()
+		defer files.mu.Unlock()
+		return repository.StorageUsage{Files: int64(len(files.files))}, nil
+	}
+	router := setupBatchRouter(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("a.png", "image/png", testPNGData()),
+		batchFile("b.png", "image/png", testPNGData()),
+		batchFile("c.png", "image/png", testPNGData()),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	last := resp.Results[2]
+	if last.Status != http.StatusRequestEntityTooLarge || last.Quota == nil || last.Quota.RemainingFiles == nil || *last.Quota.RemainingFiles != 0 {
+		t.Errorf("expected third file over quota with none remaining, got %+v", last)
+	}
+	if resp.Succeeded != 2 {
+		t.Errorf("expected 2 stored files, got %d", resp.Succeeded)
+	}
+}
+
+func TestUploadFiles_InvalidRequest(t *testing.T) {
+	router := setupBatchRouter(&mockRepository{}, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+
+	if _, code := performBatchUpload(t, router, batchField("fileType", "miniature-image")); code != http.StatusBadRequest {
+		t.Errorf("expected status %d without files, got %d", http.StatusBadRequest, code)
+	}
+	w := performRequest(router, http.MethodPost, "/api/v1/files/batch", bytes.NewReader([]byte(`{}`)),
+		map[string]string{"Content-Type": "application/json"})
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d for a non-multipart body, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUploadFiles_QuotaAcrossParallelFiles(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UserQuotaFiles = 2
+	cfg.BatchUploadConcurrency = 3
+	files := &batchRepository{}
+	repo := files.mock()
+	var usageReads atomic.Int32
+	// Every read sees no records yet, as if the files were all still being stored
+	repo.getStorageUsageFunc = func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+		usageReads.Add(1)
+		return repository.StorageUsage{}, nil
+	}
+	store := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+			time.Sleep(20 * time.Millisecond)
+			return nil
+		},
+	}
+	router := setupBatchRouter(repo, store, cfg, &mockActionLogRepo{})
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("a.png", "image/png", testPNGData()),
+		batchFile("b.png", "image/png", testPNGData()),
+		batchFile("c.png", "image/png", testPNGData()),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	if resp.Succeeded != 2 || len(files.files) != 2 {
+		t.Errorf("expected 2 stored files, got %d results stored and %d records", resp.Succeeded, len(files.files))
+	}
+	overQuota := 0
+	for _, result := range resp.Results {
+		if result.Status == http.StatusRequestEntityTooLarge {
+			overQuota++
+		}
+	}
+	if overQuota != 1 {
+		t.Errorf("expected one file over quota, got %d: %+v", overQuota, resp.Results)
+	}
+	if usageReads.Load() != 1 {
+		t.Errorf("expected usage read once per file type, got %d reads", usageReads.Load())
+	}
+}
+
+func TestUploadFiles_FailedFileReleasesQuota(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UserQuotaFiles = 1
+	cfg.BatchUploadConcurrency = 1
+	files := &batchRepository{}
+	repo := files.mock()
+	repo.getStorageUsageFunc = func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+		return repository.StorageUsage{}, nil
+	}
+	router := setupBatchRouter(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("fake.png", "image/png", []byte("not an image")),
+		batchFile("ok.png", "image/png", testPNGData()),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	if resp.Results[0].Status != http.StatusBadRequest || resp.Results[1].Status != http.StatusOK {
+		t.Errorf("expected the file after a refused one to take its place, got %+v and %+v", resp.Results[0], resp.Results[1])
+	}
+}
+
+func TestUploadFiles_RateLimitPerFile(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UploadRateBurst = 2
+	handler := New((&batchRepository{}).mock(), &mockStorage{}, cfg, &mockActionLogRepo{},
+		WithUploadLimiter(ratelimit.NewMemory(ratelimit.PerMinute(1, 2))))
+	router := setupTestRouter()
+	router.POST("/api/v1/files/batch", asUser(testUserID, common.LevelEdit), handler.UploadFiles)
+
+	resp, code := performBatchUpload(t, router,
+		batchField("fileType", "miniature-image"),
+		batchFile("a.png", "image/png", testPNGData()),
+		batchFile("b.png", "image/png", testPNGData()),
+		batchFile("c.png", "image/png", testPNGData()),
+	)
+
+	if code != http.StatusMultiStatus {
+		t.Fatalf("expected status %d, got %d", http.StatusMultiStatus, code)
+	}
+	if resp.Succeeded != 2 {
+		t.Errorf("expected 2 files within the burst stored, got %d", resp.Succeeded)
+	}
+	if last := resp.Results[2]; last.Status != http.StatusTooManyRequests || last.Error != "upload rate limit exceeded, retry in 60 seconds" {
+		t.Errorf("expected third file over the rate limit, got %+v", last)
+	}
+}
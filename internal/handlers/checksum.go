@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/md5" //nolint:gosec // Content-MD5 is an integrity check, not a security boundary
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// Upload checksum headers; both describe the uploaded file, not the multipart body
+const (
+	headerContentMD5     = "Content-MD5"
+	headerChecksumSHA256 = "X-Checksum-SHA256"
+)
+
+var errChecksumMismatch = errors.New("file content does not match the supplied checksum")
+
+// uploadChecksums holds the digests a client sent with an upload
+type uploadChecksums struct {
+	md5    []byte
+	sha256 []byte
+}
+
+// parseUploadChecksums reads Content-MD5 (base64, RFC 1864) and X-Checksum-SHA256 (hex or
+// base64). It returns nil when neither header is set.
+func parseUploadChecksums(header http.Header) (*uploadChecksums, error) {
+	var checksums uploadChecksums
+	if value := header.Get(headerContentMD5); value != "" {
+		digest, err := base64.StdEncoding.DecodeString(strings.TrimSpace(value))
+		if err != nil || len(digest) != md5.Size {
+			return nil, fmt.Errorf("invalid %s header", headerContentMD5)
+		}
+		checksums.md5 = digest
+	}
+	if value := header.Get(headerChecksumSHA256); value != "" {
+		digest, err := decodeSHA256(strings.TrimSpace(value))
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s header", headerChecksumSHA256)
+		}
+		checksums.sha256 = digest
+	}
+
+	if checksums.md5 == nil && checksums.sha256 == nil {
+		return nil, nil
+	}
+	return &checksums, nil
+}
+
+func decodeSHA256(value string) ([]byte, error) {
+	var digest []byte
+	var err error
+	if len(value) == hex.EncodedLen(sha256.Size) {
+		digest, err = hex.DecodeString(value)
+	} else {
+		digest, err = base64.StdEncoding.DecodeString(value)
+	}
+	if err != nil || len(digest) != sha256.Size {
+		return nil, errors.New("invalid SHA-256 digest")
+	}
+	return digest, nil
+}
+
+// verify streams the content through the requested hashes in one pass and rewinds it,
+// returning errChecksumMismatch if any digest differs
+func (u *uploadChecksums) verify(src io.ReadSeeker) error {
+	md5Hash := md5.New() //nolint:gosec // required to verify Content-MD5
+	sha256Hash := sha256.New()
+	var writers []io.Writer
+	if u.md5 != nil {
+		writers = append(writers, md5Hash)
+	}
+	if u.sha256 != nil {
+		writers = append(writers, sha256Hash)
+	}
+
+	if _, err := io.Copy(io.MultiWriter(writers...), src); err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	if !digestMatches(md5Hash, u.md5) || !digestMatches(sha256Hash, u.sha256) {
+		return errChecksumMismatch
+	}
+	return nil
+}
+
+func digestMatches(h hash.Hash, expected []byte) bool {
+	return expected == nil || bytes.Equal(h.Sum(nil), expected)
+}
+
+// apply records the verified checksums on the file as hex
+func (u *uploadChecksums) apply(file *repository.StorageFile) {
+	if u.md5 != nil {
+		value := hex.EncodeToString(u.md5)
+		file.UploadMD5 = &value
+	}
+	if u.sha256 != nil {
+		value := hex.EncodeToString(u.sha256)
+		file.UploadSHA256 = &value
+	}
+}
+
+// respondChecksumError answers a failed checksum verification
+func respondChecksumError(c *gin.Context, err error) {
+	respondUploadError(c, checksumError(err))
+}
+
+func checksumError(err error) *uploadError {
+	if errors.Is(err, errChecksumMismatch) {
+		return newUploadError(http.StatusBadRequest, err.Error())
+	}
+	return loggedUploadError(http.StatusInternalServerError, err, "failed to verify checksum")
+}
@@ -0,0 +1,136 @@
+package handlers
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec // Content-MD5 test vectors
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Upload Checksum Tests
+// =============================================================================
+
+func TestUploadFile_VerifiesChecksums(t *testing.T) {
+	md5Sum := md5.Sum(testPDFData) //nolint:gosec // Content-MD5 test vectors
+	sha256Sum := sha256.Sum256(testPDFData)
+	md5Hex, sha256Hex := hex.EncodeToString(md5Sum[:]), hex.EncodeToString(sha256Sum[:])
+
+	testCases := []struct {
+		name       string
+		headers    map[string]string
+		wantMD5    string
+		wantSHA256 string
+	}{
+		{"content md5", map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(md5Sum[:])}, md5Hex, ""},
+		{"hex sha256", map[string]string{"X-Checksum-SHA256": sha256Hex}, "", sha256Hex},
+		{"base64 sha256", map[string]string{"X-Checksum-SHA256": base64.StdEncoding.EncodeToString(sha256Sum[:])}, "", sha256Hex},
+		{"both", map[string]string{
+			"Content-MD5":       base64.StdEncoding.EncodeToString(md5Sum[:]),
+			"X-Checksum-SHA256": sha256Hex,
+		}, md5Hex, sha256Hex},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var created *repository.StorageFile
+			mockRepo := &mockRepository{
+				createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+					file.ID = 1
+					created = file
+					return nil
+				},
+			}
+			handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.POST("/api/v1/files", handler.UploadFile)
+
+			req, w, err := createMultipartRequest("cv.pdf", "application/pdf", "document", testPDFData)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			if got := stringValue(created.UploadMD5); got != tc.wantMD5 {
+				t.Errorf("expected stored MD5 %q, got %q", tc.wantMD5, got)
+			}
+			if got := stringValue(created.UploadSHA256); got != tc.wantSHA256 {
+				t.Errorf("expected stored SHA-256 %q, got %q", tc.wantSHA256, got)
+			}
+
+			var response map[string]interface{}
+			if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if tc.wantSHA256 != "" && response["uploadSha256"] != tc.wantSHA256 {
+				t.Errorf("expected uploadSha256 in response, got %v", response)
+			}
+		})
+	}
+}
+
+func TestUploadFile_ChecksumRejected(t *testing.T) {
+	otherMD5 := md5.Sum([]byte("other content")) //nolint:gosec // Content-MD5 test vectors
+	otherSHA256 := sha256.Sum256([]byte("other content"))
+	pdfMD5 := md5.Sum(testPDFData) //nolint:gosec // Content-MD5 test vectors
+
+	testCases := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"md5 mismatch", map[string]string{"Content-MD5": base64.StdEncoding.EncodeToString(otherMD5[:])}},
+		{"sha256 mismatch", map[string]string{"X-Checksum-SHA256": hex.EncodeToString(otherSHA256[:])}},
+		{"one of two mismatches", map[string]string{
+			"Content-MD5":       base64.StdEncoding.EncodeToString(pdfMD5[:]),
+			"X-Checksum-SHA256": hex.EncodeToString(otherSHA256[:]),
+		}},
+		{"hex md5", map[string]string{"Content-MD5": hex.EncodeToString(pdfMD5[:])}},
+		{"truncated sha256", map[string]string{"X-Checksum-SHA256": "abc123"}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				createFileFunc: func(_ context.Context, _ *repository.StorageFile) error {
+					t.Error("expected no file record")
+					return nil
+				},
+			}
+			handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.POST("/api/v1/files", handler.UploadFile)
+
+			req, w, err := createMultipartRequest("cv.pdf", "application/pdf", "document", testPDFData)
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			for name, value := range tc.headers {
+				req.Header.Set(name, value)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,58 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+var errContentTypeMismatch = errors.New("file content does not match declared content type")
+
+// mimeTypeAliases maps non-canonical MIME types sent by some clients to their canonical form
+var mimeTypeAliases = map[string]string{
+	"image/jpg":   "image/jpeg",
+	"image/pjpeg": "image/jpeg",
+}
+
+// normalizeMimeType strips parameters, lowercases and resolves aliases
+func normalizeMimeType(contentType string) string {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		mediaType = strings.ToLower(strings.TrimSpace(contentType))
+	}
+	if canonical, ok := mimeTypeAliases[mediaType]; ok {
+		return canonical
+	}
+	return mediaType
+}
+
+// detectContentType sniffs the MIME type from the leading bytes of the stream
+// and rewinds it so the full content can be uploaded afterwards
+func detectContentType(src io.ReadSeeker) (string, error) {
+	mtype, err := mimetype.DetectReader(src)
+	if err != nil {
+		return "", fmt.Errorf("failed to read file header: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return normalizeMimeType(mtype.String()), nil
+}
+
+// hashContent streams the content through SHA-256 and rewinds it, returning the hex digest
+func hashContent(src io.ReadSeeker) (string, error) {
+	hash := sha256.New()
+	if _, err := io.Copy(hash, src); err != nil {
+		return "", fmt.Errorf("failed to hash file: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return "", fmt.Errorf("failed to rewind file: %w", err)
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
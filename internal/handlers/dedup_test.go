@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// =============================================================================
+// Deduplication Tests
+// =============================================================================
+
+func TestUploadFile_StoresContentHash(t *testing.T) {
+	var created *repository.StorageFile
+	var storedKey string
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			file.ID = 1
+			created = file
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+			storedKey = key
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("cv.pdf", "application/pdf", "document", testPDFData)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	want := sha256Hex(testPDFData)
+	if created == nil || created.ContentHash == nil || *created.ContentHash != want {
+		t.Fatalf("expected record with content hash %s, got %+v", want, created)
+	}
+	if storedKey != created.S3Key {
+		t.Errorf("expected new object %s to be stored, got %q", created.S3Key, storedKey)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["sha256"] != want {
+		t.Errorf("expected sha256 %s in response, got %v", want, response["sha256"])
+	}
+}
+
+func TestUploadFile_SharesIdenticalContent(t *testing.T) {
+	var activated bool
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			// The repository found an object with the same content in the bucket
+			file.ID = 2
+			file.ContentKey = stringPtr("existing.pdf")
+			file.SharesObject = true
+			return nil
+		},
+		activateFileFunc: func(_ context.Context, id int64) error {
+			activated = id == 2
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+			t.Errorf("expected shared content not to be uploaded, got %s", key)
+			return nil
+		},
+	}
+	var logged *commonRepo.ActionLog
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), actionLogRepo)
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("cv-copy.pdf", "application/pdf", "document", testPDFData)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !activated {
+		t.Error("expected the new record to be activated")
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["id"] != float64(2) || response["fileName"] != "cv-copy.pdf" {
+		t.Errorf("expected new file record, got %v", response)
+	}
+	// The new file keeps its own URL and only shares the object
+	if url, _ := response["url"].(string); url == "" || url == "/api/v1/files/document/existing.pdf" {
+		t.Errorf("expected the new file's own URL, got %v", response["url"])
+	}
+
+	if logged == nil {
+		t.Fatal("expected upload to be audit logged")
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(logged.Metadata, &metadata); err != nil {
+		t.Fatalf("failed to unmarshal audit metadata: %v", err)
+	}
+	if metadata["shared"] != true || metadata["sha256"] != sha256Hex(testPDFData) {
+		t.Errorf("expected shared upload in audit metadata, got %v", metadata)
+	}
+}
+
+func TestUploadFile_SharedObject_ActivationError(t *testing.T) {
+	var deletions []repository.PendingDeletion
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			file.ID = 3
+			file.ContentKey = stringPtr("existing.pdf")
+			file.SharesObject = true
+			return nil
+		},
+		activateFileFunc: func(_ context.Context, _ int64) error {
+			return errors.New("database error")
+		},
+		discardPendingFileFunc: func(_ context.Context, _ int64, d []repository.PendingDeletion) error {
+			deletions = d
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("cv-copy.pdf", "application/pdf", "document", testPDFData)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	// The repository keeps the object while the file it was shared with still refers to it
+	if len(deletions) != 1 || deletions[0].S3Key != "existing.pdf" {
+		t.Errorf("expected the shared object to be handed to the discard, got %+v", deletions)
+	}
+}
+
+func TestUploadFile_SharedVariantsNotStored(t *testing.T) {
+	var variantHashes []string
+	mockRepo := &mockRepository{
+		createFileWithVariantsFunc: func(_ context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+			file.ID = 10
+			for i := range variants {
+				variants[i].File.ID = int64(11 + i)
+				if variants[i].File.ContentHash != nil {
+					variantHashes = append(variantHashes, *variants[i].File.ContentHash)
+				}
+				if variants[i].Name == "thumbnail" {
+					variants[i].File.ContentKey = stringPtr("existing_thumbnail.png")
+					variants[i].File.SharesObject = true
+				}
+			}
+			return nil
+		},
+	}
+	store := &recordingStore{}
+
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if len(variantHashes) != 2 || variantHashes[0] == variantHashes[1] {
+		t.Errorf("expected a content hash for each variant, got %v", variantHashes)
+	}
+	if len(store.stored) != 2 {
+		t.Errorf("expected original and medium variant stored only, got %v", store.stored)
+	}
+
+	var response struct {
+		Variants []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"variants"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	for _, variant := range response.Variants {
+		if variant.Name == "thumbnail" && (variant.URL == "" || variant.URL == "/api/v1/files/portfolio-image/existing_thumbnail.png") {
+			t.Errorf("expected the shared thumbnail under its own URL, got %q", variant.URL)
+		}
+	}
+
+}
+
+func TestCompletePresignedUpload_StoresContentHash(t *testing.T) {
+	session := createTestPresignedSession(int64(len(testPDFData)))
+	session.S3Bucket = testDocsBucket
+	session.FileName = "cv.pdf"
+	session.FileType = "document"
+	session.MimeType = "application/pdf"
+
+	var completed *repository.StorageFile
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+			file.ID = 7
+			completed = file
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, presignedStore(testPDFData), createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	want := sha256Hex(testPDFData)
+	if completed == nil || completed.ContentHash == nil || *completed.ContentHash != want {
+		t.Fatalf("expected record with content hash %s, got %+v", want, completed)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["sha256"] != want {
+		t.Errorf("expected sha256 %s in response, got %v", want, response["sha256"])
+	}
+}
+
+func TestPatchTusUpload_StoresContentHashOfSanitizedImage(t *testing.T) {
+	original := testJPEGWithComment()
+	session := createTestUploadSession(int64(len(original)), 0)
+	session.MimeType = "image/jpeg"
+
+	var stored []byte
+	var completed *repository.StorageFile
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+			file.ID = 7
+			completed = file
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(original)), nil
+		},
+		putObjectFunc: func(_ context.Context, _, _ string, reader io.Reader, _ int64, _ string) error {
+			stored, _ = io.ReadAll(reader)
+			return nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(original), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if stored == nil {
+		t.Fatal("expected sanitized image to be rewritten")
+	}
+	// The hash describes the content kept in storage, not the upload
+	want := sha256Hex(stored)
+	if completed == nil || completed.ContentHash == nil || *completed.ContentHash != want {
+		t.Errorf("expected record with content hash %s, got %+v", want, completed)
+	}
+}
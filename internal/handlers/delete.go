@@ -1,24 +1,37 @@
 package handlers
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
 	"github.com/GunarsK-portfolio/portfolio-common/audit"
 	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
 	"github.com/gin-gonic/gin"
 )
 
+// trashedFileMessage answers requests for files that were deleted and await purging
+const trashedFileMessage = "file has been deleted"
+
 // DeleteFile godoc
-// @Summary Delete file from S3 and database
-// @Description Delete file by ID from both S3 storage and database
+// @Summary Move file to the trash
+// @Description Move a file and its generated image variants to the trash. Trashed files are no longer served
+// @Description and are permanently deleted from S3 and the database after the retention period unless restored.
+// @Description Edit permission only allows deleting the user's own uploads; delete permission allows any file.
 // @Tags files
 // @Produce json
 // @Param id path int true "File ID"
-// @Success 200 {object} map[string]string
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /files/{id} [delete]
@@ -31,41 +44,91 @@ func (h *Handler) DeleteFile(c *gin.Context) {
 		return
 	}
 
-	// Get file from database to get S3 details
 	file, err := h.repo.GetFileByID(c.Request.Context(), id)
 	if err != nil {
 		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
 		return
 	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	if file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, "file is already in the trash")
+		return
+	}
+
+	// Objects stay in storage until the purge job removes them, so the file can be restored
+	deletedAt := time.Now()
+	if err := h.repo.TrashFile(c.Request.Context(), id, deletedAt); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to move file to trash")
+		return
+	}
+	purgeAfter := deletedAt.Add(h.cfg.TrashRetention)
+
+	// Log file deletion
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, audit.ActionFileDelete, &resourceType, &id, &source, map[string]interface{}{
+		"filename":    file.FileName,
+		"file_type":   file.FileType,
+		"size":        file.FileSize,
+		"mime_type":   file.MimeType,
+		"purge_after": purgeAfter,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "file moved to trash", "purgeAfter": purgeAfter})
+}
 
-	// Map fileType to bucket
-	bucket, err := h.fileTypeToBucket(file.FileType)
+// RestoreFile godoc
+// @Summary Restore file from the trash
+// @Description Take a trashed file and its generated image variants out of the trash before they are purged
+// @Tags files
+// @Produce json
+// @Param id path int true "File ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} repository.StorageFile
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/restore [post]
+func (h *Handler) RestoreFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
 	if err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "invalid file type in database")
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
 		return
 	}
 
-	// Delete from S3
-	if err := h.storage.DeleteObject(c.Request.Context(), bucket, file.S3Key); err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to delete file from storage")
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusConflict, "file is not in the trash")
 		return
 	}
 
-	// Delete from database
-	if err := h.repo.DeleteFile(c.Request.Context(), id); err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to delete file record")
+	if err := h.repo.RestoreFile(c.Request.Context(), id); err != nil {
+		if errors.Is(err, repository.ErrFileNotTrashed) {
+			commonHandlers.RespondError(c, http.StatusConflict, "file is not in the trash")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to restore file")
 		return
 	}
 
-	// Log file deletion
 	resourceType := audit.ResourceTypeFile
 	source := "files-api"
-	_ = audit.LogFromContext(c, h.actionLogRepo, audit.ActionFileDelete, &resourceType, &id, &source, map[string]interface{}{
-		"filename":  file.FileName,
-		"file_type": file.FileType,
-		"size":      file.FileSize,
-		"mime_type": file.MimeType,
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileRestore, &resourceType, &id, &source, map[string]interface{}{
+		"filename":   file.FileName,
+		"file_type":  file.FileType,
+		"deleted_at": file.DeletedAt,
 	})
 
-	c.JSON(http.StatusOK, gin.H{"message": "file deleted successfully"})
+	file.DeletedAt = nil
+	h.respondWithFile(c, file)
 }
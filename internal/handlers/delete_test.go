@@ -2,12 +2,16 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
@@ -17,56 +21,87 @@ import (
 // =============================================================================
 
 func TestDeleteFile_Success(t *testing.T) {
-	testFile := createTestFile()
-	var deletedBucket, deletedKey string
-	var repoDeleteCalled bool
+	var trashedID int64
+	var trashedAt time.Time
+	var logged *commonRepo.ActionLog
 
 	mockRepo := &mockRepository{
 		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
-			return testFile, nil
+			return createTestFile(), nil
+		},
+		trashFileFunc: func(_ context.Context, id int64, at time.Time) error {
+			trashedID = id
+			trashedAt = at
+			return nil
 		},
-		deleteFileFunc: func(_ context.Context, _ int64) error {
-			repoDeleteCalled = true
+		purgeFileFunc: func(_ context.Context, _ int64, _ []repository.PendingDeletion) error {
+			t.Error("record should be kept until the trash is purged")
 			return nil
 		},
 	}
-
+	// Objects are kept so the file can be restored
 	mockStore := &mockStorage{
-		deleteObjectFunc: func(_ context.Context, bucket, key string) error {
-			deletedBucket = bucket
-			deletedKey = key
+		deleteObjectFunc: func(_ context.Context, _, _ string) error {
+			t.Error("object should be kept until the trash is purged")
+			return nil
+		},
+	}
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
 			return nil
 		},
 	}
 
 	cfg := createTestConfig()
-	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+	handler := New(mockRepo, mockStore, cfg, actionLogRepo)
 
 	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
 
 	if w.Code != http.StatusOK {
-		t.Errorf("expected status %d, got %d", http.StatusOK, w.Code)
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if trashedID != 1 || time.Since(trashedAt) > time.Minute {
+		t.Errorf("expected file 1 trashed now, got file %d at %v", trashedID, trashedAt)
 	}
 
-	// Verify storage was called with correct parameters
-	if deletedBucket != testImagesBucket {
-		t.Errorf("expected bucket %s, got %s", testImagesBucket, deletedBucket)
+	var response struct {
+		Message    string    `json:"message"`
+		PurgeAfter time.Time `json:"purgeAfter"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Message != "file moved to trash" || !response.PurgeAfter.Equal(trashedAt.Add(cfg.TrashRetention)) {
+		t.Errorf("unexpected response %s", w.Body.String())
 	}
-	if deletedKey != testFileKey {
-		t.Errorf("expected key %s, got %s", testFileKey, deletedKey)
+	if logged == nil || logged.ActionType != audit.ActionFileDelete {
+		t.Errorf("expected deletion to be audit logged, got %+v", logged)
 	}
+}
 
-	// Verify repository delete was called
-	if !repoDeleteCalled {
-		t.Error("expected repository DeleteFile to be called")
+func TestDeleteFile_AlreadyTrashed(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTrashedTestFile(), nil
+		},
+		trashFileFunc: func(_ context.Context, _ int64, _ time.Time) error {
+			t.Error("expected trashed file not to be trashed again")
+			return nil
+		},
 	}
+	handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
 
-	// Verify response message
-	if !strings.Contains(w.Body.String(), "file deleted successfully") {
-		t.Errorf("expected success message, got %s", w.Body.String())
+	router := setupTestRouter()
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
+
+	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
+
+	if w.Code != http.StatusGone {
+		t.Errorf("expected status %d, got %d", http.StatusGone, w.Code)
 	}
 }
 
@@ -76,7 +111,7 @@ func TestDeleteFile_InvalidID(t *testing.T) {
 	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/invalid", nil)
 
@@ -99,7 +134,7 @@ func TestDeleteFile_NotFound(t *testing.T) {
 	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/999", nil)
 
@@ -118,72 +153,30 @@ func TestDeleteFile_RepositoryError(t *testing.T) {
 	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
-
-	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
-
-	if w.Code != http.StatusInternalServerError {
-		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
-	}
-}
-
-func TestDeleteFile_S3DeleteError(t *testing.T) {
-	testFile := createTestFile()
-
-	mockRepo := &mockRepository{
-		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
-			return testFile, nil
-		},
-	}
-
-	mockStore := &mockStorage{
-		deleteObjectFunc: func(_ context.Context, _, _ string) error {
-			return errors.New("S3 delete failed")
-		},
-	}
-
-	cfg := createTestConfig()
-	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
-
-	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
 
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
-
-	if !strings.Contains(w.Body.String(), "failed to delete file from storage") {
-		t.Errorf("expected 'failed to delete file from storage' error, got %s", w.Body.String())
-	}
 }
 
-func TestDeleteFile_DBDeleteError(t *testing.T) {
-	testFile := createTestFile()
-	var s3DeleteCalled bool
-
+func TestDeleteFile_TrashError(t *testing.T) {
 	mockRepo := &mockRepository{
 		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
-			return testFile, nil
+			return createTestFile(), nil
 		},
-		deleteFileFunc: func(_ context.Context, _ int64) error {
-			return errors.New("database delete failed")
-		},
-	}
-
-	mockStore := &mockStorage{
-		deleteObjectFunc: func(_ context.Context, _, _ string) error {
-			s3DeleteCalled = true
-			return nil
+		trashFileFunc: func(_ context.Context, _ int64, _ time.Time) error {
+			return errors.New("database update failed")
 		},
 	}
 
 	cfg := createTestConfig()
-	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
 
@@ -191,13 +184,8 @@ func TestDeleteFile_DBDeleteError(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 
-	// Verify S3 was called first
-	if !s3DeleteCalled {
-		t.Error("expected S3 delete to be called")
-	}
-
-	if !strings.Contains(w.Body.String(), "failed to delete file record") {
-		t.Errorf("expected 'failed to delete file record' error, got %s", w.Body.String())
+	if !strings.Contains(w.Body.String(), "failed to move file to trash") {
+		t.Errorf("expected 'failed to move file to trash' error, got %s", w.Body.String())
 	}
 }
 
@@ -210,19 +198,10 @@ func TestDeleteFile_ContextPropagation(t *testing.T) {
 			capturedCtx = ctx
 			return testFile, nil
 		},
-		deleteFileFunc: func(_ context.Context, _ int64) error {
-			return nil
-		},
-	}
-
-	mockStore := &mockStorage{
-		deleteObjectFunc: func(_ context.Context, _, _ string) error {
-			return nil
-		},
 	}
 
 	cfg := createTestConfig()
-	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	gin.SetMode(gin.TestMode)
 	router := gin.New()
@@ -234,7 +213,7 @@ func TestDeleteFile_ContextPropagation(t *testing.T) {
 		c.Next()
 	})
 
-	router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+	router.DELETE("/api/v1/files/:id", asAdmin(), handler.DeleteFile)
 
 	w := performRequest(router, http.MethodDelete, "/api/v1/files/1", nil)
 
@@ -251,3 +230,121 @@ func TestDeleteFile_ContextPropagation(t *testing.T) {
 		t.Error("context sentinel value was not propagated to repository")
 	}
 }
+
+// =============================================================================
+// Restore File Tests
+// =============================================================================
+
+func TestRestoreFile_Success(t *testing.T) {
+	var restoredID int64
+	var logged *commonRepo.ActionLog
+
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTrashedTestFile(), nil
+		},
+		restoreFileFunc: func(_ context.Context, id int64) error {
+			restoredID = id
+			return nil
+		},
+	}
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+	handler := New(mockRepo, nil, createTestConfig(), actionLogRepo)
+
+	router := setupTestRouter()
+	router.POST("/api/v1/files/:id/restore", handler.RestoreFile)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/1/restore", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if restoredID != 1 {
+		t.Errorf("expected file 1 restored, got %d", restoredID)
+	}
+	if strings.Contains(w.Body.String(), "deletedAt") {
+		t.Errorf("expected restored file without deletedAt, got %s", w.Body.String())
+	}
+	if logged == nil || logged.ActionType != actionFileRestore {
+		t.Errorf("expected restore to be audit logged, got %+v", logged)
+	}
+}
+
+func TestRestoreFile_NotTrashed(t *testing.T) {
+	testCases := []struct {
+		name       string
+		file       *repository.StorageFile
+		restoreErr error
+	}{
+		{"live file", createTestFile(), nil},
+		{"restored concurrently", createTrashedTestFile(), repository.ErrFileNotTrashed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return tc.file, nil
+				},
+				restoreFileFunc: func(_ context.Context, _ int64) error {
+					return tc.restoreErr
+				},
+			}
+			handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
+
+			router := setupTestRouter()
+			router.POST("/api/v1/files/:id/restore", handler.RestoreFile)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/1/restore", nil)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+		})
+	}
+}
+
+func TestRestoreFile_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		getErr     error
+		restoreErr error
+		wantStatus int
+	}{
+		{"invalid ID", "/api/v1/files/abc/restore", nil, nil, http.StatusBadRequest},
+		{"not found", "/api/v1/files/999/restore", gorm.ErrRecordNotFound, nil, http.StatusNotFound},
+		{"restore error", "/api/v1/files/1/restore", nil, errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					if tc.getErr != nil {
+						return nil, tc.getErr
+					}
+					return createTrashedTestFile(), nil
+				},
+				restoreFileFunc: func(_ context.Context, _ int64) error {
+					return tc.restoreErr
+				},
+			}
+			handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
+
+			router := setupTestRouter()
+			router.POST("/api/v1/files/:id/restore", handler.RestoreFile)
+
+			w := performRequest(router, http.MethodPost, tc.path, nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
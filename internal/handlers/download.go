@@ -3,25 +3,61 @@ package handlers
 import (
 	"fmt"
 	"io"
+	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
+	"strconv"
 
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
 	"github.com/GunarsK-portfolio/portfolio-common/audit"
 	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
 	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
 )
 
+// downloadExposedHeaders lets browser scripts read range and caching headers cross-origin
+const downloadExposedHeaders = "Accept-Ranges,Content-Range,Content-Length,Content-Disposition,ETag,Last-Modified"
+
 // DownloadFile godoc
 // @Summary Download file from S3
-// @Description Stream file from MinIO/S3 storage
+// @Description Stream file from MinIO/S3 storage. Supports byte ranges (single and multipart/byteranges),
+// @Description conditional requests via If-None-Match / If-Modified-Since and If-Range. HEAD returns headers only.
+// @Description Images can be resized or converted with w, h, fit and fmt; results are cached as derived objects.
+// @Description WebP renders waiting too long for an encoder are answered with 503 and Retry-After.
+// @Description Files of versioned file types are revalidated by caches unless v pins the current content version.
+// @Description Private files need a token with read access to files or a signed URL (expires and signature).
 // @Tags files
 // @Produce octet-stream
-// @Param fileType path string true "File type: portfolio-image, miniature-image, document"
+// @Param fileType path string true "Configured file type (defaults: portfolio-image, miniature-image, document)"
 // @Param key path string true "File key/path"
+// @Param Range header string false "Byte ranges, e.g. bytes=0-1023"
+// @Param If-None-Match header string false "ETag from a previous response"
+// @Param If-Modified-Since header string false "Last-Modified from a previous response"
+// @Param w query int false "Image width (must be an allowed size)"
+// @Param h query int false "Image height (must be an allowed size)"
+// @Param fit query string false "contain or cover" Enums(contain, cover)
+// @Param fmt query string false "Output format; webp only when IMAGE_WEBP_ENCODER is set" Enums(jpeg, png, webp)
+// @Param v query int false "Current content version, for immutable caching of versioned files"
+// @Param expires query int false "Expiry of a signed URL (Unix seconds)"
+// @Param signature query string false "Signature of a signed URL"
 // @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Success 304 "Not modified"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 416 {object} map[string]string
+// @Failure 422 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Router /files/{fileType}/{key} [get]
+// @Router /files/{fileType}/{key} [head]
 func (h *Handler) DownloadFile(c *gin.Context) {
 	fileType := c.Param("fileType")
 	key := c.Param("key")
@@ -31,20 +67,138 @@ func (h *Handler) DownloadFile(c *gin.Context) {
 	}
 
 	// Map fileType to bucket
-	bucket, err := h.fileTypeToBucket(fileType)
+	ft, err := h.fileType(fileType)
 	if err != nil {
 		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
+	bucket := ft.Bucket
 
-	// Get file metadata from database to get original filename
+	// Get file metadata from database to get original filename and the object key
 	fileRecord, err := h.repo.GetFileByKey(c.Request.Context(), bucket, key)
 	if err != nil {
 		commonHandlers.HandleRepositoryError(c, err, "file not found in database", "failed to fetch file record")
 		return
 	}
+	// Files flagged by the antivirus scanner and unfinished uploads are never served
+	if isInfected(fileRecord) || fileRecord.IsPending() {
+		commonHandlers.RespondError(c, http.StatusNotFound, "file not found")
+		return
+	}
+	if fileRecord.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, trashedFileMessage)
+		return
+	}
+	if fileRecord.IsPrivate() && !h.authorizeDownload(c, fileType, key) {
+		return
+	}
+
+	// Resized or converted images are served from cached derived objects
+	if isTransformRequest(c) {
+		h.serveTransformedImage(c, ft, fileRecord)
+		return
+	}
+
+	h.serveFileContent(c, ft, fileRecord)
+}
 
-	// Get file from S3
+// serveFileContent answers GET and HEAD with the current content of a file
+func (h *Handler) serveFileContent(c *gin.Context, ft *filetypes.FileType, fileRecord *repository.StorageFile) {
+	// Get object info for validators, size and content type
+	objectKey := fileRecord.ObjectKey()
+	info, err := h.storage.StatObject(c.Request.Context(), ft.Bucket, objectKey)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			commonHandlers.LogAndRespondError(c, http.StatusNotFound, err, "file not found in storage")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to get file info")
+		return
+	}
+
+	h.serveStoredObject(c, ft, objectKey, fileRecord.FileName, info, fileRecord)
+}
+
+// cacheControl picks the Cache-Control of a download. The content of versioned files can
+// change under their URL, so it is revalidated unless the URL pins the current version
+// with ?v=; that URL changes with every version and is cached like any other file.
+// Private files and downloads through share links are never stored by caches.
+func cacheControl(c *gin.Context, ft *filetypes.FileType, fileRecord *repository.StorageFile) string {
+	if fileRecord.IsPrivate() || isSharedDownload(c) {
+		return filetypes.PrivateCacheControl
+	}
+	if ft.Versioned && c.Query("v") != strconv.FormatInt(fileRecord.ContentVersion, 10) {
+		return filetypes.RevalidateCacheControl
+	}
+	return ft.CacheControl
+}
+
+// serveStoredObject answers GET and HEAD for an object in the file type's bucket, applying
+// conditional request and Range handling. fileName is the name offered in Content-Disposition.
+// Share links count every download, so they always send the whole object.
+func (h *Handler) serveStoredObject(c *gin.Context, ft *filetypes.FileType, key, fileName string, info minio.ObjectInfo, fileRecord *repository.StorageFile) {
+	bucket := ft.Bucket
+	etag := quoteETag(info.ETag)
+	if etag != "" {
+		c.Header("ETag", etag)
+	}
+	if !info.LastModified.IsZero() {
+		c.Header("Last-Modified", info.LastModified.UTC().Format(http.TimeFormat))
+	}
+	shared := isSharedDownload(c)
+	if shared {
+		c.Header("Accept-Ranges", "none")
+	} else {
+		c.Header("Accept-Ranges", "bytes")
+	}
+	c.Header("Access-Control-Expose-Headers", downloadExposedHeaders)
+	c.Header("Cache-Control", cacheControl(c, ft, fileRecord))
+
+	if !shared && isNotModified(c.Request, etag, info.LastModified) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	var ranges []byteRange
+	if c.Request.Method == http.MethodGet && !shared && rangeApplies(c.Request, etag, info.LastModified) {
+		parsed, err := parseRange(c.GetHeader("Range"), info.Size)
+		if err != nil {
+			c.Header("Content-Range", fmt.Sprintf("bytes */%d", info.Size))
+			commonHandlers.RespondError(c, http.StatusRequestedRangeNotSatisfiable, err.Error())
+			return
+		}
+		if rangesWorthServing(parsed, info.Size) {
+			ranges = parsed
+		}
+	}
+
+	// Set headers with original filename
+	c.Header("Content-Disposition", contentDisposition(fileName))
+
+	if c.Request.Method == http.MethodHead {
+		c.Header("Content-Type", info.ContentType)
+		c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+		c.Status(http.StatusOK)
+		return
+	}
+
+	// Viewers seek with many range requests; only log the one that starts the download
+	if len(ranges) == 0 || ranges[0].start == 0 {
+		h.logDownload(c, fileRecord, ft.Name)
+	}
+
+	switch len(ranges) {
+	case 0:
+		h.serveObject(c, bucket, key, info)
+	case 1:
+		h.serveRange(c, bucket, key, info, ranges[0])
+	default:
+		h.serveMultiRange(c, bucket, key, info, ranges)
+	}
+}
+
+// serveObject streams the whole object
+func (h *Handler) serveObject(c *gin.Context, bucket, key string, info minio.ObjectInfo) {
 	object, err := h.storage.GetObject(c.Request.Context(), bucket, key)
 	if err != nil {
 		commonHandlers.LogAndRespondError(c, http.StatusNotFound, err, "file not found in storage")
@@ -52,22 +206,74 @@ func (h *Handler) DownloadFile(c *gin.Context) {
 	}
 	defer object.Close()
 
-	// Get object info for content type
-	stat, err := object.Stat()
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Length", strconv.FormatInt(info.Size, 10))
+	c.Status(http.StatusOK)
+
+	// Stream file
+	if _, err := io.Copy(c.Writer, object); err != nil {
+		logger.GetLogger(c).Error("Failed to stream file", "error", err, "bucket", bucket, "key", key)
+	}
+}
+
+// serveRange streams a single byte range as a 206 response
+func (h *Handler) serveRange(c *gin.Context, bucket, key string, info minio.ObjectInfo, r byteRange) {
+	reader, err := h.storage.GetObjectRange(c.Request.Context(), bucket, key, r.start, r.length)
 	if err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to get file info")
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to read file range")
 		return
 	}
+	defer reader.Close()
 
-	// Set headers with original filename
-	c.Header("Content-Type", stat.ContentType)
-	c.Header("Content-Length", fmt.Sprintf("%d", stat.Size))
-	// Use RFC 5987 encoding for filename to prevent header injection and support non-ASCII characters
-	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(fileRecord.FileName)))
-	// Cache immutable files for 1 year (files have unique UUID keys)
-	c.Header("Cache-Control", "public, max-age=31536000, immutable")
-
-	// Log file download with source tracking
+	c.Header("Content-Type", info.ContentType)
+	c.Header("Content-Range", r.contentRange(info.Size))
+	c.Header("Content-Length", strconv.FormatInt(r.length, 10))
+	c.Status(http.StatusPartialContent)
+
+	if _, err := io.CopyN(c.Writer, reader, r.length); err != nil {
+		logger.GetLogger(c).Error("Failed to stream file range", "error", err, "bucket", bucket, "key", key)
+	}
+}
+
+// serveMultiRange streams several byte ranges as a multipart/byteranges 206 response,
+// fetching each range from storage as it is written
+func (h *Handler) serveMultiRange(c *gin.Context, bucket, key string, info minio.ObjectInfo, ranges []byteRange) {
+	mw := multipart.NewWriter(c.Writer)
+	c.Header("Content-Type", "multipart/byteranges; boundary="+mw.Boundary())
+	c.Status(http.StatusPartialContent)
+
+	for _, r := range ranges {
+		part, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":  {info.ContentType},
+			"Content-Range": {r.contentRange(info.Size)},
+		})
+		if err != nil {
+			logger.GetLogger(c).Error("Failed to write range part", "error", err, "bucket", bucket, "key", key)
+			return
+		}
+		if err := h.copyRange(c, bucket, key, r, part); err != nil {
+			logger.GetLogger(c).Error("Failed to stream file range", "error", err, "bucket", bucket, "key", key)
+			return
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		logger.GetLogger(c).Error("Failed to finish multipart response", "error", err, "bucket", bucket, "key", key)
+	}
+}
+
+func (h *Handler) copyRange(c *gin.Context, bucket, key string, r byteRange, dst io.Writer) error {
+	reader, err := h.storage.GetObjectRange(c.Request.Context(), bucket, key, r.start, r.length)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	_, err = io.CopyN(dst, reader, r.length)
+	return err
+}
+
+// logDownload records a file download with source tracking
+func (h *Handler) logDownload(c *gin.Context, fileRecord *repository.StorageFile, fileType string) {
 	resourceType := audit.ResourceTypeFile
 	source := c.Query("source") // "admin-web", "public-web", or empty
 	if source == "" {
@@ -79,10 +285,10 @@ func (h *Handler) DownloadFile(c *gin.Context) {
 		"size":      fileRecord.FileSize,
 		"mime_type": fileRecord.MimeType,
 	})
+}
 
-	// Stream file
-	if _, err := io.Copy(c.Writer, object); err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to stream file")
-		return
-	}
+// contentDisposition builds an attachment header for fileName.
+// Uses RFC 5987 encoding to prevent header injection and support non-ASCII characters.
+func contentDisposition(fileName string) string {
+	return fmt.Sprintf("attachment; filename*=UTF-8''%s", url.PathEscape(fileName))
 }
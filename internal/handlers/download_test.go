@@ -1,12 +1,18 @@
 package handlers
 
 import (
+	"bytes"
 	"context"
 	"errors"
+	"io"
+	"mime"
+	"mime/multipart"
 	"net/http"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
@@ -51,6 +57,58 @@ func TestDownloadFile_FileNotInDatabase(t *testing.T) {
 	}
 }
 
+func TestDownloadFile_Trashed(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return createTrashedTestFile(), nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			t.Error("expected trashed file not to be read from storage")
+			return minio.ObjectInfo{}, nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	for _, path := range []string{"/api/v1/files/portfolio-image/" + testFileKey, "/api/v1/files/portfolio-image/" + testFileKey + "?w=4"} {
+		w := performRequest(router, http.MethodGet, path, nil)
+
+		if w.Code != http.StatusGone {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusGone, w.Code)
+		}
+	}
+}
+
+func TestDownloadFile_PendingNotServed(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			file := createTestFile()
+			file.Status = repository.FileStatusPending
+			return file, nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			t.Error("expected pending file not to be read from storage")
+			return minio.ObjectInfo{}, nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	w := performRequest(router, http.MethodGet, "/api/v1/files/portfolio-image/"+testFileKey, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
 func TestDownloadFile_DatabaseError(t *testing.T) {
 	mockRepo := &mockRepository{
 		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
@@ -80,7 +138,7 @@ func TestDownloadFile_StorageGetObjectError(t *testing.T) {
 	}
 
 	mockStore := &mockStorage{
-		getObjectFunc: func(_ context.Context, _, _ string) (*minio.Object, error) {
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
 			return nil, errors.New("storage unavailable")
 		},
 	}
@@ -175,3 +233,371 @@ func TestDownloadFile_ContextPropagation(t *testing.T) {
 		t.Error("context sentinel value was not propagated to repository")
 	}
 }
+
+// =============================================================================
+// Range and Conditional Request Tests
+// =============================================================================
+
+var testLastModified = time.Date(2025, time.March, 1, 12, 0, 0, 0, time.UTC)
+
+// setupDownloadRouter serves content as the stored object for testFileKey and
+// counts full and ranged object reads
+func setupDownloadRouter(content []byte, fullReads, rangeReads *int) *gin.Engine {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return createTestFile(), nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{
+				ETag:         "abc123",
+				Size:         int64(len(content)),
+				ContentType:  testMimeType,
+				LastModified: testLastModified,
+			}, nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			*fullReads++
+			return nil, errors.New("full read not expected")
+		},
+		getObjectRangeFunc: func(_ context.Context, _, _ string, offset, length int64) (io.ReadCloser, error) {
+			*rangeReads++
+			return io.NopCloser(bytes.NewReader(content[offset : offset+length])), nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+	router.HEAD("/api/v1/files/:fileType/*key", handler.DownloadFile)
+	return router
+}
+
+const testDownloadPath = "/api/v1/files/portfolio-image/" + testFileKey
+
+func TestDownloadFile_NotModified(t *testing.T) {
+	testCases := []struct {
+		name    string
+		headers map[string]string
+	}{
+		{"if-none-match", map[string]string{"If-None-Match": `"other", "abc123"`}},
+		{"if-none-match weak", map[string]string{"If-None-Match": `W/"abc123"`}},
+		{"if-modified-since", map[string]string{"If-Modified-Since": testLastModified.Format(http.TimeFormat)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var fullReads, rangeReads int
+			router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+			w := performRequest(router, http.MethodGet, testDownloadPath, nil, tc.headers)
+
+			if w.Code != http.StatusNotModified {
+				t.Fatalf("expected status %d, got %d", http.StatusNotModified, w.Code)
+			}
+			if w.Header().Get("ETag") != `"abc123"` {
+				t.Errorf("expected ETag header, got %q", w.Header().Get("ETag"))
+			}
+			if w.Body.Len() != 0 || fullReads+rangeReads != 0 {
+				t.Error("expected no body and no object reads for 304")
+			}
+		})
+	}
+}
+
+func TestDownloadFile_Modified_ServesObject(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	w := performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{
+		"If-None-Match":     `"stale"`,
+		"If-Modified-Since": testLastModified.Add(time.Hour).Format(http.TimeFormat),
+	})
+
+	// If-None-Match takes precedence over If-Modified-Since
+	if fullReads != 1 {
+		t.Errorf("expected whole object to be read, got %d reads (status %d)", fullReads, w.Code)
+	}
+}
+
+func TestDownloadFile_SingleRange(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	w := performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{"Range": "bytes=2-5"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusPartialContent, w.Code, w.Body.String())
+	}
+	if w.Body.String() != "2345" {
+		t.Errorf("expected body 2345, got %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Range") != "bytes 2-5/10" {
+		t.Errorf("unexpected Content-Range %q", w.Header().Get("Content-Range"))
+	}
+	if w.Header().Get("Content-Length") != "4" {
+		t.Errorf("expected Content-Length 4, got %q", w.Header().Get("Content-Length"))
+	}
+	if fullReads != 0 || rangeReads != 1 {
+		t.Errorf("expected one ranged read, got %d full and %d ranged", fullReads, rangeReads)
+	}
+}
+
+func TestDownloadFile_MultiRange(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	w := performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{"Range": "bytes=0-1,-3"})
+
+	if w.Code != http.StatusPartialContent {
+		t.Fatalf("expected status %d, got %d", http.StatusPartialContent, w.Code)
+	}
+	mediaType, params, err := mime.ParseMediaType(w.Header().Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		t.Fatalf("expected multipart/byteranges, got %q", w.Header().Get("Content-Type"))
+	}
+
+	reader := multipart.NewReader(w.Body, params["boundary"])
+	want := []struct{ contentRange, body string }{
+		{"bytes 0-1/10", "01"},
+		{"bytes 7-9/10", "789"},
+	}
+	for i, expected := range want {
+		part, err := reader.NextPart()
+		if err != nil {
+			t.Fatalf("part %d: %v", i, err)
+		}
+		body, _ := io.ReadAll(part)
+		if part.Header.Get("Content-Range") != expected.contentRange || string(body) != expected.body {
+			t.Errorf("part %d: got %q %q, want %q %q", i, part.Header.Get("Content-Range"), body, expected.contentRange, expected.body)
+		}
+		if part.Header.Get("Content-Type") != testMimeType {
+			t.Errorf("part %d: expected Content-Type %s, got %q", i, testMimeType, part.Header.Get("Content-Type"))
+		}
+	}
+	if _, err := reader.NextPart(); err != io.EOF {
+		t.Errorf("expected exactly two parts, got err %v", err)
+	}
+	if rangeReads != 2 {
+		t.Errorf("expected two ranged reads, got %d", rangeReads)
+	}
+}
+
+func TestDownloadFile_RangeNotSatisfiable(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	w := performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{"Range": "bytes=20-30"})
+
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Fatalf("expected status %d, got %d", http.StatusRequestedRangeNotSatisfiable, w.Code)
+	}
+	if w.Header().Get("Content-Range") != "bytes */10" {
+		t.Errorf("unexpected Content-Range %q", w.Header().Get("Content-Range"))
+	}
+	if fullReads+rangeReads != 0 {
+		t.Error("expected no object reads")
+	}
+}
+
+func TestDownloadFile_IfRangeMismatch_ServesWholeFile(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{
+		"Range":    "bytes=2-5",
+		"If-Range": `"stale"`,
+	})
+
+	if fullReads != 1 || rangeReads != 0 {
+		t.Errorf("expected whole object to be read, got %d full and %d ranged", fullReads, rangeReads)
+	}
+}
+
+func TestDownloadFile_Head(t *testing.T) {
+	var fullReads, rangeReads int
+	router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+	w := performRequest(router, http.MethodHead, testDownloadPath, nil, map[string]string{"Range": "bytes=2-5"})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Body.Len() != 0 {
+		t.Errorf("expected empty body, got %q", w.Body.String())
+	}
+	if w.Header().Get("Content-Length") != "10" || w.Header().Get("Content-Type") != testMimeType {
+		t.Errorf("unexpected headers %v", w.Header())
+	}
+	if w.Header().Get("Accept-Ranges") != "bytes" || w.Header().Get("Last-Modified") != testLastModified.Format(http.TimeFormat) {
+		t.Errorf("expected range and validator headers, got %v", w.Header())
+	}
+	if fullReads+rangeReads != 0 {
+		t.Error("expected no object reads for HEAD")
+	}
+}
+
+func TestDownloadFile_FileTypeCacheControl(t *testing.T) {
+	var requestedBucket string
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, bucket, _ string) (*repository.StorageFile, error) {
+			requestedBucket = bucket
+			return createTestFile(), nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{ETag: "abc123", Size: 10, ContentType: testMimeType}, nil
+		},
+	}
+	cfg := createTestConfig()
+	withFileType(cfg, "miniature-image", func(ft *filetypes.FileType) {
+		ft.Bucket = "thumbnails"
+		ft.CacheControl = "public, max-age=3600"
+	})
+	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.HEAD("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	w := performRequest(router, http.MethodHead, "/api/v1/files/miniature-image/"+testFileKey, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if requestedBucket != "thumbnails" {
+		t.Errorf("expected lookup in the file type's bucket, got %q", requestedBucket)
+	}
+	if got := w.Header().Get("Cache-Control"); got != "public, max-age=3600" {
+		t.Errorf("expected the file type's Cache-Control, got %q", got)
+	}
+
+	// File types without an explicit policy use the immutable default
+	w = performRequest(router, http.MethodHead, testDownloadPath, nil)
+	if got := w.Header().Get("Cache-Control"); got != filetypes.DefaultCacheControl {
+		t.Errorf("expected default Cache-Control, got %q", got)
+	}
+}
+
+func TestDownloadFile_VersionedCacheControl(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			file := createTestDocument(2)
+			file.ContentKey = stringPtr("cv-v2.pdf")
+			return file, nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, key string) (minio.ObjectInfo, error) {
+			if key != "cv-v2.pdf" {
+				t.Errorf("expected the current version's object, got %s", key)
+			}
+			return minio.ObjectInfo{ETag: "abc123", Size: 10, ContentType: "application/pdf"}, nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.HEAD("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	// The stable URL of a versioned file changes content, so caches must revalidate;
+	// only the URL pinned to the current version is cached as the file type says
+	testCases := []struct {
+		path     string
+		expected string
+	}{
+		{"/api/v1/files/document/cv.pdf", filetypes.RevalidateCacheControl},
+		{"/api/v1/files/document/cv.pdf?v=1", filetypes.RevalidateCacheControl},
+		{"/api/v1/files/document/cv.pdf?v=2", filetypes.DefaultCacheControl},
+	}
+	for _, tc := range testCases {
+		w := performRequest(router, http.MethodHead, tc.path, nil)
+		if w.Code != http.StatusOK {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusOK, tc.path, w.Code)
+		}
+		if got := w.Header().Get("Cache-Control"); got != tc.expected {
+			t.Errorf("expected Cache-Control %q for %s, got %q", tc.expected, tc.path, got)
+		}
+	}
+}
+
+func TestDownloadFile_StatObjectNotFound(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return createTestFile(), nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	w := performRequest(router, http.MethodGet, testDownloadPath, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
+
+func TestDownloadFile_MalformedRange_ServesWholeFile(t *testing.T) {
+	for _, header := range []string{"items=0-4", "bytes=5-2", "bytes=abc"} {
+		var fullReads, rangeReads int
+		router := setupDownloadRouter([]byte("0123456789"), &fullReads, &rangeReads)
+
+		w := performRequest(router, http.MethodGet, testDownloadPath, nil, map[string]string{"Range": header})
+
+		if w.Code == http.StatusRequestedRangeNotSatisfiable {
+			t.Errorf("Range %q: expected header to be ignored, got status %d", header, w.Code)
+		}
+		if fullReads != 1 || rangeReads != 0 {
+			t.Errorf("Range %q: expected whole object to be read, got %d full and %d ranged", header, fullReads, rangeReads)
+		}
+	}
+}
+
+func TestParseRange(t *testing.T) {
+	testCases := []struct {
+		header  string
+		size    int64
+		want    []byteRange
+		wantErr bool
+	}{
+		{"", 10, nil, false},
+		{"bytes=0-4", 10, []byteRange{{0, 5}}, false},
+		{"bytes=5-", 10, []byteRange{{5, 5}}, false},
+		{"bytes=-3", 10, []byteRange{{7, 3}}, false},
+		{"bytes=-30", 10, []byteRange{{0, 10}}, false},
+		{"bytes=8-20", 10, []byteRange{{8, 2}}, false},
+		{"bytes=0-0, 2-3", 10, []byteRange{{0, 1}, {2, 2}}, false},
+		{"bytes=0-1,20-30", 10, []byteRange{{0, 2}}, false},
+		{"bytes=20-30", 10, nil, true},
+		{"bytes=-5", 0, nil, true},
+		{"BYTES=0-4", 10, []byteRange{{0, 5}}, false},
+		{"bytes=5-2", 10, nil, false},
+		{"bytes=abc", 10, nil, false},
+		{"bytes=+1-4", 10, nil, false},
+		{"bytes=0-1,abc", 10, nil, false},
+		{"bytes=", 10, nil, false},
+		{"items=0-4", 10, nil, false},
+		{"0-4", 10, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.header, func(t *testing.T) {
+			got, err := parseRange(tc.header, tc.size)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("parseRange(%q) error = %v, wantErr %v", tc.header, err, tc.wantErr)
+			}
+			if len(got) != len(tc.want) {
+				t.Fatalf("parseRange(%q) = %v, want %v", tc.header, got, tc.want)
+			}
+			for i := range got {
+				if got[i] != tc.want[i] {
+					t.Errorf("parseRange(%q)[%d] = %v, want %v", tc.header, i, got[i], tc.want[i])
+				}
+			}
+		})
+	}
+}
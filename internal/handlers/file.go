@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// Audit actions for file changes the shared audit package has no constant for
+const (
+	actionFileUpdate  = "file_update"
+	actionFileRestore = "file_restore"
+)
+
+// UpdateFileRequest lists the editable file attributes; omitted fields are left unchanged
+type UpdateFileRequest struct {
+	// Filename offered in Content-Disposition; the extension must match the content
+	FileName *string `json:"fileName" binding:"omitempty,min=1,max=255"`
+	// Empty strings clear alt text, caption and title
+	AltText *string `json:"altText" binding:"omitempty,max=1000"`
+	Caption *string `json:"caption" binding:"omitempty,max=2000"`
+	Title   *string `json:"title" binding:"omitempty,max=255"`
+	// public or private; also applied to the file's variants
+	Visibility *string `json:"visibility" binding:"omitempty,oneof=public private"`
+}
+
+// GetFile godoc
+// @Summary Get file metadata
+// @Description Get the stored record of a file by ID, including its public download URL.
+// @Description The ETag header identifies the current version for PATCH If-Match requests.
+// @Tags files
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} repository.StorageFile
+// @Header 200 {string} ETag "Metadata version"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id} [get]
+func (h *Handler) GetFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+
+	h.respondWithFile(c, file)
+}
+
+// UpdateFile godoc
+// @Summary Update file metadata
+// @Description Rename the download filename, set alt text, caption and title, and make the file public or private. If-Match must carry the ETag
+// @Description from the last read; the update is rejected with 412 when another edit got there first. Edit permission
+// @Description only allows changing the user's own uploads; delete permission allows any file.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param If-Match header string true "ETag of the version being edited"
+// @Param request body UpdateFileRequest true "Attributes to change"
+// @Success 200 {object} repository.StorageFile
+// @Header 200 {string} ETag "New metadata version"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 428 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id} [patch]
+func (h *Handler) UpdateFile(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	var req UpdateFileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.FileName == nil && req.AltText == nil && req.Caption == nil && req.Title == nil && req.Visibility == nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "no attributes to update")
+		return
+	}
+
+	ifMatch := c.GetHeader("If-Match")
+	if ifMatch == "" {
+		commonHandlers.RespondError(c, http.StatusPreconditionRequired, "If-Match header is required")
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	if file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, trashedFileMessage)
+		return
+	}
+	if !ifMatchSatisfied(ifMatch, fileETag(file)) {
+		commonHandlers.RespondError(c, http.StatusPreconditionFailed, "file was modified; fetch it again and retry")
+		return
+	}
+
+	update := repository.FileMetadataUpdate{
+		AltText:    req.AltText,
+		Caption:    req.Caption,
+		Title:      req.Title,
+		Visibility: req.Visibility,
+	}
+	if req.FileName != nil {
+		fileName, err := h.validateFileName(*req.FileName, file)
+		if err != nil {
+			commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+		update.FileName = &fileName
+	}
+
+	updated, err := h.repo.UpdateFileMetadata(c.Request.Context(), id, file.Version, update)
+	if errors.Is(err, repository.ErrFileVersionConflict) {
+		commonHandlers.RespondError(c, http.StatusPreconditionFailed, "file was modified; fetch it again and retry")
+		return
+	}
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to update file")
+		return
+	}
+
+	// Log metadata changes with their previous values
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileUpdate, &resourceType, &id, &source, map[string]interface{}{
+		"file_type": file.FileType,
+		"version":   updated.Version,
+		"changes":   metadataChanges(file, update),
+	})
+
+	h.respondWithFile(c, updated)
+}
+
+// validateFileName checks a new display filename for a stored file. The extension
+// must still match the stored content, so a rename cannot disguise the file type.
+func (h *Handler) validateFileName(fileName string, file *repository.StorageFile) (string, error) {
+	fileName = strings.TrimSpace(fileName)
+	if fileName == "" {
+		return "", errors.New("fileName must not be blank")
+	}
+	if strings.ContainsAny(fileName, `/\`) || strings.ContainsFunc(fileName, unicode.IsControl) {
+		return "", errors.New("fileName must not contain path separators or control characters")
+	}
+
+	ft, err := h.fileType(file.FileType)
+	if err != nil {
+		return "", err
+	}
+	if _, err := ft.ResolveExtension(fileName, file.MimeType); err != nil {
+		if errors.Is(err, filetypes.ErrExtensionMismatch) {
+			return "", fmt.Errorf("fileName extension does not match %s content", file.MimeType)
+		}
+		return "", err
+	}
+	return fileName, nil
+}
+
+// metadataChanges describes each changed attribute as its old and new value
+func metadataChanges(file *repository.StorageFile, update repository.FileMetadataUpdate) map[string]interface{} {
+	changes := make(map[string]interface{})
+	if update.FileName != nil && *update.FileName != file.FileName {
+		changes["filename"] = gin.H{"from": file.FileName, "to": *update.FileName}
+	}
+	for field, values := range map[string][2]*string{
+		"alt_text":   {file.AltText, update.AltText},
+		"caption":    {file.Caption, update.Caption},
+		"title":      {file.Title, update.Title},
+		"visibility": {&file.Visibility, update.Visibility},
+	} {
+		previous, next := values[0], values[1]
+		if next == nil {
+			continue
+		}
+		if old := derefString(previous); old != *next {
+			changes[field] = gin.H{"from": old, "to": *next}
+		}
+	}
+	return changes
+}
+
+func derefString(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+// respondWithFile sends the full file record with its download URLs and metadata ETag
+func (h *Handler) respondWithFile(c *gin.Context, file *repository.StorageFile) {
+	file.URL = fileURL(file)
+	if ft, err := h.fileType(file.FileType); err == nil && ft.Versioned {
+		file.VersionURL = versionURL(file)
+	}
+	c.Header("Access-Control-Expose-Headers", "ETag")
+	c.Header("ETag", fileETag(file))
+	c.JSON(http.StatusOK, file)
+}
+
+// fileETag identifies the metadata version of a file record
+func fileETag(file *repository.StorageFile) string {
+	return fmt.Sprintf(`"%d-%d"`, file.ID, file.Version)
+}
+
+// ifMatchSatisfied evaluates an If-Match list against the current ETag. If-Match
+// requires strong comparison, so weak tags never match.
+func ifMatchSatisfied(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = textproto.TrimString(candidate)
+		if candidate == "*" || candidate == etag {
+			return true
+		}
+	}
+	return false
+}
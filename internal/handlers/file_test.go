@@ -0,0 +1,305 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func setupFileRouter(mockRepo *mockRepository, actionLogRepo *mockActionLogRepo) *gin.Engine {
+	handler := New(mockRepo, nil, createTestConfig(), actionLogRepo)
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:id", handler.GetFile)
+	router.PATCH("/api/v1/files/:id", asAdmin(), handler.UpdateFile)
+	return router
+}
+
+func versionedTestFile(version int64) *repository.StorageFile {
+	file := createTestFile()
+	file.Version = version
+	return file
+}
+
+func stringPtr(s string) *string {
+	return &s
+}
+
+// =============================================================================
+// Get File Tests
+// =============================================================================
+
+func TestGetFile_Success(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, id int64) (*repository.StorageFile, error) {
+			file := versionedTestFile(3)
+			file.ID = id
+			file.AltText = stringPtr("A test image")
+			return file, nil
+		},
+	}
+
+	w := performRequest(setupFileRouter(mockRepo, &mockActionLogRepo{}), http.MethodGet, "/api/v1/files/1", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1-3"` {
+		t.Errorf("expected ETag %q, got %q", `"1-3"`, etag)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["url"] != "/api/v1/files/"+testFileType+"/"+testFileKey {
+		t.Errorf("expected download URL, got %v", response["url"])
+	}
+	if response["altText"] != "A test image" || response["fileName"] != testFileName || response["version"] != float64(3) {
+		t.Errorf("unexpected file record %v", response)
+	}
+	if _, ok := response["s3Key"]; ok {
+		t.Error("expected storage location not to be exposed")
+	}
+}
+
+func TestGetFile_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		err        error
+		wantStatus int
+	}{
+		{"invalid ID", "/api/v1/files/abc", nil, http.StatusBadRequest},
+		{"not found", "/api/v1/files/999", gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"repository error", "/api/v1/files/1", errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return nil, tc.err
+				},
+			}
+			w := performRequest(setupFileRouter(mockRepo, &mockActionLogRepo{}), http.MethodGet, tc.path, nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Update File Tests
+// =============================================================================
+
+func TestUpdateFile_Success(t *testing.T) {
+	var gotVersion int64
+	var gotUpdate repository.FileMetadataUpdate
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			file := versionedTestFile(3)
+			file.Title = stringPtr("Old title")
+			return file, nil
+		},
+		updateFileMetadataFunc: func(_ context.Context, _ int64, version int64, update repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+			gotVersion = version
+			gotUpdate = update
+			file := versionedTestFile(version + 1)
+			file.FileName = *update.FileName
+			file.AltText = update.AltText
+			return file, nil
+		},
+	}
+	var logged *commonRepo.ActionLog
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+
+	body := `{"fileName": "  hero.PNG ", "altText": "Hero shot", "title": ""}`
+	w := performRequest(setupFileRouter(mockRepo, actionLogRepo), http.MethodPatch, "/api/v1/files/1", strings.NewReader(body),
+		map[string]string{"Content-Type": "application/json", "If-Match": `"1-3"`})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if gotVersion != 3 {
+		t.Errorf("expected update of version 3, got %d", gotVersion)
+	}
+	if *gotUpdate.FileName != "hero.PNG" || *gotUpdate.AltText != "Hero shot" || *gotUpdate.Title != "" || gotUpdate.Caption != nil {
+		t.Errorf("unexpected update %+v", gotUpdate)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1-4"` {
+		t.Errorf("expected new ETag %q, got %q", `"1-4"`, etag)
+	}
+
+	if logged == nil {
+		t.Fatal("expected update to be audit logged")
+	}
+	if logged.ActionType != actionFileUpdate || logged.ResourceID == nil || *logged.ResourceID != 1 {
+		t.Errorf("unexpected audit entry %+v", logged)
+	}
+	var metadata struct {
+		Changes map[string]map[string]string `json:"changes"`
+	}
+	if err := json.Unmarshal(logged.Metadata, &metadata); err != nil {
+		t.Fatalf("failed to unmarshal audit metadata: %v", err)
+	}
+	if metadata.Changes["filename"]["from"] != testFileName || metadata.Changes["filename"]["to"] != "hero.PNG" {
+		t.Errorf("expected filename change, got %v", metadata.Changes)
+	}
+	if metadata.Changes["title"]["from"] != "Old title" || metadata.Changes["title"]["to"] != "" {
+		t.Errorf("expected title to be cleared, got %v", metadata.Changes)
+	}
+	if _, ok := metadata.Changes["caption"]; ok {
+		t.Error("expected unchanged caption not to be logged")
+	}
+}
+
+func TestUpdateFile_Visibility(t *testing.T) {
+	var gotUpdate repository.FileMetadataUpdate
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			file := versionedTestFile(1)
+			file.Visibility = repository.FileVisibilityPublic
+			return file, nil
+		},
+		updateFileMetadataFunc: func(_ context.Context, _ int64, version int64, update repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+			gotUpdate = update
+			file := versionedTestFile(version + 1)
+			file.Visibility = *update.Visibility
+			return file, nil
+		},
+	}
+	var logged *commonRepo.ActionLog
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+
+	w := performRequest(setupFileRouter(mockRepo, actionLogRepo), http.MethodPatch, "/api/v1/files/1", strings.NewReader(`{"visibility": "private"}`),
+		map[string]string{"Content-Type": "application/json", "If-Match": `"1-1"`})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if gotUpdate.Visibility == nil || *gotUpdate.Visibility != repository.FileVisibilityPrivate {
+		t.Errorf("expected visibility update, got %+v", gotUpdate)
+	}
+	if logged == nil {
+		t.Fatal("expected update to be audit logged")
+	}
+	var metadata struct {
+		Changes map[string]map[string]string `json:"changes"`
+	}
+	if err := json.Unmarshal(logged.Metadata, &metadata); err != nil {
+		t.Fatalf("failed to unmarshal audit metadata: %v", err)
+	}
+	if metadata.Changes["visibility"]["from"] != "public" || metadata.Changes["visibility"]["to"] != "private" {
+		t.Errorf("expected visibility change, got %v", metadata.Changes)
+	}
+}
+
+func TestUpdateFile_Preconditions(t *testing.T) {
+	testCases := []struct {
+		name       string
+		ifMatch    string
+		trashed    bool
+		updateErr  error
+		wantStatus int
+	}{
+		{"missing If-Match", "", false, nil, http.StatusPreconditionRequired},
+		{"stale version", `"1-2"`, false, nil, http.StatusPreconditionFailed},
+		{"weak tag", `W/"1-3"`, false, nil, http.StatusPreconditionFailed},
+		{"concurrent update", `"1-3"`, false, repository.ErrFileVersionConflict, http.StatusPreconditionFailed},
+		{"trashed file", `"1-3"`, true, nil, http.StatusGone},
+		{"current version in list", `"1-2", "1-3"`, false, nil, http.StatusOK},
+		{"any version", "*", false, nil, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					file := versionedTestFile(3)
+					if tc.trashed {
+						file.DeletedAt = createTrashedTestFile().DeletedAt
+					}
+					return file, nil
+				},
+				updateFileMetadataFunc: func(_ context.Context, _ int64, version int64, _ repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+					if tc.updateErr != nil {
+						return nil, tc.updateErr
+					}
+					return versionedTestFile(version + 1), nil
+				},
+			}
+			headers := map[string]string{"Content-Type": "application/json"}
+			if tc.ifMatch != "" {
+				headers["If-Match"] = tc.ifMatch
+			}
+			w := performRequest(setupFileRouter(mockRepo, &mockActionLogRepo{}), http.MethodPatch, "/api/v1/files/1",
+				strings.NewReader(`{"caption": "New caption"}`), headers)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUpdateFile_InvalidRequest(t *testing.T) {
+	testCases := []struct {
+		name string
+		path string
+		body string
+	}{
+		{"invalid ID", "/api/v1/files/abc", `{"title": "Title"}`},
+		{"malformed JSON", "/api/v1/files/1", `{"title":`},
+		{"no attributes", "/api/v1/files/1", `{}`},
+		{"empty filename", "/api/v1/files/1", `{"fileName": ""}`},
+		{"blank filename", "/api/v1/files/1", `{"fileName": "   "}`},
+		{"path in filename", "/api/v1/files/1", `{"fileName": "../hero.png"}`},
+		{"extension mismatch", "/api/v1/files/1", `{"fileName": "hero.pdf"}`},
+		{"title too long", "/api/v1/files/1", `{"title": "` + strings.Repeat("a", 256) + `"}`},
+		{"unknown visibility", "/api/v1/files/1", `{"visibility": "hidden"}`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return versionedTestFile(1), nil
+				},
+				updateFileMetadataFunc: func(_ context.Context, _ int64, _ int64, _ repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+					t.Error("expected file not to be updated")
+					return nil, nil
+				},
+			}
+			w := performRequest(setupFileRouter(mockRepo, &mockActionLogRepo{}), http.MethodPatch, tc.path, strings.NewReader(tc.body),
+				map[string]string{"Content-Type": "application/json", "If-Match": `"1-1"`})
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
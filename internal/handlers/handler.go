@@ -2,7 +2,9 @@ package handlers
 
 import (
 	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/ratelimit"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
 	"github.com/GunarsK-portfolio/files-api/internal/storage"
 	commonrepo "github.com/GunarsK-portfolio/portfolio-common/repository"
 )
@@ -12,13 +14,37 @@ type Handler struct {
 	storage       storage.ObjectStore
 	cfg           *config.Config
 	actionLogRepo commonrepo.ActionLogRepository
+	scanner       scanner.Scanner
+	reconciler    Reconciler
+	uploadLimiter ratelimit.Limiter
 }
 
-func New(repo repository.Repository, storage storage.ObjectStore, cfg *config.Config, actionLogRepo commonrepo.ActionLogRepository) *Handler {
-	return &Handler{
+// Option configures optional Handler dependencies
+type Option func(*Handler)
+
+// WithScanner enables antivirus scanning of uploads
+func WithScanner(s scanner.Scanner) Option {
+	return func(h *Handler) {
+		h.scanner = s
+	}
+}
+
+// WithUploadLimiter limits how often each user may start an upload
+func WithUploadLimiter(l ratelimit.Limiter) Option {
+	return func(h *Handler) {
+		h.uploadLimiter = l
+	}
+}
+
+func New(repo repository.Repository, storage storage.ObjectStore, cfg *config.Config, actionLogRepo commonrepo.ActionLogRepository, opts ...Option) *Handler {
+	h := &Handler{
 		repo:          repo,
 		storage:       storage,
 		cfg:           cfg,
 		actionLogRepo: actionLogRepo,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
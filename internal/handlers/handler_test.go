@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"bytes"
 	"strings"
 	"testing"
 )
@@ -31,59 +32,62 @@ func TestNew_ReturnsHandler(t *testing.T) {
 }
 
 // =============================================================================
-// File Type to Bucket Tests
+// File Type Lookup Tests
 // =============================================================================
 
-func TestFileTypeToBucket_PortfolioImage(t *testing.T) {
+func TestFileType_PortfolioImage(t *testing.T) {
 	cfg := createTestConfig()
 	handler := New(&mockRepository{}, nil, cfg, &mockActionLogRepo{})
 
-	bucket, err := handler.fileTypeToBucket("portfolio-image")
+	ft, err := handler.fileType("portfolio-image")
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if bucket != testImagesBucket {
+	if bucket := ft.Bucket; bucket != testImagesBucket {
 		t.Errorf("expected bucket %s, got %s", testImagesBucket, bucket)
 	}
 }
 
-func TestFileTypeToBucket_MiniatureImage(t *testing.T) {
+func TestFileType_MiniatureImage(t *testing.T) {
 	cfg := createTestConfig()
 	handler := New(&mockRepository{}, nil, cfg, &mockActionLogRepo{})
 
-	bucket, err := handler.fileTypeToBucket("miniature-image")
+	ft, err := handler.fileType("miniature-image")
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if bucket != testMiniBucket {
+	if bucket := ft.Bucket; bucket != testMiniBucket {
 		t.Errorf("expected bucket %s, got %s", testMiniBucket, bucket)
 	}
 }
 
-func TestFileTypeToBucket_Document(t *testing.T) {
+func TestFileType_Document(t *testing.T) {
 	cfg := createTestConfig()
 	handler := New(&mockRepository{}, nil, cfg, &mockActionLogRepo{})
 
-	bucket, err := handler.fileTypeToBucket("document")
+	ft, err := handler.fileType("document")
 	if err != nil {
-		t.Errorf("unexpected error: %v", err)
+		t.Fatalf("unexpected error: %v", err)
 	}
-	if bucket != testDocsBucket {
+	if bucket := ft.Bucket; bucket != testDocsBucket {
 		t.Errorf("expected bucket %s, got %s", testDocsBucket, bucket)
 	}
 }
 
-func TestFileTypeToBucket_Invalid(t *testing.T) {
+func TestFileType_Invalid(t *testing.T) {
 	cfg := createTestConfig()
 	handler := New(&mockRepository{}, nil, cfg, &mockActionLogRepo{})
 
-	_, err := handler.fileTypeToBucket("invalid-type")
+	_, err := handler.fileType("invalid-type")
 	if err == nil {
 		t.Error("expected error for invalid file type")
 	}
 	if !strings.Contains(err.Error(), "invalid fileType") {
 		t.Errorf("expected 'invalid fileType' error, got %v", err)
 	}
+	if !strings.Contains(err.Error(), "portfolio-image, miniature-image, document") {
+		t.Errorf("expected error to list the configured file types, got %v", err)
+	}
 }
 
 // =============================================================================
@@ -118,10 +122,10 @@ func TestIsAllowedContentType_ValidImage(t *testing.T) {
 }
 
 // =============================================================================
-// Get Bucket For File Type Tests
+// File Type Content Tests
 // =============================================================================
 
-func TestGetBucketForFileType_ValidCombinations(t *testing.T) {
+func TestFileType_ContentTypeCombinations(t *testing.T) {
 	cfg := createTestConfig()
 	handler := New(&mockRepository{}, nil, cfg, &mockActionLogRepo{})
 
@@ -145,7 +149,11 @@ func TestGetBucketForFileType_ValidCombinations(t *testing.T) {
 	for _, tc := range testCases {
 		name := tc.fileType + "_" + tc.contentType
 		t.Run(name, func(t *testing.T) {
-			bucket, err := handler.getBucketForFileType(tc.fileType, tc.contentType)
+			ft, err := handler.fileType(tc.fileType)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			err = ft.CheckMimeType(tc.contentType)
 			if tc.wantErr {
 				if err == nil {
 					t.Error("expected error, got nil")
@@ -154,10 +162,66 @@ func TestGetBucketForFileType_ValidCombinations(t *testing.T) {
 				if err != nil {
 					t.Errorf("unexpected error: %v", err)
 				}
-				if bucket != tc.wantBucket {
-					t.Errorf("expected bucket %s, got %s", tc.wantBucket, bucket)
+				if ft.Bucket != tc.wantBucket {
+					t.Errorf("expected bucket %s, got %s", tc.wantBucket, ft.Bucket)
 				}
 			}
 		})
 	}
 }
+
+// =============================================================================
+// Content Detection Tests
+// =============================================================================
+
+func TestNormalizeMimeType(t *testing.T) {
+	testCases := []struct {
+		input    string
+		expected string
+	}{
+		{"image/png", "image/png"},
+		{"IMAGE/PNG", "image/png"},
+		{"image/jpg", "image/jpeg"},
+		{"text/plain; charset=utf-8", "text/plain"},
+		{"", ""},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.input, func(t *testing.T) {
+			if got := normalizeMimeType(tc.input); got != tc.expected {
+				t.Errorf("normalizeMimeType(%q) = %q, want %q", tc.input, got, tc.expected)
+			}
+		})
+	}
+}
+
+func TestDetectContentType(t *testing.T) {
+	testCases := []struct {
+		name     string
+		data     []byte
+		expected string
+	}{
+		{"png", testPNGData(), "image/png"},
+		{"jpeg", testJPEGData(), "image/jpeg"},
+		{"pdf", testPDFData, "application/pdf"},
+		{"docx", testDOCXData(), "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+		{"text", []byte("plain text"), "text/plain"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			src := bytes.NewReader(tc.data)
+			got, err := detectContentType(src)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got != tc.expected {
+				t.Errorf("detectContentType() = %q, want %q", got, tc.expected)
+			}
+			// Stream must be rewound for the subsequent upload
+			if src.Len() != len(tc.data) {
+				t.Errorf("expected reader to be rewound, %d bytes remaining", src.Len())
+			}
+		})
+	}
+}
@@ -1,17 +1,27 @@
 package handlers
 
-import "fmt"
+import (
+	"fmt"
+	"strings"
 
-// fileTypeToBucket maps fileType to S3 bucket name using configuration
-func (h *Handler) fileTypeToBucket(fileType string) (string, error) {
-	switch fileType {
-	case "portfolio-image":
-		return h.cfg.ImagesBucket, nil
-	case "miniature-image":
-		return h.cfg.MiniaturesBucket, nil
-	case "document":
-		return h.cfg.DocumentsBucket, nil
-	default:
-		return "", fmt.Errorf("invalid fileType: must be portfolio-image, miniature-image, or document")
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+)
+
+// fileType looks up a file type definition by name
+func (h *Handler) fileType(name string) (*filetypes.FileType, error) {
+	fileType, ok := h.cfg.FileTypes.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("invalid fileType: must be one of %s", h.fileTypeNames())
 	}
+	return fileType, nil
+}
+
+// fileTypeNames lists the configured file types for error messages
+func (h *Handler) fileTypeNames() string {
+	return strings.Join(h.cfg.FileTypes.Names(), ", ")
+}
+
+// isAllowedContentType reports whether any file type accepts contentType
+func (h *Handler) isAllowedContentType(contentType string) bool {
+	return h.cfg.FileTypes.AllowsMimeType(normalizeMimeType(contentType))
 }
@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"hash"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+const (
+	idempotencyKeyHeader = "Idempotency-Key"
+	// idempotentReplayHeader marks responses replayed from an earlier request
+	idempotentReplayHeader  = "Idempotent-Replayed"
+	maxIdempotencyKeyLength = 255
+)
+
+// unreplayedHeaders describe the attempt that stored a response rather than its result
+var unreplayedHeaders = []string{"Date", "Set-Cookie", "X-Ratelimit-Limit", "X-Ratelimit-Remaining"}
+
+// Idempotent lets clients retry a request safely by sending an Idempotency-Key header.
+// The first request with a key runs and its successful response is stored for
+// IdempotencyKeyTTL; retries with the same key and request get that response again
+// instead of running the handler. Reusing a key for a different request is answered
+// with 422, and a retry arriving while the first request still runs with 409. Failed
+// requests are forgotten so they can be retried. Keys belong to the authenticated user
+// and requests without one run as usual. The body is fingerprinted as the handler reads
+// it, so uploads still stream.
+func (h *Handler) Idempotent() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		key := c.GetHeader(idempotencyKeyHeader)
+		userID := audit.GetUserID(c)
+		if key == "" || userID == nil {
+			c.Next()
+			return
+		}
+		if !validIdempotencyKey(key) {
+			c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{
+				"error": "Idempotency-Key must be 1 to 255 printable ASCII characters",
+			})
+			return
+		}
+
+		now := time.Now()
+		record := &repository.IdempotencyKey{
+			UserID:      *userID,
+			Key:         key,
+			LockedUntil: now.Add(h.cfg.IdempotencyLockTimeout),
+			ExpiresAt:   now.Add(h.cfg.IdempotencyKeyTTL),
+		}
+		claimed, err := h.repo.ClaimIdempotencyKey(c.Request.Context(), record, now)
+		if err != nil {
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to check idempotency key")
+			c.Abort()
+			return
+		}
+		if !claimed {
+			h.replayIdempotent(c, record)
+			return
+		}
+		h.runIdempotent(c, record)
+	}
+}
+
+// runIdempotent runs the rest of the chain for a claimed key and stores a successful
+// response. The key is released when the request fails, including by panicking.
+func (h *Handler) runIdempotent(c *gin.Context, record *repository.IdempotencyKey) {
+	// Stored and released even when the client went away
+	ctx := context.WithoutCancel(c.Request.Context())
+	body := newBodyFingerprint(c.Request)
+	c.Request.Body = body
+	stored := false
+	defer func() {
+		body.close()
+		if stored {
+			return
+		}
+		if err := h.repo.ReleaseIdempotencyKey(ctx, record); err != nil {
+			logger.GetLogger(c).Error("Failed to release idempotency key", "error", err)
+		}
+	}()
+
+	preset := make(map[string]bool)
+	for name := range c.Writer.Header() {
+		preset[name] = true
+	}
+	recorder := &responseRecorder{ResponseWriter: c.Writer}
+	c.Writer = recorder
+
+	c.Next()
+
+	status := recorder.Status()
+	if status >= http.StatusBadRequest {
+		return
+	}
+	record.ResponseStatus = status
+	record.ResponseHeaders = repository.ResponseHeaders{}
+	for name, values := range recorder.Header() {
+		if !preset[name] && !containsHeader(unreplayedHeaders, name) {
+			record.ResponseHeaders[name] = values
+		}
+	}
+	record.ResponseBody = recorder.body.Bytes()
+	// What the handler left unread is part of the request too
+	digest, err := body.finish()
+	if err != nil {
+		// A retry then counts as a different request rather than running again
+		logger.GetLogger(c).Warn("Failed to read the rest of an idempotent request body", "error", err)
+	}
+	record.Fingerprint = requestFingerprint(c.Request, digest)
+	if err := h.repo.CompleteIdempotencyKey(ctx, record); err != nil {
+		logger.GetLogger(c).Error("Failed to store idempotent response", "error", err)
+		return
+	}
+	stored = true
+}
+
+// replayIdempotent answers a request whose key is already held with the stored
+// response, or explains why it cannot be replayed
+func (h *Handler) replayIdempotent(c *gin.Context, record *repository.IdempotencyKey) {
+	existing, err := h.repo.GetIdempotencyKey(c.Request.Context(), record.UserID, record.Key)
+	// A key released since the claim belongs to a request that just failed
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		existing = &repository.IdempotencyKey{Status: repository.IdempotencyStatusProcessing}
+	} else if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to check idempotency key")
+		c.Abort()
+		return
+	}
+	// The first request's fingerprint is only known once it completed
+	if !existing.IsCompleted() {
+		c.Header("Retry-After", "1")
+		c.AbortWithStatusJSON(http.StatusConflict, gin.H{
+			"error": "a request with this Idempotency-Key is still being processed",
+		})
+		return
+	}
+
+	body := newBodyFingerprint(c.Request)
+	defer body.close()
+	digest, err := body.finish()
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "failed to read request body"})
+		return
+	}
+
+	if existing.Fingerprint != requestFingerprint(c.Request, digest) {
+		c.AbortWithStatusJSON(http.StatusUnprocessableEntity, gin.H{
+			"error": "Idempotency-Key was already used for a different request",
+		})
+		return
+	}
+
+	for name, values := range existing.ResponseHeaders {
+		c.Writer.Header()[name] = values
+	}
+	c.Header(idempotentReplayHeader, "true")
+	c.Writer.Header().Add("Access-Control-Expose-Headers", idempotentReplayHeader)
+	c.Data(existing.ResponseStatus, http.Header(existing.ResponseHeaders).Get("Content-Type"), existing.ResponseBody)
+	c.Abort()
+}
+
+// validIdempotencyKey accepts 1 to 255 printable ASCII characters
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] < 0x20 || key[i] > 0x7e {
+			return false
+		}
+	}
+	return true
+}
+
+func containsHeader(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// bodyFingerprint hashes a request body as it is read. Multipart bodies are also
+// hashed part by part, through a pipe to a parser running alongside, since clients
+// choose a new boundary for every attempt.
+type bodyFingerprint struct {
+	body  io.ReadCloser
+	raw   hash.Hash
+	pipe  *io.PipeWriter
+	parts chan []byte
+}
+
+func newBodyFingerprint(r *http.Request) *bodyFingerprint {
+	f := &bodyFingerprint{body: r.Body, raw: sha256.New()}
+	if f.body == nil {
+		f.body = http.NoBody
+	}
+	mediaType, params, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if strings.HasPrefix(mediaType, "multipart/") && params["boundary"] != "" {
+		reader, writer := io.Pipe()
+		f.pipe = writer
+		f.parts = make(chan []byte, 1)
+		go func() {
+			parts, ok := fingerprintMultipart(reader, params["boundary"])
+			// Keep taking what is read, so the request never waits on the parser
+			_, _ = io.Copy(io.Discard, reader)
+			if !ok {
+				parts = nil
+			}
+			f.parts <- parts
+		}()
+	}
+	return f
+}
+
+func (f *bodyFingerprint) Read(p []byte) (int, error) {
+	n, err := f.body.Read(p)
+	if n > 0 {
+		f.raw.Write(p[:n])
+		if f.pipe != nil {
+			_, _ = f.pipe.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// Close leaves the request body to the server, which closes it after the request
+func (f *bodyFingerprint) Close() error {
+	return nil
+}
+
+// finish reads the rest of the body and returns its digest: the part by part digest
+// of a well-formed multipart body, otherwise the digest of its bytes
+func (f *bodyFingerprint) finish() ([]byte, error) {
+	_, err := io.Copy(io.Discard, f)
+	if f.pipe != nil {
+		_ = f.pipe.Close()
+		if parts := <-f.parts; parts != nil && err == nil {
+			return parts, nil
+		}
+	}
+	return f.raw.Sum(nil), err
+}
+
+// close stops the multipart parser of a body that was not finished
+func (f *bodyFingerprint) close() {
+	if f.pipe != nil {
+		_ = f.pipe.CloseWithError(io.ErrUnexpectedEOF)
+	}
+}
+
+// requestFingerprint hashes what makes two requests the same: method, path, query,
+// content type and the digest of the body
+func requestFingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	writeFingerprintField(h, []byte(r.Method))
+	writeFingerprintField(h, []byte(r.URL.Path))
+	writeFingerprintField(h, []byte(r.URL.RawQuery))
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	writeFingerprintField(h, []byte(mediaType))
+	writeFingerprintField(h, body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// fingerprintMultipart hashes the name, filename, content type and content of every
+// part, reporting false for a malformed body
+func fingerprintMultipart(body io.Reader, boundary string) ([]byte, bool) {
+	if boundary == "" {
+		return nil, false
+	}
+	h := sha256.New()
+	reader := multipart.NewReader(body, boundary)
+	for {
+		part, err := reader.NextRawPart()
+		if errors.Is(err, io.EOF) {
+			return h.Sum(nil), true
+		}
+		if err != nil {
+			return nil, false
+		}
+		content := sha256.New()
+		if _, err := io.Copy(content, part); err != nil {
+			return nil, false
+		}
+		writeFingerprintField(h, []byte(part.FormName()))
+		writeFingerprintField(h, []byte(part.FileName()))
+		writeFingerprintField(h, []byte(part.Header.Get("Content-Type")))
+		writeFingerprintField(h, content.Sum(nil))
+	}
+}
+
+// writeFingerprintField writes a length-prefixed field, so adjacent fields cannot be
+// shifted into each other
+func writeFingerprintField(h hash.Hash, field []byte) {
+	_ = binary.Write(h, binary.BigEndian, uint64(len(field)))
+	h.Write(field)
+}
+
+// responseRecorder keeps a copy of the response body written through it
+type responseRecorder struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseRecorder) WriteString(s string) (int, error) {
+	w.body.WriteString(s)
+	return w.ResponseWriter.WriteString(s)
+}
@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// idempotencyStore keeps idempotency keys in memory with the claim rules of the
+// repository
+type idempotencyStore struct {
+	mu       sync.Mutex
+	keys     map[string]repository.IdempotencyKey
+	released int
+}
+
+// withIdempotencyStore backs the idempotency methods of repo with a new store
+func withIdempotencyStore(repo *mockRepository) *idempotencyStore {
+	s := &idempotencyStore{keys: make(map[string]repository.IdempotencyKey)}
+	repo.claimIdempotencyKeyFunc = func(_ context.Context, key *repository.IdempotencyKey, now time.Time) (bool, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if existing, ok := s.keys[key.Key]; ok && existing.ExpiresAt.After(now) &&
+			(existing.IsCompleted() || existing.LockedUntil.After(now)) {
+			return false, nil
+		}
+		key.Status = repository.IdempotencyStatusProcessing
+		s.keys[key.Key] = *key
+		return true, nil
+	}
+	repo.getIdempotencyKeyFunc = func(_ context.Context, _ int64, key string) (*repository.IdempotencyKey, error) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		existing, ok := s.keys[key]
+		if !ok {
+			return nil, gorm.ErrRecordNotFound
+		}
+		return &existing, nil
+	}
+	repo.completeIdempotencyKeyFunc = func(_ context.Context, key *repository.IdempotencyKey) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.holds(key) {
+			return nil
+		}
+		key.Status = repository.IdempotencyStatusCompleted
+		s.keys[key.Key] = *key
+		return nil
+	}
+	repo.releaseIdempotencyKeyFunc = func(_ context.Context, key *repository.IdempotencyKey) error {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if !s.holds(key) {
+			return nil
+		}
+		delete(s.keys, key.Key)
+		s.released++
+		return nil
+	}
+	return s
+}
+
+// holds reports whether key is still the claim stored for it
+func (s *idempotencyStore) holds(key *repository.IdempotencyKey) bool {
+	existing, ok := s.keys[key.Key]
+	return ok && !existing.IsCompleted() && existing.LockedUntil.Equal(key.LockedUntil)
+}
+
+// setupIdempotentRouter serves POST /api/v1/items through the idempotency middleware,
+// answering with status and counting how often the handler ran
+func setupIdempotentRouter(repo *mockRepository, status int, runs *int) *gin.Engine {
+	cfg := createTestConfig()
+	cfg.IdempotencyKeyTTL = time.Hour
+	cfg.IdempotencyLockTimeout = time.Minute
+	handler := New(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/items", asUser(testUserID, common.LevelEdit), handler.Idempotent(), func(c *gin.Context) {
+		*runs++
+		c.Header("Location", "/api/v1/items/1")
+		c.JSON(status, gin.H{"run": *runs})
+	})
+	return router
+}
+
+func idempotentHeaders(key string) map[string]string {
+	return map[string]string{"Content-Type": "application/json", idempotencyKeyHeader: key}
+}
+
+// =============================================================================
+// Idempotency Tests
+// =============================================================================
+
+func TestIdempotent_ReplaysCompletedResponse(t *testing.T) {
+	repo := &mockRepository{}
+	withIdempotencyStore(repo)
+	var runs int
+	router := setupIdempotentRouter(repo, http.StatusCreated, &runs)
+
+	first := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{"name":"a"}`), idempotentHeaders("key-1"))
+	retry := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{"name":"a"}`), idempotentHeaders("key-1"))
+
+	if runs != 1 {
+		t.Errorf("expected handler to run once, ran %d times", runs)
+	}
+	if retry.Code != http.StatusCreated || retry.Body.String() != first.Body.String() {
+		t.Errorf("expected replay of %d %s, got %d %s", first.Code, first.Body.String(), retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Location") != "/api/v1/items/1" {
+		t.Errorf("expected Location header to be replayed, got %q", retry.Header().Get("Location"))
+	}
+	if first.Header().Get(idempotentReplayHeader) != "" || retry.Header().Get(idempotentReplayHeader) != "true" {
+		t.Errorf("expected only the retry to be marked replayed, got %q and %q",
+			first.Header().Get(idempotentReplayHeader), retry.Header().Get(idempotentReplayHeader))
+	}
+}
+
+func TestIdempotent_DifferentRequestWithSameKey(t *testing.T) {
+	repo := &mockRepository{}
+	withIdempotencyStore(repo)
+	var runs int
+	router := setupIdempotentRouter(repo, http.StatusCreated, &runs)
+
+	performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{"name":"a"}`), idempotentHeaders("key-1"))
+	w := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{"name":"b"}`), idempotentHeaders("key-1"))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	if runs != 1 {
+		t.Errorf("expected handler to run once, ran %d times", runs)
+	}
+}
+
+func TestIdempotent_RequestInProgress(t *testing.T) {
+	repo := &mockRepository{}
+	store := withIdempotencyStore(repo)
+	var runs int
+	router := setupIdempotentRouter(repo, http.StatusCreated, &runs)
+
+	// Put the key back to processing, as if the first request were still running
+	body := `{"name":"a"}`
+	first := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(body), idempotentHeaders("key-1"))
+	inFlight := store.keys["key-1"]
+	inFlight.Status = repository.IdempotencyStatusProcessing
+	store.keys["key-1"] = inFlight
+
+	w := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(body), idempotentHeaders("key-1"))
+
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected first request to succeed, got %d", first.Code)
+	}
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Retry-After") == "" {
+		t.Error("expected Retry-After header")
+	}
+	if runs != 1 {
+		t.Errorf("expected handler to run once, ran %d times", runs)
+	}
+}
+
+func TestIdempotent_FailedRequestCanBeRetried(t *testing.T) {
+	repo := &mockRepository{}
+	store := withIdempotencyStore(repo)
+	var runs int
+	router := setupIdempotentRouter(repo, http.StatusInternalServerError, &runs)
+
+	for i := 0; i < 2; i++ {
+		w := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{}`), idempotentHeaders("key-1"))
+		if w.Code != http.StatusInternalServerError || w.Header().Get(idempotentReplayHeader) != "" {
+			t.Errorf("attempt %d: expected handler error, got %d replayed=%q", i+1, w.Code, w.Header().Get(idempotentReplayHeader))
+		}
+	}
+	if runs != 2 {
+		t.Errorf("expected handler to run for each attempt, ran %d times", runs)
+	}
+	if store.released != 2 || len(store.keys) != 0 {
+		t.Errorf("expected key released after each failure, released %d with %d left", store.released, len(store.keys))
+	}
+}
+
+func TestIdempotent_WithoutKey(t *testing.T) {
+	repo := &mockRepository{
+		claimIdempotencyKeyFunc: func(_ context.Context, _ *repository.IdempotencyKey, _ time.Time) (bool, error) {
+			t.Error("expected no key to be claimed without an Idempotency-Key header")
+			return true, nil
+		},
+	}
+	var runs int
+	router := setupIdempotentRouter(repo, http.StatusCreated, &runs)
+
+	for i := 0; i < 2; i++ {
+		performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{}`))
+	}
+	if runs != 2 {
+		t.Errorf("expected handler to run for each request, ran %d times", runs)
+	}
+}
+
+func TestIdempotent_InvalidKey(t *testing.T) {
+	tests := []struct {
+		name string
+		key  string
+	}{
+		{"too long", strings.Repeat("k", maxIdempotencyKeyLength+1)},
+		{"non-ASCII", "clé"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var runs int
+			router := setupIdempotentRouter(&mockRepository{}, http.StatusCreated, &runs)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{}`), idempotentHeaders(tt.key))
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if runs != 0 {
+				t.Error("expected handler not to run")
+			}
+		})
+	}
+}
+
+func TestIdempotent_UploadRetryWithNewBoundary(t *testing.T) {
+	var created int
+	repo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			created++
+			file.ID = int64(created)
+			return nil
+		},
+	}
+	withIdempotencyStore(repo)
+	cfg := createTestConfig()
+	cfg.IdempotencyKeyTTL = time.Hour
+	cfg.IdempotencyLockTimeout = time.Minute
+	handler := New(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.Idempotent(), handler.UploadFile)
+
+	var codes []int
+	var boundaries []string
+	for i := 0; i < 2; i++ {
+		// Every form gets a new random boundary, as browsers do for each attempt
+		req, w, err := createMultipartRequest(testFileName, testMimeType, testFileType, testPNGData())
+		if err != nil {
+			t.Fatalf("failed to create request: %v", err)
+		}
+		req.Header.Set(idempotencyKeyHeader, "upload-1")
+		router.ServeHTTP(w, req)
+		codes = append(codes, w.Code)
+		boundaries = append(boundaries, req.Header.Get("Content-Type"))
+	}
+
+	if boundaries[0] == boundaries[1] {
+		t.Fatal("expected attempts to use different multipart boundaries")
+	}
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Errorf("expected both attempts to succeed, got %v", codes)
+	}
+	if created != 1 {
+		t.Errorf("expected one file record, got %d", created)
+	}
+}
+
+// countingReader counts the bytes read from it
+type countingReader struct {
+	io.Reader
+	read int
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.Reader.Read(p)
+	r.read += n
+	return n, err
+}
+
+func TestIdempotent_StreamsBodyToHandler(t *testing.T) {
+	repo := &mockRepository{}
+	withIdempotencyStore(repo)
+	claim := repo.claimIdempotencyKeyFunc
+	body := &countingReader{Reader: strings.NewReader(`{"name":"a"}`)}
+	var claims int
+	repo.claimIdempotencyKeyFunc = func(ctx context.Context, key *repository.IdempotencyKey, now time.Time) (bool, error) {
+		if claims++; claims == 1 && body.read != 0 {
+			t.Errorf("expected the body to be unread when the key is claimed, %d bytes were read", body.read)
+		}
+		return claim(ctx, key, now)
+	}
+	cfg := createTestConfig()
+	cfg.IdempotencyKeyTTL = time.Hour
+	cfg.IdempotencyLockTimeout = time.Minute
+	handler := New(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	var got string
+	router.POST("/api/v1/items", asUser(testUserID, common.LevelEdit), handler.Idempotent(), func(c *gin.Context) {
+		data, _ := io.ReadAll(c.Request.Body)
+		got = string(data)
+		c.Status(http.StatusCreated)
+	})
+
+	w := performRequest(router, http.MethodPost, "/api/v1/items", body, idempotentHeaders("key-1"))
+	retry := performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{"name":"b"}`), idempotentHeaders("key-1"))
+
+	if w.Code != http.StatusCreated || got != `{"name":"a"}` {
+		t.Errorf("expected handler to read the whole body, got %d %q", w.Code, got)
+	}
+	if retry.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected a different body to be refused with %d, got %d", http.StatusUnprocessableEntity, retry.Code)
+	}
+}
+
+func TestIdempotent_TakenOverKeyIsLeftToItsNewClaim(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+	}{
+		{"failed request does not release it", http.StatusInternalServerError},
+		{"successful request does not complete it", http.StatusCreated},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			repo := &mockRepository{}
+			store := withIdempotencyStore(repo)
+			cfg := createTestConfig()
+			cfg.IdempotencyKeyTTL = time.Hour
+			cfg.IdempotencyLockTimeout = time.Minute
+			handler := New(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
+			router := setupTestRouter()
+			retryClaim := repository.IdempotencyKey{
+				UserID:      testUserID,
+				Key:         "key-1",
+				Status:      repository.IdempotencyStatusProcessing,
+				LockedUntil: time.Now().Add(2 * time.Minute),
+				ExpiresAt:   time.Now().Add(time.Hour),
+			}
+			router.POST("/api/v1/items", asUser(testUserID, common.LevelEdit), handler.Idempotent(), func(c *gin.Context) {
+				// The lock ran out and a retry took the key over while this request ran
+				store.mu.Lock()
+				store.keys["key-1"] = retryClaim
+				store.mu.Unlock()
+				c.Status(tt.status)
+			})
+
+			performRequest(router, http.MethodPost, "/api/v1/items", strings.NewReader(`{}`), idempotentHeaders("key-1"))
+
+			got, ok := store.keys["key-1"]
+			if !ok || got.IsCompleted() || !got.LockedUntil.Equal(retryClaim.LockedUntil) {
+				t.Errorf("expected the retry's claim to be kept, got %+v (present %v)", got, ok)
+			}
+		})
+	}
+}
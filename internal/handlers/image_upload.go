@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// shouldSanitize reports whether uploads of fileType have their image metadata stripped
+func (h *Handler) shouldSanitize(fileType, contentType string) bool {
+	ft, ok := h.cfg.FileTypes.Get(fileType)
+	return images.CanSanitize(contentType) && !(ok && ft.KeepMetadata)
+}
+
+func (h *Handler) imageLimits() images.Limits {
+	return images.Limits{
+		MaxWidth:  h.cfg.ImageMaxWidth,
+		MaxHeight: h.cfg.ImageMaxHeight,
+		MaxPixels: h.cfg.ImageMaxPixels,
+	}
+}
+
+func toImageInfo(info images.Info) *repository.ImageInfo {
+	return &repository.ImageInfo{Width: info.Width, Height: info.Height, Format: info.Format}
+}
+
+// processImage checks an image's dimensions against the configured limits and
+// strips its metadata unless the file type keeps it. It returns the content to
+// store and the properties of that content.
+func (h *Handler) processImage(data []byte, fileType, contentType string) ([]byte, *repository.ImageInfo, error) {
+	info, err := images.Inspect(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := h.imageLimits().Check(info); err != nil {
+		return nil, nil, err
+	}
+
+	if h.shouldSanitize(fileType, contentType) {
+		if data, err = images.Sanitize(data, contentType); err != nil {
+			return nil, nil, err
+		}
+		// Applying the EXIF orientation can swap width and height
+		if info, err = images.Inspect(data); err != nil {
+			return nil, nil, err
+		}
+	}
+	return data, toImageInfo(info), nil
+}
+
+// processUpload runs processImage on an upload before it is stored, returning the
+// content and size to store. Files that are not images are returned unchanged
+// with nil image info.
+func (h *Handler) processUpload(src io.ReadSeeker, size int64, fileType, contentType string) (io.ReadSeeker, int64, *repository.ImageInfo, error) {
+	if !images.CanInspect(contentType) {
+		return src, size, nil, nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(src, h.cfg.FileTypes.MaxSize()+1))
+	if err != nil {
+		return nil, 0, nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	clean, info, err := h.processImage(data, fileType, contentType)
+	if err != nil {
+		return nil, 0, nil, err
+	}
+	return bytes.NewReader(clean), int64(len(clean)), info, nil
+}
+
+// processStoredUpload runs processImage on an object uploaded by tus or a presigned
+// URL. A sanitized image is rewritten in place and the session length updated. It
+// returns the SHA-256 of the stored content; objects that are not images are streamed
+// through the hash.
+func (h *Handler) processStoredUpload(c *gin.Context, session *repository.UploadSession) (*repository.ImageInfo, string, error) {
+	reader, err := h.storage.GetObject(c.Request.Context(), session.S3Bucket, session.S3Key)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	defer reader.Close()
+
+	if !images.CanInspect(session.MimeType) {
+		hash := sha256.New()
+		if _, err := io.Copy(hash, reader); err != nil {
+			return nil, "", fmt.Errorf("failed to hash upload: %w", err)
+		}
+		return nil, hex.EncodeToString(hash.Sum(nil)), nil
+	}
+
+	data, err := io.ReadAll(io.LimitReader(reader, session.Length+1))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read upload: %w", err)
+	}
+	clean, info, err := h.processImage(data, session.FileType, session.MimeType)
+	if err != nil {
+		return nil, "", err
+	}
+	sum := sha256.Sum256(clean)
+	if bytes.Equal(clean, data) {
+		return info, hex.EncodeToString(sum[:]), nil
+	}
+
+	if err := h.storage.PutObject(c.Request.Context(), session.S3Bucket, session.S3Key, bytes.NewReader(clean), int64(len(clean)), session.MimeType); err != nil {
+		return nil, "", fmt.Errorf("failed to store sanitized upload: %w", err)
+	}
+	session.Length = int64(len(clean))
+	return info, hex.EncodeToString(sum[:]), nil
+}
+
+// respondImageError answers a failed image check or sanitization step
+func respondImageError(c *gin.Context, err error) {
+	respondUploadError(c, imageError(err))
+}
+
+func imageError(err error) *uploadError {
+	switch {
+	case errors.Is(err, images.ErrImageTooLarge):
+		return newUploadError(http.StatusUnprocessableEntity, "image dimensions too large")
+	case errors.Is(err, images.ErrMalformedImage):
+		return newUploadError(http.StatusBadRequest, "invalid image file")
+	default:
+		return loggedUploadError(http.StatusInternalServerError, err, "failed to process image")
+	}
+}
+
+// fileURL is the public download URL of a stored file
+func fileURL(file *repository.StorageFile) string {
+	return fmt.Sprintf("/api/v1/files/%s/%s", file.FileType, file.S3Key)
+}
+
+// versionURL is the download URL pinned to the file's current content version
+func versionURL(file *repository.StorageFile) string {
+	return fmt.Sprintf("%s?v=%d", fileURL(file), file.ContentVersion)
+}
+
+// fileResponse is the JSON body returned for a newly created file
+func fileResponse(file *repository.StorageFile) gin.H {
+	response := gin.H{
+		"id":         file.ID,
+		"fileName":   file.FileName,
+		"fileSize":   file.FileSize,
+		"mimeType":   file.MimeType,
+		"url":        fileURL(file),
+		"fileType":   file.FileType,
+		"visibility": file.Visibility,
+	}
+	if file.Width != nil && file.Height != nil {
+		response["width"] = *file.Width
+		response["height"] = *file.Height
+	}
+	if file.ImageFormat != nil {
+		response["imageFormat"] = *file.ImageFormat
+	}
+	if file.ScanStatus != nil {
+		response["scanStatus"] = *file.ScanStatus
+	}
+	if file.ContentHash != nil {
+		response["sha256"] = *file.ContentHash
+	}
+	if file.UploadMD5 != nil {
+		response["uploadMd5"] = *file.UploadMD5
+	}
+	if file.UploadSHA256 != nil {
+		response["uploadSha256"] = *file.UploadSHA256
+	}
+	if file.UploadedBy != nil {
+		response["uploadedBy"] = *file.UploadedBy
+	}
+	return response
+}
@@ -0,0 +1,285 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"slices"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+const testMetadataSecret = "GPS 56.9496N 24.1052E"
+
+// testJPEGWithComment returns a JPEG carrying a comment segment with testMetadataSecret
+func testJPEGWithComment() []byte {
+	plain := testJPEGData()
+	comment := []byte{0xFF, 0xFE, 0, byte(len(testMetadataSecret) + 2)}
+	out := append([]byte{}, plain[:2]...)
+	out = append(out, comment...)
+	out = append(out, testMetadataSecret...)
+	return append(out, plain[2:]...)
+}
+
+// uploadCapture records what the upload handler stores and records
+type uploadCapture struct {
+	stored   []byte
+	fileSize int64
+	record   *repository.StorageFile
+}
+
+func (u *uploadCapture) handler(keepMetadata ...string) *Handler {
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			u.fileSize = file.FileSize
+			u.record = file
+			file.ID = 1
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, _ string, reader io.Reader, size int64, _ string) error {
+			data, err := io.ReadAll(reader)
+			if err != nil {
+				return err
+			}
+			if int64(len(data)) != size {
+				return io.ErrShortWrite
+			}
+			u.stored = data
+			return nil
+		},
+	}
+	types := testFileTypes()
+	for i := range types {
+		types[i].KeepMetadata = slices.Contains(keepMetadata, types[i].Name)
+	}
+	cfg := createTestConfig()
+	cfg.FileTypes = newTestRegistry(types)
+	return New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+}
+
+// =============================================================================
+// Metadata Sanitization Tests
+// =============================================================================
+
+func TestUploadFile_StripsImageMetadata(t *testing.T) {
+	capture := &uploadCapture{}
+	router := setupTestRouter()
+	router.POST("/api/v1/files", capture.handler().UploadFile)
+
+	req, w, err := createMultipartRequest("photo.jpg", "image/jpeg", "miniature-image", testJPEGWithComment())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !bytes.Equal(capture.stored, testJPEGData()) {
+		t.Error("expected metadata to be stripped from the stored image")
+	}
+	if capture.fileSize != int64(len(capture.stored)) {
+		t.Errorf("expected recorded size %d to match stored size %d", capture.fileSize, len(capture.stored))
+	}
+}
+
+func TestUploadFile_KeepImageMetadata(t *testing.T) {
+	capture := &uploadCapture{}
+	router := setupTestRouter()
+	router.POST("/api/v1/files", capture.handler("miniature-image").UploadFile)
+
+	original := testJPEGWithComment()
+	req, w, err := createMultipartRequest("photo.jpg", "image/jpeg", "miniature-image", original)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !bytes.Equal(capture.stored, original) {
+		t.Error("expected image to be stored unchanged when its file type keeps metadata")
+	}
+}
+
+func TestUploadFile_MalformedImage_Rejected(t *testing.T) {
+	capture := &uploadCapture{}
+	router := setupTestRouter()
+	router.POST("/api/v1/files", capture.handler().UploadFile)
+
+	// A valid signature followed by a chunk claiming more data than the file holds
+	malformed := append([]byte("\x89PNG\r\n\x1a\n"), 0, 0, 1, 0, 'I', 'H', 'D', 'R')
+	req, w, err := createMultipartRequest("broken.png", "image/png", "portfolio-image", malformed)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+	if capture.stored != nil {
+		t.Error("expected nothing to be stored")
+	}
+}
+
+func TestCompletePresignedUpload_StripsImageMetadata(t *testing.T) {
+	content := testJPEGWithComment()
+	session := createTestPresignedSession(int64(len(content)))
+	session.MimeType = "image/jpeg"
+	var rewritten []byte
+	var completedLength int64
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+			completedLength = file.FileSize
+			file.ID = 42
+			return nil
+		},
+	}
+	mockStore := presignedStore(content)
+	mockStore.putObjectFunc = func(_ context.Context, _, key string, reader io.Reader, _ int64, _ string) error {
+		if key != session.S3Key {
+			t.Errorf("expected upload to be rewritten in place, got key %s", key)
+		}
+		data, err := io.ReadAll(reader)
+		rewritten = data
+		return err
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !bytes.Equal(rewritten, testJPEGData()) {
+		t.Error("expected stored object to be rewritten without metadata")
+	}
+	if completedLength != int64(len(rewritten)) {
+		t.Errorf("expected recorded size %d, got %d", len(rewritten), completedLength)
+	}
+}
+
+// =============================================================================
+// Image Dimension Tests
+// =============================================================================
+
+func TestUploadFile_RecordsImageDimensions(t *testing.T) {
+	capture := &uploadCapture{}
+	router := setupTestRouter()
+	router.POST("/api/v1/files", capture.handler().UploadFile)
+
+	req, w, err := createMultipartRequest("photo.png", "image/png", "miniature-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	record := capture.record
+	if record == nil || record.Width == nil || record.Height == nil || record.ImageFormat == nil {
+		t.Fatalf("expected image properties to be recorded, got %+v", record)
+	}
+	if *record.Width != 4 || *record.Height != 4 || *record.ImageFormat != "png" {
+		t.Errorf("expected 4x4 png, got %dx%d %s", *record.Width, *record.Height, *record.ImageFormat)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["width"] != float64(4) || response["height"] != float64(4) || response["imageFormat"] != "png" {
+		t.Errorf("expected image properties in response, got %v", response)
+	}
+}
+
+func TestUploadFile_OversizedImage_Rejected(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits func(h *Handler)
+	}{
+		{"width", func(h *Handler) { h.cfg.ImageMaxWidth = 3 }},
+		{"height", func(h *Handler) { h.cfg.ImageMaxHeight = 3 }},
+		{"pixels", func(h *Handler) { h.cfg.ImageMaxPixels = 15 }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			capture := &uploadCapture{}
+			handler := capture.handler()
+			tt.limits(handler)
+			router := setupTestRouter()
+			router.POST("/api/v1/files", handler.UploadFile)
+
+			req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testPNGData())
+			if err != nil {
+				t.Fatalf("failed to create request: %v", err)
+			}
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			}
+			if capture.stored != nil || capture.record != nil {
+				t.Error("expected nothing to be stored")
+			}
+		})
+	}
+}
+
+func TestCompletePresignedUpload_RecordsImageDimensions(t *testing.T) {
+	content := testPNGData()
+	session := createTestPresignedSession(int64(len(content)))
+	var recorded *repository.StorageFile
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+			recorded = file
+			file.ID = 42
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, presignedStore(content), createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if recorded == nil || recorded.Width == nil || recorded.Height == nil || recorded.ImageFormat == nil {
+		t.Fatalf("expected image properties to be recorded, got %+v", recorded)
+	}
+	if *recorded.Width != 4 || *recorded.Height != 4 || *recorded.ImageFormat != "png" {
+		t.Errorf("expected 4x4 png to be recorded, got %dx%d %s", *recorded.Width, *recorded.Height, *recorded.ImageFormat)
+	}
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["width"] != float64(4) || response["imageFormat"] != "png" {
+		t.Errorf("expected image properties in response, got %v", response)
+	}
+}
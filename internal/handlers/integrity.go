@@ -0,0 +1,74 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// maxListedCorruptedFiles caps the corrupted files listed in the integrity report
+const maxListedCorruptedFiles = 100
+
+// integrityReport summarises the integrity scrubber's findings
+type integrityReport struct {
+	repository.IntegrityStats
+	CorruptedFiles []corruptedFile `json:"corruptedFiles"`
+}
+
+// corruptedFile is a file whose object did not match its SHA-256 when last verified
+type corruptedFile struct {
+	ID          int64      `json:"id"`
+	FileName    string     `json:"fileName"`
+	FileType    string     `json:"fileType"`
+	Bucket      string     `json:"bucket"`
+	Key         string     `json:"key"`
+	SHA256      *string    `json:"sha256"`
+	URL         string     `json:"url"`
+	VerifiedAt  *time.Time `json:"verifiedAt"`
+	CorruptedAt *time.Time `json:"corruptedAt"`
+}
+
+// GetIntegrityReport godoc
+// @Summary Get the storage integrity report
+// @Description Counts of active files, files the integrity scrubber has verified and files whose object
+// @Description did not match its SHA-256, with up to 100 corrupted files, most recently found first.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} integrityReport
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/integrity [get]
+func (h *Handler) GetIntegrityReport(c *gin.Context) {
+	stats, err := h.repo.GetIntegrityStats(c.Request.Context())
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to load integrity report")
+		return
+	}
+	files, err := h.repo.ListCorruptedFiles(c.Request.Context(), maxListedCorruptedFiles)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to load integrity report")
+		return
+	}
+
+	report := integrityReport{IntegrityStats: stats, CorruptedFiles: make([]corruptedFile, len(files))}
+	for i := range files {
+		file := &files[i]
+		report.CorruptedFiles[i] = corruptedFile{
+			ID:          file.ID,
+			FileName:    file.FileName,
+			FileType:    file.FileType,
+			Bucket:      file.S3Bucket,
+			Key:         file.ObjectKey(),
+			SHA256:      file.ContentHash,
+			URL:         fileURL(file),
+			VerifiedAt:  file.VerifiedAt,
+			CorruptedAt: file.CorruptedAt,
+		}
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func setupIntegrityRouter(mockRepo *mockRepository) *gin.Engine {
+	handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/admin/integrity", handler.GetIntegrityReport)
+	return router
+}
+
+// =============================================================================
+// Integrity Report Tests
+// =============================================================================
+
+func TestGetIntegrityReport(t *testing.T) {
+	corruptedAt := time.Now()
+	mockRepo := &mockRepository{
+		getIntegrityStatsFunc: func(_ context.Context) (repository.IntegrityStats, error) {
+			return repository.IntegrityStats{Files: 10, Verified: 8, Corrupted: 1}, nil
+		},
+		listCorruptedFilesFunc: func(_ context.Context, limit int) ([]repository.StorageFile, error) {
+			if limit != maxListedCorruptedFiles {
+				t.Errorf("expected limit %d, got %d", maxListedCorruptedFiles, limit)
+			}
+			file := createTestFile()
+			file.ContentHash = stringPtr("abc123")
+			file.CorruptedAt = &corruptedAt
+			return []repository.StorageFile{*file}, nil
+		},
+	}
+
+	w := performRequest(setupIntegrityRouter(mockRepo), http.MethodGet, "/api/v1/admin/integrity", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var report struct {
+		Files          int64                    `json:"files"`
+		Verified       int64                    `json:"verified"`
+		Corrupted      int64                    `json:"corrupted"`
+		CorruptedFiles []map[string]interface{} `json:"corruptedFiles"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	if report.Files != 10 || report.Verified != 8 || report.Corrupted != 1 || len(report.CorruptedFiles) != 1 {
+		t.Fatalf("unexpected report %s", w.Body.String())
+	}
+	file := report.CorruptedFiles[0]
+	if file["key"] != testFileKey || file["bucket"] != testBucket || file["sha256"] != "abc123" || file["corruptedAt"] == nil {
+		t.Errorf("unexpected corrupted file %v", file)
+	}
+}
+
+func TestGetIntegrityReport_RepositoryError(t *testing.T) {
+	mockRepo := &mockRepository{
+		getIntegrityStatsFunc: func(_ context.Context) (repository.IntegrityStats, error) {
+			return repository.IntegrityStats{}, errors.New("database error")
+		},
+	}
+
+	w := performRequest(setupIntegrityRouter(mockRepo), http.MethodGet, "/api/v1/admin/integrity", nil)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// defaultListLimit is the page size used when the client does not ask for one
+const defaultListLimit = 50
+
+// ListFilesRequest holds the query parameters of a file listing
+type ListFilesRequest struct {
+	FileType    string     `form:"fileType"`
+	MimeType    string     `form:"mimeType"`
+	FileName    string     `form:"fileName" binding:"max=255"`
+	MinSize     *int64     `form:"minSize" binding:"omitempty,gte=0"`
+	MaxSize     *int64     `form:"maxSize" binding:"omitempty,gte=0"`
+	CreatedFrom *time.Time `form:"createdFrom"`
+	CreatedTo   *time.Time `form:"createdTo"`
+	Trashed     bool       `form:"trashed"`
+	Sort        string     `form:"sort" binding:"omitempty,oneof=createdAt -createdAt fileName -fileName fileSize -fileSize"`
+	Limit       int        `form:"limit" binding:"omitempty,min=1,max=100"`
+	Offset      int        `form:"offset" binding:"omitempty,min=0"`
+}
+
+// ListFilesResponse is one page of files and the number of files matching the filters
+type ListFilesResponse struct {
+	Files  []map[string]interface{} `json:"files"`
+	Total  int64                    `json:"total"`
+	Limit  int                      `json:"limit"`
+	Offset int                      `json:"offset"`
+}
+
+// ListFiles godoc
+// @Summary List files
+// @Description List stored files with offset pagination, sorting and filters. Generated image variants are
+// @Description not listed separately. total counts every file matching the filters, across all pages.
+// @Tags files
+// @Produce json
+// @Param fileType query string false "Configured file type"
+// @Param mimeType query string false "Exact MIME type, e.g. image/png"
+// @Param fileName query string false "Case-insensitive filename substring"
+// @Param minSize query int false "Minimum size in bytes"
+// @Param maxSize query int false "Maximum size in bytes"
+// @Param createdFrom query string false "Created at or after (RFC 3339)"
+// @Param createdTo query string false "Created at or before (RFC 3339)"
+// @Param trashed query bool false "List files in the trash instead of live files"
+// @Param sort query string false "Sort field; prefix with - for descending" Enums(createdAt, -createdAt, fileName, -fileName, fileSize, -fileSize) default(-createdAt)
+// @Param limit query int false "Page size (max 100)" default(50)
+// @Param offset query int false "Number of files to skip" default(0)
+// @Success 200 {object} ListFilesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files [get]
+func (h *Handler) ListFiles(c *gin.Context) {
+	var req ListFilesRequest
+	if err := c.ShouldBindQuery(&req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid query parameters")
+		return
+	}
+
+	if req.FileType != "" {
+		if _, err := h.fileType(req.FileType); err != nil {
+			commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+			return
+		}
+	}
+	if req.MinSize != nil && req.MaxSize != nil && *req.MinSize > *req.MaxSize {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "minSize must not exceed maxSize")
+		return
+	}
+	if req.CreatedFrom != nil && req.CreatedTo != nil && req.CreatedFrom.After(*req.CreatedTo) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "createdFrom must not be after createdTo")
+		return
+	}
+
+	query := repository.FileListQuery{
+		FileFilter: repository.FileFilter{
+			FileType:    req.FileType,
+			FileName:    req.FileName,
+			MinSize:     req.MinSize,
+			MaxSize:     req.MaxSize,
+			CreatedFrom: req.CreatedFrom,
+			CreatedTo:   req.CreatedTo,
+			Trashed:     req.Trashed,
+		},
+		Sort:   repository.FileSortCreatedAt,
+		Limit:  defaultListLimit,
+		Offset: req.Offset,
+	}
+	if req.MimeType != "" {
+		query.MimeType = normalizeMimeType(req.MimeType)
+	}
+	if req.Sort == "" {
+		query.Descending = true
+	} else {
+		query.Sort = strings.TrimPrefix(req.Sort, "-")
+		query.Descending = strings.HasPrefix(req.Sort, "-")
+	}
+	if req.Limit > 0 {
+		query.Limit = req.Limit
+	}
+
+	files, total, err := h.repo.ListFiles(c.Request.Context(), query)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to list files")
+		return
+	}
+
+	response := ListFilesResponse{
+		Files:  make([]map[string]interface{}, 0, len(files)),
+		Total:  total,
+		Limit:  query.Limit,
+		Offset: query.Offset,
+	}
+	for i := range files {
+		item := fileResponse(&files[i])
+		item["createdAt"] = files[i].CreatedAt
+		if files[i].DeletedAt != nil {
+			item["deletedAt"] = *files[i].DeletedAt
+		}
+		response.Files = append(response.Files, item)
+	}
+	c.JSON(http.StatusOK, response)
+}
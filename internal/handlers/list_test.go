@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func setupListRouter(mockRepo *mockRepository) *gin.Engine {
+	handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/files", handler.ListFiles)
+	return router
+}
+
+// =============================================================================
+// List Files Tests
+// =============================================================================
+
+func TestListFiles_Defaults(t *testing.T) {
+	var got repository.FileListQuery
+	mockRepo := &mockRepository{
+		listFilesFunc: func(_ context.Context, query repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+			got = query
+			return []repository.StorageFile{*createTestFile()}, 42, nil
+		},
+	}
+
+	w := performRequest(setupListRouter(mockRepo), http.MethodGet, "/api/v1/files", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got.Sort != repository.FileSortCreatedAt || !got.Descending || got.Limit != defaultListLimit || got.Offset != 0 {
+		t.Errorf("expected newest first with default page size, got %+v", got)
+	}
+	if got.FileFilter != (repository.FileFilter{}) {
+		t.Errorf("expected no filters, got %+v", got.FileFilter)
+	}
+
+	var response struct {
+		Files []map[string]interface{} `json:"files"`
+		Total int64                    `json:"total"`
+		Limit int                      `json:"limit"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.Total != 42 || response.Limit != defaultListLimit || len(response.Files) != 1 {
+		t.Fatalf("unexpected response %s", w.Body.String())
+	}
+	file := response.Files[0]
+	if file["url"] != "/api/v1/files/"+testFileType+"/"+testFileKey || file["fileName"] != testFileName {
+		t.Errorf("expected file with download URL, got %v", file)
+	}
+	if _, ok := file["createdAt"]; !ok {
+		t.Error("expected createdAt in listed file")
+	}
+}
+
+func TestListFiles_FiltersAndSorting(t *testing.T) {
+	var got repository.FileListQuery
+	mockRepo := &mockRepository{
+		listFilesFunc: func(_ context.Context, query repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+			got = query
+			return nil, 0, nil
+		},
+	}
+
+	params := url.Values{
+		"fileType":    {"document"},
+		"mimeType":    {"Application/PDF"},
+		"fileName":    {"cv_2025%"},
+		"minSize":     {"100"},
+		"maxSize":     {"2048"},
+		"createdFrom": {"2025-01-01T00:00:00Z"},
+		"createdTo":   {"2025-06-30T23:59:59+02:00"},
+		"trashed":     {"true"},
+		"sort":        {"-fileSize"},
+		"limit":       {"10"},
+		"offset":      {"20"},
+	}
+	w := performRequest(setupListRouter(mockRepo), http.MethodGet, "/api/v1/files?"+params.Encode(), nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if got.FileType != "document" || got.MimeType != "application/pdf" || got.FileName != "cv_2025%" || !got.Trashed {
+		t.Errorf("unexpected filters %+v", got.FileFilter)
+	}
+	if got.MinSize == nil || *got.MinSize != 100 || got.MaxSize == nil || *got.MaxSize != 2048 {
+		t.Errorf("expected size range 100-2048, got %v-%v", got.MinSize, got.MaxSize)
+	}
+	wantFrom := time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC)
+	wantTo := time.Date(2025, time.June, 30, 21, 59, 59, 0, time.UTC)
+	if got.CreatedFrom == nil || !got.CreatedFrom.Equal(wantFrom) || got.CreatedTo == nil || !got.CreatedTo.Equal(wantTo) {
+		t.Errorf("unexpected created range %v - %v", got.CreatedFrom, got.CreatedTo)
+	}
+	if got.Sort != repository.FileSortFileSize || !got.Descending || got.Limit != 10 || got.Offset != 20 {
+		t.Errorf("unexpected sorting or paging %+v", got)
+	}
+
+	// Sort fields without a prefix are ascending
+	performRequest(setupListRouter(mockRepo), http.MethodGet, "/api/v1/files?sort=fileName", nil)
+	if got.Sort != repository.FileSortFileName || got.Descending {
+		t.Errorf("expected ascending filename sort, got %+v", got)
+	}
+}
+
+func TestListFiles_InvalidParameters(t *testing.T) {
+	testCases := []struct {
+		name  string
+		query string
+	}{
+		{"unknown sort", "sort=s3Key"},
+		{"limit too large", "limit=101"},
+		{"negative offset", "offset=-1"},
+		{"negative size", "minSize=-1"},
+		{"non-numeric size", "maxSize=big"},
+		{"inverted size range", "minSize=10&maxSize=5"},
+		{"malformed date", "createdFrom=2025-01-01"},
+		{"inverted date range", "createdFrom=2025-02-01T00:00:00Z&createdTo=2025-01-01T00:00:00Z"},
+		{"unknown file type", "fileType=video"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				listFilesFunc: func(_ context.Context, _ repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+					t.Error("expected repository not to be queried")
+					return nil, 0, nil
+				},
+			}
+			w := performRequest(setupListRouter(mockRepo), http.MethodGet, "/api/v1/files?"+tc.query, nil)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestListFiles_RepositoryError(t *testing.T) {
+	mockRepo := &mockRepository{
+		listFilesFunc: func(_ context.Context, _ repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+			return nil, 0, errors.New("database connection error")
+		},
+	}
+
+	w := performRequest(setupListRouter(mockRepo), http.MethodGet, "/api/v1/files", nil)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+}
@@ -1,8 +1,13 @@
 package handlers
 
 import (
+	"archive/zip"
 	"bytes"
 	"context"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -10,8 +15,11 @@ import (
 	"time"
 
 	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
 	commonConfig "github.com/GunarsK-portfolio/portfolio-common/config"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
 	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
@@ -32,6 +40,8 @@ const (
 	testImagesBucket = "images"
 	testDocsBucket   = "documents"
 	testMiniBucket   = "miniatures"
+	testQuarantine   = "quarantine"
+	testUserID       = int64(7)
 )
 
 // =============================================================================
@@ -39,17 +49,62 @@ const (
 // =============================================================================
 
 type mockRepository struct {
-	createFileFunc   func(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*repository.StorageFile, error)
-	getFileByIDFunc  func(ctx context.Context, id int64) (*repository.StorageFile, error)
-	getFileByKeyFunc func(ctx context.Context, bucket, key string) (*repository.StorageFile, error)
-	deleteFileFunc   func(ctx context.Context, id int64) error
+	createFileFunc      func(ctx context.Context, file *repository.StorageFile) error
+	getFileByIDFunc     func(ctx context.Context, id int64) (*repository.StorageFile, error)
+	getFileByKeyFunc    func(ctx context.Context, bucket, key string) (*repository.StorageFile, error)
+	listFilesFunc       func(ctx context.Context, query repository.FileListQuery) ([]repository.StorageFile, int64, error)
+	getStorageUsageFunc func(ctx context.Context, userID int64, fileType string, now time.Time) (repository.StorageUsage, error)
+
+	updateFileMetadataFunc func(ctx context.Context, id, version int64, update repository.FileMetadataUpdate) (*repository.StorageFile, error)
+	trashFileFunc          func(ctx context.Context, id int64, at time.Time) error
+	restoreFileFunc        func(ctx context.Context, id int64) error
+	listTrashedFilesFunc   func(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error)
+	purgeFileFunc          func(ctx context.Context, id int64, deletions []repository.PendingDeletion) error
+
+	activateFileFunc       func(ctx context.Context, id int64) error
+	discardPendingFileFunc func(ctx context.Context, id int64, deletions []repository.PendingDeletion) error
+
+	getIntegrityStatsFunc  func(ctx context.Context) (repository.IntegrityStats, error)
+	listCorruptedFilesFunc func(ctx context.Context, limit int) ([]repository.StorageFile, error)
+
+	createFileWithVariantsFunc func(ctx context.Context, file *repository.StorageFile, variants []repository.FileVariant) error
+	listFileVariantsFunc       func(ctx context.Context, parentID int64) ([]repository.FileVariant, error)
+
+	replaceFileContentFunc func(ctx context.Context, id, version int64, content *repository.StorageFile) (*repository.StorageFile, error)
+	restoreFileVersionFunc func(ctx context.Context, id, version, contentVersion int64, check repository.QuotaCheck) (*repository.StorageFile, error)
+	listFileVersionsFunc   func(ctx context.Context, fileID int64) ([]repository.FileVersion, error)
+
+	createFileShareFunc            func(ctx context.Context, share *repository.FileShare) error
+	getFileShareFunc               func(ctx context.Context, fileID, id int64) (*repository.FileShare, error)
+	getFileShareByTokenFunc        func(ctx context.Context, tokenHash string) (*repository.FileShare, error)
+	listFileSharesFunc             func(ctx context.Context, fileID int64) ([]repository.FileShare, error)
+	updateFileShareFunc            func(ctx context.Context, share *repository.FileShare) error
+	deleteFileShareFunc            func(ctx context.Context, fileID, id int64) error
+	claimShareDownloadFunc         func(ctx context.Context, id int64, now time.Time) (*repository.FileShare, error)
+	releaseShareDownloadFunc       func(ctx context.Context, id int64) error
+	claimSharePasswordAttemptFunc  func(ctx context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*repository.FileShare, error)
+	resetSharePasswordFailuresFunc func(ctx context.Context, id int64) error
+
+	createUploadSessionFunc         func(ctx context.Context, session *repository.UploadSession) error
+	getUploadSessionFunc            func(ctx context.Context, id string) (*repository.UploadSession, error)
+	claimUploadPartFunc             func(ctx context.Context, session *repository.UploadSession) (int, error)
+	updateUploadSessionProgressFunc func(ctx context.Context, session *repository.UploadSession, expectedOffset int64) error
+	completeUploadSessionFunc       func(ctx context.Context, session *repository.UploadSession, file *repository.StorageFile) error
+	deleteUploadSessionFunc         func(ctx context.Context, id string) error
+	listExpiredUploadSessionsFunc   func(ctx context.Context, now time.Time, limit int) ([]repository.UploadSession, error)
+
+	claimIdempotencyKeyFunc          func(ctx context.Context, key *repository.IdempotencyKey, now time.Time) (bool, error)
+	getIdempotencyKeyFunc            func(ctx context.Context, userID int64, key string) (*repository.IdempotencyKey, error)
+	completeIdempotencyKeyFunc       func(ctx context.Context, key *repository.IdempotencyKey) error
+	releaseIdempotencyKeyFunc        func(ctx context.Context, key *repository.IdempotencyKey) error
+	deleteExpiredIdempotencyKeysFunc func(ctx context.Context, now time.Time) (int64, error)
 }
 
-func (m *mockRepository) CreateFile(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*repository.StorageFile, error) {
+func (m *mockRepository) CreateFile(ctx context.Context, file *repository.StorageFile) error {
 	if m.createFileFunc != nil {
-		return m.createFileFunc(ctx, bucket, key, fileName, fileType, fileSize, mimeType)
+		return m.createFileFunc(ctx, file)
 	}
-	return nil, nil
+	return nil
 }
 
 func (m *mockRepository) GetFileByID(ctx context.Context, id int64) (*repository.StorageFile, error) {
@@ -66,25 +121,334 @@ func (m *mockRepository) GetFileByKey(ctx context.Context, bucket, key string) (
 	return nil, nil
 }
 
-func (m *mockRepository) DeleteFile(ctx context.Context, id int64) error {
-	if m.deleteFileFunc != nil {
-		return m.deleteFileFunc(ctx, id)
+func (m *mockRepository) UpdateFileMetadata(ctx context.Context, id, version int64, update repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+	if m.updateFileMetadataFunc != nil {
+		return m.updateFileMetadataFunc(ctx, id, version, update)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) TrashFile(ctx context.Context, id int64, at time.Time) error {
+	if m.trashFileFunc != nil {
+		return m.trashFileFunc(ctx, id, at)
+	}
+	return nil
+}
+
+func (m *mockRepository) RestoreFile(ctx context.Context, id int64) error {
+	if m.restoreFileFunc != nil {
+		return m.restoreFileFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListTrashedFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	if m.listTrashedFilesFunc != nil {
+		return m.listTrashedFilesFunc(ctx, before, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) PurgeFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	if m.purgeFileFunc != nil {
+		return m.purgeFileFunc(ctx, id, deletions)
+	}
+	return nil
+}
+
+func (m *mockRepository) ActivateFile(ctx context.Context, id int64) error {
+	if m.activateFileFunc != nil {
+		return m.activateFileFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) DiscardPendingFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	if m.discardPendingFileFunc != nil {
+		return m.discardPendingFileFunc(ctx, id, deletions)
 	}
 	return nil
 }
 
+func (m *mockRepository) ListStalePendingFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListBucketFiles(ctx context.Context, bucket, afterKey string, afterID int64, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) FindKnownObjectKeys(ctx context.Context, bucket string, keys []string) (map[string]bool, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) MarkFilesMissing(ctx context.Context, ids []int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) ListFilesToScrub(ctx context.Context, verifiedBefore time.Time, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) RecordScrubResult(ctx context.Context, id int64, hash string, corrupted bool, at time.Time) error {
+	return nil
+}
+
+func (m *mockRepository) GetIntegrityStats(ctx context.Context) (repository.IntegrityStats, error) {
+	if m.getIntegrityStatsFunc != nil {
+		return m.getIntegrityStatsFunc(ctx)
+	}
+	return repository.IntegrityStats{}, nil
+}
+
+func (m *mockRepository) ListCorruptedFiles(ctx context.Context, limit int) ([]repository.StorageFile, error) {
+	if m.listCorruptedFilesFunc != nil {
+		return m.listCorruptedFilesFunc(ctx, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]repository.PendingDeletion, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) CompleteDeletion(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) RetryDeletion(ctx context.Context, deletion *repository.PendingDeletion) error {
+	return nil
+}
+
+func (m *mockRepository) GetDeletionStats(ctx context.Context, stuckBefore time.Time) (repository.DeletionStats, error) {
+	return repository.DeletionStats{}, nil
+}
+
+func (m *mockRepository) ListFiles(ctx context.Context, query repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+	if m.listFilesFunc != nil {
+		return m.listFilesFunc(ctx, query)
+	}
+	return nil, 0, nil
+}
+
+func (m *mockRepository) GetStorageUsage(ctx context.Context, userID int64, fileType string, now time.Time) (repository.StorageUsage, error) {
+	if m.getStorageUsageFunc != nil {
+		return m.getStorageUsageFunc(ctx, userID, fileType, now)
+	}
+	return repository.StorageUsage{}, nil
+}
+
+func (m *mockRepository) CreateFileWithVariants(ctx context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+	if m.createFileWithVariantsFunc != nil {
+		return m.createFileWithVariantsFunc(ctx, file, variants)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListFileVariants(ctx context.Context, parentID int64) ([]repository.FileVariant, error) {
+	if m.listFileVariantsFunc != nil {
+		return m.listFileVariantsFunc(ctx, parentID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ReplaceFileContent(ctx context.Context, id, version int64, content *repository.StorageFile) (*repository.StorageFile, error) {
+	if m.replaceFileContentFunc != nil {
+		return m.replaceFileContentFunc(ctx, id, version, content)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) RestoreFileVersion(ctx context.Context, id, version, contentVersion int64, check repository.QuotaCheck) (*repository.StorageFile, error) {
+	if m.restoreFileVersionFunc != nil {
+		return m.restoreFileVersionFunc(ctx, id, version, contentVersion, check)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListFileVersions(ctx context.Context, fileID int64) ([]repository.FileVersion, error) {
+	if m.listFileVersionsFunc != nil {
+		return m.listFileVersionsFunc(ctx, fileID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) CreateFileShare(ctx context.Context, share *repository.FileShare) error {
+	if m.createFileShareFunc != nil {
+		return m.createFileShareFunc(ctx, share)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetFileShare(ctx context.Context, fileID, id int64) (*repository.FileShare, error) {
+	if m.getFileShareFunc != nil {
+		return m.getFileShareFunc(ctx, fileID, id)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) GetFileShareByToken(ctx context.Context, tokenHash string) (*repository.FileShare, error) {
+	if m.getFileShareByTokenFunc != nil {
+		return m.getFileShareByTokenFunc(ctx, tokenHash)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListFileShares(ctx context.Context, fileID int64) ([]repository.FileShare, error) {
+	if m.listFileSharesFunc != nil {
+		return m.listFileSharesFunc(ctx, fileID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateFileShare(ctx context.Context, share *repository.FileShare) error {
+	if m.updateFileShareFunc != nil {
+		return m.updateFileShareFunc(ctx, share)
+	}
+	return nil
+}
+
+func (m *mockRepository) DeleteFileShare(ctx context.Context, fileID, id int64) error {
+	if m.deleteFileShareFunc != nil {
+		return m.deleteFileShareFunc(ctx, fileID, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) ClaimShareDownload(ctx context.Context, id int64, now time.Time) (*repository.FileShare, error) {
+	if m.claimShareDownloadFunc != nil {
+		return m.claimShareDownloadFunc(ctx, id, now)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ReleaseShareDownload(ctx context.Context, id int64) error {
+	if m.releaseShareDownloadFunc != nil {
+		return m.releaseShareDownloadFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) ClaimSharePasswordAttempt(ctx context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*repository.FileShare, error) {
+	if m.claimSharePasswordAttemptFunc != nil {
+		return m.claimSharePasswordAttemptFunc(ctx, id, maxAttempts, lockout, now)
+	}
+	return &repository.FileShare{ID: id}, nil
+}
+
+func (m *mockRepository) ResetSharePasswordFailures(ctx context.Context, id int64) error {
+	if m.resetSharePasswordFailuresFunc != nil {
+		return m.resetSharePasswordFailuresFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) CreateUploadSession(ctx context.Context, session *repository.UploadSession) error {
+	if m.createUploadSessionFunc != nil {
+		return m.createUploadSessionFunc(ctx, session)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetUploadSession(ctx context.Context, id string) (*repository.UploadSession, error) {
+	if m.getUploadSessionFunc != nil {
+		return m.getUploadSessionFunc(ctx, id)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ClaimUploadPart(ctx context.Context, session *repository.UploadSession) (int, error) {
+	if m.claimUploadPartFunc != nil {
+		return m.claimUploadPartFunc(ctx, session)
+	}
+	return len(session.Parts) + 1, nil
+}
+
+func (m *mockRepository) UpdateUploadSessionProgress(ctx context.Context, session *repository.UploadSession, expectedOffset int64) error {
+	if m.updateUploadSessionProgressFunc != nil {
+		return m.updateUploadSessionProgressFunc(ctx, session, expectedOffset)
+	}
+	return nil
+}
+
+func (m *mockRepository) CompleteUploadSession(ctx context.Context, session *repository.UploadSession, file *repository.StorageFile) error {
+	if m.completeUploadSessionFunc != nil {
+		return m.completeUploadSessionFunc(ctx, session, file)
+	}
+	return nil
+}
+
+func (m *mockRepository) DeleteUploadSession(ctx context.Context, id string) error {
+	if m.deleteUploadSessionFunc != nil {
+		return m.deleteUploadSessionFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListExpiredUploadSessions(ctx context.Context, now time.Time, limit int) ([]repository.UploadSession, error) {
+	if m.listExpiredUploadSessionsFunc != nil {
+		return m.listExpiredUploadSessionsFunc(ctx, now, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ClaimIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey, now time.Time) (bool, error) {
+	if m.claimIdempotencyKeyFunc != nil {
+		return m.claimIdempotencyKeyFunc(ctx, key, now)
+	}
+	return true, nil
+}
+
+func (m *mockRepository) GetIdempotencyKey(ctx context.Context, userID int64, key string) (*repository.IdempotencyKey, error) {
+	if m.getIdempotencyKeyFunc != nil {
+		return m.getIdempotencyKeyFunc(ctx, userID, key)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) CompleteIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey) error {
+	if m.completeIdempotencyKeyFunc != nil {
+		return m.completeIdempotencyKeyFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *mockRepository) ReleaseIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey) error {
+	if m.releaseIdempotencyKeyFunc != nil {
+		return m.releaseIdempotencyKeyFunc(ctx, key)
+	}
+	return nil
+}
+
+func (m *mockRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	if m.deleteExpiredIdempotencyKeysFunc != nil {
+		return m.deleteExpiredIdempotencyKeysFunc(ctx, now)
+	}
+	return 0, nil
+}
+
 // =============================================================================
 // Mock Storage
 // =============================================================================
 
 type mockStorage struct {
-	getObjectFunc    func(ctx context.Context, bucket, key string) (*minio.Object, error)
+	getObjectFunc    func(ctx context.Context, bucket, key string) (io.ReadCloser, error)
 	putObjectFunc    func(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) error
 	deleteObjectFunc func(ctx context.Context, bucket, key string) error
+	copyObjectFunc   func(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
 	statObjectFunc   func(ctx context.Context, bucket, key string) (minio.ObjectInfo, error)
+
+	newMultipartUploadFunc      func(ctx context.Context, bucket, key, contentType string) (string, error)
+	putObjectPartFunc           func(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (string, error)
+	completeMultipartUploadFunc func(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) error
+	abortMultipartUploadFunc    func(ctx context.Context, bucket, key, uploadID string) error
+
+	getObjectRangeFunc   func(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error)
+	presignPutObjectFunc func(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error)
+	deletePrefixFunc     func(ctx context.Context, bucket, prefix string) error
 }
 
-func (m *mockStorage) GetObject(ctx context.Context, bucket, key string) (*minio.Object, error) {
+func (m *mockStorage) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	if m.getObjectFunc != nil {
 		return m.getObjectFunc(ctx, bucket, key)
 	}
@@ -105,6 +469,13 @@ func (m *mockStorage) DeleteObject(ctx context.Context, bucket, key string) erro
 	return nil
 }
 
+func (m *mockStorage) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	if m.copyObjectFunc != nil {
+		return m.copyObjectFunc(ctx, srcBucket, srcKey, dstBucket, dstKey)
+	}
+	return nil
+}
+
 func (m *mockStorage) StatObject(ctx context.Context, bucket, key string) (minio.ObjectInfo, error) {
 	if m.statObjectFunc != nil {
 		return m.statObjectFunc(ctx, bucket, key)
@@ -112,13 +483,86 @@ func (m *mockStorage) StatObject(ctx context.Context, bucket, key string) (minio
 	return minio.ObjectInfo{}, nil
 }
 
+func (m *mockStorage) NewMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	if m.newMultipartUploadFunc != nil {
+		return m.newMultipartUploadFunc(ctx, bucket, key, contentType)
+	}
+	return "", nil
+}
+
+func (m *mockStorage) PutObjectPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (string, error) {
+	if m.putObjectPartFunc != nil {
+		return m.putObjectPartFunc(ctx, bucket, key, uploadID, partNumber, reader, size)
+	}
+	return "", nil
+}
+
+func (m *mockStorage) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) error {
+	if m.completeMultipartUploadFunc != nil {
+		return m.completeMultipartUploadFunc(ctx, bucket, key, uploadID, parts)
+	}
+	return nil
+}
+
+func (m *mockStorage) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if m.abortMultipartUploadFunc != nil {
+		return m.abortMultipartUploadFunc(ctx, bucket, key, uploadID)
+	}
+	return nil
+}
+
+func (m *mockStorage) GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	if m.getObjectRangeFunc != nil {
+		return m.getObjectRangeFunc(ctx, bucket, key, offset, length)
+	}
+	return io.NopCloser(bytes.NewReader(nil)), nil
+}
+
+func (m *mockStorage) PresignPutObject(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error) {
+	if m.presignPutObjectFunc != nil {
+		return m.presignPutObjectFunc(ctx, bucket, key, contentType, expiry)
+	}
+	return "", nil
+}
+
+func (m *mockStorage) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	if m.deletePrefixFunc != nil {
+		return m.deletePrefixFunc(ctx, bucket, prefix)
+	}
+	return nil
+}
+
+func (m *mockStorage) ListObjects(ctx context.Context, bucket string, fn func(minio.ObjectInfo) error) error {
+	return nil
+}
+
+// =============================================================================
+// Mock Scanner
+// =============================================================================
+
+type mockScanner struct {
+	scanFunc func(ctx context.Context, r io.Reader) (scanner.Result, error)
+}
+
+func (m *mockScanner) Scan(ctx context.Context, r io.Reader) (scanner.Result, error) {
+	if m.scanFunc != nil {
+		return m.scanFunc(ctx, r)
+	}
+	return scanner.Result{}, nil
+}
+
 // =============================================================================
 // Mock Action Log Repository
 // =============================================================================
 
-type mockActionLogRepo struct{}
+type mockActionLogRepo struct {
+	logActionFunc func(log *commonRepo.ActionLog) error
+}
 
-func (m *mockActionLogRepo) LogAction(_ *commonRepo.ActionLog) error {
+func (m *mockActionLogRepo) LogAction(log *commonRepo.ActionLog) error {
+	if m.logActionFunc != nil {
+		return m.logActionFunc(log)
+	}
 	return nil
 }
 
@@ -149,6 +593,20 @@ func setupTestRouter() *gin.Engine {
 	return gin.New()
 }
 
+// asUser sets the user and files access the auth middleware would for a token
+func asUser(userID int64, level string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Set("scopes", map[string]string{common.ResourceFiles: level})
+		c.Next()
+	}
+}
+
+// asAdmin runs requests as a user with delete access, who may change any file
+func asAdmin() gin.HandlerFunc {
+	return asUser(testUserID, common.LevelDelete)
+}
+
 func createTestConfig() *config.Config {
 	return &config.Config{
 		S3Config: commonConfig.S3Config{
@@ -156,9 +614,57 @@ func createTestConfig() *config.Config {
 			DocumentsBucket:  testDocsBucket,
 			MiniaturesBucket: testMiniBucket,
 		},
-		MaxFileSize:      testMaxFileSize,
-		AllowedFileTypes: []string{"image/png", "image/jpeg", "image/gif", "application/pdf"},
+		FileTypes:       newTestRegistry(testFileTypes()),
+		TusUploadExpiry: time.Hour,
+		TrashRetention:  30 * 24 * time.Hour,
+
+		BatchUploadMaxFiles:    10,
+		BatchUploadConcurrency: 2,
+
+		PresignedUploadExpiry: 15 * time.Minute,
+		SignedURLSecret:       "test-signed-url-secret-of-32-bytes-min",
+		SignedURLExpiry:       15 * time.Minute,
+		SignedURLMaxExpiry:    24 * time.Hour,
+		ImageAllowedSizes:     []int{2, 4, 8},
+		ImageMaxWidth:         1000,
+		ImageMaxHeight:        1000,
+		ImageMaxPixels:        1_000_000,
+
+		ShareLinkExpiry:          7 * 24 * time.Hour,
+		ShareLinkMaxExpiry:       90 * 24 * time.Hour,
+		SharePasswordMaxAttempts: 5,
+		SharePasswordLockout:     15 * time.Minute,
+	}
+}
+
+// testFileTypes mirrors the built-in file types, limited to the formats the tests upload
+func testFileTypes() []filetypes.FileType {
+	imageTypes := []string{"image/png", "image/jpeg", "image/gif"}
+	imageExtensions := []string{".png", ".jpg", ".jpeg", ".gif"}
+	return []filetypes.FileType{
+		{Name: "portfolio-image", Bucket: testImagesBucket, MimeTypes: imageTypes, Extensions: imageExtensions, MaxSize: testMaxFileSize, Variants: true},
+		{Name: "miniature-image", Bucket: testMiniBucket, MimeTypes: imageTypes, Extensions: imageExtensions, MaxSize: testMaxFileSize},
+		{Name: "document", Bucket: testDocsBucket, MimeTypes: []string{"application/pdf"}, Extensions: []string{".pdf"}, MaxSize: testMaxFileSize, Versioned: true},
+	}
+}
+
+func newTestRegistry(types []filetypes.FileType) *filetypes.Registry {
+	registry, err := filetypes.NewRegistry(types)
+	if err != nil {
+		panic(err)
+	}
+	return registry
+}
+
+// withFileType rebuilds the config's file types with the named definition changed
+func withFileType(cfg *config.Config, name string, change func(*filetypes.FileType)) {
+	types := cfg.FileTypes.Types()
+	for i := range types {
+		if types[i].Name == name {
+			change(&types[i])
+		}
 	}
+	cfg.FileTypes = newTestRegistry(types)
 }
 
 func createTestFile() *repository.StorageFile {
@@ -171,9 +677,18 @@ func createTestFile() *repository.StorageFile {
 		MimeType:  testMimeType,
 		FileType:  testFileType,
 		CreatedAt: time.Now(),
+		Status:    repository.FileStatusActive,
 	}
 }
 
+// createTrashedTestFile returns the test file after it was moved to the trash an hour ago
+func createTrashedTestFile() *repository.StorageFile {
+	file := createTestFile()
+	deletedAt := time.Now().Add(-time.Hour)
+	file.DeletedAt = &deletedAt
+	return file
+}
+
 func performRequest(router *gin.Engine, method, path string, body io.Reader, headers ...map[string]string) *httptest.ResponseRecorder {
 	w := httptest.NewRecorder()
 	req := httptest.NewRequest(method, path, body)
@@ -218,3 +733,47 @@ func createMultipartRequest(filename, contentType, fileType string, fileContent
 
 	return req, w, nil
 }
+
+// =============================================================================
+// Test File Content
+// =============================================================================
+
+// testPDFData is a minimal PDF header recognized by content sniffing
+var testPDFData = []byte("%PDF-1.4\n%test document\n")
+
+// createTestImage creates a small solid-color image for encoding tests
+func createTestImage(width, height int) *image.RGBA {
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: 200, G: 100, B: 50, A: 255})
+		}
+	}
+	return img
+}
+
+// testPNGData returns a valid encoded PNG image
+func testPNGData() []byte {
+	buf := &bytes.Buffer{}
+	_ = png.Encode(buf, createTestImage(4, 4))
+	return buf.Bytes()
+}
+
+// testJPEGData returns a valid encoded JPEG image
+func testJPEGData() []byte {
+	buf := &bytes.Buffer{}
+	_ = jpeg.Encode(buf, createTestImage(4, 4), nil)
+	return buf.Bytes()
+}
+
+// testDOCXData returns a minimal zip archive laid out like a Word document
+func testDOCXData() []byte {
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, name := range []string{"[Content_Types].xml", "word/document.xml"} {
+		w, _ := zw.Create(name)
+		_, _ = w.Write([]byte("<xml/>"))
+	}
+	_ = zw.Close()
+	return buf.Bytes()
+}
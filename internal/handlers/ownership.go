@@ -0,0 +1,27 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// authorizeFileChange lets users with delete access change or delete any file and users
+// with edit access only the files they uploaded. Files without a recorded uploader can
+// only be changed with delete access. Failures are answered and false is returned.
+func authorizeFileChange(c *gin.Context, file *repository.StorageFile) bool {
+	scopes, _ := c.Get("scopes")
+	scopesMap, _ := scopes.(map[string]string)
+	if common.HasPermission(scopesMap[common.ResourceFiles], common.LevelDelete) {
+		return true
+	}
+	if file.IsUploadedBy(audit.GetUserID(c)) {
+		return true
+	}
+	commonHandlers.RespondError(c, http.StatusForbidden, "only the uploader or an administrator can change this file")
+	return false
+}
@@ -0,0 +1,166 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// Uploader Tests
+// =============================================================================
+
+func TestUploadFile_RecordsUploader(t *testing.T) {
+	var createdFile *repository.StorageFile
+	var createdVariants []repository.FileVariant
+	mockRepo := &mockRepository{
+		createFileWithVariantsFunc: func(_ context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+			file.ID = 10
+			createdFile = file
+			createdVariants = variants
+			return nil
+		},
+	}
+	store := &recordingStore{}
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.UploadFile)
+
+	w := performVisibilityUpload(t, router, repository.FileVisibilityPublic)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if createdFile == nil || !createdFile.IsUploadedBy(int64Ptr(testUserID)) {
+		t.Fatalf("expected the file recorded as uploaded by user %d, got %+v", testUserID, createdFile)
+	}
+	for _, variant := range createdVariants {
+		if !variant.File.IsUploadedBy(int64Ptr(testUserID)) {
+			t.Errorf("expected variant %s to belong to the uploader of its original", variant.Name)
+		}
+	}
+	var resp struct {
+		UploadedBy *int64 `json:"uploadedBy"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.UploadedBy == nil || *resp.UploadedBy != testUserID {
+		t.Errorf("expected uploader in response, got %s", w.Body.String())
+	}
+}
+
+// =============================================================================
+// Ownership Tests
+// =============================================================================
+
+func TestFileChanges_RequireOwnershipOrDeleteAccess(t *testing.T) {
+	otherUserID := testUserID + 1
+	users := []struct {
+		name       string
+		middleware gin.HandlerFunc
+		uploadedBy *int64
+		allowed    bool
+	}{
+		{"editor changing own file", asUser(testUserID, common.LevelEdit), int64Ptr(testUserID), true},
+		{"editor changing another user's file", asUser(testUserID, common.LevelEdit), int64Ptr(otherUserID), false},
+		{"editor changing file without uploader", asUser(testUserID, common.LevelEdit), nil, false},
+		{"administrator changing another user's file", asUser(testUserID, common.LevelDelete), int64Ptr(otherUserID), true},
+		{"administrator changing file without uploader", asUser(testUserID, common.LevelDelete), nil, true},
+	}
+	jsonHeaders := map[string]string{"Content-Type": "application/json"}
+	requests := []struct {
+		method     string
+		path       string
+		headers    map[string]string
+		body       string
+		wantStatus int
+	}{
+		{http.MethodDelete, "/api/v1/files/1", nil, "", http.StatusOK},
+		{http.MethodPatch, "/api/v1/files/1", map[string]string{"Content-Type": "application/json", "If-Match": `"1-1"`}, `{"title": "New title"}`, http.StatusOK},
+		{http.MethodPost, "/api/v1/files/1/versions/1/restore", nil, "", http.StatusOK},
+		{http.MethodPost, "/api/v1/files/1/shares", jsonHeaders, "", http.StatusCreated},
+		{http.MethodPatch, "/api/v1/files/1/shares/5", jsonHeaders, `{"expiresIn": 60}`, http.StatusOK},
+		{http.MethodDelete, "/api/v1/files/1/shares/5", nil, "", http.StatusOK},
+		{http.MethodPost, "/api/v1/files/1/signed-url", jsonHeaders, "", http.StatusOK},
+	}
+
+	for _, user := range users {
+		for _, req := range requests {
+			t.Run(user.name+" "+req.method+" "+req.path, func(t *testing.T) {
+				var changed bool
+				mockRepo := &mockRepository{
+					getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+						file := createTestDocument(2)
+						file.Version = 1
+						file.UploadedBy = user.uploadedBy
+						return file, nil
+					},
+					trashFileFunc: func(_ context.Context, _ int64, _ time.Time) error {
+						changed = true
+						return nil
+					},
+					updateFileMetadataFunc: func(_ context.Context, _, _ int64, _ repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+						changed = true
+						return createTestDocument(2), nil
+					},
+					restoreFileVersionFunc: func(_ context.Context, _, _, _ int64, _ repository.QuotaCheck) (*repository.StorageFile, error) {
+						changed = true
+						return createTestDocument(3), nil
+					},
+					createFileShareFunc: func(_ context.Context, _ *repository.FileShare) error {
+						changed = true
+						return nil
+					},
+					getFileShareFunc: func(_ context.Context, _, _ int64) (*repository.FileShare, error) {
+						return createTestShare(), nil
+					},
+					updateFileShareFunc: func(_ context.Context, _ *repository.FileShare) error {
+						changed = true
+						return nil
+					},
+					deleteFileShareFunc: func(_ context.Context, _, _ int64) error {
+						changed = true
+						return nil
+					},
+				}
+				// Signed URLs change nothing stored; issuing one is seen in the audit log
+				actionLogRepo := &mockActionLogRepo{
+					logActionFunc: func(log *commonRepo.ActionLog) error {
+						if log.ActionType == actionFileSignedURL {
+							changed = true
+						}
+						return nil
+					},
+				}
+				handler := New(mockRepo, &mockStorage{}, createTestConfig(), actionLogRepo)
+				router := setupTestRouter()
+				router.Use(user.middleware)
+				router.DELETE("/api/v1/files/:id", handler.DeleteFile)
+				router.PATCH("/api/v1/files/:id", handler.UpdateFile)
+				router.POST("/api/v1/files/:id/versions/:version/restore", handler.RestoreFileVersion)
+				router.POST("/api/v1/files/:id/shares", handler.CreateFileShare)
+				router.PATCH("/api/v1/files/:id/shares/:shareId", handler.UpdateFileShare)
+				router.DELETE("/api/v1/files/:id/shares/:shareId", handler.DeleteFileShare)
+				router.POST("/api/v1/files/:id/signed-url", handler.CreateSignedURL)
+
+				w := performRequest(router, req.method, req.path, strings.NewReader(req.body), req.headers)
+
+				if user.allowed && w.Code != req.wantStatus {
+					t.Errorf("expected status %d, got %d: %s", req.wantStatus, w.Code, w.Body.String())
+				}
+				if !user.allowed && w.Code != http.StatusForbidden {
+					t.Errorf("expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+				}
+				if changed != user.allowed {
+					t.Errorf("expected file changed: %v, got %v", user.allowed, changed)
+				}
+			})
+		}
+	}
+}
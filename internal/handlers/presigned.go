@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// PresignedUploadRequest describes a file the client wants to upload directly to S3
+type PresignedUploadRequest struct {
+	FileName    string `json:"fileName" binding:"required,max=255"`
+	FileType    string `json:"fileType" binding:"required"`
+	ContentType string `json:"contentType" binding:"required"`
+	Size        int64  `json:"size" binding:"required,gt=0"`
+	// public (default) or private
+	Visibility string `json:"visibility" enums:"public,private"`
+}
+
+// PresignedUploadResponse tells the client where and how to upload the file
+type PresignedUploadResponse struct {
+	ID        string            `json:"id"`
+	UploadURL string            `json:"uploadUrl"`
+	Method    string            `json:"method"`
+	Headers   map[string]string `json:"headers"`
+	ExpiresAt time.Time         `json:"expiresAt"`
+}
+
+// CreatePresignedUpload godoc
+// @Summary Create presigned upload
+// @Description Validate the file and return a presigned URL for uploading it directly to S3.
+// @Description The client must PUT the file with the returned headers and then call the complete endpoint before the URL expires.
+// @Description Uploads over the caller's storage quotas are rejected with 413; open uploads count towards the quotas with their declared size.
+// @Tags uploads
+// @Accept json
+// @Produce json
+// @Param request body PresignedUploadRequest true "File to upload"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 201 {object} PresignedUploadResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/uploads [post]
+func (h *Handler) CreatePresignedUpload(c *gin.Context) {
+	var req PresignedUploadRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+
+	ft, err := h.fileType(req.FileType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if req.Size > ft.MaxSize {
+		commonHandlers.RespondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file too large (max %d bytes)", ft.MaxSize))
+		return
+	}
+	visibility, err := parseVisibility(req.Visibility)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkQuota(c, ft, req.Size) {
+		return
+	}
+
+	// Declared type is signed into the URL and verified against content on completion
+	if !h.isAllowedContentType(req.ContentType) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file type")
+		return
+	}
+	contentType := normalizeMimeType(req.ContentType)
+
+	if err := ft.CheckMimeType(contentType); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	ext, err := ft.ResolveExtension(req.FileName, contentType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	bucket := ft.Bucket
+
+	key := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	uploadURL, err := h.storage.PresignPutObject(c.Request.Context(), bucket, key, contentType, h.cfg.PresignedUploadExpiry)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create upload URL")
+		return
+	}
+
+	session := &repository.UploadSession{
+		ID:         uuid.New().String(),
+		Protocol:   repository.UploadProtocolPresigned,
+		S3Bucket:   bucket,
+		S3Key:      key,
+		FileName:   req.FileName,
+		FileType:   req.FileType,
+		MimeType:   contentType,
+		Visibility: visibility,
+		Length:     req.Size,
+		UserID:     audit.GetUserID(c),
+		ExpiresAt:  time.Now().Add(h.cfg.PresignedUploadExpiry),
+	}
+	session.QuotaCheck = h.quotaCheck(session.UserID, ft, req.Size, 1)
+	if err := h.repo.CreateUploadSession(c.Request.Context(), session); err != nil {
+		if refusal, ok := quotaRefusal(err); ok {
+			respondUploadError(c, refusal)
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create upload")
+		return
+	}
+
+	c.JSON(http.StatusCreated, PresignedUploadResponse{
+		ID:        session.ID,
+		UploadURL: uploadURL,
+		Method:    http.MethodPut,
+		Headers:   map[string]string{"Content-Type": contentType},
+		ExpiresAt: session.ExpiresAt,
+	})
+}
+
+// CompletePresignedUpload godoc
+// @Summary Complete presigned upload
+// @Description Verify the object uploaded through a presigned URL and create the file record. The SHA-256 of the stored content is returned as sha256; content already stored in the bucket is shared with the new file and the uploaded copy removed.
+// @Description Objects whose size or content does not match the declared values are deleted.
+// @Description Objects flagged by the antivirus scanner are deleted or quarantined and answered with 422.
+// @Tags uploads
+// @Produce json
+// @Param id path string true "Upload ID"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/uploads/{id}/complete [post]
+func (h *Handler) CompletePresignedUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c, repository.UploadProtocolPresigned)
+	if !ok {
+		return
+	}
+
+	info, err := h.storage.StatObject(c.Request.Context(), session.S3Bucket, session.S3Key)
+	if err != nil {
+		if storage.IsNotFound(err) {
+			commonHandlers.RespondError(c, http.StatusConflict, "file has not been uploaded")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to inspect upload")
+		return
+	}
+
+	// The presigned URL cannot limit size, so the stored object is checked instead
+	if info.Size != session.Length {
+		if h.discardUpload(c, session) {
+			commonHandlers.RespondError(c, http.StatusBadRequest, "uploaded file size does not match declared size")
+		}
+		return
+	}
+
+	head, err := h.readObjectHead(c, session)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to inspect upload")
+		return
+	}
+	if normalizeMimeType(mimetype.Detect(head).String()) != session.MimeType {
+		if h.discardUpload(c, session) {
+			commonHandlers.RespondError(c, http.StatusBadRequest, errContentTypeMismatch.Error())
+		}
+		return
+	}
+
+	imageInfo, hash, err := h.processStoredUpload(c, session)
+	if err != nil {
+		if h.discardUpload(c, session) {
+			respondImageError(c, err)
+		}
+		return
+	}
+
+	fileRecord := session.NewFile()
+	fileRecord.SetImageInfo(imageInfo)
+	if !h.scanStoredUpload(c, session, fileRecord) {
+		return
+	}
+	// Set once the content is clean, so infected uploads never share an object
+	fileRecord.ContentHash = &hash
+
+	if err := h.repo.CompleteUploadSession(c.Request.Context(), session, fileRecord); err != nil {
+		// The object now belongs to the file of the request that completed first
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload already completed")
+			return
+		}
+		if h.discardUpload(c, session) {
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create file record")
+		}
+		return
+	}
+
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, audit.ActionFileUpload, &resourceType, &fileRecord.ID, &source, map[string]interface{}{
+		"filename":  fileRecord.FileName,
+		"file_type": fileRecord.FileType,
+		"size":      fileRecord.FileSize,
+		"mime_type": fileRecord.MimeType,
+		"sha256":    hash,
+		"shared":    fileRecord.SharesObject,
+		"protocol":  repository.UploadProtocolPresigned,
+	})
+
+	c.JSON(http.StatusOK, fileResponse(fileRecord))
+}
+
+// readObjectHead reads the leading bytes of the uploaded object for content detection
+func (h *Handler) readObjectHead(c *gin.Context, session *repository.UploadSession) ([]byte, error) {
+	reader, err := h.storage.GetObjectRange(c.Request.Context(), session.S3Bucket, session.S3Key, 0, sniffLength)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+	return io.ReadAll(io.LimitReader(reader, sniffLength))
+}
@@ -0,0 +1,487 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Presigned Upload Test Helpers
+// =============================================================================
+
+func setupPresignedRouter(handler *Handler, userID int64) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	router.POST("/api/v1/files/uploads", handler.CreatePresignedUpload)
+	router.POST("/api/v1/files/uploads/:id/complete", handler.CompletePresignedUpload)
+	return router
+}
+
+func presignedRequestBody(t *testing.T, req PresignedUploadRequest) io.Reader {
+	t.Helper()
+	body, err := json.Marshal(req)
+	if err != nil {
+		t.Fatalf("failed to marshal request: %v", err)
+	}
+	return bytes.NewReader(body)
+}
+
+func createTestPresignedSession(length int64) *repository.UploadSession {
+	session := createTestUploadSession(length, 0)
+	session.Protocol = repository.UploadProtocolPresigned
+	session.S3UploadID = ""
+	return session
+}
+
+// presignedStore returns a storage mock holding content as the uploaded object
+func presignedStore(content []byte) *mockStorage {
+	return &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{Size: int64(len(content))}, nil
+		},
+		getObjectRangeFunc: func(_ context.Context, _, _ string, offset, length int64) (io.ReadCloser, error) {
+			end := min(offset+length, int64(len(content)))
+			return io.NopCloser(bytes.NewReader(content[offset:end])), nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(content)), nil
+		},
+	}
+}
+
+// =============================================================================
+// Create Presigned Upload Tests
+// =============================================================================
+
+func TestCreatePresignedUpload_Success(t *testing.T) {
+	var createdSession *repository.UploadSession
+	var signedKey, signedType string
+	var signedExpiry time.Duration
+
+	mockRepo := &mockRepository{
+		createUploadSessionFunc: func(_ context.Context, session *repository.UploadSession) error {
+			createdSession = session
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		presignPutObjectFunc: func(_ context.Context, bucket, key, contentType string, expiry time.Duration) (string, error) {
+			signedKey = key
+			signedType = contentType
+			signedExpiry = expiry
+			return "https://s3.example.com/" + bucket + "/" + key + "?X-Amz-Signature=abc", nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads", presignedRequestBody(t, PresignedUploadRequest{
+		FileName:    "photo.png",
+		FileType:    "portfolio-image",
+		ContentType: "image/png",
+		Size:        2048,
+		Visibility:  repository.FileVisibilityPrivate,
+	}), map[string]string{"Content-Type": "application/json"})
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if createdSession == nil {
+		t.Fatal("expected upload session to be created")
+	}
+	if createdSession.Protocol != repository.UploadProtocolPresigned {
+		t.Errorf("expected protocol %s, got %s", repository.UploadProtocolPresigned, createdSession.Protocol)
+	}
+	if createdSession.S3Key != signedKey || !strings.HasSuffix(signedKey, ".png") {
+		t.Errorf("expected session key to match signed key, got %s and %s", createdSession.S3Key, signedKey)
+	}
+	if createdSession.Length != 2048 {
+		t.Errorf("expected declared size to be stored, got %d", createdSession.Length)
+	}
+	if createdSession.Visibility != repository.FileVisibilityPrivate {
+		t.Errorf("expected visibility private, got %q", createdSession.Visibility)
+	}
+	if signedType != "image/png" {
+		t.Errorf("expected content type to be signed, got %s", signedType)
+	}
+	if signedExpiry != 15*time.Minute {
+		t.Errorf("expected configured expiry, got %v", signedExpiry)
+	}
+
+	var response PresignedUploadResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.ID != createdSession.ID {
+		t.Errorf("expected id %s, got %s", createdSession.ID, response.ID)
+	}
+	if response.Method != http.MethodPut {
+		t.Errorf("expected method PUT, got %s", response.Method)
+	}
+	if !strings.Contains(response.UploadURL, signedKey) {
+		t.Errorf("expected upload URL for key %s, got %s", signedKey, response.UploadURL)
+	}
+	if response.Headers["Content-Type"] != "image/png" {
+		t.Errorf("expected Content-Type header image/png, got %q", response.Headers["Content-Type"])
+	}
+}
+
+func TestCreatePresignedUpload_Validation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		body       string
+		wantStatus int
+	}{
+		{"invalid json", `{`, http.StatusBadRequest},
+		{"missing filename", `{"fileType":"portfolio-image","contentType":"image/png","size":10}`, http.StatusBadRequest},
+		{"missing size", `{"fileName":"photo.png","fileType":"portfolio-image","contentType":"image/png"}`, http.StatusBadRequest},
+		{"negative size", `{"fileName":"photo.png","fileType":"portfolio-image","contentType":"image/png","size":-1}`, http.StatusBadRequest},
+		{"too large", `{"fileName":"photo.png","fileType":"portfolio-image","contentType":"image/png","size":999999999999}`, http.StatusRequestEntityTooLarge},
+		{"invalid content type", `{"fileName":"tool.exe","fileType":"document","contentType":"application/x-msdownload","size":10}`, http.StatusBadRequest},
+		{"extension mismatch", `{"fileName":"photo.pdf","fileType":"portfolio-image","contentType":"image/png","size":10}`, http.StatusBadRequest},
+		{"invalid fileType", `{"fileName":"photo.png","fileType":"avatar","contentType":"image/png","size":10}`, http.StatusBadRequest},
+		{"type not valid for fileType", `{"fileName":"cv.pdf","fileType":"portfolio-image","contentType":"application/pdf","size":10}`, http.StatusBadRequest},
+		{"invalid visibility", `{"fileName":"photo.png","fileType":"portfolio-image","contentType":"image/png","size":10,"visibility":"hidden"}`, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var presigned bool
+			mockStore := &mockStorage{
+				presignPutObjectFunc: func(_ context.Context, _, _, _ string, _ time.Duration) (string, error) {
+					presigned = true
+					return "https://s3.example.com/upload", nil
+				},
+			}
+
+			handler := New(&mockRepository{}, mockStore, createTestConfig(), &mockActionLogRepo{})
+			router := setupPresignedRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/uploads", strings.NewReader(tc.body), map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if presigned {
+				t.Error("expected no upload URL for invalid request")
+			}
+		})
+	}
+}
+
+func TestCreatePresignedUpload_PresignError(t *testing.T) {
+	var sessionCreated bool
+	mockRepo := &mockRepository{
+		createUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession) error {
+			sessionCreated = true
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		presignPutObjectFunc: func(_ context.Context, _, _, _ string, _ time.Duration) (string, error) {
+			return "", errors.New("signing failed")
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads", presignedRequestBody(t, PresignedUploadRequest{
+		FileName: "photo.png", FileType: "portfolio-image", ContentType: "image/png", Size: 10,
+	}), map[string]string{"Content-Type": "application/json"})
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if sessionCreated {
+		t.Error("expected no session when signing fails")
+	}
+}
+
+// =============================================================================
+// Complete Presigned Upload Tests
+// =============================================================================
+
+func TestCompletePresignedUpload_Success(t *testing.T) {
+	content := testPNGData()
+	session := createTestPresignedSession(int64(len(content)))
+	var completed bool
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+			completed = true
+			file.ID = 42
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, presignedStore(content), createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !completed {
+		t.Error("expected upload session to be completed")
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["id"] != float64(42) {
+		t.Errorf("expected id 42, got %v", response["id"])
+	}
+	if response["url"] != "/api/v1/files/"+testFileType+"/"+testFileKey {
+		t.Errorf("unexpected url %v", response["url"])
+	}
+	if response["uploadedBy"] != float64(1) {
+		t.Errorf("expected the session's user as uploader, got %v", response["uploadedBy"])
+	}
+}
+
+func TestCompletePresignedUpload_NotUploaded(t *testing.T) {
+	var discarded bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestPresignedSession(10), nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			discarded = true
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if discarded {
+		t.Error("expected session to be kept so the client can retry")
+	}
+}
+
+func TestCompletePresignedUpload_RejectsMismatchedObject(t *testing.T) {
+	testCases := []struct {
+		name     string
+		declared int64
+		content  []byte
+	}{
+		{"size mismatch", 10, testPNGData()},
+		{"content mismatch", int64(len(testPDFData)), testPDFData},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var deletedKey, deletedSession string
+			var completed bool
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return createTestPresignedSession(tc.declared), nil
+				},
+				completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+					completed = true
+					return nil
+				},
+				deleteUploadSessionFunc: func(_ context.Context, id string) error {
+					deletedSession = id
+					return nil
+				},
+			}
+			mockStore := presignedStore(tc.content)
+			mockStore.deleteObjectFunc = func(_ context.Context, _, key string) error {
+				deletedKey = key
+				return nil
+			}
+
+			handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+			router := setupPresignedRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if completed {
+				t.Error("expected no file record for mismatched upload")
+			}
+			if deletedKey != testFileKey || deletedSession != testUploadID {
+				t.Errorf("expected object and session to be discarded, got key %q session %q", deletedKey, deletedSession)
+			}
+		})
+	}
+}
+
+func TestCompletePresignedUpload_RejectedAfterCompletion_KeepsObject(t *testing.T) {
+	testCases := []struct {
+		name     string
+		declared int64
+		content  []byte
+	}{
+		{"size mismatch", 10, testPNGData()},
+		{"content mismatch", int64(len(testPDFData)), testPDFData},
+		{"unreadable image", int64(len(testPNGData()[:64])), testPNGData()[:64]},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var objectDeleted bool
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return createTestPresignedSession(tc.declared), nil
+				},
+				// Another request completed the upload while this one checked it
+				deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+					return repository.ErrUploadSessionCompleted
+				},
+			}
+			mockStore := presignedStore(tc.content)
+			mockStore.deleteObjectFunc = func(_ context.Context, _, _ string) error {
+				objectDeleted = true
+				return nil
+			}
+
+			handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+			router := setupPresignedRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+			if w.Code != http.StatusConflict {
+				t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+			}
+			if objectDeleted {
+				t.Error("expected object of the completed upload to be kept")
+			}
+		})
+	}
+}
+
+func TestCompletePresignedUpload_SessionErrors(t *testing.T) {
+	expired := createTestPresignedSession(10)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	ownerless := createTestPresignedSession(10)
+	ownerless.UserID = nil
+
+	testCases := []struct {
+		name       string
+		session    *repository.UploadSession
+		err        error
+		userID     int64
+		wantStatus int
+	}{
+		{"not found", nil, gorm.ErrRecordNotFound, 1, http.StatusNotFound},
+		{"tus session", createTestUploadSession(10, 0), nil, 1, http.StatusNotFound},
+		{"other user", createTestPresignedSession(10), nil, 2, http.StatusNotFound},
+		{"session without owner", ownerless, nil, 1, http.StatusNotFound},
+		{"expired", expired, nil, 1, http.StatusGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return tc.session, tc.err
+				},
+			}
+
+			handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+			router := setupPresignedRouter(handler, tc.userID)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+func TestCompletePresignedUpload_DatabaseError_DiscardsUpload(t *testing.T) {
+	content := testPNGData()
+	var objectDeleted bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestPresignedSession(int64(len(content))), nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+			return errors.New("database error")
+		},
+	}
+	mockStore := presignedStore(content)
+	mockStore.deleteObjectFunc = func(_ context.Context, _, _ string) error {
+		objectDeleted = true
+		return nil
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if !objectDeleted {
+		t.Error("expected uploaded object to be deleted after database error")
+	}
+}
+
+func TestCompletePresignedUpload_AlreadyCompleted_KeepsObject(t *testing.T) {
+	content := testPNGData()
+	var objectDeleted bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestPresignedSession(int64(len(content))), nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+			return repository.ErrUploadSessionCompleted
+		},
+	}
+	mockStore := presignedStore(content)
+	mockStore.deleteObjectFunc = func(_ context.Context, _, _ string) error {
+		objectDeleted = true
+		return nil
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if objectDeleted {
+		t.Error("expected object of the completed upload to be kept")
+	}
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	"github.com/gin-gonic/gin"
+)
+
+// quotaStatus reports a storage quota and what is left of it. FileType is empty for the
+// quota across all file types; limits of 0 are unlimited and have nothing remaining.
+type quotaStatus struct {
+	FileType       string `json:"fileType,omitempty"`
+	MaxBytes       int64  `json:"maxBytes,omitempty"`
+	MaxFiles       int64  `json:"maxFiles,omitempty"`
+	UsedBytes      int64  `json:"usedBytes"`
+	UsedFiles      int64  `json:"usedFiles"`
+	RemainingBytes *int64 `json:"remainingBytes,omitempty"`
+	RemainingFiles *int64 `json:"remainingFiles,omitempty"`
+}
+
+func newQuotaStatus(fileType string, maxBytes, maxFiles, usedBytes, usedFiles int64) quotaStatus {
+	q := quotaStatus{
+		FileType:  fileType,
+		MaxBytes:  maxBytes,
+		MaxFiles:  maxFiles,
+		UsedBytes: usedBytes,
+		UsedFiles: usedFiles,
+	}
+	if maxBytes > 0 {
+		remaining := max(0, maxBytes-usedBytes)
+		q.RemainingBytes = &remaining
+	}
+	if maxFiles > 0 {
+		remaining := max(0, maxFiles-usedFiles)
+		q.RemainingFiles = &remaining
+	}
+	return q
+}
+
+// allows reports whether size more bytes in files more files fit in the quota
+func (q quotaStatus) allows(size, files int64) bool {
+	return (q.RemainingBytes == nil || size <= *q.RemainingBytes) &&
+		(q.RemainingFiles == nil || files <= *q.RemainingFiles)
+}
+
+// message explains which quota is exceeded and what is left of it
+func (q quotaStatus) message() string {
+	name := "storage quota"
+	if q.FileType != "" {
+		name = q.FileType + " quota"
+	}
+	var left []string
+	if q.RemainingBytes != nil {
+		left = append(left, fmt.Sprintf("%d of %d bytes", *q.RemainingBytes, q.MaxBytes))
+	}
+	if q.RemainingFiles != nil {
+		left = append(left, fmt.Sprintf("%d of %d files", *q.RemainingFiles, q.MaxFiles))
+	}
+	return fmt.Sprintf("%s exceeded: %s left", name, strings.Join(left, " and "))
+}
+
+// hasQuota reports whether uploads of the file type are limited by any quota
+func (h *Handler) hasQuota(ft *filetypes.FileType) bool {
+	return h.cfg.UserQuotaBytes > 0 || h.cfg.UserQuotaFiles > 0 || ft.QuotaBytes > 0 || ft.QuotaFiles > 0
+}
+
+// checkQuota refuses a new file of size bytes that would take the caller past their
+// storage quota or their quota for the file type, answering 413 with what is left of
+// it. Uploads without a user ID belong to no one and are not limited.
+func (h *Handler) checkQuota(c *gin.Context, ft *filetypes.FileType, size int64) bool {
+	if err := h.quotaError(c, ft, size); err != nil {
+		respondUploadError(c, err)
+		return false
+	}
+	return true
+}
+
+// checkVersionQuota refuses new content of size bytes for file that would take its
+// uploader past their quotas like checkQuota. The replaced content is kept as a
+// version and still counts; the number of files does not change.
+func (h *Handler) checkVersionQuota(c *gin.Context, ft *filetypes.FileType, file *repository.StorageFile, size int64) bool {
+	if err := h.usageQuotaError(c, file.UploadedBy, ft, size, 0); err != nil {
+		respondUploadError(c, err)
+		return false
+	}
+	return true
+}
+
+// quotaError is checkQuota returning the refusal instead of answering it
+func (h *Handler) quotaError(c *gin.Context, ft *filetypes.FileType, size int64) *uploadError {
+	return h.usageQuotaError(c, audit.GetUserID(c), ft, size, 1)
+}
+
+// usageQuotaError refuses size more bytes in files more files that would take userID past
+// their quotas. These checks come before anything is stored; quotaCheck repeats them
+// when the record is created.
+func (h *Handler) usageQuotaError(c *gin.Context, userID *int64, ft *filetypes.FileType, size, files int64) *uploadError {
+	if userID == nil || !h.hasQuota(ft) {
+		return nil
+	}
+
+	usage, err := h.repo.GetStorageUsage(c.Request.Context(), *userID, ft.Name, time.Now())
+	if err != nil {
+		return loggedUploadError(http.StatusInternalServerError, err, "failed to check storage quota")
+	}
+	return h.exceededQuota(ft, usage, size, files)
+}
+
+// quotaCheck repeats the quota check of an upload by userID while its record is
+// created, with the uploader's usage locked, so concurrent uploads cannot together
+// exceed a quota. The refusal comes back from the repository as an *uploadError.
+func (h *Handler) quotaCheck(userID *int64, ft *filetypes.FileType, size, files int64) repository.QuotaCheck {
+	if userID == nil || !h.hasQuota(ft) {
+		return nil
+	}
+	return func(usage repository.StorageUsage) error {
+		if err := h.exceededQuota(ft, usage, size, files); err != nil {
+			return err
+		}
+		return nil
+	}
+}
+
+// exceededQuota refuses size more bytes in files more files when they do not fit next to
+// usage in the quotas
+func (h *Handler) exceededQuota(ft *filetypes.FileType, usage repository.StorageUsage, size, files int64) *uploadError {
+	quotas := []quotaStatus{
+		newQuotaStatus("", h.cfg.UserQuotaBytes, h.cfg.UserQuotaFiles, usage.Bytes, usage.Files),
+		newQuotaStatus(ft.Name, ft.QuotaBytes, ft.QuotaFiles, usage.FileTypeBytes, usage.FileTypeFiles),
+	}
+	for _, quota := range quotas {
+		if !quota.allows(size, files) {
+			return &uploadError{status: http.StatusRequestEntityTooLarge, message: quota.message(), quota: &quota}
+		}
+	}
+	return nil
+}
+
+// quotaRefusal returns the refusal of a failed create when a quota check refused it
+func quotaRefusal(err error) (*uploadError, bool) {
+	var refusal *uploadError
+	if errors.As(err, &refusal) {
+		return refusal, true
+	}
+	return nil, false
+}
@@ -0,0 +1,469 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+)
+
+// quotaResponse is the body of a 413 answer to an upload over quota
+type quotaResponse struct {
+	Error string      `json:"error"`
+	Quota quotaStatus `json:"quota"`
+}
+
+// quotaTestConfig returns a test config with the given user quotas and portfolio-image
+// file quota
+func quotaTestConfig(userBytes, userFiles, imageFiles int64) *config.Config {
+	cfg := createTestConfig()
+	cfg.UserQuotaBytes = userBytes
+	cfg.UserQuotaFiles = userFiles
+	withFileType(cfg, "portfolio-image", func(ft *filetypes.FileType) {
+		ft.QuotaFiles = imageFiles
+	})
+	return cfg
+}
+
+// =============================================================================
+// Upload Quota Tests
+// =============================================================================
+
+func TestUploadFile_StorageQuota(t *testing.T) {
+	size := int64(len(testPNGData()))
+	tests := []struct {
+		name           string
+		cfg            *config.Config
+		usage          repository.StorageUsage
+		expectedStatus int
+		expectedType   string
+		expectedLeft   string
+	}{
+		{
+			name:           "within quotas",
+			cfg:            quotaTestConfig(10*size, 5, 2),
+			usage:          repository.StorageUsage{Bytes: 8 * size, Files: 4, FileTypeFiles: 1},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "user bytes exceeded",
+			cfg:            quotaTestConfig(10*size, 0, 0),
+			usage:          repository.StorageUsage{Bytes: 10*size - 1, Files: 4},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedLeft:   "storage quota exceeded: 1 of " + strconv.FormatInt(10*size, 10) + " bytes left",
+		},
+		{
+			name:           "user files exceeded",
+			cfg:            quotaTestConfig(10*size, 5, 0),
+			usage:          repository.StorageUsage{Bytes: size, Files: 5},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedLeft:   "storage quota exceeded: " + strconv.FormatInt(9*size, 10) + " of " + strconv.FormatInt(10*size, 10) + " bytes and 0 of 5 files left",
+		},
+		{
+			name:           "file type files exceeded",
+			cfg:            quotaTestConfig(0, 5, 2),
+			usage:          repository.StorageUsage{Files: 3, FileTypeFiles: 2},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedType:   "portfolio-image",
+			expectedLeft:   "portfolio-image quota exceeded: 0 of 2 files left",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var usageUser int64
+			var usageType string
+			var stored bool
+			mockRepo := &mockRepository{
+				getStorageUsageFunc: func(_ context.Context, userID int64, fileType string, _ time.Time) (repository.StorageUsage, error) {
+					usageUser, usageType = userID, fileType
+					return tt.usage, nil
+				},
+			}
+			mockStore := &mockStorage{
+				putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+					stored = true
+					return nil
+				},
+			}
+			handler := New(mockRepo, mockStore, tt.cfg, &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.UploadFile)
+
+			w := performVisibilityUpload(t, router, repository.FileVisibilityPublic)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if usageUser != testUserID || usageType != "portfolio-image" {
+				t.Errorf("expected usage of user %d for portfolio-image, got user %d for %q", testUserID, usageUser, usageType)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				if !stored {
+					t.Error("expected upload within quotas to be stored")
+				}
+				return
+			}
+			if stored {
+				t.Error("expected upload over quota not to be stored")
+			}
+			var resp quotaResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to unmarshal response: %v", err)
+			}
+			if resp.Error != tt.expectedLeft {
+				t.Errorf("expected error %q, got %q", tt.expectedLeft, resp.Error)
+			}
+			if resp.Quota.FileType != tt.expectedType {
+				t.Errorf("expected quota of %q, got %q", tt.expectedType, resp.Quota.FileType)
+			}
+		})
+	}
+}
+
+func TestUploadFile_NoQuota_SkipsUsage(t *testing.T) {
+	mockRepo := &mockRepository{
+		getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+			t.Error("expected usage not to be queried without quotas")
+			return repository.StorageUsage{}, nil
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.UploadFile)
+
+	if w := performVisibilityUpload(t, router, repository.FileVisibilityPublic); w.Code != http.StatusOK {
+		t.Errorf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+}
+
+func TestUploadFile_QuotaUsageError(t *testing.T) {
+	mockRepo := &mockRepository{
+		getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+			return repository.StorageUsage{}, errors.New("connection refused")
+		},
+	}
+	cfg := createTestConfig()
+	cfg.UserQuotaFiles = 10
+	handler := New(mockRepo, &mockStorage{}, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.UploadFile)
+
+	if w := performVisibilityUpload(t, router, repository.FileVisibilityPublic); w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+}
+
+func TestCreateUploads_StorageQuota(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UserQuotaBytes = 4096
+	var sessionCreated bool
+	mockRepo := &mockRepository{
+		getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+			return repository.StorageUsage{Bytes: 3072, Files: 1}, nil
+		},
+		createUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession) error {
+			sessionCreated = true
+			return nil
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	presigned := performRequest(setupPresignedRouter(handler, testUserID), http.MethodPost, "/api/v1/files/uploads",
+		presignedRequestBody(t, PresignedUploadRequest{FileName: "photo.png", FileType: "portfolio-image", ContentType: "image/png", Size: 2048}),
+		map[string]string{"Content-Type": "application/json"})
+	tus := performRequest(setupTusRouter(handler, testUserID), http.MethodPost, "/api/v1/files/tus", nil, tusHeaders(map[string]string{
+		"Upload-Length":   "2048",
+		"Upload-Metadata": tusMetadata(map[string]string{"filename": "photo.png", "contentType": "image/png", "fileType": "portfolio-image"}),
+	}))
+
+	for name, code := range map[string]int{"presigned": presigned.Code, "tus": tus.Code} {
+		if code != http.StatusRequestEntityTooLarge {
+			t.Errorf("expected %s upload over quota to get status %d, got %d", name, http.StatusRequestEntityTooLarge, code)
+		}
+	}
+	var resp quotaResponse
+	if err := json.Unmarshal(presigned.Body.Bytes(), &resp); err != nil || resp.Quota.RemainingBytes == nil || *resp.Quota.RemainingBytes != 1024 {
+		t.Errorf("expected 1024 bytes remaining in response, got %s", presigned.Body.String())
+	}
+	if sessionCreated {
+		t.Error("expected no upload session for uploads over quota")
+	}
+}
+
+func TestUploadFile_QuotaTakenConcurrently(t *testing.T) {
+	var stored bool
+	mockRepo := &mockRepository{
+		getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+			return repository.StorageUsage{Files: 4}, nil
+		},
+		// Another upload created its record between the check and this one
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			if file.QuotaCheck == nil {
+				t.Fatal("expected the record to carry a quota check")
+			}
+			if err := file.QuotaCheck(repository.StorageUsage{Files: 5}); err != nil {
+				return fmt.Errorf("failed to create file: %w", err)
+			}
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+			stored = true
+			return nil
+		},
+	}
+	handler := New(mockRepo, mockStore, quotaTestConfig(0, 5, 0), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", asUser(testUserID, common.LevelEdit), handler.UploadFile)
+
+	w := performVisibilityUpload(t, router, repository.FileVisibilityPublic)
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+	var resp quotaResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if resp.Error != "storage quota exceeded: 0 of 5 files left" {
+		t.Errorf("expected the quota refusal, got %q", resp.Error)
+	}
+	if stored {
+		t.Error("expected upload refused at create not to be stored")
+	}
+}
+
+func TestCreateTusUpload_QuotaTakenConcurrently_AbortsUpload(t *testing.T) {
+	var aborted bool
+	mockRepo := &mockRepository{
+		getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+			return repository.StorageUsage{Bytes: 1024}, nil
+		},
+		createUploadSessionFunc: func(_ context.Context, session *repository.UploadSession) error {
+			if session.QuotaCheck == nil {
+				t.Fatal("expected the session to carry a quota check")
+			}
+			if err := session.QuotaCheck(repository.StorageUsage{Bytes: 3072}); err != nil {
+				return fmt.Errorf("failed to create upload session: %w", err)
+			}
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, _ string) error {
+			aborted = true
+			return nil
+		},
+	}
+	cfg := createTestConfig()
+	cfg.UserQuotaBytes = 4096
+	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+
+	w := performRequest(setupTusRouter(handler, testUserID), http.MethodPost, "/api/v1/files/tus", nil, tusHeaders(map[string]string{
+		"Upload-Length":   "2048",
+		"Upload-Metadata": tusMetadata(map[string]string{"filename": "photo.png", "contentType": "image/png", "fileType": "portfolio-image"}),
+	}))
+
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusRequestEntityTooLarge, w.Code, w.Body.String())
+	}
+	if !aborted {
+		t.Error("expected the multipart upload of a refused session to be aborted")
+	}
+}
+
+// =============================================================================
+// Version Quota Tests
+// =============================================================================
+
+func TestUploadFileVersion_StorageQuota(t *testing.T) {
+	size := int64(len(testPDFData))
+	tests := []struct {
+		name           string
+		cfg            *config.Config
+		usage          repository.StorageUsage
+		createUsage    repository.StorageUsage
+		expectedStatus int
+		expectStored   bool
+	}{
+		{
+			name:           "within quota",
+			cfg:            quotaTestConfig(10*size, 0, 0),
+			usage:          repository.StorageUsage{Bytes: 8 * size, Files: 4},
+			createUsage:    repository.StorageUsage{Bytes: 8 * size, Files: 4},
+			expectedStatus: http.StatusOK,
+			expectStored:   true,
+		},
+		{
+			name:           "bytes exceeded",
+			cfg:            quotaTestConfig(10*size, 0, 0),
+			usage:          repository.StorageUsage{Bytes: 10*size - 1, Files: 4},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			// A new version adds no file, so a full file quota does not refuse it
+			name:           "files at quota",
+			cfg:            quotaTestConfig(0, 5, 0),
+			usage:          repository.StorageUsage{Bytes: size, Files: 5},
+			createUsage:    repository.StorageUsage{Bytes: size, Files: 5},
+			expectedStatus: http.StatusOK,
+			expectStored:   true,
+		},
+		{
+			name:           "bytes taken concurrently",
+			cfg:            quotaTestConfig(10*size, 0, 0),
+			usage:          repository.StorageUsage{Bytes: 8 * size, Files: 4},
+			createUsage:    repository.StorageUsage{Bytes: 10 * size, Files: 4},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectStored:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := int64(7)
+			var usageUser int64
+			var stored, deleted string
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					file := createTestDocument(1)
+					file.UploadedBy = &owner
+					return file, nil
+				},
+				getStorageUsageFunc: func(_ context.Context, userID int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+					usageUser = userID
+					return tt.usage, nil
+				},
+				replaceFileContentFunc: func(_ context.Context, id, _ int64, content *repository.StorageFile) (*repository.StorageFile, error) {
+					if content.QuotaCheck == nil {
+						t.Fatal("expected the new content to carry a quota check")
+					}
+					if err := content.QuotaCheck(tt.createUsage); err != nil {
+						return nil, fmt.Errorf("failed to replace content of file id %d: %w", id, err)
+					}
+					updated := createTestDocument(2)
+					updated.ContentKey = content.ContentKey
+					return updated, nil
+				},
+			}
+			mockStore := &mockStorage{
+				putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+					stored = key
+					return nil
+				},
+				deleteObjectFunc: func(_ context.Context, _, key string) error {
+					deleted = key
+					return nil
+				},
+			}
+			handler := New(mockRepo, mockStore, tt.cfg, &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.PUT("/api/v1/files/:id/content", asAdmin(), handler.UploadFileVersion)
+
+			w := performVersionUpload(t, router, "application/pdf", testPDFData, nil)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			// The content counts against its uploader, not the caller replacing it
+			if usageUser != owner {
+				t.Errorf("expected usage of the uploader %d, got %d", owner, usageUser)
+			}
+			if (stored != "") != tt.expectStored {
+				t.Errorf("expected stored %v, got object %q", tt.expectStored, stored)
+			}
+			if tt.expectedStatus == http.StatusOK {
+				return
+			}
+			if deleted != stored {
+				t.Errorf("expected the refused object %q to be deleted, got %q", stored, deleted)
+			}
+			var resp quotaResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Quota.MaxBytes != 10*size {
+				t.Errorf("expected the storage quota in response, got %s", w.Body.String())
+			}
+		})
+	}
+}
+
+func TestRestoreFileVersion_StorageQuota(t *testing.T) {
+	const restoredSize = 1000
+	tests := []struct {
+		name           string
+		usage          repository.StorageUsage
+		createUsage    repository.StorageUsage
+		expectedStatus int
+	}{
+		{
+			name:           "within quota",
+			usage:          repository.StorageUsage{Bytes: 8000, Files: 4},
+			createUsage:    repository.StorageUsage{Bytes: 8000, Files: 4},
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "bytes exceeded",
+			usage:          repository.StorageUsage{Bytes: 9500, Files: 4},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "bytes taken concurrently",
+			usage:          repository.StorageUsage{Bytes: 8000, Files: 4},
+			createUsage:    repository.StorageUsage{Bytes: 9500, Files: 4},
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			owner := int64(7)
+			var restored bool
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					file := createTestDocument(2)
+					file.UploadedBy = &owner
+					return file, nil
+				},
+				listFileVersionsFunc: func(_ context.Context, _ int64) ([]repository.FileVersion, error) {
+					return []repository.FileVersion{{Version: 1, FileSize: restoredSize}}, nil
+				},
+				getStorageUsageFunc: func(_ context.Context, _ int64, _ string, _ time.Time) (repository.StorageUsage, error) {
+					return tt.usage, nil
+				},
+				restoreFileVersionFunc: func(_ context.Context, id, _, contentVersion int64, check repository.QuotaCheck) (*repository.StorageFile, error) {
+					if check == nil {
+						t.Fatal("expected the restore to carry a quota check")
+					}
+					if err := check(tt.createUsage); err != nil {
+						return nil, fmt.Errorf("failed to restore version %d of file id %d: %w", contentVersion, id, err)
+					}
+					restored = true
+					return createTestDocument(3), nil
+				},
+			}
+			handler := New(mockRepo, &mockStorage{}, quotaTestConfig(10000, 0, 0), &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.POST("/api/v1/files/:id/versions/:version/restore", asAdmin(), handler.RestoreFileVersion)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/1/versions/1/restore", nil)
+
+			if w.Code != tt.expectedStatus {
+				t.Fatalf("expected status %d, got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if restored != (tt.expectedStatus == http.StatusOK) {
+				t.Errorf("expected restored %v, got %v", tt.expectedStatus == http.StatusOK, restored)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxRanges caps multi-range requests; larger requests are answered with the whole file
+// so a single request cannot fan out into an unbounded number of S3 reads
+const maxRanges = 16
+
+var errRangeNotSatisfiable = errors.New("requested range not satisfiable")
+
+// byteRange is a resolved, non-empty range within an object
+type byteRange struct {
+	start  int64
+	length int64
+}
+
+func (r byteRange) contentRange(size int64) string {
+	return fmt.Sprintf("bytes %d-%d/%d", r.start, r.start+r.length-1, size)
+}
+
+// parseRange resolves a Range header (RFC 9110 section 14.2) against the object size.
+// A nil result without error means the whole object is served: the header is absent,
+// uses another unit or is malformed, all of which the RFC says to ignore.
+// errRangeNotSatisfiable is returned only for well-formed byte ranges that all lie
+// outside the object.
+func parseRange(header string, size int64) ([]byteRange, error) {
+	unit, set, ok := strings.Cut(header, "=")
+	if !ok || !strings.EqualFold(textproto.TrimString(unit), "bytes") {
+		return nil, nil
+	}
+
+	var ranges []byteRange
+	valid := false
+	for _, spec := range strings.Split(set, ",") {
+		spec = textproto.TrimString(spec)
+		if spec == "" {
+			continue
+		}
+		startStr, endStr, ok := strings.Cut(spec, "-")
+		if !ok {
+			return nil, nil
+		}
+		startStr, endStr = textproto.TrimString(startStr), textproto.TrimString(endStr)
+
+		if startStr == "" {
+			// Suffix range: the last N bytes
+			n, ok := parseRangeInt(endStr)
+			if !ok {
+				return nil, nil
+			}
+			valid = true
+			if n == 0 || size == 0 {
+				continue
+			}
+			n = min(n, size)
+			ranges = append(ranges, byteRange{start: size - n, length: n})
+			continue
+		}
+
+		start, ok := parseRangeInt(startStr)
+		if !ok {
+			return nil, nil
+		}
+		end := size - 1
+		if endStr != "" {
+			end, ok = parseRangeInt(endStr)
+			if !ok || start > end {
+				return nil, nil
+			}
+			end = min(end, size-1)
+		}
+		valid = true
+		// Ranges starting past the end are skipped; the request fails only if none remain
+		if start >= size {
+			continue
+		}
+		ranges = append(ranges, byteRange{start: start, length: end - start + 1})
+	}
+
+	if !valid {
+		return nil, nil
+	}
+	if len(ranges) == 0 {
+		return nil, errRangeNotSatisfiable
+	}
+	return ranges, nil
+}
+
+// parseRangeInt parses a range position, which is a plain sequence of digits
+func parseRangeInt(s string) (int64, bool) {
+	if s == "" || strings.TrimLeft(s, "0123456789") != "" {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(s, 10, 64)
+	return n, err == nil
+}
+
+// rangesWorthServing reports whether ranges should be honoured rather than
+// replaced by the whole object (too many ranges or overlapping ranges)
+func rangesWorthServing(ranges []byteRange, size int64) bool {
+	if len(ranges) > maxRanges {
+		return false
+	}
+	var total int64
+	for _, r := range ranges {
+		total += r.length
+	}
+	return total <= size
+}
+
+// quoteETag converts the unquoted ETag reported by S3 into an HTTP entity tag
+func quoteETag(etag string) string {
+	if etag == "" || strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, "W/") {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// etagListMatches reports whether an If-None-Match list contains etag using weak comparison
+func etagListMatches(header, etag string) bool {
+	for _, candidate := range strings.Split(header, ",") {
+		candidate = textproto.TrimString(candidate)
+		if candidate == "*" {
+			return true
+		}
+		if etag != "" && strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+	}
+	return false
+}
+
+// isNotModified evaluates If-None-Match, falling back to If-Modified-Since when absent
+func isNotModified(r *http.Request, etag string, lastModified time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		return etagListMatches(inm, etag)
+	}
+
+	ims := r.Header.Get("If-Modified-Since")
+	if ims == "" || lastModified.IsZero() {
+		return false
+	}
+	since, err := http.ParseTime(ims)
+	if err != nil {
+		return false
+	}
+	return !lastModified.Truncate(time.Second).After(since)
+}
+
+// rangeApplies evaluates If-Range: a Range header is honoured only while the
+// client's validator still identifies the current object
+func rangeApplies(r *http.Request, etag string, lastModified time.Time) bool {
+	ifRange := r.Header.Get("If-Range")
+	if ifRange == "" {
+		return true
+	}
+	if strings.HasPrefix(ifRange, `"`) || strings.HasPrefix(ifRange, "W/") {
+		// If-Range requires strong comparison, so weak tags never match
+		return etag != "" && ifRange == etag
+	}
+	since, err := http.ParseTime(ifRange)
+	return err == nil && lastModified.Truncate(time.Second).Equal(since)
+}
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// rateLimitExposedHeaders lets browser clients read how long to wait
+const rateLimitExposedHeaders = "Retry-After,X-RateLimit-Limit,X-RateLimit-Remaining"
+
+// RateLimitUploads limits how often each user may start an upload, keyed by the user
+// ID in their token. Requests over the limit are answered with 429 and Retry-After.
+// When the limiter is unavailable uploads are let through rather than refused.
+func (h *Handler) RateLimitUploads() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if uploadErr := h.takeUploadToken(c); uploadErr != nil {
+			respondUploadError(c, uploadErr)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// takeUploadToken takes one upload from the caller's rate limit, setting the rate limit
+// headers and returning the 429 refusal once it is used up
+func (h *Handler) takeUploadToken(c *gin.Context) *uploadError {
+	if h.uploadLimiter == nil {
+		return nil
+	}
+
+	result, err := h.uploadLimiter.Allow(c.Request.Context(), "uploads:"+rateLimitSubject(c))
+	if err != nil {
+		logger.GetLogger(c).Error("Upload rate limit unavailable", "error", err)
+		return nil
+	}
+
+	// Batches take a token per file; the headers report the last one
+	if c.Writer.Header().Get("X-RateLimit-Limit") == "" {
+		c.Writer.Header().Add("Access-Control-Expose-Headers", rateLimitExposedHeaders)
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(h.cfg.UploadRateBurst))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	if !result.Allowed {
+		retryAfter := int(math.Ceil(result.RetryAfter.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(retryAfter))
+		return newUploadError(http.StatusTooManyRequests,
+			fmt.Sprintf("upload rate limit exceeded, retry in %d seconds", retryAfter))
+	}
+	return nil
+}
+
+// rateLimitSubject identifies the caller: the user of their token, or their address
+// for tokens without a user ID
+func rateLimitSubject(c *gin.Context) string {
+	if userID := audit.GetUserID(c); userID != nil {
+		return "user:" + strconv.FormatInt(*userID, 10)
+	}
+	return "ip:" + c.ClientIP()
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/ratelimit"
+	"github.com/gin-gonic/gin"
+)
+
+// failingLimiter is a limiter whose store cannot be reached
+type failingLimiter struct{}
+
+func (failingLimiter) Allow(_ context.Context, _ string) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+// setupRateLimitRouter serves a stub upload behind the rate limit, as the user set in
+// the X-Test-User header
+func setupRateLimitRouter(handler *Handler) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		switch c.GetHeader("X-Test-User") {
+		case "1":
+			c.Set("user_id", int64(1))
+		case "2":
+			c.Set("user_id", int64(2))
+		}
+		c.Next()
+	})
+	router.POST("/api/v1/files", handler.RateLimitUploads(), func(c *gin.Context) {
+		c.Status(http.StatusOK)
+	})
+	return router
+}
+
+// =============================================================================
+// Upload Rate Limit Tests
+// =============================================================================
+
+func TestRateLimitUploads_RefusesOverLimit(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.UploadRateBurst = 2
+	handler := New(&mockRepository{}, &mockStorage{}, cfg, &mockActionLogRepo{},
+		WithUploadLimiter(ratelimit.NewMemory(ratelimit.PerMinute(1, 2))))
+	router := setupRateLimitRouter(handler)
+	upload := func(user string) *http.Response {
+		return performRequest(router, http.MethodPost, "/api/v1/files", nil, map[string]string{"X-Test-User": user}).Result()
+	}
+
+	for _, remaining := range []string{"1", "0"} {
+		resp := upload("1")
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("expected status %d within the burst, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if resp.Header.Get("X-RateLimit-Limit") != "2" || resp.Header.Get("X-RateLimit-Remaining") != remaining {
+			t.Errorf("expected limit 2 with %s remaining, got %q and %q", remaining,
+				resp.Header.Get("X-RateLimit-Limit"), resp.Header.Get("X-RateLimit-Remaining"))
+		}
+	}
+
+	resp := upload("1")
+	if resp.StatusCode != http.StatusTooManyRequests {
+		t.Fatalf("expected status %d over the limit, got %d", http.StatusTooManyRequests, resp.StatusCode)
+	}
+	if resp.Header.Get("Retry-After") != "60" {
+		t.Errorf("expected Retry-After 60, got %q", resp.Header.Get("Retry-After"))
+	}
+
+	if resp := upload("2"); resp.StatusCode != http.StatusOK {
+		t.Errorf("expected another user not to be limited, got status %d", resp.StatusCode)
+	}
+}
+
+func TestRateLimitUploads_LimiterError_AllowsUpload(t *testing.T) {
+	handler := New(&mockRepository{}, &mockStorage{}, createTestConfig(), &mockActionLogRepo{},
+		WithUploadLimiter(failingLimiter{}))
+	router := setupRateLimitRouter(handler)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files", nil, map[string]string{"X-Test-User": "1"})
+
+	if w.Code != http.StatusOK {
+		t.Errorf("expected status %d when the limiter fails, got %d", http.StatusOK, w.Code)
+	}
+}
+
+func TestRateLimitUploads_Disabled(t *testing.T) {
+	handler := New(&mockRepository{}, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupRateLimitRouter(handler)
+
+	for i := 0; i < 5; i++ {
+		w := performRequest(router, http.MethodPost, "/api/v1/files", nil, map[string]string{"X-Test-User": "1"})
+		if w.Code != http.StatusOK || w.Header().Get("X-RateLimit-Remaining") != "" {
+			t.Fatalf("expected unlimited uploads without rate limit headers, got status %d", w.Code)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/GunarsK-portfolio/files-api/internal/reconcile"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/gin-gonic/gin"
+)
+
+// Reconciler compares storage with the database; implemented by reconcile.Reconciler
+type Reconciler interface {
+	Reconcile(ctx context.Context) (*reconcile.Report, error)
+	LastReport() *reconcile.Report
+}
+
+// WithReconciler enables the storage reconciliation endpoints
+func WithReconciler(r Reconciler) Option {
+	return func(h *Handler) {
+		h.reconciler = r
+	}
+}
+
+// GetReconciliationReport godoc
+// @Summary Get the storage reconciliation report
+// @Description Report of the latest comparison of each bucket with the file records: objects no file refers
+// @Description to (orphaned) and files whose object is missing. Lists are capped at 100 entries per bucket.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} reconcile.Report
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/reconciliation [get]
+func (h *Handler) GetReconciliationReport(c *gin.Context) {
+	if h.reconciler == nil {
+		commonHandlers.RespondError(c, http.StatusServiceUnavailable, "reconciliation is not enabled")
+		return
+	}
+
+	report := h.reconciler.LastReport()
+	if report == nil {
+		commonHandlers.RespondError(c, http.StatusNotFound, "no reconciliation has run yet")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RunReconciliation godoc
+// @Summary Run storage reconciliation
+// @Description Compare every bucket with the file records now and return the report. In repair mode
+// @Description (RECONCILE_MODE) orphaned objects are deleted and files missing their object are marked missing.
+// @Description Buckets that could not be walked carry an error in the report.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} reconcile.Report
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /admin/reconciliation [post]
+func (h *Handler) RunReconciliation(c *gin.Context) {
+	if h.reconciler == nil {
+		commonHandlers.RespondError(c, http.StatusServiceUnavailable, "reconciliation is not enabled")
+		return
+	}
+
+	report, err := h.reconciler.Reconcile(c.Request.Context())
+	if errors.Is(err, reconcile.ErrRunning) {
+		commonHandlers.RespondError(c, http.StatusConflict, err.Error())
+		return
+	}
+	if report == nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to reconcile storage")
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
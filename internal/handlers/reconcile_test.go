@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/reconcile"
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+type mockReconciler struct {
+	reconcileFunc func(ctx context.Context) (*reconcile.Report, error)
+	last          *reconcile.Report
+}
+
+func (m *mockReconciler) Reconcile(ctx context.Context) (*reconcile.Report, error) {
+	return m.reconcileFunc(ctx)
+}
+
+func (m *mockReconciler) LastReport() *reconcile.Report {
+	return m.last
+}
+
+func setupReconcileRouter(reconciler Reconciler) *gin.Engine {
+	var opts []Option
+	if reconciler != nil {
+		opts = append(opts, WithReconciler(reconciler))
+	}
+	handler := New(&mockRepository{}, nil, createTestConfig(), &mockActionLogRepo{}, opts...)
+	router := setupTestRouter()
+	router.GET("/api/v1/admin/reconciliation", handler.GetReconciliationReport)
+	router.POST("/api/v1/admin/reconciliation", handler.RunReconciliation)
+	return router
+}
+
+func testReconcileReport() *reconcile.Report {
+	return &reconcile.Report{
+		Mode:      reconcile.ModeReport,
+		StartedAt: time.Now(),
+		Buckets: []reconcile.BucketReport{{
+			Bucket:          testImagesBucket,
+			OrphanedCount:   1,
+			OrphanedObjects: []reconcile.OrphanedObject{{Key: "stray.png", Size: 10}},
+			MissingObjects:  []reconcile.MissingObject{},
+		}},
+	}
+}
+
+// =============================================================================
+// Reconciliation Tests
+// =============================================================================
+
+func TestGetReconciliationReport(t *testing.T) {
+	w := performRequest(setupReconcileRouter(&mockReconciler{}), http.MethodGet, "/api/v1/admin/reconciliation", nil)
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d before the first run, got %d", http.StatusNotFound, w.Code)
+	}
+
+	reconciler := &mockReconciler{last: testReconcileReport()}
+	w = performRequest(setupReconcileRouter(reconciler), http.MethodGet, "/api/v1/admin/reconciliation", nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+
+	var report reconcile.Report
+	if err := json.Unmarshal(w.Body.Bytes(), &report); err != nil {
+		t.Fatalf("failed to unmarshal report: %v", err)
+	}
+	if len(report.Buckets) != 1 || report.Buckets[0].OrphanedObjects[0].Key != "stray.png" {
+		t.Errorf("unexpected report %s", w.Body.String())
+	}
+}
+
+func TestRunReconciliation(t *testing.T) {
+	testCases := []struct {
+		name       string
+		report     *reconcile.Report
+		err        error
+		wantStatus int
+	}{
+		{"success", testReconcileReport(), nil, http.StatusOK},
+		{"bucket failed", testReconcileReport(), errors.New("bucket images: access denied"), http.StatusOK},
+		{"already running", nil, reconcile.ErrRunning, http.StatusConflict},
+		{"failed", nil, errors.New("unexpected"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			reconciler := &mockReconciler{
+				reconcileFunc: func(_ context.Context) (*reconcile.Report, error) {
+					return tc.report, tc.err
+				},
+			}
+			w := performRequest(setupReconcileRouter(reconciler), http.MethodPost, "/api/v1/admin/reconciliation", nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestReconciliation_NotEnabled(t *testing.T) {
+	router := setupReconcileRouter(nil)
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		w := performRequest(router, method, "/api/v1/admin/reconciliation", nil)
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("%s: expected status %d, got %d", method, http.StatusServiceUnavailable, w.Code)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+
+	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// infectedFileMessage is returned for flagged uploads, whether rejected or quarantined
+const infectedFileMessage = "file rejected: malware detected"
+
+// scanContent scans an upload before it is stored and rewinds it.
+// A nil result means scanning is disabled.
+func (h *Handler) scanContent(ctx context.Context, content io.ReadSeeker) (*scanner.Result, error) {
+	if h.scanner == nil {
+		return nil, nil
+	}
+	result, err := h.scanner.Scan(ctx, content)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := content.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind file after scan: %w", err)
+	}
+	return &result, nil
+}
+
+// scanStoredObject scans an object uploaded through tus or a presigned URL.
+// A nil result means scanning is disabled.
+func (h *Handler) scanStoredObject(ctx context.Context, bucket, key string) (*scanner.Result, error) {
+	if h.scanner == nil {
+		return nil, nil
+	}
+	reader, err := h.storage.GetObject(ctx, bucket, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read upload for scanning: %w", err)
+	}
+	defer reader.Close()
+
+	result, err := h.scanner.Scan(ctx, reader)
+	if err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+func applyScanResult(file *repository.StorageFile, result *scanner.Result) {
+	if result != nil {
+		file.SetScanResult(result.Verdict(), result.Signature)
+	}
+}
+
+func (h *Handler) quarantineEnabled() bool {
+	return h.cfg.InfectedFileAction == config.InfectedFileQuarantine
+}
+
+// rejectInfectedUpload handles a flagged multipart upload that has not been stored yet.
+// In quarantine mode the content and its record are kept in the quarantine bucket.
+func (h *Handler) rejectInfectedUpload(c *gin.Context, fileRecord *repository.StorageFile, content io.Reader, size int64) {
+	respondUploadError(c, h.quarantineInfectedUpload(c, fileRecord, content, size))
+}
+
+// quarantineInfectedUpload is rejectInfectedUpload returning the refusal instead of
+// answering it
+func (h *Handler) quarantineInfectedUpload(c *gin.Context, fileRecord *repository.StorageFile, content io.Reader, size int64) *uploadError {
+	if h.quarantineEnabled() {
+		fileRecord.S3Bucket = h.cfg.QuarantineBucket
+		if err := h.storage.PutObject(c.Request.Context(), fileRecord.S3Bucket, fileRecord.S3Key, content, size, fileRecord.MimeType); err != nil {
+			return loggedUploadError(http.StatusInternalServerError, err, "failed to quarantine file")
+		}
+		if err := h.repo.CreateFile(c.Request.Context(), fileRecord); err != nil {
+			if cleanupErr := h.storage.DeleteObject(c.Request.Context(), fileRecord.S3Bucket, fileRecord.S3Key); cleanupErr != nil {
+				logger.GetLogger(c).Error("Failed to cleanup quarantined file after database error",
+					"error", cleanupErr,
+					"bucket", fileRecord.S3Bucket,
+					"key", fileRecord.S3Key,
+				)
+			}
+			return loggedUploadError(http.StatusInternalServerError, err, "failed to create file record")
+		}
+	}
+	h.logInfectedUpload(c, fileRecord, "")
+	return newUploadError(http.StatusUnprocessableEntity, infectedFileMessage)
+}
+
+// scanStoredUpload scans a completed tus or presigned upload and records the verdict on
+// fileRecord. It returns false once a response has been sent: flagged uploads are
+// discarded or moved to the quarantine bucket, and scan failures discard the upload.
+func (h *Handler) scanStoredUpload(c *gin.Context, session *repository.UploadSession, fileRecord *repository.StorageFile) bool {
+	result, err := h.scanStoredObject(c.Request.Context(), session.S3Bucket, session.S3Key)
+	if err != nil {
+		if h.discardUpload(c, session) {
+			commonHandlers.LogAndRespondError(c, http.StatusServiceUnavailable, err, "virus scan unavailable")
+		}
+		return false
+	}
+	applyScanResult(fileRecord, result)
+	if result == nil || !result.Infected {
+		return true
+	}
+
+	if !h.quarantineEnabled() {
+		if h.discardUpload(c, session) {
+			h.respondInfected(c, fileRecord, session.Protocol)
+		}
+		return false
+	}
+
+	if err := h.storage.CopyObject(c.Request.Context(), session.S3Bucket, session.S3Key, h.cfg.QuarantineBucket, session.S3Key); err != nil {
+		if h.discardUpload(c, session) {
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to quarantine file")
+		}
+		return false
+	}
+	fileRecord.S3Bucket = h.cfg.QuarantineBucket
+	if err := h.repo.CompleteUploadSession(c.Request.Context(), session, fileRecord); err != nil {
+		// The upload and its quarantine copy belong to the request that completed it first
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload already completed")
+			return false
+		}
+		if h.discardUpload(c, session) {
+			h.deleteQuarantineCopy(c, session)
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create file record")
+		}
+		return false
+	}
+	// The original is only removed once this request owns the upload
+	if err := h.storage.DeleteObject(c.Request.Context(), session.S3Bucket, session.S3Key); err != nil {
+		logger.GetLogger(c).Error("Failed to remove quarantined file from its bucket",
+			"error", err,
+			"bucket", session.S3Bucket,
+			"key", session.S3Key,
+		)
+	}
+	h.respondInfected(c, fileRecord, session.Protocol)
+	return false
+}
+
+// deleteQuarantineCopy removes the quarantine copy of an upload that was discarded
+func (h *Handler) deleteQuarantineCopy(c *gin.Context, session *repository.UploadSession) {
+	if err := h.storage.DeleteObject(c.Request.Context(), h.cfg.QuarantineBucket, session.S3Key); err != nil {
+		logger.GetLogger(c).Error("Failed to cleanup quarantined file after database error",
+			"error", err,
+			"bucket", h.cfg.QuarantineBucket,
+			"key", session.S3Key,
+		)
+	}
+}
+
+// respondInfected logs and audits a flagged upload and answers 422
+func (h *Handler) respondInfected(c *gin.Context, fileRecord *repository.StorageFile, protocol string) {
+	h.logInfectedUpload(c, fileRecord, protocol)
+	commonHandlers.RespondError(c, http.StatusUnprocessableEntity, infectedFileMessage)
+}
+
+// logInfectedUpload logs and audits a flagged upload. Quarantined uploads have a file
+// record, which is referenced in the audit log.
+func (h *Handler) logInfectedUpload(c *gin.Context, fileRecord *repository.StorageFile, protocol string) {
+	signature := ""
+	if fileRecord.ScanSignature != nil {
+		signature = *fileRecord.ScanSignature
+	}
+	quarantined := fileRecord.ID != 0
+	logger.GetLogger(c).Warn("Infected upload rejected",
+		"signature", signature,
+		"filename", fileRecord.FileName,
+		"file_type", fileRecord.FileType,
+		"quarantined", quarantined,
+	)
+
+	metadata := map[string]interface{}{
+		"filename":       fileRecord.FileName,
+		"file_type":      fileRecord.FileType,
+		"size":           fileRecord.FileSize,
+		"mime_type":      fileRecord.MimeType,
+		"scan_status":    scanner.VerdictInfected,
+		"scan_signature": signature,
+		"quarantined":    quarantined,
+	}
+	if protocol != "" {
+		metadata["protocol"] = protocol
+	}
+	var resourceID *int64
+	if quarantined {
+		resourceID = &fileRecord.ID
+	}
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, audit.ActionFileUpload, &resourceType, resourceID, &source, metadata)
+}
+
+// isInfected reports whether the file was flagged by the antivirus scanner
+func isInfected(file *repository.StorageFile) bool {
+	return file.ScanStatus != nil && *file.ScanStatus == scanner.VerdictInfected
+}
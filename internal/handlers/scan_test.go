@@ -0,0 +1,315 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+const testSignature = "Eicar-Test-Signature"
+
+// verdictScanner reads the whole stream and answers with result, recording what it saw
+func verdictScanner(result scanner.Result, scanned *[]byte) *mockScanner {
+	return &mockScanner{
+		scanFunc: func(_ context.Context, r io.Reader) (scanner.Result, error) {
+			data, err := io.ReadAll(r)
+			if scanned != nil {
+				*scanned = data
+			}
+			return result, err
+		},
+	}
+}
+
+func infectedResult() scanner.Result {
+	return scanner.Result{Infected: true, Signature: testSignature}
+}
+
+// scanCapture records the objects and file rows an upload handler creates
+type scanCapture struct {
+	puts    map[string][]byte
+	created *repository.StorageFile
+}
+
+func (s *scanCapture) handler(scan *mockScanner, action string) *Handler {
+	s.puts = map[string][]byte{}
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			s.created = file
+			file.ID = 7
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, bucket, key string, reader io.Reader, _ int64, _ string) error {
+			data, err := io.ReadAll(reader)
+			s.puts[bucket+"/"+key] = data
+			return err
+		},
+	}
+	cfg := createTestConfig()
+	cfg.InfectedFileAction = action
+	cfg.QuarantineBucket = testQuarantine
+	return New(mockRepo, mockStore, cfg, &mockActionLogRepo{}, WithScanner(scan))
+}
+
+func uploadPDF(t *testing.T, handler *Handler) *httptest.ResponseRecorder {
+	t.Helper()
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("cv.pdf", "application/pdf", "document", testPDFData)
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+	return w
+}
+
+// =============================================================================
+// Upload Scan Tests
+// =============================================================================
+
+func TestUploadFile_ScanClean(t *testing.T) {
+	var scanned []byte
+	capture := &scanCapture{}
+	w := uploadPDF(t, capture.handler(verdictScanner(scanner.Result{}, &scanned), config.InfectedFileReject))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !bytes.Equal(scanned, testPDFData) {
+		t.Error("expected the uploaded content to be scanned")
+	}
+	if len(capture.puts) != 1 {
+		t.Fatalf("expected one stored object, got %d", len(capture.puts))
+	}
+	for key, data := range capture.puts {
+		if !bytes.Equal(data, testPDFData) {
+			t.Errorf("expected full content to be stored after scanning, got %d bytes at %s", len(data), key)
+		}
+	}
+	if capture.created == nil || capture.created.ScanStatus == nil || *capture.created.ScanStatus != scanner.VerdictClean {
+		t.Errorf("expected clean verdict on the file record, got %+v", capture.created)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["scanStatus"] != scanner.VerdictClean {
+		t.Errorf("expected scanStatus clean, got %v", response["scanStatus"])
+	}
+}
+
+func TestUploadFile_Infected_Rejected(t *testing.T) {
+	capture := &scanCapture{}
+	w := uploadPDF(t, capture.handler(verdictScanner(infectedResult(), nil), config.InfectedFileReject))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	if len(capture.puts) != 0 || capture.created != nil {
+		t.Error("expected infected file to be neither stored nor recorded")
+	}
+}
+
+func TestUploadFile_Infected_Quarantined(t *testing.T) {
+	capture := &scanCapture{}
+	w := uploadPDF(t, capture.handler(verdictScanner(infectedResult(), nil), config.InfectedFileQuarantine))
+
+	if w.Code != http.StatusUnprocessableEntity {
+		t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+	}
+	record := capture.created
+	if record == nil {
+		t.Fatal("expected quarantined file to be recorded")
+	}
+	if !bytes.Equal(capture.puts[testQuarantine+"/"+record.S3Key], testPDFData) || len(capture.puts) != 1 {
+		t.Errorf("expected content to be stored only in the quarantine bucket, got %v", capture.puts)
+	}
+	if record.S3Bucket != testQuarantine || !isInfected(record) {
+		t.Errorf("expected infected record in quarantine bucket, got %+v", record)
+	}
+	if record.ScanSignature == nil || *record.ScanSignature != testSignature {
+		t.Errorf("expected signature %s to be recorded", testSignature)
+	}
+}
+
+func TestUploadFile_ScannerUnavailable(t *testing.T) {
+	capture := &scanCapture{}
+	failing := &mockScanner{
+		scanFunc: func(_ context.Context, _ io.Reader) (scanner.Result, error) {
+			return scanner.Result{}, errors.New("connection refused")
+		},
+	}
+	w := uploadPDF(t, capture.handler(failing, config.InfectedFileReject))
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+	if len(capture.puts) != 0 || capture.created != nil {
+		t.Error("expected nothing to be stored when the scan fails")
+	}
+}
+
+// =============================================================================
+// Stored Upload Scan Tests
+// =============================================================================
+
+func TestCompletePresignedUpload_Infected(t *testing.T) {
+	tests := []struct {
+		name            string
+		action          string
+		wantQuarantined bool
+	}{
+		{"reject", config.InfectedFileReject, false},
+		{"quarantine", config.InfectedFileQuarantine, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			content := testPNGData()
+			session := createTestPresignedSession(int64(len(content)))
+			var completed *repository.StorageFile
+			var sessionDeleted, originalDeleted bool
+			var copiedTo string
+
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return session, nil
+				},
+				completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, file *repository.StorageFile) error {
+					completed = file
+					return nil
+				},
+				deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+					sessionDeleted = true
+					return nil
+				},
+			}
+			mockStore := presignedStore(content)
+			mockStore.copyObjectFunc = func(_ context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+				if srcBucket != session.S3Bucket || srcKey != session.S3Key || dstKey != session.S3Key {
+					t.Errorf("unexpected copy %s/%s -> %s/%s", srcBucket, srcKey, dstBucket, dstKey)
+				}
+				copiedTo = dstBucket
+				return nil
+			}
+			mockStore.deleteObjectFunc = func(_ context.Context, bucket, key string) error {
+				originalDeleted = bucket == session.S3Bucket && key == session.S3Key
+				return nil
+			}
+
+			cfg := createTestConfig()
+			cfg.InfectedFileAction = tt.action
+			cfg.QuarantineBucket = testQuarantine
+			handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{}, WithScanner(verdictScanner(infectedResult(), nil)))
+			router := setupPresignedRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+			if w.Code != http.StatusUnprocessableEntity {
+				t.Errorf("expected status %d, got %d: %s", http.StatusUnprocessableEntity, w.Code, w.Body.String())
+			}
+			if !originalDeleted {
+				t.Error("expected the uploaded object to be removed from its bucket")
+			}
+			if tt.wantQuarantined {
+				if copiedTo != testQuarantine {
+					t.Errorf("expected object to be copied to %s, got %q", testQuarantine, copiedTo)
+				}
+				if completed == nil || completed.S3Bucket != testQuarantine || !isInfected(completed) {
+					t.Errorf("expected infected record in quarantine bucket, got %+v", completed)
+				}
+				return
+			}
+			if copiedTo != "" || completed != nil {
+				t.Error("expected rejected upload not to be quarantined or recorded")
+			}
+			if !sessionDeleted {
+				t.Error("expected upload session to be deleted")
+			}
+		})
+	}
+}
+
+func TestCompletePresignedUpload_QuarantineAfterCompletion_KeepsObjects(t *testing.T) {
+	content := testPNGData()
+	var deleted []string
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestPresignedSession(int64(len(content))), nil
+		},
+		// Another request completed the upload while this one scanned it
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+			return repository.ErrUploadSessionCompleted
+		},
+	}
+	mockStore := presignedStore(content)
+	mockStore.copyObjectFunc = func(_ context.Context, _, _, _, _ string) error {
+		return nil
+	}
+	mockStore.deleteObjectFunc = func(_ context.Context, bucket, key string) error {
+		deleted = append(deleted, bucket+"/"+key)
+		return nil
+	}
+
+	cfg := createTestConfig()
+	cfg.InfectedFileAction = config.InfectedFileQuarantine
+	cfg.QuarantineBucket = testQuarantine
+	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{}, WithScanner(verdictScanner(infectedResult(), nil)))
+	router := setupPresignedRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/uploads/"+testUploadID+"/complete", nil)
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d: %s", http.StatusConflict, w.Code, w.Body.String())
+	}
+	if len(deleted) != 0 {
+		t.Errorf("expected the objects of the completed upload to be kept, got %v deleted", deleted)
+	}
+}
+
+// =============================================================================
+// Download Tests
+// =============================================================================
+
+func TestDownloadFile_InfectedNotServed(t *testing.T) {
+	infected := createTestFile()
+	infected.SetScanResult(scanner.VerdictInfected, testSignature)
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return infected, nil
+		},
+	}
+	mockStore := &mockStorage{
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			t.Error("expected infected file not to be read from storage")
+			return io.NopCloser(bytes.NewReader(testPDFData)), nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+
+	w := performRequest(router, http.MethodGet, "/api/v1/files/portfolio-image/"+testFileKey, nil)
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+}
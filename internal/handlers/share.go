@@ -0,0 +1,601 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// Share links give anyone holding one access to a single file, public or private, until
+// the link expires or runs out of downloads. Password-protected links ask for the
+// password with HTTP Basic authentication; the username is ignored.
+
+// Audit actions for share links
+const (
+	actionFileShareCreate = "file_share_create"
+	actionFileShareUpdate = "file_share_update"
+	actionFileShareDelete = "file_share_delete"
+	actionFileShareAccess = "file_share_access"
+)
+
+// Outcomes of share link accesses recorded in the audit log
+const (
+	shareAccessDownloaded       = "downloaded"
+	shareAccessHead             = "head"
+	shareAccessNotFound         = "not_found"
+	shareAccessExpired          = "expired"
+	shareAccessExhausted        = "exhausted"
+	shareAccessLocked           = "locked"
+	shareAccessPasswordRequired = "password_required"
+	shareAccessWrongPassword    = "wrong_password"
+	shareAccessFileUnavailable  = "file_unavailable"
+)
+
+// shareContextKey marks requests downloading a file through a share link
+const shareContextKey = "file_share"
+
+// shareTokenBytes is the number of random bytes in a share token
+const shareTokenBytes = 32
+
+// sharePasswordChallenge makes browsers prompt for the password of a share link
+const sharePasswordChallenge = `Basic realm="Shared file", charset="UTF-8"`
+
+// minSharePasswordLength is the shortest password a share link accepts; bcrypt limits
+// the longest to 72 bytes
+const minSharePasswordLength = 8
+
+// FileShareRequest sets the attributes of a share link; omitted fields keep their value
+// on updates and their default on creation
+type FileShareRequest struct {
+	// Seconds from now until the link expires; defaults to the configured expiry
+	ExpiresIn *int64 `json:"expiresIn" binding:"omitempty,gt=0"`
+	// Downloads allowed through the link; 0 allows any number
+	MaxDownloads *int64 `json:"maxDownloads" binding:"omitempty,gte=0"`
+	// Password the link asks for; empty for none
+	Password *string `json:"password" binding:"omitempty,max=72"`
+}
+
+// FileShareResponse is a share link; URL is only returned when the link is created, as
+// its token is not stored
+type FileShareResponse struct {
+	repository.FileShare
+	HasPassword bool   `json:"hasPassword"`
+	URL         string `json:"url,omitempty"`
+}
+
+type fileSharesResponse struct {
+	FileID int64               `json:"fileId"`
+	Shares []FileShareResponse `json:"shares"`
+}
+
+// CreateFileShare godoc
+// @Summary Create share link
+// @Description Create a link that downloads the file without authentication until it expires or its download
+// @Description limit is reached, optionally protected by a password. The body is optional. The link is only
+// @Description returned in this response. Needs delete permission for files the caller did not upload. Audit
+// @Description logged as file_share_create.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param request body FileShareRequest false "Link settings"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
+// @Success 201 {object} FileShareResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/shares [post]
+func (h *Handler) CreateFileShare(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	var req FileShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if err := h.validateShareRequest(req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	if isInfected(file) || file.IsPending() {
+		commonHandlers.RespondError(c, http.StatusNotFound, "file not found")
+		return
+	}
+	if file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, trashedFileMessage)
+		return
+	}
+
+	token, err := newShareToken()
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create share link")
+		return
+	}
+	share := &repository.FileShare{
+		FileID:    id,
+		TokenHash: hashShareToken(token),
+		ExpiresAt: time.Now().Add(h.cfg.ShareLinkExpiry).Truncate(time.Second).UTC(),
+		CreatedBy: audit.GetUserID(c),
+	}
+	if err := applyShareRequest(share, req); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create share link")
+		return
+	}
+	if err := h.repo.CreateFileShare(c.Request.Context(), share); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create share link")
+		return
+	}
+
+	h.logShareChange(c, actionFileShareCreate, share)
+
+	response := shareResponse(share)
+	response.URL = shareURL(token)
+	c.JSON(http.StatusCreated, response)
+}
+
+// ListFileShares godoc
+// @Summary List share links
+// @Description Share links of a file, newest first, including expired and used up ones until they are deleted.
+// @Tags shares
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} fileSharesResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/shares [get]
+func (h *Handler) ListFileShares(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	if _, err := h.repo.GetFileByID(c.Request.Context(), id); err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	shares, err := h.repo.ListFileShares(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to list share links")
+		return
+	}
+
+	response := fileSharesResponse{FileID: id, Shares: make([]FileShareResponse, len(shares))}
+	for i := range shares {
+		response.Shares[i] = shareResponse(&shares[i])
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetFileShare godoc
+// @Summary Get share link
+// @Description A share link of a file with its expiry and download count. The link itself is not returned.
+// @Tags shares
+// @Produce json
+// @Param id path int true "File ID"
+// @Param shareId path int true "Share link ID"
+// @Success 200 {object} FileShareResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/shares/{shareId} [get]
+func (h *Handler) GetFileShare(c *gin.Context) {
+	fileID, shareID, ok := shareParams(c)
+	if !ok {
+		return
+	}
+
+	share, err := h.repo.GetFileShare(c.Request.Context(), fileID, shareID)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "share link not found", "failed to fetch share link")
+		return
+	}
+	c.JSON(http.StatusOK, shareResponse(share))
+}
+
+// UpdateFileShare godoc
+// @Summary Update share link
+// @Description Change the expiry (counted from now), download limit or password of a share link. An empty
+// @Description password removes it; changing the password lifts a lockout. Needs delete permission for files
+// @Description the caller did not upload. Audit logged as file_share_update.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param shareId path int true "Share link ID"
+// @Param request body FileShareRequest true "Attributes to change"
+// @Success 200 {object} FileShareResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/shares/{shareId} [patch]
+func (h *Handler) UpdateFileShare(c *gin.Context) {
+	fileID, shareID, ok := shareParams(c)
+	if !ok {
+		return
+	}
+
+	var req FileShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	if req.ExpiresIn == nil && req.MaxDownloads == nil && req.Password == nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "no share link attributes to update")
+		return
+	}
+	if err := h.validateShareRequest(req); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), fileID)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	share, err := h.repo.GetFileShare(c.Request.Context(), fileID, shareID)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "share link not found", "failed to fetch share link")
+		return
+	}
+	if err := applyShareRequest(share, req); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to update share link")
+		return
+	}
+	if err := h.repo.UpdateFileShare(c.Request.Context(), share); err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "share link not found", "failed to update share link")
+		return
+	}
+
+	h.logShareChange(c, actionFileShareUpdate, share)
+	c.JSON(http.StatusOK, shareResponse(share))
+}
+
+// DeleteFileShare godoc
+// @Summary Delete share link
+// @Description Revoke a share link; it stops working immediately. Needs delete permission for files the caller
+// @Description did not upload. Audit logged as file_share_delete.
+// @Tags shares
+// @Produce json
+// @Param id path int true "File ID"
+// @Param shareId path int true "Share link ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/shares/{shareId} [delete]
+func (h *Handler) DeleteFileShare(c *gin.Context) {
+	fileID, shareID, ok := shareParams(c)
+	if !ok {
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), fileID)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	if err := h.repo.DeleteFileShare(c.Request.Context(), fileID, shareID); err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "share link not found", "failed to delete share link")
+		return
+	}
+
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileShareDelete, &resourceType, &fileID, &source, map[string]interface{}{
+		"share_id": shareID,
+	})
+
+	c.JSON(http.StatusOK, gin.H{"message": "share link deleted"})
+}
+
+// DownloadSharedFile godoc
+// @Summary Download shared file
+// @Description Download the file of a share link without authentication. Password-protected links answer 401
+// @Description with a Basic challenge; the password is sent as the Basic password with any username. Too many
+// @Description wrong passwords lock the link for a while (429). Every GET counts as a download and receives the
+// @Description whole file; HEAD does not count, nor does a GET that fails before the file is sent. A transfer the
+// @Description client breaks off still counts. Every access is audit logged as file_share_access.
+// @Tags shares
+// @Produce octet-stream
+// @Param token path string true "Share link token"
+// @Success 200 {file} binary
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /s/{token} [get]
+// @Router /s/{token} [head]
+func (h *Handler) DownloadSharedFile(c *gin.Context) {
+	now := time.Now()
+	share, err := h.repo.GetFileShareByToken(c.Request.Context(), hashShareToken(c.Param("token")))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			h.logShareAccess(c, nil, shareAccessNotFound)
+		}
+		commonHandlers.HandleRepositoryError(c, err, "share link not found", "failed to fetch share link")
+		return
+	}
+	if !now.Before(share.ExpiresAt) {
+		h.logShareAccess(c, share, shareAccessExpired)
+		commonHandlers.RespondError(c, http.StatusGone, "share link has expired")
+		return
+	}
+	if share.IsExhausted() {
+		h.logShareAccess(c, share, shareAccessExhausted)
+		commonHandlers.RespondError(c, http.StatusGone, "share link has no downloads left")
+		return
+	}
+	if share.HasPassword() && !h.checkSharePassword(c, share, now) {
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), share.FileID)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if isInfected(file) || file.IsPending() || file.IsTrashed() {
+		h.logShareAccess(c, share, shareAccessFileUnavailable)
+		commonHandlers.RespondError(c, http.StatusNotFound, "file not found")
+		return
+	}
+	ft, err := h.fileType(file.FileType)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to resolve file type")
+		return
+	}
+
+	outcome := shareAccessHead
+	if c.Request.Method == http.MethodGet {
+		claimed, err := h.repo.ClaimShareDownload(c.Request.Context(), share.ID, now)
+		if errors.Is(err, repository.ErrShareUnavailable) {
+			// Another download used up the link since it was read
+			h.logShareAccess(c, share, shareAccessExhausted)
+			commonHandlers.RespondError(c, http.StatusGone, "share link has no downloads left")
+			return
+		}
+		if err != nil {
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to count download")
+			return
+		}
+		share, outcome = claimed, shareAccessDownloaded
+	}
+	h.logShareAccess(c, share, outcome)
+
+	c.Set(shareContextKey, share)
+	h.serveFileContent(c, ft, file)
+
+	// The download is counted before the file is sent, so concurrent downloads cannot
+	// exceed the limit. It is given back when sending failed before the first byte;
+	// a transfer the client breaks off still counts.
+	if outcome == shareAccessDownloaded && c.Writer.Status() >= http.StatusBadRequest {
+		if err := h.repo.ReleaseShareDownload(context.WithoutCancel(c.Request.Context()), share.ID); err != nil {
+			logger.GetLogger(c).Error("Failed to release share download", "error", err, "share_id", share.ID)
+		}
+	}
+}
+
+// checkSharePassword checks the Basic password sent for a password-protected share,
+// counting attempts and refusing locked shares. Failures are answered and false is
+// returned.
+func (h *Handler) checkSharePassword(c *gin.Context, share *repository.FileShare, now time.Time) bool {
+	if share.IsLocked(now) {
+		h.logShareAccess(c, share, shareAccessLocked)
+		respondShareLocked(c, share, now)
+		return false
+	}
+
+	_, password, ok := c.Request.BasicAuth()
+	if !ok {
+		h.logShareAccess(c, share, shareAccessPasswordRequired)
+		c.Header("WWW-Authenticate", sharePasswordChallenge)
+		commonHandlers.RespondError(c, http.StatusUnauthorized, "password required")
+		return false
+	}
+	// Counted before comparing, so concurrent guesses cannot get past the limit
+	attempt, err := h.repo.ClaimSharePasswordAttempt(c.Request.Context(), share.ID,
+		h.cfg.SharePasswordMaxAttempts, h.cfg.SharePasswordLockout, now)
+	if errors.Is(err, repository.ErrShareLocked) {
+		h.logShareAccess(c, attempt, shareAccessLocked)
+		respondShareLocked(c, attempt, now)
+		return false
+	}
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to check password")
+		return false
+	}
+	if bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte(password)) != nil {
+		h.logShareAccess(c, attempt, shareAccessWrongPassword)
+		if attempt.IsLocked(now) {
+			respondShareLocked(c, attempt, now)
+			return false
+		}
+		c.Header("WWW-Authenticate", sharePasswordChallenge)
+		commonHandlers.RespondError(c, http.StatusUnauthorized, "wrong password")
+		return false
+	}
+
+	if err := h.repo.ResetSharePasswordFailures(c.Request.Context(), share.ID); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to check password")
+		return false
+	}
+	share.FailedAttempts, share.LockedUntil = 0, nil
+	return true
+}
+
+// respondShareLocked refuses a share locked after too many wrong passwords. A lock
+// lifted since the share was read is retried after a second.
+func respondShareLocked(c *gin.Context, share *repository.FileShare, now time.Time) {
+	retryAfter := int64(1)
+	if share.LockedUntil != nil {
+		retryAfter = max(retryAfter, int64(math.Ceil(share.LockedUntil.Sub(now).Seconds())))
+	}
+	c.Header("Retry-After", strconv.FormatInt(retryAfter, 10))
+	commonHandlers.RespondError(c, http.StatusTooManyRequests, "too many wrong passwords, try again later")
+}
+
+// isSharedDownload reports whether the request downloads a file through a share link
+func isSharedDownload(c *gin.Context) bool {
+	_, ok := c.Get(shareContextKey)
+	return ok
+}
+
+// validateShareRequest checks the lifetime and password of a share link request
+func (h *Handler) validateShareRequest(req FileShareRequest) error {
+	if req.ExpiresIn != nil && time.Duration(*req.ExpiresIn)*time.Second > h.cfg.ShareLinkMaxExpiry {
+		return fmt.Errorf("expiresIn must be at most %d seconds", int64(h.cfg.ShareLinkMaxExpiry.Seconds()))
+	}
+	if req.Password != nil && *req.Password != "" && len(*req.Password) < minSharePasswordLength {
+		return fmt.Errorf("password must be at least %d characters", minSharePasswordLength)
+	}
+	return nil
+}
+
+// applyShareRequest sets the attributes sent in a validated request on share
+func applyShareRequest(share *repository.FileShare, req FileShareRequest) error {
+	if req.ExpiresIn != nil {
+		share.ExpiresAt = time.Now().Add(time.Duration(*req.ExpiresIn) * time.Second).Truncate(time.Second).UTC()
+	}
+	if req.MaxDownloads != nil {
+		share.MaxDownloads = nil
+		if *req.MaxDownloads > 0 {
+			share.MaxDownloads = req.MaxDownloads
+		}
+	}
+	if req.Password != nil {
+		// A new password starts over without a lockout
+		share.PasswordHash = nil
+		share.FailedAttempts = 0
+		share.LockedUntil = nil
+		if *req.Password != "" {
+			hash, err := bcrypt.GenerateFromPassword([]byte(*req.Password), bcrypt.DefaultCost)
+			if err != nil {
+				return fmt.Errorf("failed to hash share password: %w", err)
+			}
+			passwordHash := string(hash)
+			share.PasswordHash = &passwordHash
+		}
+	}
+	return nil
+}
+
+// shareParams parses the file and share link IDs of a share route, answering 400 for
+// invalid ones
+func shareParams(c *gin.Context) (fileID, shareID int64, ok bool) {
+	fileID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return 0, 0, false
+	}
+	shareID, err = strconv.ParseInt(c.Param("shareId"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid share link ID")
+		return 0, 0, false
+	}
+	return fileID, shareID, true
+}
+
+func shareResponse(share *repository.FileShare) FileShareResponse {
+	return FileShareResponse{FileShare: *share, HasPassword: share.HasPassword()}
+}
+
+// newShareToken returns a random URL-safe share token
+func newShareToken() (string, error) {
+	b := make([]byte, shareTokenBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate share token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashShareToken is the form a share token is stored and looked up in
+func hashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+func shareURL(token string) string {
+	return "/api/v1/s/" + token
+}
+
+// logShareChange audits the creation or update of a share link
+func (h *Handler) logShareChange(c *gin.Context, action string, share *repository.FileShare) {
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, action, &resourceType, &share.FileID, &source, map[string]interface{}{
+		"share_id":      share.ID,
+		"expires_at":    share.ExpiresAt,
+		"max_downloads": share.MaxDownloads,
+		"has_password":  share.HasPassword(),
+	})
+}
+
+// logShareAccess audits an access to a share link; share is nil for unknown tokens
+func (h *Handler) logShareAccess(c *gin.Context, share *repository.FileShare, outcome string) {
+	source := "files-api"
+	metadata := map[string]interface{}{"outcome": outcome}
+	if share == nil {
+		_ = audit.LogFromContext(c, h.actionLogRepo, actionFileShareAccess, nil, nil, &source, metadata)
+		return
+	}
+	resourceType := audit.ResourceTypeFile
+	metadata["share_id"] = share.ID
+	metadata["download_count"] = share.DownloadCount
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileShareAccess, &resourceType, &share.FileID, &source, metadata)
+}
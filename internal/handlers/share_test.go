@@ -0,0 +1,652 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+const (
+	testShareToken    = "share-token"
+	testSharePassword = "correct horse"
+)
+
+func setupShareRouter(mockRepo *mockRepository, mockStore *mockStorage, actionLogRepo *mockActionLogRepo) *gin.Engine {
+	handler := New(mockRepo, mockStore, createTestConfig(), actionLogRepo)
+	router := setupTestRouter()
+	router.POST("/api/v1/files/:id/shares", asAdmin(), handler.CreateFileShare)
+	router.GET("/api/v1/files/:id/shares", handler.ListFileShares)
+	router.GET("/api/v1/files/:id/shares/:shareId", handler.GetFileShare)
+	router.PATCH("/api/v1/files/:id/shares/:shareId", asAdmin(), handler.UpdateFileShare)
+	router.DELETE("/api/v1/files/:id/shares/:shareId", asAdmin(), handler.DeleteFileShare)
+	router.GET("/api/v1/s/:token", handler.DownloadSharedFile)
+	router.HEAD("/api/v1/s/:token", handler.DownloadSharedFile)
+	return router
+}
+
+// createTestShare returns an unused share of the test file valid for another hour
+func createTestShare() *repository.FileShare {
+	return &repository.FileShare{
+		ID:        5,
+		FileID:    1,
+		TokenHash: hashShareToken(testShareToken),
+		ExpiresAt: time.Now().Add(time.Hour),
+	}
+}
+
+// createPasswordTestShare returns the test share protected by testSharePassword
+func createPasswordTestShare(t *testing.T) *repository.FileShare {
+	t.Helper()
+	hash, err := bcrypt.GenerateFromPassword([]byte(testSharePassword), bcrypt.MinCost)
+	if err != nil {
+		t.Fatalf("failed to hash password: %v", err)
+	}
+	share := createTestShare()
+	passwordHash := string(hash)
+	share.PasswordHash = &passwordHash
+	return share
+}
+
+// shareDownloadRepo serves share for the test token and the test file, counting
+// claimed downloads
+func shareDownloadRepo(share *repository.FileShare, claims *int) *mockRepository {
+	return &mockRepository{
+		getFileShareByTokenFunc: func(_ context.Context, tokenHash string) (*repository.FileShare, error) {
+			if tokenHash != hashShareToken(testShareToken) {
+				return nil, gorm.ErrRecordNotFound
+			}
+			return share, nil
+		},
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createPrivateTestFile(), nil
+		},
+		claimShareDownloadFunc: func(_ context.Context, _ int64, _ time.Time) (*repository.FileShare, error) {
+			*claims++
+			claimed := *share
+			claimed.DownloadCount++
+			return &claimed, nil
+		},
+	}
+}
+
+// shareDownloadStore serves "data" for any object and counts ranged reads
+func shareDownloadStore(rangeReads *int) *mockStorage {
+	return &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{ETag: "abc123", Size: 4, ContentType: testMimeType, LastModified: testLastModified}, nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("data")), nil
+		},
+		getObjectRangeFunc: func(_ context.Context, _, _ string, _, _ int64) (io.ReadCloser, error) {
+			*rangeReads++
+			return io.NopCloser(strings.NewReader("da")), nil
+		},
+	}
+}
+
+// recordActions collects the audit log entries of a test
+func recordActions(logged *[]*commonRepo.ActionLog) *mockActionLogRepo {
+	return &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			*logged = append(*logged, log)
+			return nil
+		},
+	}
+}
+
+func int64Ptr(n int64) *int64 {
+	return &n
+}
+
+// shareAccessOutcome returns the outcome of the last share access in logged
+func shareAccessOutcome(t *testing.T, logged []*commonRepo.ActionLog) string {
+	t.Helper()
+	for i := len(logged) - 1; i >= 0; i-- {
+		if logged[i].ActionType != actionFileShareAccess {
+			continue
+		}
+		var metadata map[string]interface{}
+		if err := json.Unmarshal(logged[i].Metadata, &metadata); err != nil {
+			t.Fatalf("failed to unmarshal audit metadata: %v", err)
+		}
+		outcome, _ := metadata["outcome"].(string)
+		return outcome
+	}
+	return ""
+}
+
+// =============================================================================
+// Create File Share Tests
+// =============================================================================
+
+func TestCreateFileShare_Success(t *testing.T) {
+	testCases := []struct {
+		name         string
+		body         string
+		lifetime     time.Duration
+		maxDownloads *int64
+		password     bool
+	}{
+		{"defaults", "", 7 * 24 * time.Hour, nil, false},
+		{"limited and protected", `{"expiresIn": 3600, "maxDownloads": 3, "password": "` + testSharePassword + `"}`, time.Hour, int64Ptr(3), true},
+		{"unlimited downloads", `{"maxDownloads": 0}`, 7 * 24 * time.Hour, nil, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var created *repository.FileShare
+			var logged []*commonRepo.ActionLog
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return createPrivateTestFile(), nil
+				},
+				createFileShareFunc: func(_ context.Context, share *repository.FileShare) error {
+					share.ID = 5
+					created = share
+					return nil
+				},
+			}
+
+			w := performRequest(setupShareRouter(mockRepo, &mockStorage{}, recordActions(&logged)), http.MethodPost,
+				"/api/v1/files/1/shares", strings.NewReader(tc.body), map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+			}
+			var resp FileShareResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			token, ok := strings.CutPrefix(resp.URL, "/api/v1/s/")
+			if !ok || created == nil || created.TokenHash != hashShareToken(token) {
+				t.Fatalf("expected the link's token to be stored hashed, got URL %q", resp.URL)
+			}
+			if strings.Contains(w.Body.String(), created.TokenHash) {
+				t.Error("expected the token hash not to be returned")
+			}
+			if lifetime := time.Until(resp.ExpiresAt); lifetime > tc.lifetime || lifetime < tc.lifetime-time.Minute {
+				t.Errorf("expected link to expire in %v, expires at %v", tc.lifetime, resp.ExpiresAt)
+			}
+			if (resp.MaxDownloads == nil) != (tc.maxDownloads == nil) || (tc.maxDownloads != nil && *resp.MaxDownloads != *tc.maxDownloads) {
+				t.Errorf("expected download limit %v, got %v", tc.maxDownloads, resp.MaxDownloads)
+			}
+			if resp.HasPassword != tc.password {
+				t.Errorf("expected hasPassword %v, got %v", tc.password, resp.HasPassword)
+			}
+			if tc.password && bcrypt.CompareHashAndPassword([]byte(*created.PasswordHash), []byte(testSharePassword)) != nil {
+				t.Error("expected the password to be stored as a bcrypt hash")
+			}
+			if len(logged) != 1 || logged[0].ActionType != actionFileShareCreate {
+				t.Errorf("expected share creation to be audit logged, got %v", logged)
+			}
+		})
+	}
+}
+
+func TestCreateFileShare_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		body       string
+		file       *repository.StorageFile
+		err        error
+		wantStatus int
+	}{
+		{"invalid ID", "/api/v1/files/abc/shares", "", createTestFile(), nil, http.StatusBadRequest},
+		{"malformed JSON", "/api/v1/files/1/shares", `{"expiresIn":`, createTestFile(), nil, http.StatusBadRequest},
+		{"negative download limit", "/api/v1/files/1/shares", `{"maxDownloads": -1}`, createTestFile(), nil, http.StatusBadRequest},
+		{"expiry over maximum", "/api/v1/files/1/shares", `{"expiresIn": 7776001}`, createTestFile(), nil, http.StatusBadRequest},
+		{"short password", "/api/v1/files/1/shares", `{"password": "short"}`, createTestFile(), nil, http.StatusBadRequest},
+		{"not found", "/api/v1/files/1/shares", "", nil, gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"trashed file", "/api/v1/files/1/shares", "", createTrashedTestFile(), nil, http.StatusGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return tc.file, tc.err
+				},
+				createFileShareFunc: func(_ context.Context, _ *repository.FileShare) error {
+					t.Error("expected no share link to be created")
+					return nil
+				},
+			}
+
+			w := performRequest(setupShareRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodPost, tc.path,
+				strings.NewReader(tc.body), map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Manage File Share Tests
+// =============================================================================
+
+func TestListFileShares_Success(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTestFile(), nil
+		},
+		listFileSharesFunc: func(_ context.Context, fileID int64) ([]repository.FileShare, error) {
+			if fileID != 1 {
+				t.Errorf("expected shares of file 1, got %d", fileID)
+			}
+			return []repository.FileShare{*createPasswordTestShare(t), *createTestShare()}, nil
+		},
+	}
+
+	w := performRequest(setupShareRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodGet, "/api/v1/files/1/shares", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var resp fileSharesResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.FileID != 1 || len(resp.Shares) != 2 || !resp.Shares[0].HasPassword || resp.Shares[1].HasPassword {
+		t.Errorf("expected both shares with their password flags, got %+v", resp)
+	}
+	if strings.Contains(w.Body.String(), hashShareToken(testShareToken)) || strings.Contains(w.Body.String(), `"url"`) {
+		t.Errorf("expected neither tokens nor links in the list, got %s", w.Body.String())
+	}
+}
+
+func TestUpdateFileShare(t *testing.T) {
+	lockedUntil := time.Now().Add(time.Minute)
+	testCases := []struct {
+		name       string
+		body       string
+		getErr     error
+		wantStatus int
+		check      func(t *testing.T, share *repository.FileShare)
+	}{
+		{
+			name:       "new password lifts lockout",
+			body:       `{"password": "another password"}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, share *repository.FileShare) {
+				if share.PasswordHash == nil || bcrypt.CompareHashAndPassword([]byte(*share.PasswordHash), []byte("another password")) != nil {
+					t.Error("expected the new password to be stored")
+				}
+				if share.FailedAttempts != 0 || share.LockedUntil != nil {
+					t.Errorf("expected the lockout lifted, got %d attempts until %v", share.FailedAttempts, share.LockedUntil)
+				}
+			},
+		},
+		{
+			name:       "remove password and limit",
+			body:       `{"password": "", "maxDownloads": 0}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, share *repository.FileShare) {
+				if share.HasPassword() || share.MaxDownloads != nil {
+					t.Errorf("expected an open unlimited share, got %+v", share)
+				}
+			},
+		},
+		{
+			name:       "extend expiry",
+			body:       `{"expiresIn": 86400}`,
+			wantStatus: http.StatusOK,
+			check: func(t *testing.T, share *repository.FileShare) {
+				if time.Until(share.ExpiresAt) < 23*time.Hour || !share.HasPassword() || share.LockedUntil == nil {
+					t.Errorf("expected only the expiry to change, got %+v", share)
+				}
+			},
+		},
+		{name: "nothing to update", body: `{}`, wantStatus: http.StatusBadRequest},
+		{name: "not found", body: `{"expiresIn": 60}`, getErr: gorm.ErrRecordNotFound, wantStatus: http.StatusNotFound},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var updated *repository.FileShare
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return createTestFile(), nil
+				},
+				getFileShareFunc: func(_ context.Context, fileID, id int64) (*repository.FileShare, error) {
+					if fileID != 1 || id != 5 {
+						t.Errorf("expected share 5 of file 1, got %d of %d", id, fileID)
+					}
+					if tc.getErr != nil {
+						return nil, tc.getErr
+					}
+					share := createPasswordTestShare(t)
+					share.MaxDownloads = int64Ptr(3)
+					share.FailedAttempts = 2
+					share.LockedUntil = &lockedUntil
+					return share, nil
+				},
+				updateFileShareFunc: func(_ context.Context, share *repository.FileShare) error {
+					updated = share
+					return nil
+				},
+			}
+
+			w := performRequest(setupShareRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodPatch,
+				"/api/v1/files/1/shares/5", strings.NewReader(tc.body), map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if tc.check != nil {
+				if updated == nil {
+					t.Fatal("expected the share to be saved")
+				}
+				tc.check(t, updated)
+			} else if updated != nil {
+				t.Error("expected the share not to be saved")
+			}
+		})
+	}
+}
+
+func TestDeleteFileShare(t *testing.T) {
+	testCases := []struct {
+		name       string
+		err        error
+		wantStatus int
+	}{
+		{"success", nil, http.StatusOK},
+		{"not found", gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"database error", errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var logged []*commonRepo.ActionLog
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return createTestFile(), nil
+				},
+				deleteFileShareFunc: func(_ context.Context, fileID, id int64) error {
+					if fileID != 1 || id != 5 {
+						t.Errorf("expected share 5 of file 1, got %d of %d", id, fileID)
+					}
+					if tc.err != nil {
+						return fmt.Errorf("failed to delete share id %d: %w", id, tc.err)
+					}
+					return nil
+				},
+			}
+
+			w := performRequest(setupShareRouter(mockRepo, &mockStorage{}, recordActions(&logged)), http.MethodDelete, "/api/v1/files/1/shares/5", nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if wantLog := tc.err == nil; (len(logged) == 1 && logged[0].ActionType == actionFileShareDelete) != wantLog {
+				t.Errorf("expected deletion audit logged: %v, got %v", wantLog, logged)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Shared Download Tests
+// =============================================================================
+
+func TestDownloadSharedFile_CountsDownloads(t *testing.T) {
+	var claims, rangeReads int
+	var logged []*commonRepo.ActionLog
+	router := setupShareRouter(shareDownloadRepo(createTestShare(), &claims), shareDownloadStore(&rangeReads), recordActions(&logged))
+
+	// Ranges and conditionals are ignored so every GET is one whole download
+	w := performRequest(router, http.MethodGet, "/api/v1/s/"+testShareToken, nil,
+		map[string]string{"Range": "bytes=0-1", "If-None-Match": `"abc123"`})
+
+	if w.Code != http.StatusOK || w.Body.String() != "data" {
+		t.Fatalf("expected the whole file, got %d: %s", w.Code, w.Body.String())
+	}
+	if claims != 1 || rangeReads != 0 {
+		t.Errorf("expected one counted full download, got %d claims and %d ranged reads", claims, rangeReads)
+	}
+	if w.Header().Get("Accept-Ranges") != "none" || w.Header().Get("Cache-Control") != "private, no-store" {
+		t.Errorf("expected no ranges or caching, got %q and %q", w.Header().Get("Accept-Ranges"), w.Header().Get("Cache-Control"))
+	}
+	if outcome := shareAccessOutcome(t, logged); outcome != shareAccessDownloaded {
+		t.Errorf("expected access audit logged as %s, got %q", shareAccessDownloaded, outcome)
+	}
+
+	// HEAD shows the file without using a download
+	w = performRequest(router, http.MethodHead, "/api/v1/s/"+testShareToken, nil)
+
+	if w.Code != http.StatusOK || claims != 1 {
+		t.Errorf("expected HEAD to succeed without a claim, got %d with %d claims", w.Code, claims)
+	}
+	if outcome := shareAccessOutcome(t, logged); outcome != shareAccessHead {
+		t.Errorf("expected access audit logged as %s, got %q", shareAccessHead, outcome)
+	}
+}
+
+func TestDownloadSharedFile_StorageError_ReleasesDownload(t *testing.T) {
+	var claims, rangeReads, releases int
+	mockRepo := shareDownloadRepo(createTestShare(), &claims)
+	mockRepo.releaseShareDownloadFunc = func(_ context.Context, id int64) error {
+		if id != 5 {
+			t.Errorf("expected share 5 released, got %d", id)
+		}
+		releases++
+		return nil
+	}
+	mockStore := shareDownloadStore(&rangeReads)
+	mockStore.statObjectFunc = func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+		return minio.ObjectInfo{}, errors.New("storage unavailable")
+	}
+
+	w := performRequest(setupShareRouter(mockRepo, mockStore, &mockActionLogRepo{}), http.MethodGet, "/api/v1/s/"+testShareToken, nil)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if claims != 1 || releases != 1 {
+		t.Errorf("expected the counted download to be given back, got %d claims and %d releases", claims, releases)
+	}
+}
+
+func TestDownloadSharedFile_Refused(t *testing.T) {
+	testCases := []struct {
+		name        string
+		token       string
+		share       func() *repository.FileShare
+		file        *repository.StorageFile
+		claimErr    error
+		wantStatus  int
+		wantOutcome string
+	}{
+		{
+			name:        "unknown token",
+			token:       "other-token",
+			share:       createTestShare,
+			wantStatus:  http.StatusNotFound,
+			wantOutcome: shareAccessNotFound,
+		},
+		{
+			name:  "expired",
+			token: testShareToken,
+			share: func() *repository.FileShare {
+				share := createTestShare()
+				share.ExpiresAt = time.Now().Add(-time.Minute)
+				return share
+			},
+			wantStatus:  http.StatusGone,
+			wantOutcome: shareAccessExpired,
+		},
+		{
+			name:  "downloads used up",
+			token: testShareToken,
+			share: func() *repository.FileShare {
+				share := createTestShare()
+				share.MaxDownloads = int64Ptr(2)
+				share.DownloadCount = 2
+				return share
+			},
+			wantStatus:  http.StatusGone,
+			wantOutcome: shareAccessExhausted,
+		},
+		{
+			name:        "last download taken concurrently",
+			token:       testShareToken,
+			share:       createTestShare,
+			claimErr:    repository.ErrShareUnavailable,
+			wantStatus:  http.StatusGone,
+			wantOutcome: shareAccessExhausted,
+		},
+		{
+			name:        "trashed file",
+			token:       testShareToken,
+			share:       createTestShare,
+			file:        createTrashedTestFile(),
+			wantStatus:  http.StatusNotFound,
+			wantOutcome: shareAccessFileUnavailable,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var claims, rangeReads int
+			var logged []*commonRepo.ActionLog
+			mockRepo := shareDownloadRepo(tc.share(), &claims)
+			if tc.file != nil {
+				mockRepo.getFileByIDFunc = func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return tc.file, nil
+				}
+			}
+			if tc.claimErr != nil {
+				mockRepo.claimShareDownloadFunc = func(_ context.Context, _ int64, _ time.Time) (*repository.FileShare, error) {
+					return nil, tc.claimErr
+				}
+			}
+			mockStore := shareDownloadStore(&rangeReads)
+			mockStore.getObjectFunc = func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+				t.Error("expected the file not to be served")
+				return io.NopCloser(strings.NewReader("")), nil
+			}
+
+			w := performRequest(setupShareRouter(mockRepo, mockStore, recordActions(&logged)), http.MethodGet, "/api/v1/s/"+tc.token, nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if outcome := shareAccessOutcome(t, logged); outcome != tc.wantOutcome {
+				t.Errorf("expected access audit logged as %s, got %q", tc.wantOutcome, outcome)
+			}
+		})
+	}
+}
+
+func TestDownloadSharedFile_Password(t *testing.T) {
+	lockedUntil := time.Now().Add(90 * time.Second)
+	testCases := []struct {
+		name          string
+		password      *string
+		attempts      int
+		locked        bool
+		lockedOnClaim bool
+		wantStatus    int
+		wantOutcome   string
+		wantReset     bool
+	}{
+		{name: "missing", wantStatus: http.StatusUnauthorized, wantOutcome: shareAccessPasswordRequired},
+		{name: "wrong", password: stringPtr("wrong password"), wantStatus: http.StatusUnauthorized, wantOutcome: shareAccessWrongPassword},
+		{name: "wrong locks", password: stringPtr("wrong password"), attempts: 4, wantStatus: http.StatusTooManyRequests, wantOutcome: shareAccessWrongPassword},
+		{name: "locked", password: stringPtr(testSharePassword), locked: true, wantStatus: http.StatusTooManyRequests, wantOutcome: shareAccessLocked},
+		{name: "locked concurrently", password: stringPtr(testSharePassword), lockedOnClaim: true, wantStatus: http.StatusTooManyRequests, wantOutcome: shareAccessLocked},
+		{name: "correct", password: stringPtr(testSharePassword), attempts: 2, wantStatus: http.StatusOK, wantOutcome: shareAccessDownloaded, wantReset: true},
+		{name: "correct on the last attempt", password: stringPtr(testSharePassword), attempts: 4, wantStatus: http.StatusOK, wantOutcome: shareAccessDownloaded, wantReset: true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			share := createPasswordTestShare(t)
+			share.FailedAttempts = tc.attempts
+			if tc.locked {
+				share.LockedUntil = &lockedUntil
+			}
+			var claims, rangeReads, attempts int
+			var reset bool
+			var logged []*commonRepo.ActionLog
+			mockRepo := shareDownloadRepo(share, &claims)
+			mockRepo.claimSharePasswordAttemptFunc = func(_ context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*repository.FileShare, error) {
+				updated := *share
+				if tc.lockedOnClaim {
+					until := now.Add(lockout)
+					updated.LockedUntil = &until
+					return &updated, repository.ErrShareLocked
+				}
+				attempts++
+				updated.FailedAttempts++
+				if updated.FailedAttempts >= maxAttempts {
+					until := now.Add(lockout)
+					updated.FailedAttempts, updated.LockedUntil = 0, &until
+				}
+				return &updated, nil
+			}
+			mockRepo.resetSharePasswordFailuresFunc = func(_ context.Context, _ int64) error {
+				reset = true
+				return nil
+			}
+
+			router := setupShareRouter(mockRepo, shareDownloadStore(&rangeReads), recordActions(&logged))
+			headers := map[string]string{}
+			if tc.password != nil {
+				req, _ := http.NewRequest(http.MethodGet, "/", nil)
+				req.SetBasicAuth("", *tc.password)
+				headers["Authorization"] = req.Header.Get("Authorization")
+			}
+			w := performRequest(router, http.MethodGet, "/api/v1/s/"+testShareToken, nil, headers)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			switch tc.wantStatus {
+			case http.StatusUnauthorized:
+				if w.Header().Get("WWW-Authenticate") != sharePasswordChallenge {
+					t.Errorf("expected a Basic challenge, got %q", w.Header().Get("WWW-Authenticate"))
+				}
+			case http.StatusTooManyRequests:
+				if w.Header().Get("Retry-After") == "" || w.Header().Get("Retry-After") == "0" {
+					t.Errorf("expected Retry-After, got %q", w.Header().Get("Retry-After"))
+				}
+			}
+			// Every password checked is counted before the comparison
+			wantAttempt := tc.password != nil && !tc.locked && !tc.lockedOnClaim
+			if (attempts == 1) != wantAttempt {
+				t.Errorf("expected password attempt counted: %v, got %d", wantAttempt, attempts)
+			}
+			if reset != tc.wantReset {
+				t.Errorf("expected failures reset: %v, got %v", tc.wantReset, reset)
+			}
+			if wantClaims := map[bool]int{true: 1}[tc.wantStatus == http.StatusOK]; claims != wantClaims {
+				t.Errorf("expected %d downloads counted, got %d", wantClaims, claims)
+			}
+			if outcome := shareAccessOutcome(t, logged); outcome != tc.wantOutcome {
+				t.Errorf("expected access audit logged as %s, got %q", tc.wantOutcome, outcome)
+			}
+		})
+	}
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	"github.com/gin-gonic/gin"
+)
+
+// Signed download URLs let anyone holding one download a private file until it expires.
+// The signature is an HMAC-SHA256 over the download path and the expiry, so neither can
+// be changed without invalidating it.
+
+// Query parameters of signed download URLs
+const (
+	signedURLExpiresParam   = "expires"
+	signedURLSignatureParam = "signature"
+)
+
+const actionFileSignedURL = "file_signed_url"
+
+// errInvalidSignature is answered for signed URLs that were altered or have expired
+var errInvalidSignature = errors.New("invalid or expired signature")
+
+// errSignedURLsDisabled is answered for signed URLs without SIGNED_URL_SECRET
+var errSignedURLsDisabled = errors.New("signed URLs are not enabled")
+
+// SignedURLRequest optionally sets the lifetime of a signed download URL
+type SignedURLRequest struct {
+	// Seconds until the URL expires; defaults to the configured expiry
+	ExpiresIn int64 `json:"expiresIn" binding:"omitempty,gt=0"`
+}
+
+// SignedURLResponse is a download URL that works without authentication until ExpiresAt
+type SignedURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// CreateSignedURL godoc
+// @Summary Create signed download URL
+// @Description Issue a download URL for a file that needs no token until it expires, so private files
+// @Description can be shared. The body is optional; expiresIn defaults to the configured expiry and
+// @Description may not exceed the configured maximum. Needs delete permission for files the caller did
+// @Description not upload. Audit logged as file_signed_url. Answered with 503 when SIGNED_URL_SECRET
+// @Description is not set.
+// @Tags files
+// @Accept json
+// @Produce json
+// @Param id path int true "File ID"
+// @Param request body SignedURLRequest false "URL lifetime"
+// @Success 200 {object} SignedURLResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/signed-url [post]
+func (h *Handler) CreateSignedURL(c *gin.Context) {
+	if h.cfg.SignedURLSecret == "" {
+		commonHandlers.RespondError(c, http.StatusServiceUnavailable, errSignedURLsDisabled.Error())
+		return
+	}
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	var req SignedURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid request body")
+		return
+	}
+	expiry := h.cfg.SignedURLExpiry
+	if req.ExpiresIn > 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+	}
+	if expiry > h.cfg.SignedURLMaxExpiry {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("expiresIn must be at most %d seconds", int64(h.cfg.SignedURLMaxExpiry.Seconds())))
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	if !authorizeFileChange(c, file) {
+		return
+	}
+	if isInfected(file) || file.IsPending() {
+		commonHandlers.RespondError(c, http.StatusNotFound, "file not found")
+		return
+	}
+	if file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, trashedFileMessage)
+		return
+	}
+
+	expiresAt := time.Now().Add(expiry).Truncate(time.Second).UTC()
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileSignedURL, &resourceType, &id, &source, map[string]interface{}{
+		"file_type":  file.FileType,
+		"visibility": file.Visibility,
+		"expires_at": expiresAt,
+	})
+
+	c.JSON(http.StatusOK, SignedURLResponse{
+		URL:       h.signedURL(file, expiresAt),
+		ExpiresAt: expiresAt,
+	})
+}
+
+// signedURL is the download URL of file signed to work until expiresAt
+func (h *Handler) signedURL(file *repository.StorageFile, expiresAt time.Time) string {
+	expires := expiresAt.Unix()
+	query := url.Values{}
+	query.Set(signedURLExpiresParam, strconv.FormatInt(expires, 10))
+	query.Set(signedURLSignatureParam, base64.RawURLEncoding.EncodeToString(h.downloadSignature(file.FileType, file.S3Key, expires)))
+	return fileURL(file) + "?" + query.Encode()
+}
+
+// downloadSignature signs the download of key of fileType until expires (Unix seconds)
+func (h *Handler) downloadSignature(fileType, key string, expires int64) []byte {
+	mac := hmac.New(sha256.New, []byte(h.cfg.SignedURLSecret))
+	fmt.Fprintf(mac, "%s/%s\n%d", fileType, key, expires)
+	return mac.Sum(nil)
+}
+
+// verifySignedURL checks the expiry and signature query parameters of a download
+func (h *Handler) verifySignedURL(c *gin.Context, fileType, key string) error {
+	// An empty key would let anyone sign
+	if h.cfg.SignedURLSecret == "" {
+		return errSignedURLsDisabled
+	}
+	expires, err := strconv.ParseInt(c.Query(signedURLExpiresParam), 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return errInvalidSignature
+	}
+	signature, err := base64.RawURLEncoding.DecodeString(c.Query(signedURLSignatureParam))
+	if err != nil {
+		return errInvalidSignature
+	}
+	if !hmac.Equal(signature, h.downloadSignature(fileType, key, expires)) {
+		return errInvalidSignature
+	}
+	return nil
+}
+
+// authorizeDownload lets a private file be downloaded with a valid signed URL or a token
+// granting read access to files. Failures are answered and false is returned.
+func (h *Handler) authorizeDownload(c *gin.Context, fileType, key string) bool {
+	if c.Query(signedURLSignatureParam) != "" || c.Query(signedURLExpiresParam) != "" {
+		if err := h.verifySignedURL(c, fileType, key); err != nil {
+			commonHandlers.RespondError(c, http.StatusForbidden, err.Error())
+			return false
+		}
+		return true
+	}
+
+	scopes, exists := c.Get("scopes")
+	if !exists {
+		commonHandlers.RespondError(c, http.StatusUnauthorized, "authentication or a signed URL is required")
+		return false
+	}
+	scopesMap, _ := scopes.(map[string]string)
+	if !common.HasPermission(scopesMap[common.ResourceFiles], common.LevelRead) {
+		commonHandlers.RespondError(c, http.StatusForbidden, "insufficient permissions")
+		return false
+	}
+	return true
+}
+
+// parseVisibility validates a requested visibility; empty means public
+func parseVisibility(visibility string) (string, error) {
+	switch visibility {
+	case "", repository.FileVisibilityPublic:
+		return repository.FileVisibilityPublic, nil
+	case repository.FileVisibilityPrivate:
+		return visibility, nil
+	}
+	return "", fmt.Errorf("visibility must be %s or %s", repository.FileVisibilityPublic, repository.FileVisibilityPrivate)
+}
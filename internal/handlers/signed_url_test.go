@@ -0,0 +1,366 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func createPrivateTestFile() *repository.StorageFile {
+	file := createTestFile()
+	file.Visibility = repository.FileVisibilityPrivate
+	return file
+}
+
+// setupPrivateDownloadRouter serves file for any key, with scopes set as the
+// optional authentication on the download route would
+func setupPrivateDownloadRouter(file *repository.StorageFile, scopes map[string]string) (*gin.Engine, *Handler) {
+	mockRepo := &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return file, nil
+		},
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return file, nil
+		},
+	}
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{ETag: "abc123", Size: 4, ContentType: testMimeType, LastModified: testLastModified}, nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader("data")), nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", func(c *gin.Context) {
+		if scopes != nil {
+			c.Set("scopes", scopes)
+		}
+		c.Next()
+	}, handler.DownloadFile)
+	return router, handler
+}
+
+// =============================================================================
+// Create Signed URL Tests
+// =============================================================================
+
+func TestCreateSignedURL_Success(t *testing.T) {
+	file := createPrivateTestFile()
+	var logged *commonRepo.ActionLog
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return file, nil
+		},
+	}
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+	handler := New(mockRepo, nil, createTestConfig(), actionLogRepo)
+	router := setupTestRouter()
+	router.POST("/api/v1/files/:id/signed-url", asAdmin(), handler.CreateSignedURL)
+
+	testCases := []struct {
+		name     string
+		body     string
+		lifetime time.Duration
+	}{
+		{"default expiry", "", 15 * time.Minute},
+		{"requested expiry", `{"expiresIn": 3600}`, time.Hour},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := performRequest(router, http.MethodPost, "/api/v1/files/1/signed-url", strings.NewReader(tc.body),
+				map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+			}
+			var resp SignedURLResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("failed to parse response: %v", err)
+			}
+			if lifetime := time.Until(resp.ExpiresAt); lifetime > tc.lifetime || lifetime < tc.lifetime-time.Minute {
+				t.Errorf("expected URL to expire in %v, expires at %v", tc.lifetime, resp.ExpiresAt)
+			}
+			signed, err := url.Parse(resp.URL)
+			if err != nil || signed.Path != testDownloadPath {
+				t.Fatalf("expected signed download URL of the file, got %q", resp.URL)
+			}
+			if signed.Query().Get("expires") == "" || signed.Query().Get("signature") == "" {
+				t.Errorf("expected expiry and signature in %q", resp.URL)
+			}
+			if logged == nil || logged.ActionType != actionFileSignedURL || logged.ResourceID == nil || *logged.ResourceID != 1 {
+				t.Errorf("expected signed URL to be audit logged, got %+v", logged)
+			}
+		})
+	}
+}
+
+func TestCreateSignedURL_Errors(t *testing.T) {
+	testCases := []struct {
+		name       string
+		path       string
+		body       string
+		file       *repository.StorageFile
+		err        error
+		wantStatus int
+	}{
+		{"invalid ID", "/api/v1/files/abc/signed-url", "", createTestFile(), nil, http.StatusBadRequest},
+		{"malformed JSON", "/api/v1/files/1/signed-url", `{"expiresIn":`, createTestFile(), nil, http.StatusBadRequest},
+		{"negative expiry", "/api/v1/files/1/signed-url", `{"expiresIn": -1}`, createTestFile(), nil, http.StatusBadRequest},
+		{"expiry over maximum", "/api/v1/files/1/signed-url", `{"expiresIn": 86401}`, createTestFile(), nil, http.StatusBadRequest},
+		{"not found", "/api/v1/files/1/signed-url", "", nil, gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"database error", "/api/v1/files/1/signed-url", "", nil, errors.New("database error"), http.StatusInternalServerError},
+		{"trashed file", "/api/v1/files/1/signed-url", "", createTrashedTestFile(), nil, http.StatusGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return tc.file, tc.err
+				},
+			}
+			handler := New(mockRepo, nil, createTestConfig(), &mockActionLogRepo{})
+			router := setupTestRouter()
+			router.POST("/api/v1/files/:id/signed-url", asAdmin(), handler.CreateSignedURL)
+
+			w := performRequest(router, http.MethodPost, tc.path, strings.NewReader(tc.body),
+				map[string]string{"Content-Type": "application/json"})
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCreateSignedURL_WithoutSecret(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.SignedURLSecret = ""
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			t.Error("expected no file lookup without a signing secret")
+			return createPrivateTestFile(), nil
+		},
+	}
+	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files/:id/signed-url", asAdmin(), handler.CreateSignedURL)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/1/signed-url", nil)
+
+	if w.Code != http.StatusServiceUnavailable {
+		t.Errorf("expected status %d, got %d: %s", http.StatusServiceUnavailable, w.Code, w.Body.String())
+	}
+}
+
+// =============================================================================
+// Private Download Tests
+// =============================================================================
+
+func TestDownloadFile_PrivateRequiresAuthorization(t *testing.T) {
+	testCases := []struct {
+		name       string
+		scopes     map[string]string
+		wantStatus int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"no files access", map[string]string{"profile": common.LevelDelete}, http.StatusForbidden},
+		{"read access", map[string]string{common.ResourceFiles: common.LevelRead}, http.StatusOK},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			router, _ := setupPrivateDownloadRouter(createPrivateTestFile(), tc.scopes)
+
+			w := performRequest(router, http.MethodGet, testDownloadPath, nil)
+
+			if w.Code != tc.wantStatus {
+				t.Fatalf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if w.Code == http.StatusOK && w.Header().Get("Cache-Control") != "private, no-store" {
+				t.Errorf("expected private file not to be cached, got %q", w.Header().Get("Cache-Control"))
+			}
+		})
+	}
+}
+
+func TestDownloadFile_PrivateSignedURL(t *testing.T) {
+	file := createPrivateTestFile()
+	router, handler := setupPrivateDownloadRouter(file, nil)
+	signed := handler.signedURL(file, time.Now().Add(time.Minute))
+	query, _ := url.ParseQuery(strings.SplitN(signed, "?", 2)[1])
+	expired := handler.signedURL(file, time.Now().Add(-time.Minute))
+
+	other := *file
+	other.S3Key = "other.png"
+	otherSigned := handler.signedURL(&other, time.Now().Add(time.Minute))
+
+	testCases := []struct {
+		name       string
+		path       string
+		wantStatus int
+	}{
+		{"valid signature", signed, http.StatusOK},
+		{"expired", expired, http.StatusForbidden},
+		{"extended expiry", testDownloadPath + "?expires=" + url.QueryEscape(query.Get("expires")+"0") + "&signature=" + query.Get("signature"), http.StatusForbidden},
+		{"signature of another file", testDownloadPath + "?" + strings.SplitN(otherSigned, "?", 2)[1], http.StatusForbidden},
+		{"missing signature", testDownloadPath + "?expires=" + query.Get("expires"), http.StatusForbidden},
+		{"malformed signature", testDownloadPath + "?expires=" + query.Get("expires") + "&signature=%%%", http.StatusForbidden},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			w := performRequest(router, http.MethodGet, tc.path, nil)
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestDownloadFile_SignedURLWithoutSecret(t *testing.T) {
+	file := createPrivateTestFile()
+	router, handler := setupPrivateDownloadRouter(file, nil)
+	// Signed with an empty key, as anyone could without the secret
+	handler.cfg.SignedURLSecret = ""
+	signed := handler.signedURL(file, time.Now().Add(time.Minute))
+
+	w := performRequest(router, http.MethodGet, signed, nil)
+
+	if w.Code != http.StatusForbidden {
+		t.Errorf("expected status %d, got %d: %s", http.StatusForbidden, w.Code, w.Body.String())
+	}
+}
+
+func TestDownloadFile_PublicNeedsNoAuthorization(t *testing.T) {
+	router, _ := setupPrivateDownloadRouter(createTestFile(), nil)
+
+	// Public files ignore signature parameters entirely
+	for _, path := range []string{testDownloadPath, testDownloadPath + "?expires=1&signature=invalid"} {
+		w := performRequest(router, http.MethodGet, path, nil)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("%s: expected status %d, got %d", path, http.StatusOK, w.Code)
+		}
+		if w.Header().Get("Cache-Control") == "private, no-store" {
+			t.Errorf("%s: expected public caching, got %q", path, w.Header().Get("Cache-Control"))
+		}
+	}
+}
+
+// =============================================================================
+// Private Upload Tests
+// =============================================================================
+
+// performVisibilityUpload uploads a PNG as a portfolio image with the given visibility
+func performVisibilityUpload(t *testing.T, router *gin.Engine, visibility string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="photo.png"`},
+		"Content-Type":        {"image/png"},
+	})
+	if err != nil {
+		t.Fatalf("failed to create form part: %v", err)
+	}
+	if _, err := part.Write(testPNGData()); err != nil {
+		t.Fatalf("failed to write form part: %v", err)
+	}
+	if err := writer.WriteField("fileType", "portfolio-image"); err != nil {
+		t.Fatalf("failed to write field: %v", err)
+	}
+	if err := writer.WriteField("visibility", visibility); err != nil {
+		t.Fatalf("failed to write field: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close form: %v", err)
+	}
+	return performRequest(router, http.MethodPost, "/api/v1/files", body,
+		map[string]string{"Content-Type": writer.FormDataContentType()})
+}
+
+func TestUploadFile_PrivateVariants(t *testing.T) {
+	var createdFile *repository.StorageFile
+	var createdVariants []repository.FileVariant
+	mockRepo := &mockRepository{
+		createFileWithVariantsFunc: func(_ context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+			file.ID = 10
+			createdFile = file
+			createdVariants = variants
+			return nil
+		},
+	}
+	store := &recordingStore{}
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	w := performVisibilityUpload(t, router, repository.FileVisibilityPrivate)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if createdFile == nil || !createdFile.IsPrivate() {
+		t.Fatalf("expected a private file, got %+v", createdFile)
+	}
+	for _, variant := range createdVariants {
+		if !variant.File.IsPrivate() {
+			t.Errorf("expected variant %s to be private like its original", variant.Name)
+		}
+	}
+	var resp struct {
+		Visibility string `json:"visibility"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil || resp.Visibility != repository.FileVisibilityPrivate {
+		t.Errorf("expected private visibility in response, got %s", w.Body.String())
+	}
+}
+
+func TestUploadFile_InvalidVisibility(t *testing.T) {
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, _ *repository.StorageFile) error {
+			t.Error("expected no file to be recorded")
+			return nil
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	w := performVisibilityUpload(t, router, "hidden")
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d: %s", http.StatusBadRequest, w.Code, w.Body.String())
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// transformQueryParams are the download query parameters that request a derived image
+var transformQueryParams = []string{"w", "h", "fit", "fmt"}
+
+// transformableMimeTypes are the source formats the image pipeline can decode
+var transformableMimeTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+
+func isTransformRequest(c *gin.Context) bool {
+	for _, param := range transformQueryParams {
+		if _, ok := c.GetQuery(param); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// serveTransformedImage serves a resized or converted image, rendering it and
+// caching it as a derived object in the original's bucket on first request
+func (h *Handler) serveTransformedImage(c *gin.Context, ft *filetypes.FileType, fileRecord *repository.StorageFile) {
+	if !ft.IsImage() || !slices.Contains(transformableMimeTypes, fileRecord.MimeType) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "only images can be resized or converted")
+		return
+	}
+
+	transform, err := h.parseTransform(c, fileRecord.MimeType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	bucket := ft.Bucket
+	// Derived images belong to the object, so a new version of the file gets its own
+	derivedKey := images.DerivedKey(fileRecord.ObjectKey(), transform)
+	fileName := derivedFileName(fileRecord.FileName, transform.Format)
+
+	// Serve the cached derivative when it has been rendered before
+	info, err := h.storage.StatObject(c.Request.Context(), bucket, derivedKey)
+	if err == nil {
+		h.serveStoredObject(c, ft, derivedKey, fileName, info, fileRecord)
+		return
+	}
+	if !storage.IsNotFound(err) {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to get file info")
+		return
+	}
+
+	data, err := h.renderDerivedImage(c, bucket, fileRecord, transform)
+	if err != nil {
+		if errors.Is(err, images.ErrImageTooLarge) {
+			commonHandlers.RespondError(c, http.StatusUnprocessableEntity, err.Error())
+			return
+		}
+		if errors.Is(err, images.ErrEncoderBusy) {
+			c.Header("Retry-After", "1")
+			commonHandlers.RespondError(c, http.StatusServiceUnavailable, "image encoder busy, retry shortly")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to render image")
+		return
+	}
+
+	// A failed cache write only costs a re-render on the next request
+	contentType := transform.Format.ContentType()
+	if err := h.storage.PutObject(c.Request.Context(), bucket, derivedKey, bytes.NewReader(data), int64(len(data)), contentType); err != nil {
+		logger.GetLogger(c).Error("Failed to cache derived image",
+			"error", err,
+			"bucket", bucket,
+			"key", derivedKey,
+		)
+	}
+
+	c.Header("Content-Disposition", contentDisposition(fileName))
+	c.Header("Cache-Control", cacheControl(c, ft, fileRecord))
+	c.Header("Access-Control-Expose-Headers", downloadExposedHeaders)
+	if c.Request.Method == http.MethodGet {
+		h.logDownload(c, fileRecord, ft.Name)
+	}
+	c.Data(http.StatusOK, contentType, data)
+}
+
+// parseTransform validates the transform query parameters against the configured sizes
+func (h *Handler) parseTransform(c *gin.Context, sourceMimeType string) (images.Transform, error) {
+	width, err := h.parseImageSize(c.Query("w"))
+	if err != nil {
+		return images.Transform{}, err
+	}
+	height, err := h.parseImageSize(c.Query("h"))
+	if err != nil {
+		return images.Transform{}, err
+	}
+
+	fit := images.Fit(c.DefaultQuery("fit", string(images.FitContain)))
+	if fit != images.FitContain && fit != images.FitCover {
+		return images.Transform{}, fmt.Errorf("fit must be %s or %s", images.FitContain, images.FitCover)
+	}
+	if fit == images.FitCover && (width == 0 || height == 0) {
+		return images.Transform{}, fmt.Errorf("fit=%s requires both w and h", images.FitCover)
+	}
+
+	format, err := images.ParseFormat(c.Query("fmt"), sourceMimeType)
+	if err != nil {
+		return images.Transform{}, err
+	}
+
+	return images.Transform{Width: width, Height: height, Fit: fit, Format: format}, nil
+}
+
+// parseImageSize parses a w or h parameter; empty means unconstrained
+func (h *Handler) parseImageSize(value string) (int, error) {
+	if value == "" {
+		return 0, nil
+	}
+	size, err := strconv.Atoi(value)
+	if err != nil || !slices.Contains(h.cfg.ImageAllowedSizes, size) {
+		return 0, fmt.Errorf("image size must be one of %v", h.cfg.ImageAllowedSizes)
+	}
+	return size, nil
+}
+
+// renderDerivedImage loads the original object and applies the transform
+func (h *Handler) renderDerivedImage(c *gin.Context, bucket string, fileRecord *repository.StorageFile, transform images.Transform) ([]byte, error) {
+	object, err := h.storage.GetObject(c.Request.Context(), bucket, fileRecord.ObjectKey())
+	if err != nil {
+		return nil, err
+	}
+	defer object.Close()
+
+	// Originals never exceed the upload limit, so this bounds memory use
+	src, err := io.ReadAll(io.LimitReader(object, h.cfg.FileTypes.MaxSize()+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read original image: %w", err)
+	}
+
+	return images.Render(src, transform)
+}
+
+// derivedFileName swaps the extension of the original filename for the output format
+func derivedFileName(fileName string, format images.Format) string {
+	base := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	ext := filetypes.Extensions(format.ContentType())[0]
+	return base + ext
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+)
+
+// =============================================================================
+// Image Transform Test Helpers
+// =============================================================================
+
+func setupTransformRouter(mockRepo *mockRepository, mockStore *mockStorage) *gin.Engine {
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.GET("/api/v1/files/:fileType/*key", handler.DownloadFile)
+	return router
+}
+
+func imageFileRepo(file *repository.StorageFile) *mockRepository {
+	return &mockRepository{
+		getFileByKeyFunc: func(_ context.Context, _, _ string) (*repository.StorageFile, error) {
+			return file, nil
+		},
+	}
+}
+
+// fakeWebPEncoder installs a stand-in for cwebp answering with a minimal WebP header
+func fakeWebPEncoder(t *testing.T) {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake encoder is a shell script")
+	}
+	encoder := filepath.Join(t.TempDir(), "cwebp")
+	script := "#!/bin/sh\ncat > /dev/null\nprintf 'RIFF\\000\\000\\000\\000WEBPVP8 '\n"
+	if err := os.WriteFile(encoder, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake encoder: %v", err)
+	}
+	if err := images.SetWebPEncoder(encoder, 1); err != nil {
+		t.Fatalf("failed to set webp encoder: %v", err)
+	}
+	t.Cleanup(func() { _ = images.SetWebPEncoder("", 0) })
+}
+
+// =============================================================================
+// Image Transform Tests
+// =============================================================================
+
+func TestDownloadFile_Transform_RendersAndCaches(t *testing.T) {
+	var cachedKey, cachedType string
+	var cachedData []byte
+
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+		},
+		getObjectFunc: func(_ context.Context, _, key string) (io.ReadCloser, error) {
+			if key != testFileKey {
+				t.Errorf("expected original key %s, got %s", testFileKey, key)
+			}
+			return io.NopCloser(bytes.NewReader(testPNGData())), nil
+		},
+		putObjectFunc: func(_ context.Context, _, key string, reader io.Reader, _ int64, contentType string) error {
+			cachedKey = key
+			cachedType = contentType
+			cachedData, _ = io.ReadAll(reader)
+			return nil
+		},
+	}
+	router := setupTransformRouter(imageFileRepo(createTestFile()), mockStore)
+
+	w := performRequest(router, http.MethodGet, "/api/v1/files/portfolio-image/"+testFileKey+"?w=2&h=2&fit=cover", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(w.Body.Bytes()))
+	if err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if cfg.Width != 2 || cfg.Height != 2 || format != "png" {
+		t.Errorf("expected 2x2 png, got %dx%d %s", cfg.Width, cfg.Height, format)
+	}
+	if cachedKey != "_derived/"+testFileKey+"/w2-h2-cover.png" {
+		t.Errorf("unexpected derived key %q", cachedKey)
+	}
+	if cachedType != "image/png" || !bytes.Equal(cachedData, w.Body.Bytes()) {
+		t.Error("expected rendered image to be cached as served")
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "test-image.png") {
+		t.Errorf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestDownloadFile_Transform_WebP(t *testing.T) {
+	fakeWebPEncoder(t)
+	var cachedKey string
+
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+			return minio.ObjectInfo{}, minio.ErrorResponse{Code: "NoSuchKey", StatusCode: http.StatusNotFound}
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(testPNGData())), nil
+		},
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+			cachedKey = key
+			return nil
+		},
+	}
+	router := setupTransformRouter(imageFileRepo(createTestFile()), mockStore)
+
+	w := performRequest(router, http.MethodGet, "/api/v1/files/portfolio-image/"+testFileKey+"?w=2&fmt=webp", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if w.Header().Get("Content-Type") != "image/webp" {
+		t.Errorf("expected image/webp, got %q", w.Header().Get("Content-Type"))
+	}
+	if !bytes.HasPrefix(w.Body.Bytes(), []byte("RIFF")) {
+		t.Error("expected encoder output as body")
+	}
+	if cachedKey != "_derived/"+testFileKey+"/w2-h0-contain.webp" {
+		t.Errorf("unexpected derived key %q", cachedKey)
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "test-image.webp") {
+		t.Errorf("unexpected Content-Disposition %q", w.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestDownloadFile_Transform_ServesCachedVariant(t *testing.T) {
+	cached := testJPEGData()
+	var statKey string
+	var rendered bool
+
+	mockStore := &mockStorage{
+		statObjectFunc: func(_ context.Context, _, key string) (minio.ObjectInfo, error) {
+			statKey = key
+			return minio.ObjectInfo{ETag: "variant", Size: int64(len(cached)), ContentType: "image/jpeg"}, nil
+		},
+		getObjectFunc: func(_ context.Context, _, key string) (io.ReadCloser, error) {
+			if key == testFileKey {
+				rendered = true
+			}
+			return io.NopCloser(bytes.NewReader(cached)), nil
+		},
+	}
+	router := setupTransformRouter(imageFileRepo(createTestFile()), mockStore)
+
+	w := performRequest(router, http.MethodGet, "/api/v1/files/portfolio-image/"+testFileKey+"?w=4&fmt=jpg", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if statKey != "_derived/"+testFileKey+"/w4-h0-contain.jpeg" {
+		t.Errorf("unexpected derived key %q", statKey)
+	}
+	if rendered {
+		t.Error("expected cached variant to be served without rendering")
+	}
+	if !bytes.Equal(w.Body.Bytes(), cached) {
+		t.Error("expected cached variant body")
+	}
+	if w.Header().Get("ETag") != `"variant"` {
+		t.Errorf("expected variant ETag, got %q", w.Header().Get("ETag"))
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), "test-image.jpg") {
+		t.Errorf("expected converted filename, got %q", w.Header().Get("Content-Disposition"))
+	}
+}
+
+func TestDownloadFile_Transform_Validation(t *testing.T) {
+	document := createTestFile()
+	document.FileType = "document"
+	document.MimeType = "application/pdf"
+
+	testCases := []struct {
+		name  string
+		file  *repository.StorageFile
+		path  string
+		query string
+	}{
+		{"size not allowed", createTestFile(), "portfolio-image", "w=3"},
+		{"size not a number", createTestFile(), "portfolio-image", "w=big"},
+		{"unknown fit", createTestFile(), "portfolio-image", "w=2&h=2&fit=stretch"},
+		{"cover needs both sizes", createTestFile(), "portfolio-image", "w=2&fit=cover"},
+		{"webp output without encoder", createTestFile(), "portfolio-image", "fmt=webp"},
+		{"document", document, "document", "w=2"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var storageUsed bool
+			mockStore := &mockStorage{
+				statObjectFunc: func(_ context.Context, _, _ string) (minio.ObjectInfo, error) {
+					storageUsed = true
+					return minio.ObjectInfo{}, nil
+				},
+			}
+			router := setupTransformRouter(imageFileRepo(tc.file), mockStore)
+
+			w := performRequest(router, http.MethodGet, "/api/v1/files/"+tc.path+"/"+testFileKey+"?"+tc.query, nil)
+
+			if w.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+			}
+			if storageUsed {
+				t.Error("expected validation to fail before storage access")
+			}
+		})
+	}
+}
+
+func TestDerivedFileName(t *testing.T) {
+	if got := derivedFileName("photo.final.png", "jpeg"); got != "photo.final.jpg" {
+		t.Errorf("expected photo.final.jpg, got %s", got)
+	}
+	if got := derivedFileName("scan", "png"); got != "scan.png" {
+		t.Errorf("expected scan.png, got %s", got)
+	}
+}
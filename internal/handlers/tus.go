@@ -0,0 +1,443 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gabriel-vasile/mimetype"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/minio/minio-go/v7"
+)
+
+const (
+	tusVersion          = "1.0.0"
+	tusExtensions       = "creation,expiration,termination"
+	tusPatchContentType = "application/offset+octet-stream"
+	tusExposedHeaders   = "Location,Tus-Resumable,Tus-Version,Tus-Extension,Tus-Max-Size,Upload-Offset,Upload-Length,Upload-Expires,X-File-Id,X-File-Url"
+
+	// sniffLength is the number of leading bytes inspected for content detection
+	sniffLength = 3072
+)
+
+// TusProtocol sets tus protocol headers on every response and rejects
+// requests from clients speaking an unsupported protocol version.
+func (h *Handler) TusProtocol() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("Tus-Resumable", tusVersion)
+		c.Header("Tus-Version", tusVersion)
+		c.Header("Tus-Extension", tusExtensions)
+		c.Header("Tus-Max-Size", strconv.FormatInt(h.cfg.FileTypes.MaxSize(), 10))
+		c.Header("Access-Control-Expose-Headers", tusExposedHeaders)
+
+		if c.GetHeader("Tus-Resumable") != tusVersion {
+			c.AbortWithStatusJSON(http.StatusPreconditionFailed, gin.H{"error": "unsupported tus version"})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// CreateTusUpload godoc
+// @Summary Create resumable upload
+// @Description Start a tus 1.0 resumable upload. Upload-Metadata must contain base64 encoded filename, contentType and fileType, and may contain visibility (public or private, default public).
+// @Description Uploads over the caller's storage quotas are rejected with 413; open uploads count towards the quotas with their Upload-Length.
+// @Tags uploads
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param Upload-Length header int true "Total upload size in bytes"
+// @Param Upload-Metadata header string true "tus metadata: filename, contentType, fileType, optional visibility"
+// @Success 201 "Created, Location header points to the upload"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/tus [post]
+func (h *Handler) CreateTusUpload(c *gin.Context) {
+	if c.GetHeader("Upload-Defer-Length") != "" {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "deferred upload length is not supported")
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil || length <= 0 {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid Upload-Length header")
+		return
+	}
+
+	metadata, err := parseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid Upload-Metadata header")
+		return
+	}
+
+	fileName := metadata["filename"]
+	if fileName == "" {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "filename metadata is required")
+		return
+	}
+	fileType := metadata["fileType"]
+	if fileType == "" {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("fileType metadata is required (%s)", h.fileTypeNames()))
+		return
+	}
+	ft, err := h.fileType(fileType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if length > ft.MaxSize {
+		commonHandlers.RespondError(c, http.StatusRequestEntityTooLarge, fmt.Sprintf("file too large (max %d bytes)", ft.MaxSize))
+		return
+	}
+	visibility, err := parseVisibility(metadata["visibility"])
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	if !h.checkQuota(c, ft, length) {
+		return
+	}
+
+	// Declared type is validated now and verified against content on the first chunk
+	declaredType := metadata["contentType"]
+	if !h.isAllowedContentType(declaredType) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file type")
+		return
+	}
+	contentType := normalizeMimeType(declaredType)
+
+	if err := ft.CheckMimeType(contentType); err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	ext, err := ft.ResolveExtension(fileName, contentType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	bucket := ft.Bucket
+
+	key := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	uploadID, err := h.storage.NewMultipartUpload(c.Request.Context(), bucket, key, contentType)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to start upload")
+		return
+	}
+
+	session := &repository.UploadSession{
+		ID:         uuid.New().String(),
+		Protocol:   repository.UploadProtocolTus,
+		S3Bucket:   bucket,
+		S3Key:      key,
+		S3UploadID: uploadID,
+		FileName:   fileName,
+		FileType:   fileType,
+		MimeType:   contentType,
+		Visibility: visibility,
+		Length:     length,
+		UserID:     audit.GetUserID(c),
+		ExpiresAt:  time.Now().Add(h.cfg.TusUploadExpiry),
+	}
+	session.QuotaCheck = h.quotaCheck(session.UserID, ft, length, 1)
+	if err := h.repo.CreateUploadSession(c.Request.Context(), session); err != nil {
+		h.abortMultipartUpload(c, session)
+		if refusal, ok := quotaRefusal(err); ok {
+			respondUploadError(c, refusal)
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create upload")
+		return
+	}
+
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+session.ID)
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Status(http.StatusCreated)
+}
+
+// GetTusUploadStatus godoc
+// @Summary Get resumable upload offset
+// @Description Return the current offset of a tus upload so the client can resume
+// @Tags uploads
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param id path string true "Upload ID"
+// @Success 200 "Upload-Offset and Upload-Length headers"
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/tus/{id} [head]
+func (h *Handler) GetTusUploadStatus(c *gin.Context) {
+	session, ok := h.loadUploadSession(c, repository.UploadProtocolTus)
+	if !ok {
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("Upload-Length", strconv.FormatInt(session.Length, 10))
+	c.Header("Upload-Expires", session.ExpiresAt.UTC().Format(http.TimeFormat))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
+}
+
+// PatchTusUpload godoc
+// @Summary Upload a chunk
+// @Description Append a chunk to a tus upload. Every chunk except the last must be at least 5 MiB (S3 minimum part size).
+// @Description The file record is created when the final chunk arrives; its ID and URL are returned in X-File-Id and X-File-Url.
+// @Tags uploads
+// @Accept application/offset+octet-stream
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param Upload-Offset header int true "Offset the chunk starts at"
+// @Param id path string true "Upload ID"
+// @Success 204 "Upload-Offset header contains the new offset"
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 411 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 415 {object} map[string]string
+// @Failure 422 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/tus/{id} [patch]
+func (h *Handler) PatchTusUpload(c *gin.Context) {
+	if c.ContentType() != tusPatchContentType {
+		commonHandlers.RespondError(c, http.StatusUnsupportedMediaType, "Content-Type must be "+tusPatchContentType)
+		return
+	}
+
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid Upload-Offset header")
+		return
+	}
+
+	session, ok := h.loadUploadSession(c, repository.UploadProtocolTus)
+	if !ok {
+		return
+	}
+	if offset != session.Offset {
+		commonHandlers.RespondError(c, http.StatusConflict, "Upload-Offset does not match current offset")
+		return
+	}
+
+	// Chunks are committed as whole S3 parts, so the size must be known up front
+	size := c.Request.ContentLength
+	if size < 0 {
+		commonHandlers.RespondError(c, http.StatusLengthRequired, "Content-Length is required")
+		return
+	}
+	remaining := session.Length - session.Offset
+	if size > remaining {
+		commonHandlers.RespondError(c, http.StatusRequestEntityTooLarge, "chunk exceeds Upload-Length")
+		return
+	}
+	if size == 0 {
+		c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+		c.Status(http.StatusNoContent)
+		return
+	}
+	isFinal := size == remaining
+	if !isFinal && size < storage.MinPartSize {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("non-final chunks must be at least %d bytes", storage.MinPartSize))
+		return
+	}
+
+	body := io.Reader(c.Request.Body)
+	if session.Offset == 0 {
+		// Verify the declared type against the real content before storing anything
+		buffered := bufio.NewReaderSize(c.Request.Body, sniffLength)
+		head, err := buffered.Peek(sniffLength)
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, bufio.ErrBufferFull) {
+			commonHandlers.LogAndRespondError(c, http.StatusBadRequest, err, "failed to read upload chunk")
+			return
+		}
+		if normalizeMimeType(mimetype.Detect(head).String()) != session.MimeType {
+			commonHandlers.RespondError(c, http.StatusBadRequest, errContentTypeMismatch.Error())
+			return
+		}
+		body = buffered
+	}
+
+	// Each PATCH writes to a part number of its own, so one racing at the same offset
+	// cannot overwrite the part recorded by the other
+	partNumber, err := h.repo.ClaimUploadPart(c.Request.Context(), session)
+	if err != nil {
+		if errors.Is(err, repository.ErrUploadOffsetConflict) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload was modified concurrently")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to update upload")
+		return
+	}
+	etag, err := h.storage.PutObjectPart(c.Request.Context(), session.S3Bucket, session.S3Key, session.S3UploadID, partNumber, body, size)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to store upload chunk")
+		return
+	}
+
+	expectedOffset := session.Offset
+	session.Parts = append(session.Parts, repository.UploadPart{Number: partNumber, ETag: etag, Size: size})
+	session.Offset += size
+
+	if isFinal {
+		h.completeTusUpload(c, session)
+		return
+	}
+
+	if err := h.repo.UpdateUploadSessionProgress(c.Request.Context(), session, expectedOffset); err != nil {
+		if errors.Is(err, repository.ErrUploadOffsetConflict) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload was modified concurrently")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to update upload")
+		return
+	}
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Status(http.StatusNoContent)
+}
+
+// TerminateTusUpload godoc
+// @Summary Cancel resumable upload
+// @Description Abort a tus upload and discard all uploaded chunks
+// @Tags uploads
+// @Param Tus-Resumable header string true "Protocol version (1.0.0)"
+// @Param id path string true "Upload ID"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/tus/{id} [delete]
+func (h *Handler) TerminateTusUpload(c *gin.Context) {
+	session, ok := h.loadUploadSession(c, repository.UploadProtocolTus)
+	if !ok {
+		return
+	}
+
+	// The session is removed first, so an upload completed meanwhile keeps its object
+	if err := h.repo.DeleteUploadSession(c.Request.Context(), session.ID); err != nil {
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			commonHandlers.RespondError(c, http.StatusNotFound, "upload not found")
+			return
+		}
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to delete upload")
+		return
+	}
+	h.abortMultipartUpload(c, session)
+
+	c.Status(http.StatusNoContent)
+}
+
+// completeTusUpload assembles the uploaded parts and creates the file record
+func (h *Handler) completeTusUpload(c *gin.Context, session *repository.UploadSession) {
+	parts := make([]minio.CompletePart, len(session.Parts))
+	for i, part := range session.Parts {
+		parts[i] = minio.CompletePart{PartNumber: part.Number, ETag: part.ETag}
+	}
+	if err := h.storage.CompleteMultipartUpload(c.Request.Context(), session.S3Bucket, session.S3Key, session.S3UploadID, parts); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to complete upload")
+		return
+	}
+
+	imageInfo, hash, err := h.processStoredUpload(c, session)
+	if err != nil {
+		if h.discardUpload(c, session) {
+			respondImageError(c, err)
+		}
+		return
+	}
+
+	fileRecord := session.NewFile()
+	fileRecord.SetImageInfo(imageInfo)
+	if !h.scanStoredUpload(c, session, fileRecord) {
+		return
+	}
+	// Set once the content is clean, so infected uploads never share an object
+	fileRecord.ContentHash = &hash
+
+	if err := h.repo.CompleteUploadSession(c.Request.Context(), session, fileRecord); err != nil {
+		// The object now belongs to the file of the request that completed first
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload already completed")
+			return
+		}
+		// The multipart upload is already assembled, so the session cannot be resumed
+		if h.discardUpload(c, session) {
+			commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create file record")
+		}
+		return
+	}
+
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, audit.ActionFileUpload, &resourceType, &fileRecord.ID, &source, map[string]interface{}{
+		"filename":  fileRecord.FileName,
+		"file_type": fileRecord.FileType,
+		"size":      fileRecord.FileSize,
+		"mime_type": fileRecord.MimeType,
+		"sha256":    hash,
+		"shared":    fileRecord.SharesObject,
+		"protocol":  repository.UploadProtocolTus,
+	})
+
+	c.Header("Upload-Offset", strconv.FormatInt(session.Offset, 10))
+	c.Header("X-File-Id", strconv.FormatInt(fileRecord.ID, 10))
+	c.Header("X-File-Url", fileURL(fileRecord))
+	c.Status(http.StatusNoContent)
+}
+
+// abortMultipartUpload discards uploaded parts, logging failures without failing the request
+func (h *Handler) abortMultipartUpload(c *gin.Context, session *repository.UploadSession) {
+	if err := h.storage.AbortMultipartUpload(c.Request.Context(), session.S3Bucket, session.S3Key, session.S3UploadID); err != nil {
+		logger.GetLogger(c).Error("Failed to abort multipart upload",
+			"error", err,
+			"bucket", session.S3Bucket,
+			"key", session.S3Key,
+			"upload_id", session.ID,
+		)
+	}
+}
+
+// parseTusMetadata decodes the Upload-Metadata header
+// ("key base64value,key2 base64value2") into a map
+func parseTusMetadata(header string) (map[string]string, error) {
+	metadata := make(map[string]string)
+	if strings.TrimSpace(header) == "" {
+		return metadata, nil
+	}
+
+	for _, pair := range strings.Split(header, ",") {
+		fields := strings.Fields(pair)
+		switch len(fields) {
+		case 1:
+			metadata[fields[0]] = ""
+		case 2:
+			value, err := base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, fmt.Errorf("invalid metadata value for %s: %w", fields[0], err)
+			}
+			metadata[fields[0]] = string(value)
+		default:
+			return nil, fmt.Errorf("invalid metadata pair: %q", pair)
+		}
+	}
+
+	return metadata, nil
+}
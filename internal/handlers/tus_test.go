@@ -0,0 +1,778 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/gin-gonic/gin"
+	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Tus Test Helpers
+// =============================================================================
+
+const testUploadID = "session-123"
+
+func setupTusRouter(handler *Handler, userID int64) *gin.Engine {
+	router := setupTestRouter()
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", userID)
+		c.Next()
+	})
+	tus := router.Group("/api/v1/files/tus")
+	tus.Use(handler.TusProtocol())
+	{
+		tus.POST("", handler.CreateTusUpload)
+		tus.HEAD("/:id", handler.GetTusUploadStatus)
+		tus.PATCH("/:id", handler.PatchTusUpload)
+		tus.DELETE("/:id", handler.TerminateTusUpload)
+	}
+	return router
+}
+
+func tusMetadata(pairs map[string]string) string {
+	encoded := make([]string, 0, len(pairs))
+	for key, value := range pairs {
+		encoded = append(encoded, key+" "+base64.StdEncoding.EncodeToString([]byte(value)))
+	}
+	return strings.Join(encoded, ",")
+}
+
+func tusHeaders(extra map[string]string) map[string]string {
+	headers := map[string]string{"Tus-Resumable": tusVersion}
+	for key, value := range extra {
+		headers[key] = value
+	}
+	return headers
+}
+
+func createTestUploadSession(length, offset int64) *repository.UploadSession {
+	userID := int64(1)
+	return &repository.UploadSession{
+		ID:         testUploadID,
+		Protocol:   repository.UploadProtocolTus,
+		S3Bucket:   testImagesBucket,
+		S3Key:      testFileKey,
+		S3UploadID: "s3-upload-id",
+		FileName:   testFileName,
+		FileType:   testFileType,
+		MimeType:   testMimeType,
+		Length:     length,
+		Offset:     offset,
+		UserID:     &userID,
+		ExpiresAt:  time.Now().Add(time.Hour),
+	}
+}
+
+// =============================================================================
+// Tus Protocol Tests
+// =============================================================================
+
+func TestTusProtocol_UnsupportedVersion(t *testing.T) {
+	handler := New(&mockRepository{}, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/tus", nil, map[string]string{"Tus-Resumable": "0.2.2"})
+
+	if w.Code != http.StatusPreconditionFailed {
+		t.Errorf("expected status %d, got %d", http.StatusPreconditionFailed, w.Code)
+	}
+	if w.Header().Get("Tus-Version") != tusVersion {
+		t.Errorf("expected Tus-Version header %s, got %q", tusVersion, w.Header().Get("Tus-Version"))
+	}
+}
+
+func TestParseTusMetadata(t *testing.T) {
+	header := "filename " + base64.StdEncoding.EncodeToString([]byte("cv.pdf")) + ",is_confidential"
+	metadata, err := parseTusMetadata(header)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if metadata["filename"] != "cv.pdf" {
+		t.Errorf("expected filename cv.pdf, got %q", metadata["filename"])
+	}
+	if _, ok := metadata["is_confidential"]; !ok {
+		t.Error("expected key without value to be present")
+	}
+
+	if _, err := parseTusMetadata("filename not-base64!"); err == nil {
+		t.Error("expected error for invalid base64 value")
+	}
+}
+
+// =============================================================================
+// Create Tus Upload Tests
+// =============================================================================
+
+func TestCreateTusUpload_Success(t *testing.T) {
+	var createdSession *repository.UploadSession
+	var multipartBucket, multipartKey string
+
+	mockRepo := &mockRepository{
+		createUploadSessionFunc: func(_ context.Context, session *repository.UploadSession) error {
+			createdSession = session
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		newMultipartUploadFunc: func(_ context.Context, bucket, key, _ string) (string, error) {
+			multipartBucket = bucket
+			multipartKey = key
+			return "s3-upload-id", nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/tus", nil, tusHeaders(map[string]string{
+		"Upload-Length": "1024",
+		"Upload-Metadata": tusMetadata(map[string]string{
+			"filename":    "photo.png",
+			"contentType": "image/png",
+			"fileType":    "portfolio-image",
+			"visibility":  "private",
+		}),
+	}))
+
+	if w.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body.String())
+	}
+	if createdSession == nil {
+		t.Fatal("expected upload session to be created")
+	}
+	if w.Header().Get("Location") != "/api/v1/files/tus/"+createdSession.ID {
+		t.Errorf("unexpected Location header %q", w.Header().Get("Location"))
+	}
+	if multipartBucket != testImagesBucket {
+		t.Errorf("expected bucket %s, got %s", testImagesBucket, multipartBucket)
+	}
+	if !strings.HasSuffix(multipartKey, ".png") || createdSession.S3Key != multipartKey {
+		t.Errorf("expected session key to match multipart key, got %s and %s", createdSession.S3Key, multipartKey)
+	}
+	if createdSession.S3UploadID != "s3-upload-id" {
+		t.Errorf("expected S3 upload ID to be stored, got %s", createdSession.S3UploadID)
+	}
+	if createdSession.UserID == nil || *createdSession.UserID != 1 {
+		t.Error("expected uploader user ID to be stored")
+	}
+	if createdSession.Visibility != repository.FileVisibilityPrivate {
+		t.Errorf("expected visibility private, got %q", createdSession.Visibility)
+	}
+	if w.Header().Get("Upload-Expires") == "" {
+		t.Error("expected Upload-Expires header")
+	}
+}
+
+func TestCreateTusUpload_Validation(t *testing.T) {
+	validMetadata := map[string]string{"filename": "photo.png", "contentType": "image/png", "fileType": "portfolio-image"}
+
+	testCases := []struct {
+		name       string
+		length     string
+		metadata   map[string]string
+		wantStatus int
+	}{
+		{"missing length", "", validMetadata, http.StatusBadRequest},
+		{"zero length", "0", validMetadata, http.StatusBadRequest},
+		{"too large", "999999999999", validMetadata, http.StatusRequestEntityTooLarge},
+		{"missing filename", "10", map[string]string{"contentType": "image/png", "fileType": "portfolio-image"}, http.StatusBadRequest},
+		{"missing fileType", "10", map[string]string{"filename": "photo.png", "contentType": "image/png"}, http.StatusBadRequest},
+		{"invalid content type", "10", map[string]string{"filename": "tool.exe", "contentType": "application/x-msdownload", "fileType": "document"}, http.StatusBadRequest},
+		{"extension mismatch", "10", map[string]string{"filename": "photo.pdf", "contentType": "image/png", "fileType": "portfolio-image"}, http.StatusBadRequest},
+		{"type not valid for fileType", "10", map[string]string{"filename": "cv.pdf", "contentType": "application/pdf", "fileType": "portfolio-image"}, http.StatusBadRequest},
+		{"invalid visibility", "10", map[string]string{"filename": "photo.png", "contentType": "image/png", "fileType": "portfolio-image", "visibility": "hidden"}, http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var multipartStarted bool
+			mockStore := &mockStorage{
+				newMultipartUploadFunc: func(_ context.Context, _, _, _ string) (string, error) {
+					multipartStarted = true
+					return "id", nil
+				},
+			}
+			handler := New(&mockRepository{}, mockStore, createTestConfig(), &mockActionLogRepo{})
+			router := setupTusRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPost, "/api/v1/files/tus", nil, tusHeaders(map[string]string{
+				"Upload-Length":   tc.length,
+				"Upload-Metadata": tusMetadata(tc.metadata),
+			}))
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if multipartStarted {
+				t.Error("multipart upload should not be started for invalid requests")
+			}
+		})
+	}
+}
+
+func TestCreateTusUpload_RepositoryError_AbortsMultipart(t *testing.T) {
+	var aborted bool
+	mockRepo := &mockRepository{
+		createUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession) error {
+			return errors.New("database error")
+		},
+	}
+	mockStore := &mockStorage{
+		newMultipartUploadFunc: func(_ context.Context, _, _, _ string) (string, error) {
+			return "s3-upload-id", nil
+		},
+		abortMultipartUploadFunc: func(_ context.Context, _, _, uploadID string) error {
+			aborted = uploadID == "s3-upload-id"
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPost, "/api/v1/files/tus", nil, tusHeaders(map[string]string{
+		"Upload-Length":   "10",
+		"Upload-Metadata": tusMetadata(map[string]string{"filename": "photo.png", "contentType": "image/png", "fileType": "portfolio-image"}),
+	}))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if !aborted {
+		t.Error("expected multipart upload to be aborted")
+	}
+}
+
+// =============================================================================
+// Tus Upload Status Tests
+// =============================================================================
+
+func TestGetTusUploadStatus_ReturnsOffset(t *testing.T) {
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(1000, 400), nil
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodHead, "/api/v1/files/tus/"+testUploadID, nil, tusHeaders(nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, w.Code)
+	}
+	if w.Header().Get("Upload-Offset") != "400" {
+		t.Errorf("expected Upload-Offset 400, got %q", w.Header().Get("Upload-Offset"))
+	}
+	if w.Header().Get("Upload-Length") != "1000" {
+		t.Errorf("expected Upload-Length 1000, got %q", w.Header().Get("Upload-Length"))
+	}
+	if w.Header().Get("Cache-Control") != "no-store" {
+		t.Errorf("expected Cache-Control no-store, got %q", w.Header().Get("Cache-Control"))
+	}
+}
+
+func TestGetTusUploadStatus_Errors(t *testing.T) {
+	expired := createTestUploadSession(1000, 0)
+	expired.ExpiresAt = time.Now().Add(-time.Minute)
+	ownerless := createTestUploadSession(1000, 0)
+	ownerless.UserID = nil
+
+	testCases := []struct {
+		name       string
+		session    *repository.UploadSession
+		err        error
+		userID     int64
+		wantStatus int
+	}{
+		{"not found", nil, gorm.ErrRecordNotFound, 1, http.StatusNotFound},
+		{"other user", createTestUploadSession(1000, 0), nil, 2, http.StatusNotFound},
+		{"session without owner", ownerless, nil, 1, http.StatusNotFound},
+		{"expired", expired, nil, 1, http.StatusGone},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return tc.session, tc.err
+				},
+			}
+			handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+			router := setupTusRouter(handler, tc.userID)
+
+			w := performRequest(router, http.MethodHead, "/api/v1/files/tus/"+testUploadID, nil, tusHeaders(nil))
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d", tc.wantStatus, w.Code)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Patch Tus Upload Tests
+// =============================================================================
+
+func TestPatchTusUpload_Validation(t *testing.T) {
+	testCases := []struct {
+		name        string
+		contentType string
+		offset      string
+		body        []byte
+		wantStatus  int
+	}{
+		{"wrong content type", "application/json", "0", testPNGData(), http.StatusUnsupportedMediaType},
+		{"missing offset", tusPatchContentType, "", testPNGData(), http.StatusBadRequest},
+		{"offset mismatch", tusPatchContentType, "100", testPNGData(), http.StatusConflict},
+		{"chunk exceeds length", tusPatchContentType, "0", make([]byte, 20000), http.StatusRequestEntityTooLarge},
+		{"small non-final chunk", tusPatchContentType, "0", testPNGData(), http.StatusBadRequest},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var partUploaded bool
+			mockRepo := &mockRepository{
+				getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+					return createTestUploadSession(10000, 0), nil
+				},
+			}
+			mockStore := &mockStorage{
+				putObjectPartFunc: func(_ context.Context, _, _, _ string, _ int, _ io.Reader, _ int64) (string, error) {
+					partUploaded = true
+					return "etag", nil
+				},
+			}
+			handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+			router := setupTusRouter(handler, 1)
+
+			w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(tc.body), tusHeaders(map[string]string{
+				"Content-Type":  tc.contentType,
+				"Upload-Offset": tc.offset,
+			}))
+
+			if w.Code != tc.wantStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.wantStatus, w.Code, w.Body.String())
+			}
+			if partUploaded {
+				t.Error("no part should be uploaded for invalid requests")
+			}
+		})
+	}
+}
+
+func TestPatchTusUpload_ContentMismatch_Rejected(t *testing.T) {
+	pdf := testPDFData
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(pdf)), 0), nil
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(pdf), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "does not match declared content type") {
+		t.Errorf("expected content mismatch error, got %s", w.Body.String())
+	}
+}
+
+func TestPatchTusUpload_IntermediateChunk_UpdatesProgress(t *testing.T) {
+	chunk := append(testPNGData(), make([]byte, storage.MinPartSize)...)
+	var storedBytes int
+	var savedOffset, expectedOffset int64
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(chunk))*2, 0), nil
+		},
+		updateUploadSessionProgressFunc: func(_ context.Context, session *repository.UploadSession, expected int64) error {
+			savedOffset = session.Offset
+			expectedOffset = expected
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectPartFunc: func(_ context.Context, _, _, _ string, partNumber int, reader io.Reader, _ int64) (string, error) {
+			data, _ := io.ReadAll(reader)
+			storedBytes = len(data)
+			if partNumber != 1 {
+				t.Errorf("expected part number 1, got %d", partNumber)
+			}
+			return "etag-1", nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(chunk), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if storedBytes != len(chunk) {
+		t.Errorf("expected %d bytes stored including sniffed header, got %d", len(chunk), storedBytes)
+	}
+	if savedOffset != int64(len(chunk)) || expectedOffset != 0 {
+		t.Errorf("expected offset update 0 -> %d, got %d -> %d", len(chunk), expectedOffset, savedOffset)
+	}
+	if w.Header().Get("Upload-Offset") != strconv.FormatInt(savedOffset, 10) {
+		t.Errorf("unexpected Upload-Offset header %q", w.Header().Get("Upload-Offset"))
+	}
+}
+
+func TestPatchTusUpload_OffsetConflict(t *testing.T) {
+	chunk := append(testPNGData(), make([]byte, storage.MinPartSize)...)
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(chunk))*2, 0), nil
+		},
+		updateUploadSessionProgressFunc: func(_ context.Context, _ *repository.UploadSession, _ int64) error {
+			return repository.ErrUploadOffsetConflict
+		},
+	}
+	handler := New(mockRepo, &mockStorage{}, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(chunk), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+}
+
+func TestPatchTusUpload_RacingChunks_WriteOwnParts(t *testing.T) {
+	chunk := append(testPNGData(), make([]byte, storage.MinPartSize)...)
+	var mu sync.Mutex
+	claimed := 0
+	written := map[int]bool{}
+	var recorded repository.UploadParts
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(chunk))*2, 0), nil
+		},
+		claimUploadPartFunc: func(_ context.Context, _ *repository.UploadSession) (int, error) {
+			mu.Lock()
+			defer mu.Unlock()
+			claimed++
+			return claimed, nil
+		},
+		updateUploadSessionProgressFunc: func(_ context.Context, session *repository.UploadSession, _ int64) error {
+			mu.Lock()
+			defer mu.Unlock()
+			if recorded != nil {
+				return repository.ErrUploadOffsetConflict
+			}
+			recorded = session.Parts
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectPartFunc: func(_ context.Context, _, _, _ string, partNumber int, reader io.Reader, _ int64) (string, error) {
+			_, _ = io.Copy(io.Discard, reader)
+			mu.Lock()
+			defer mu.Unlock()
+			if written[partNumber] {
+				t.Errorf("expected part %d to be written once", partNumber)
+			}
+			written[partNumber] = true
+			return "etag-" + strconv.Itoa(partNumber), nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 2)
+	for i := range codes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			codes[i] = performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(chunk), tusHeaders(map[string]string{
+				"Content-Type":  tusPatchContentType,
+				"Upload-Offset": "0",
+			})).Code
+		}()
+	}
+	wg.Wait()
+
+	if codes[0]+codes[1] != http.StatusNoContent+http.StatusConflict {
+		t.Errorf("expected one chunk recorded and one conflict, got %v", codes)
+	}
+	if len(written) != 2 {
+		t.Errorf("expected both chunks written to parts of their own, got %v", written)
+	}
+	if len(recorded) != 1 || recorded[0].ETag != "etag-"+strconv.Itoa(recorded[0].Number) {
+		t.Errorf("expected the recorded part to be the one its chunk was written to, got %+v", recorded)
+	}
+}
+
+func TestPatchTusUpload_ClaimConflict_StoresNothing(t *testing.T) {
+	chunk := append(testPNGData(), make([]byte, storage.MinPartSize)...)
+	var stored bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(chunk))*2, 0), nil
+		},
+		claimUploadPartFunc: func(_ context.Context, _ *repository.UploadSession) (int, error) {
+			return 0, repository.ErrUploadOffsetConflict
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectPartFunc: func(_ context.Context, _, _, _ string, _ int, _ io.Reader, _ int64) (string, error) {
+			stored = true
+			return "etag-1", nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(chunk), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if stored {
+		t.Error("expected no part written once the offset moved on")
+	}
+}
+
+func TestPatchTusUpload_FinalChunk_CreatesFile(t *testing.T) {
+	png := testPNGData()
+	session := createTestUploadSession(int64(storage.MinPartSize+len(png)), storage.MinPartSize)
+	session.Parts = repository.UploadParts{{Number: 1, ETag: "etag-1", Size: storage.MinPartSize}}
+	session.Visibility = repository.FileVisibilityPrivate
+
+	var completedParts []minio.CompletePart
+	var completedSession *repository.UploadSession
+	var createdFile *repository.StorageFile
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return session, nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, s *repository.UploadSession, file *repository.StorageFile) error {
+			completedSession = s
+			createdFile = file
+			file.ID = 42
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectPartFunc: func(_ context.Context, _, _, _ string, partNumber int, _ io.Reader, _ int64) (string, error) {
+			if partNumber != 2 {
+				t.Errorf("expected part number 2, got %d", partNumber)
+			}
+			return "etag-2", nil
+		},
+		completeMultipartUploadFunc: func(_ context.Context, _, _, _ string, parts []minio.CompletePart) error {
+			completedParts = parts
+			return nil
+		},
+		// The assembled object as read back for metadata sanitization
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(png)), nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	// Non-initial chunk is not sniffed, so arbitrary bytes are accepted
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(png), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": strconv.Itoa(storage.MinPartSize),
+	}))
+
+	if w.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusNoContent, w.Code, w.Body.String())
+	}
+	if len(completedParts) != 2 || completedParts[1].ETag != "etag-2" {
+		t.Errorf("expected two parts to be completed, got %+v", completedParts)
+	}
+	if completedSession == nil || completedSession.Offset != completedSession.Length {
+		t.Error("expected session to be completed at full length")
+	}
+	if createdFile == nil || createdFile.Visibility != repository.FileVisibilityPrivate {
+		t.Error("expected the file to keep the visibility of the upload")
+	}
+	if w.Header().Get("X-File-Id") != "42" {
+		t.Errorf("expected X-File-Id 42, got %q", w.Header().Get("X-File-Id"))
+	}
+	if !strings.Contains(w.Header().Get("X-File-Url"), "/api/v1/files/portfolio-image/") {
+		t.Errorf("unexpected X-File-Url %q", w.Header().Get("X-File-Url"))
+	}
+}
+
+func TestPatchTusUpload_CompleteRecordError_CleansUp(t *testing.T) {
+	png := testPNGData()
+	var objectDeleted, sessionDeleted bool
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(png)), 0), nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+			return errors.New("database error")
+		},
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			sessionDeleted = true
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, _, _ string) error {
+			objectDeleted = true
+			return nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(png)), nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(png), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusInternalServerError {
+		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
+	}
+	if !objectDeleted || !sessionDeleted {
+		t.Error("expected assembled object and session to be cleaned up")
+	}
+}
+
+func TestPatchTusUpload_AlreadyCompleted_KeepsObject(t *testing.T) {
+	png := testPNGData()
+	var objectDeleted bool
+
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(int64(len(png)), 0), nil
+		},
+		completeUploadSessionFunc: func(_ context.Context, _ *repository.UploadSession, _ *repository.StorageFile) error {
+			return repository.ErrUploadSessionCompleted
+		},
+	}
+	mockStore := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, _, _ string) error {
+			objectDeleted = true
+			return nil
+		},
+		getObjectFunc: func(_ context.Context, _, _ string) (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(png)), nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodPatch, "/api/v1/files/tus/"+testUploadID, bytes.NewReader(png), tusHeaders(map[string]string{
+		"Content-Type":  tusPatchContentType,
+		"Upload-Offset": "0",
+	}))
+
+	if w.Code != http.StatusConflict {
+		t.Errorf("expected status %d, got %d", http.StatusConflict, w.Code)
+	}
+	if objectDeleted {
+		t.Error("expected object of the completed upload to be kept")
+	}
+}
+
+// =============================================================================
+// Terminate Tus Upload Tests
+// =============================================================================
+
+func TestTerminateTusUpload_AbortsAndDeletes(t *testing.T) {
+	var aborted, deleted bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(1000, 0), nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, id string) error {
+			deleted = id == testUploadID
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, uploadID string) error {
+			aborted = uploadID == "s3-upload-id"
+			return nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodDelete, "/api/v1/files/tus/"+testUploadID, nil, tusHeaders(nil))
+
+	if w.Code != http.StatusNoContent {
+		t.Errorf("expected status %d, got %d", http.StatusNoContent, w.Code)
+	}
+	if !aborted {
+		t.Error("expected multipart upload to be aborted")
+	}
+	if !deleted {
+		t.Error("expected upload session to be deleted")
+	}
+}
+
+func TestTerminateTusUpload_AlreadyCompleted_KeepsUpload(t *testing.T) {
+	var aborted bool
+	mockRepo := &mockRepository{
+		getUploadSessionFunc: func(_ context.Context, _ string) (*repository.UploadSession, error) {
+			return createTestUploadSession(1000, 1000), nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			return repository.ErrUploadSessionCompleted
+		},
+	}
+	mockStore := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, _ string) error {
+			aborted = true
+			return nil
+		},
+	}
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTusRouter(handler, 1)
+
+	w := performRequest(router, http.MethodDelete, "/api/v1/files/tus/"+testUploadID, nil, tusHeaders(nil))
+
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected status %d, got %d", http.StatusNotFound, w.Code)
+	}
+	if aborted {
+		t.Error("expected the upload completed meanwhile to be kept")
+	}
+}
@@ -1,11 +1,15 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"net/http"
-	"path/filepath"
-	"strings"
+	"time"
 
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
 	"github.com/GunarsK-portfolio/portfolio-common/audit"
 	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
 	"github.com/GunarsK-portfolio/portfolio-common/logger"
@@ -15,16 +19,25 @@ import (
 
 // UploadFile godoc
 // @Summary Upload file to S3
-// @Description Upload file to MinIO/S3 and create database record
+// @Description Upload file to MinIO/S3 and create database record. The content type is detected from the file bytes and must match the declared type and extension. JPEG, PNG and WebP metadata (EXIF, GPS, XMP) is stripped unless the file type keeps it. Images larger than the configured dimension limits are rejected with 422; width, height and imageFormat are returned for images. File types with variants enabled also get the configured variants, listed under "variants". When antivirus scanning is enabled, infected files are rejected with 422 (and kept in the quarantine bucket if configured) and the verdict is returned as scanStatus. A Content-MD5 (base64) or X-Checksum-SHA256 (hex or base64) header is verified against the file as received; a mismatch is rejected with 400 and verified checksums are returned as uploadMd5 and uploadSha256. The SHA-256 of the stored content is returned as sha256; content already stored in the bucket is not uploaded again and the new file shares the existing object under its own URL. Uploads that would exceed the caller's storage quota or their quota for the file type are rejected with 413 stating what is left; callers starting uploads faster than the rate limit get 429 with Retry-After.
 // @Tags files
 // @Accept multipart/form-data
 // @Produce json
 // @Param file formData file true "File to upload"
-// @Param fileType formData string true "File type: portfolio-image, miniature-image, document"
+// @Param fileType formData string true "Configured file type (defaults: portfolio-image, miniature-image, document)"
+// @Param visibility formData string false "public (default) or private" Enums(public, private)
+// @Param Content-MD5 header string false "Base64 MD5 of the file"
+// @Param X-Checksum-SHA256 header string false "Hex or base64 SHA-256 of the file"
+// @Param Idempotency-Key header string false "Retries with the same key replay the first response"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 413 {object} map[string]interface{}
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
 // @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
 // @Security BearerAuth
 // @Router /files [post]
 func (h *Handler) UploadFile(c *gin.Context) {
@@ -38,26 +51,42 @@ func (h *Handler) UploadFile(c *gin.Context) {
 	// Get required fileType parameter
 	fileType := c.PostForm("fileType")
 	if fileType == "" {
-		commonHandlers.RespondError(c, http.StatusBadRequest, "fileType is required (portfolio-image, miniature-image, document)")
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("fileType is required (%s)", h.fileTypeNames()))
+		return
+	}
+	ft, err := h.fileType(fileType)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+	visibility, err := parseVisibility(c.PostForm("visibility"))
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
 	// Validate file size
-	if file.Size > h.cfg.MaxFileSize {
-		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("file too large (max %d bytes)", h.cfg.MaxFileSize))
+	if file.Size > ft.MaxSize {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("file too large (max %d bytes)", ft.MaxSize))
+		return
+	}
+	if !h.checkQuota(c, ft, file.Size) {
 		return
 	}
 
-	// Validate file type
-	contentType := file.Header.Get("Content-Type")
-	if !h.isAllowedContentType(contentType) {
-		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file type")
+	// Checksums the client computed over the file, verified against what was received
+	checksums, err := parseUploadChecksums(c.Request.Header)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
 		return
 	}
 
-	// Generate unique key
-	ext := filepath.Ext(file.Filename)
-	key := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+	// Validate declared file type
+	declaredType := file.Header.Get("Content-Type")
+	if !h.isAllowedContentType(declaredType) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file type")
+		return
+	}
 
 	// Open file
 	src, err := file.Open()
@@ -67,35 +96,192 @@ func (h *Handler) UploadFile(c *gin.Context) {
 	}
 	defer src.Close()
 
-	// Determine bucket based on file type
-	bucket, err := h.getBucketForFileType(fileType, contentType)
-	if err != nil {
-		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+	response, uploadErr := h.storeMultipartUpload(c, multipartUpload{
+		fileName:     file.Filename,
+		declaredType: declaredType,
+		size:         file.Size,
+		content:      src,
+		checksums:    checksums,
+		fileType:     ft,
+		visibility:   visibility,
+	})
+	if uploadErr != nil {
+		respondUploadError(c, uploadErr)
 		return
 	}
+	c.JSON(http.StatusOK, response)
+}
 
-	// Upload to S3
-	if err := h.storage.PutObject(c.Request.Context(), bucket, key, src, file.Size, contentType); err != nil {
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to upload file")
-		return
+// multipartUpload is a file received in a multipart form whose size, file type and
+// declared content type were accepted, before its content is checked and stored
+type multipartUpload struct {
+	fileName     string
+	declaredType string
+	size         int64
+	content      io.ReadSeeker
+	checksums    *uploadChecksums
+	fileType     *filetypes.FileType
+	visibility   string
+}
+
+// uploadError is a failed upload step: the status and message to answer it with, the
+// cause to log for failures that are not the client's, and the quota an upload over
+// quota exceeded
+type uploadError struct {
+	status  int
+	message string
+	err     error
+	quota   *quotaStatus
+}
+
+// Error lets a refusal travel as an error, as quota checks run by the repository do
+func (e *uploadError) Error() string {
+	return e.message
+}
+
+func newUploadError(status int, message string) *uploadError {
+	return &uploadError{status: status, message: message}
+}
+
+// loggedUploadError is an upload failure whose cause is logged rather than returned
+func loggedUploadError(status int, err error, message string) *uploadError {
+	return &uploadError{status: status, message: message, err: err}
+}
+
+// respondUploadError answers a failed upload step
+func respondUploadError(c *gin.Context, e *uploadError) {
+	switch {
+	case e.quota != nil:
+		c.JSON(e.status, gin.H{"error": e.message, "quota": e.quota})
+	case e.err != nil:
+		commonHandlers.LogAndRespondError(c, e.status, e.err, e.message)
+	default:
+		commonHandlers.RespondError(c, e.status, e.message)
+	}
+}
+
+// storeMultipartUpload verifies, sniffs, sanitizes and scans an accepted upload, stores
+// it with its variants and audits it, returning the file response. It only reads c, so
+// uploads of one request may be stored concurrently.
+func (h *Handler) storeMultipartUpload(c *gin.Context, upload multipartUpload) (gin.H, *uploadError) {
+	src, ft, fileType := upload.content, upload.fileType, upload.fileType.Name
+
+	if upload.checksums != nil {
+		if err := upload.checksums.verify(src); err != nil {
+			return nil, checksumError(err)
+		}
+	}
+
+	// Detect actual content type from magic bytes instead of trusting the client
+	contentType, err := detectContentType(src)
+	if err != nil {
+		return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to inspect file")
+	}
+	if contentType != normalizeMimeType(upload.declaredType) || !h.isAllowedContentType(contentType) {
+		return nil, newUploadError(http.StatusBadRequest, errContentTypeMismatch.Error())
+	}
+
+	// Validate content and extension against the file type
+	if err := ft.CheckMimeType(contentType); err != nil {
+		return nil, newUploadError(http.StatusBadRequest, err.Error())
+	}
+	ext, err := ft.ResolveExtension(upload.fileName, contentType)
+	if err != nil {
+		return nil, newUploadError(http.StatusBadRequest, err.Error())
+	}
+	bucket := ft.Bucket
+
+	// Generate unique key
+	key := fmt.Sprintf("%s%s", uuid.New().String(), ext)
+
+	// Check image dimensions and strip EXIF/GPS metadata unless the file type keeps it
+	content, size, imageInfo, err := h.processUpload(src, upload.size, fileType, contentType)
+	if err != nil {
+		return nil, imageError(err)
+	}
+
+	fileRecord := &repository.StorageFile{
+		S3Key:      key,
+		S3Bucket:   bucket,
+		FileName:   upload.fileName,
+		FileSize:   size,
+		MimeType:   contentType,
+		FileType:   fileType,
+		Visibility: upload.visibility,
+		UploadedBy: audit.GetUserID(c),
+	}
+	fileRecord.SetImageInfo(imageInfo)
+	if upload.checksums != nil {
+		upload.checksums.apply(fileRecord)
+	}
+
+	// Scan the content that will be stored; infected files are rejected or quarantined
+	scanResult, err := h.scanContent(c.Request.Context(), content)
+	if err != nil {
+		return nil, loggedUploadError(http.StatusServiceUnavailable, err, "virus scan unavailable")
+	}
+	applyScanResult(fileRecord, scanResult)
+	if scanResult != nil && scanResult.Infected {
+		return nil, h.quarantineInfectedUpload(c, fileRecord, content, size)
 	}
 
-	// Create database record
-	fileRecord, err := h.repo.CreateFile(c.Request.Context(), bucket, key, file.Filename, fileType, file.Size, contentType)
+	// Content already stored in the bucket is shared instead of uploaded again
+	hash, err := hashContent(content)
 	if err != nil {
-		// Cleanup S3 file if DB insert fails
-		if cleanupErr := h.storage.DeleteObject(c.Request.Context(), bucket, key); cleanupErr != nil {
-			logger.GetLogger(c).Error("Failed to cleanup S3 file after database error",
-				"error", cleanupErr,
-				"bucket", bucket,
-				"key", key,
-				"original_error", err,
-			)
+		return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to inspect file")
+	}
+	fileRecord.ContentHash = &hash
+
+	// Render image variants before storing anything so a bad image leaves no objects behind
+	var variants []renderedVariant
+	if ft.Variants && len(h.cfg.ImageVariants) > 0 {
+		variants, err = h.renderVariants(content, key, upload.fileName, fileType, contentType)
+		if errors.Is(err, images.ErrImageTooLarge) {
+			return nil, newUploadError(http.StatusUnprocessableEntity, "image dimensions too large")
+		}
+		if errors.Is(err, images.ErrEncoderBusy) {
+			return nil, newUploadError(http.StatusServiceUnavailable, "image encoder busy, retry shortly")
+		}
+		if err != nil {
+			return nil, loggedUploadError(http.StatusBadRequest, err, "failed to process image")
+		}
+		// Variants of a private image must not be downloadable without authorization, and
+		// belong to the uploader of their original
+		for i := range variants {
+			variants[i].record.File.Visibility = upload.visibility
+			variants[i].record.File.UploadedBy = fileRecord.UploadedBy
 		}
-		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to create file record")
-		return
 	}
 
+	// Record the upload as pending before storing anything, so objects of an upload
+	// interrupted by a crash are found and removed by the pending upload sweep
+	fileRecord.QuotaCheck = h.quotaCheck(fileRecord.UploadedBy, ft, size, 1)
+	if err := h.createPendingRecord(c, fileRecord, variants); err != nil {
+		if refusal, ok := quotaRefusal(err); ok {
+			return nil, refusal
+		}
+		return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to create file record")
+	}
+
+	// Upload to S3 unless the record shares an existing object
+	if !fileRecord.SharesObject {
+		if err := h.storage.PutObject(c.Request.Context(), bucket, fileRecord.S3Key, content, size, contentType); err != nil {
+			h.discardPendingUpload(c, fileRecord, variants)
+			return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to upload file")
+		}
+	}
+	if err := h.storeVariants(c, variants); err != nil {
+		h.discardPendingUpload(c, fileRecord, variants)
+		return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to upload image variants")
+	}
+
+	// Serve the file only once every object is stored
+	if err := h.repo.ActivateFile(c.Request.Context(), fileRecord.ID); err != nil {
+		h.discardPendingUpload(c, fileRecord, variants)
+		return nil, loggedUploadError(http.StatusInternalServerError, err, "failed to create file record")
+	}
+	fileRecord.Status = repository.FileStatusActive
+
 	// Log file upload
 	resourceType := audit.ResourceTypeFile
 	source := "files-api"
@@ -104,49 +290,64 @@ func (h *Handler) UploadFile(c *gin.Context) {
 		"file_type": fileType,
 		"size":      fileRecord.FileSize,
 		"mime_type": fileRecord.MimeType,
+		"sha256":    hash,
+		"shared":    fileRecord.SharesObject,
 	})
 
 	// Return file info
-	c.JSON(http.StatusOK, gin.H{
-		"id":       fileRecord.ID,
-		"fileName": fileRecord.FileName,
-		"fileSize": fileRecord.FileSize,
-		"mimeType": fileRecord.MimeType,
-		"url":      fmt.Sprintf("/api/v1/files/%s/%s", fileType, key),
-		"fileType": fileType,
-	})
+	response := fileResponse(fileRecord)
+	if len(variants) > 0 {
+		response["variants"] = variantResponse(fileType, variantRecords(variants))
+	}
+	return response, nil
 }
 
-func (h *Handler) isAllowedContentType(contentType string) bool {
-	for _, ct := range h.cfg.AllowedFileTypes {
-		if strings.HasPrefix(contentType, ct) {
-			return true
-		}
+// createPendingRecord stores the pending file row, linking any variants to it in the same transaction
+func (h *Handler) createPendingRecord(c *gin.Context, fileRecord *repository.StorageFile, variants []renderedVariant) error {
+	fileRecord.Status = repository.FileStatusPending
+	if len(variants) == 0 {
+		return h.repo.CreateFile(c.Request.Context(), fileRecord)
 	}
-	return false
-}
 
-func (h *Handler) getBucketForFileType(fileType, contentType string) (string, error) {
-	// Get bucket for fileType
-	bucket, err := h.fileTypeToBucket(fileType)
-	if err != nil {
-		return "", err
+	records := variantRecords(variants)
+	for i := range records {
+		records[i].File.S3Bucket = fileRecord.S3Bucket
+		records[i].File.Status = repository.FileStatusPending
+	}
+	if err := h.repo.CreateFileWithVariants(c.Request.Context(), fileRecord, records); err != nil {
+		return err
+	}
+	for i := range variants {
+		variants[i].record = records[i]
 	}
+	return nil
+}
 
-	// Validate content type matches fileType
-	switch fileType {
-	case "portfolio-image", "miniature-image":
-		if !strings.HasPrefix(contentType, "image/") {
-			return "", fmt.Errorf("%s requires image content type", fileType)
-		}
-	case "document":
-		isPDF := strings.HasPrefix(contentType, "application/pdf")
-		isWord := strings.HasPrefix(contentType, "application/msword") ||
-			strings.HasPrefix(contentType, "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
-		if !isPDF && !isWord {
-			return "", fmt.Errorf("document requires PDF or Word document content type")
+// discardPendingUpload deletes the pending rows of a failed upload and hands any stored
+// objects to the deletion worker. If that fails too, the pending upload sweep retries it.
+func (h *Handler) discardPendingUpload(c *gin.Context, fileRecord *repository.StorageFile, variants []renderedVariant) {
+	now := time.Now()
+	deletion := func(file *repository.StorageFile) repository.PendingDeletion {
+		return repository.PendingDeletion{
+			FileID:        fileRecord.ID,
+			S3Bucket:      file.S3Bucket,
+			S3Key:         file.ObjectKey(),
+			NextAttemptAt: now,
 		}
 	}
 
-	return bucket, nil
+	deletions := make([]repository.PendingDeletion, 0, len(variants)+1)
+	deletions = append(deletions, deletion(fileRecord))
+	for i := range variants {
+		deletions = append(deletions, deletion(&variants[i].record.File))
+	}
+
+	if err := h.repo.DiscardPendingFile(c.Request.Context(), fileRecord.ID, deletions); err != nil {
+		logger.GetLogger(c).Error("Failed to discard pending upload",
+			"error", err,
+			"file_id", fileRecord.ID,
+			"bucket", fileRecord.S3Bucket,
+			"key", fileRecord.S3Key,
+		)
+	}
 }
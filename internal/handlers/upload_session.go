@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+)
+
+// loadUploadSession fetches the upload session for the :id path parameter and writes an
+// error response if it does not exist, uses another protocol, is expired or belongs to another user
+func (h *Handler) loadUploadSession(c *gin.Context, protocol string) (*repository.UploadSession, bool) {
+	session, err := h.repo.GetUploadSession(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "upload not found", "failed to fetch upload")
+		return nil, false
+	}
+
+	if session.Protocol != protocol || !isSameUser(session.UserID, audit.GetUserID(c)) {
+		commonHandlers.RespondError(c, http.StatusNotFound, "upload not found")
+		return nil, false
+	}
+	if time.Now().After(session.ExpiresAt) {
+		commonHandlers.RespondError(c, http.StatusGone, "upload expired")
+		return nil, false
+	}
+
+	return session, true
+}
+
+// discardUpload removes the session of an upload that can no longer complete and then its
+// object. The session is removed first, claiming the upload the way completing it does:
+// when another request completed it meanwhile, the object belongs to that file and is
+// kept, and 409 is answered. It returns false once that response has been sent.
+func (h *Handler) discardUpload(c *gin.Context, session *repository.UploadSession) bool {
+	if err := h.repo.DeleteUploadSession(c.Request.Context(), session.ID); err != nil {
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			commonHandlers.RespondError(c, http.StatusConflict, "upload already completed")
+			return false
+		}
+		// The object is removed with the session once it expires
+		logger.GetLogger(c).Error("Failed to delete discarded upload session",
+			"error", err,
+			"upload_id", session.ID,
+		)
+		return true
+	}
+	if err := h.storage.DeleteObject(c.Request.Context(), session.S3Bucket, session.S3Key); err != nil {
+		logger.GetLogger(c).Error("Failed to cleanup S3 file for discarded upload",
+			"error", err,
+			"bucket", session.S3Bucket,
+			"key", session.S3Key,
+		)
+	}
+	return true
+}
+
+// isSameUser reports whether the session owner matches the requesting user. A session
+// without an owner only belongs to requests without a user, and the other way round.
+func isSameUser(owner, current *int64) bool {
+	if owner == nil || current == nil {
+		return owner == nil && current == nil
+	}
+	return *owner == *current
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"errors"
+	"fmt"
 	"io"
 	"mime/multipart"
 	"net/http"
@@ -11,6 +12,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
 	"github.com/gin-gonic/gin"
 )
@@ -81,7 +83,9 @@ func TestUploadFile_MissingFileType(t *testing.T) {
 func TestUploadFile_FileTooLarge(t *testing.T) {
 	mockRepo := &mockRepository{}
 	cfg := createTestConfig()
-	cfg.MaxFileSize = 100 // Set very small limit for test
+	withFileType(cfg, "portfolio-image", func(ft *filetypes.FileType) {
+		ft.MaxSize = 100 // Set very small limit for test
+	})
 	handler := New(mockRepo, nil, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
@@ -184,12 +188,13 @@ func TestUploadFile_Success(t *testing.T) {
 	}
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*repository.StorageFile, error) {
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
 			dbCreateCalled = true
-			dbBucket = bucket
-			dbKey = key
-			createdFile.S3Key = key
-			return createdFile, nil
+			dbBucket = file.S3Bucket
+			dbKey = file.S3Key
+			createdFile.S3Key = file.S3Key
+			file.ID = createdFile.ID
+			return nil
 		},
 	}
 
@@ -210,6 +215,7 @@ func TestUploadFile_Success(t *testing.T) {
 	router.POST("/api/v1/files", handler.UploadFile)
 
 	// Create multipart request with valid file
+	pngData := testPNGData()
 	body := &bytes.Buffer{}
 	writer := multipart.NewWriter(body)
 
@@ -221,7 +227,7 @@ func TestUploadFile_Success(t *testing.T) {
 	if err != nil {
 		t.Fatalf("failed to create part: %v", err)
 	}
-	if _, err := part.Write([]byte("fake png data")); err != nil {
+	if _, err := part.Write(pngData); err != nil {
 		t.Fatalf("failed to write to part: %v", err)
 	}
 	if err := writer.WriteField("fileType", "portfolio-image"); err != nil {
@@ -251,8 +257,8 @@ func TestUploadFile_Success(t *testing.T) {
 	if s3Key == "test-upload.png" {
 		t.Error("S3 key should be server-generated UUID, not client filename")
 	}
-	if s3Size != 13 { // "fake png data" is 13 bytes
-		t.Errorf("expected S3 size 13, got %d", s3Size)
+	if s3Size != int64(len(pngData)) {
+		t.Errorf("expected S3 size %d, got %d", len(pngData), s3Size)
 	}
 	if s3ContentType != "image/png" {
 		t.Errorf("expected S3 content type image/png, got %s", s3ContentType)
@@ -281,17 +287,73 @@ func TestUploadFile_Success(t *testing.T) {
 // Upload File Error Tests
 // =============================================================================
 
-func TestUploadFile_S3Error_DBNotTouched(t *testing.T) {
-	var dbCreateCalled bool
+func TestUploadFile_PendingUntilStored(t *testing.T) {
+	var steps []string
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, _, _, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
-			dbCreateCalled = true
-			return nil, nil
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			steps = append(steps, "create "+file.Status)
+			file.ID = 7
+			return nil
+		},
+		activateFileFunc: func(_ context.Context, id int64) error {
+			steps = append(steps, fmt.Sprintf("activate %d", id))
+			return nil
+		},
+		discardPendingFileFunc: func(_ context.Context, _ int64, _ []repository.PendingDeletion) error {
+			t.Error("expected successful upload not to be discarded")
+			return nil
 		},
 	}
 	mockStore := &mockStorage{
 		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+			steps = append(steps, "put")
+			return nil
+		},
+	}
+
+	handler := New(mockRepo, mockStore, createTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create multipart request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	expected := []string{"create pending", "put", "activate 7"}
+	if strings.Join(steps, ",") != strings.Join(expected, ",") {
+		t.Errorf("expected steps %v, got %v", expected, steps)
+	}
+}
+
+func TestUploadFile_S3Error_DiscardsPendingRecord(t *testing.T) {
+	var discardedID int64
+	var deletions []repository.PendingDeletion
+	var storedKey string
+
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			file.ID = 7
+			return nil
+		},
+		activateFileFunc: func(_ context.Context, _ int64) error {
+			t.Error("expected failed upload not to be activated")
+			return nil
+		},
+		discardPendingFileFunc: func(_ context.Context, id int64, d []repository.PendingDeletion) error {
+			discardedID = id
+			deletions = d
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+			storedKey = key
 			return errors.New("S3 connection error")
 		},
 	}
@@ -302,7 +364,7 @@ func TestUploadFile_S3Error_DBNotTouched(t *testing.T) {
 	router := setupTestRouter()
 	router.POST("/api/v1/files", handler.UploadFile)
 
-	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", []byte("data"))
+	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", testPNGData())
 	if err != nil {
 		t.Fatalf("failed to create multipart request: %v", err)
 	}
@@ -311,35 +373,29 @@ func TestUploadFile_S3Error_DBNotTouched(t *testing.T) {
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
-
 	if !strings.Contains(w.Body.String(), "failed to upload file") {
 		t.Errorf("expected 'failed to upload file' error, got %s", w.Body.String())
 	}
 
-	// Verify DB was not touched when S3 fails
-	if dbCreateCalled {
-		t.Error("DB CreateFile should not be called when S3 upload fails")
+	// A partially written object is removed by the deletion worker
+	if discardedID != 7 {
+		t.Fatalf("expected pending record 7 to be discarded, got %d", discardedID)
+	}
+	if len(deletions) != 1 || deletions[0].S3Bucket != testImagesBucket || deletions[0].S3Key != storedKey || deletions[0].FileID != 7 {
+		t.Errorf("expected deletion of the uploaded object, got %+v", deletions)
 	}
 }
 
-func TestUploadFile_DBError_CleansUpS3Object(t *testing.T) {
-	var s3CleanupCalled bool
-	var cleanupBucket, cleanupKey string
-
+func TestUploadFile_DBError_NothingStored(t *testing.T) {
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, _, _, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
-			return nil, errors.New("database error")
+		createFileFunc: func(_ context.Context, _ *repository.StorageFile) error {
+			return errors.New("database error")
 		},
 	}
 
 	mockStore := &mockStorage{
 		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
-			return nil // S3 upload succeeds
-		},
-		deleteObjectFunc: func(_ context.Context, bucket, key string) error {
-			s3CleanupCalled = true
-			cleanupBucket = bucket
-			cleanupKey = key
+			t.Error("expected nothing to be stored without a pending record")
 			return nil
 		},
 	}
@@ -350,7 +406,7 @@ func TestUploadFile_DBError_CleansUpS3Object(t *testing.T) {
 	router := setupTestRouter()
 	router.POST("/api/v1/files", handler.UploadFile)
 
-	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", []byte("data"))
+	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", testPNGData())
 	if err != nil {
 		t.Fatalf("failed to create multipart request: %v", err)
 	}
@@ -359,16 +415,8 @@ func TestUploadFile_DBError_CleansUpS3Object(t *testing.T) {
 	if w.Code != http.StatusInternalServerError {
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
-
-	// Verify S3 cleanup was attempted
-	if !s3CleanupCalled {
-		t.Error("expected S3 cleanup to be called after database error")
-	}
-	if cleanupBucket != testImagesBucket {
-		t.Errorf("expected cleanup bucket %s, got %s", testImagesBucket, cleanupBucket)
-	}
-	if cleanupKey == "" {
-		t.Error("expected cleanup key to be set")
+	if !strings.Contains(w.Body.String(), "failed to create file record") {
+		t.Errorf("expected 'failed to create file record' error, got %s", w.Body.String())
 	}
 }
 
@@ -391,11 +439,12 @@ func TestUploadFile_PDFDocument(t *testing.T) {
 	}
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, bucket, key, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
 			dbCreateCalled = true
-			dbBucket = bucket
-			createdFile.S3Key = key
-			return createdFile, nil
+			dbBucket = file.S3Bucket
+			createdFile.S3Key = file.S3Key
+			file.ID = createdFile.ID
+			return nil
 		},
 	}
 
@@ -412,7 +461,7 @@ func TestUploadFile_PDFDocument(t *testing.T) {
 	router := setupTestRouter()
 	router.POST("/api/v1/files", handler.UploadFile)
 
-	req, w, err := createMultipartRequest("test-document.pdf", "application/pdf", "document", []byte("PDF data"))
+	req, w, err := createMultipartRequest("test-document.pdf", "application/pdf", "document", testPDFData)
 	if err != nil {
 		t.Fatalf("failed to create multipart request: %v", err)
 	}
@@ -450,10 +499,11 @@ func TestUploadFile_WordDocument(t *testing.T) {
 	}
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, bucket, key, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
 			dbCreateCalled = true
-			createdFile.S3Key = key
-			return createdFile, nil
+			createdFile.S3Key = file.S3Key
+			file.ID = createdFile.ID
+			return nil
 		},
 	}
 
@@ -465,9 +515,11 @@ func TestUploadFile_WordDocument(t *testing.T) {
 	}
 
 	cfg := createTestConfig()
-	// Add Word document to allowed types
-	cfg.AllowedFileTypes = append(cfg.AllowedFileTypes,
-		"application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+	// Add Word document to the document file type
+	withFileType(cfg, "document", func(ft *filetypes.FileType) {
+		ft.MimeTypes = append(ft.MimeTypes, "application/vnd.openxmlformats-officedocument.wordprocessingml.document")
+		ft.Extensions = append(ft.Extensions, ".docx")
+	})
 	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
 
 	router := setupTestRouter()
@@ -480,7 +532,7 @@ func TestUploadFile_WordDocument(t *testing.T) {
 	h["Content-Disposition"] = []string{`form-data; name="file"; filename="test-document.docx"`}
 	h["Content-Type"] = []string{"application/vnd.openxmlformats-officedocument.wordprocessingml.document"}
 	part, _ := writer.CreatePart(h)
-	_, _ = part.Write([]byte("Word data"))
+	_, _ = part.Write(testDOCXData())
 	_ = writer.WriteField("fileType", "document")
 	_ = writer.Close()
 
@@ -519,7 +571,7 @@ func TestUploadFile_InvalidFileTypeForImage(t *testing.T) {
 	h["Content-Disposition"] = []string{`form-data; name="file"; filename="test.pdf"`}
 	h["Content-Type"] = []string{"application/pdf"}
 	part, _ := writer.CreatePart(h)
-	_, _ = part.Write([]byte("PDF data"))
+	_, _ = part.Write(testPDFData)
 	_ = writer.WriteField("fileType", "portfolio-image")
 	_ = writer.Close()
 
@@ -532,8 +584,8 @@ func TestUploadFile_InvalidFileTypeForImage(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 
-	if !strings.Contains(w.Body.String(), "requires image content type") {
-		t.Errorf("expected 'requires image content type' error, got %s", w.Body.String())
+	if !strings.Contains(w.Body.String(), "portfolio-image does not accept application/pdf") {
+		t.Errorf("expected 'portfolio-image does not accept application/pdf' error, got %s", w.Body.String())
 	}
 }
 
@@ -553,7 +605,7 @@ func TestUploadFile_InvalidFileTypeForDocument(t *testing.T) {
 	h["Content-Disposition"] = []string{`form-data; name="file"; filename="test.png"`}
 	h["Content-Type"] = []string{"image/png"}
 	part, _ := writer.CreatePart(h)
-	_, _ = part.Write([]byte("image data"))
+	_, _ = part.Write(testPNGData())
 	_ = writer.WriteField("fileType", "document")
 	_ = writer.Close()
 
@@ -566,18 +618,22 @@ func TestUploadFile_InvalidFileTypeForDocument(t *testing.T) {
 		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
 	}
 
-	if !strings.Contains(w.Body.String(), "requires PDF or Word document") {
-		t.Errorf("expected 'requires PDF or Word document' error, got %s", w.Body.String())
+	if !strings.Contains(w.Body.String(), "document does not accept image/png") {
+		t.Errorf("expected 'document does not accept image/png' error, got %s", w.Body.String())
 	}
 }
 
-func TestUploadFile_DBError_S3CleanupFailure_ReturnsOriginalError(t *testing.T) {
-	// Test that cleanup failure is logged but doesn't change the response
-	var s3CleanupCalled bool
+func TestUploadFile_ActivationError_DiscardFailure_ReturnsOriginalError(t *testing.T) {
+	// Test that discard failure is logged but doesn't change the response
+	var discardCalled bool
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, _, _, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
-			return nil, errors.New("database error")
+		activateFileFunc: func(_ context.Context, _ int64) error {
+			return errors.New("database error")
+		},
+		discardPendingFileFunc: func(_ context.Context, _ int64, _ []repository.PendingDeletion) error {
+			discardCalled = true
+			return errors.New("discard failed") // The pending upload sweep retries later
 		},
 	}
 
@@ -585,10 +641,6 @@ func TestUploadFile_DBError_S3CleanupFailure_ReturnsOriginalError(t *testing.T)
 		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
 			return nil // S3 upload succeeds
 		},
-		deleteObjectFunc: func(_ context.Context, _, _ string) error {
-			s3CleanupCalled = true
-			return errors.New("S3 cleanup failed") // Cleanup also fails
-		},
 	}
 
 	cfg := createTestConfig()
@@ -597,7 +649,7 @@ func TestUploadFile_DBError_S3CleanupFailure_ReturnsOriginalError(t *testing.T)
 	router := setupTestRouter()
 	router.POST("/api/v1/files", handler.UploadFile)
 
-	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", []byte("data"))
+	req, w, err := createMultipartRequest("test.png", "image/png", "portfolio-image", testPNGData())
 	if err != nil {
 		t.Fatalf("failed to create multipart request: %v", err)
 	}
@@ -608,9 +660,9 @@ func TestUploadFile_DBError_S3CleanupFailure_ReturnsOriginalError(t *testing.T)
 		t.Errorf("expected status %d, got %d", http.StatusInternalServerError, w.Code)
 	}
 
-	// Verify cleanup was attempted
-	if !s3CleanupCalled {
-		t.Error("expected S3 cleanup to be attempted")
+	// Verify discard was attempted
+	if !discardCalled {
+		t.Error("expected pending upload to be discarded")
 	}
 
 	// Original error message should still be returned
@@ -618,9 +670,9 @@ func TestUploadFile_DBError_S3CleanupFailure_ReturnsOriginalError(t *testing.T)
 		t.Errorf("expected 'failed to create file record' error, got %s", w.Body.String())
 	}
 
-	// S3 cleanup error should NOT be leaked in response (security)
-	if strings.Contains(w.Body.String(), "S3 cleanup failed") {
-		t.Error("S3 cleanup error should not be leaked in response")
+	// Discard error should NOT be leaked in response (security)
+	if strings.Contains(w.Body.String(), "discard failed") {
+		t.Error("discard error should not be leaked in response")
 	}
 }
 
@@ -647,10 +699,12 @@ func TestUploadFile_HostileFilenames(t *testing.T) {
 			expectError:      false,
 		},
 		{
+			// URL encoding is preserved, leaving ".%2Fetc%2Fpasswd" as the extension,
+			// which does not match the detected PNG content
 			name:             "path traversal encoded",
 			filename:         "..%2F..%2F..%2Fetc%2Fpasswd",
-			expectedFilename: "..%2F..%2F..%2Fetc%2Fpasswd", // URL encoding preserved
-			expectError:      false,
+			expectedFilename: "..%2F..%2F..%2Fetc%2Fpasswd",
+			expectError:      true,
 		},
 		{
 			name:             "directory separator",
@@ -697,11 +751,12 @@ func TestUploadFile_HostileFilenames(t *testing.T) {
 			}
 
 			mockRepo := &mockRepository{
-				createFileFunc: func(_ context.Context, _, key, fileName, _ string, _ int64, _ string) (*repository.StorageFile, error) {
-					storedFilename = fileName
-					s3Key = key
-					createdFile.S3Key = key
-					return createdFile, nil
+				createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+					storedFilename = file.FileName
+					s3Key = file.S3Key
+					createdFile.S3Key = file.S3Key
+					file.ID = createdFile.ID
+					return nil
 				},
 			}
 
@@ -717,7 +772,7 @@ func TestUploadFile_HostileFilenames(t *testing.T) {
 			router := setupTestRouter()
 			router.POST("/api/v1/files", handler.UploadFile)
 
-			req, w, err := createMultipartRequest(tc.filename, "image/png", "portfolio-image", []byte("png data"))
+			req, w, err := createMultipartRequest(tc.filename, "image/png", "portfolio-image", testPNGData())
 			if err != nil {
 				t.Fatalf("failed to create multipart request: %v", err)
 			}
@@ -762,10 +817,11 @@ func TestUploadFile_MiniatureImage(t *testing.T) {
 	}
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(_ context.Context, bucket, key, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
-			uploadedBucket = bucket
-			createdFile.S3Key = key
-			return createdFile, nil
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			uploadedBucket = file.S3Bucket
+			createdFile.S3Key = file.S3Key
+			file.ID = createdFile.ID
+			return nil
 		},
 	}
 
@@ -781,7 +837,7 @@ func TestUploadFile_MiniatureImage(t *testing.T) {
 	router := setupTestRouter()
 	router.POST("/api/v1/files", handler.UploadFile)
 
-	req, w, err := createMultipartRequest("miniature.png", "image/png", "miniature-image", []byte("png data"))
+	req, w, err := createMultipartRequest("miniature.png", "image/png", "miniature-image", testPNGData())
 	if err != nil {
 		t.Fatalf("failed to create multipart request: %v", err)
 	}
@@ -804,9 +860,10 @@ func TestUploadFile_ContextPropagation(t *testing.T) {
 	var capturedCtx context.Context
 
 	mockRepo := &mockRepository{
-		createFileFunc: func(ctx context.Context, _, _, _, _ string, _ int64, _ string) (*repository.StorageFile, error) {
+		createFileFunc: func(ctx context.Context, file *repository.StorageFile) error {
 			capturedCtx = ctx
-			return &repository.StorageFile{ID: 1, FileName: "test.png"}, nil
+			file.ID = 1
+			return nil
 		},
 	}
 
@@ -838,7 +895,7 @@ func TestUploadFile_ContextPropagation(t *testing.T) {
 	h["Content-Disposition"] = []string{`form-data; name="file"; filename="test.png"`}
 	h["Content-Type"] = []string{"image/png"}
 	part, _ := writer.CreatePart(h)
-	_, _ = part.Write([]byte("data"))
+	_, _ = part.Write(testPNGData())
 	_ = writer.WriteField("fileType", "portfolio-image")
 	_ = writer.Close()
 
@@ -859,3 +916,126 @@ func TestUploadFile_ContextPropagation(t *testing.T) {
 		t.Error("context sentinel value was not propagated to repository")
 	}
 }
+
+// =============================================================================
+// Upload File Content Sniffing Tests
+// =============================================================================
+
+func TestUploadFile_SpoofedContentType_Rejected(t *testing.T) {
+	var putCalled bool
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, _ string, _ io.Reader, _ int64, _ string) error {
+			putCalled = true
+			return nil
+		},
+	}
+
+	cfg := createTestConfig()
+	handler := New(&mockRepository{}, mockStore, cfg, &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	// Windows executable header labeled as PNG
+	exeData := append([]byte("MZ\x90\x00\x03\x00\x00\x00"), make([]byte, 64)...)
+	req, w, err := createMultipartRequest("image.png", "image/png", "portfolio-image", exeData)
+	if err != nil {
+		t.Fatalf("failed to create multipart request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "does not match declared content type") {
+		t.Errorf("expected content mismatch error, got %s", w.Body.String())
+	}
+	if putCalled {
+		t.Error("storage should not be called for spoofed content")
+	}
+}
+
+func TestUploadFile_DeclaredTypeMismatch_Rejected(t *testing.T) {
+	cfg := createTestConfig()
+	handler := New(&mockRepository{}, &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	// Real JPEG declared as PNG
+	req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testJPEGData())
+	if err != nil {
+		t.Fatalf("failed to create multipart request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+}
+
+func TestUploadFile_ExtensionMismatch_Rejected(t *testing.T) {
+	cfg := createTestConfig()
+	handler := New(&mockRepository{}, &mockStorage{}, cfg, &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("image.exe", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create multipart request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected status %d, got %d", http.StatusBadRequest, w.Code)
+	}
+	if !strings.Contains(w.Body.String(), "file extension does not match") {
+		t.Errorf("expected extension mismatch error, got %s", w.Body.String())
+	}
+}
+
+func TestUploadFile_StoresDetectedMimeType(t *testing.T) {
+	var dbMimeType, s3ContentType, s3Key string
+
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			dbMimeType = file.MimeType
+			file.ID = 1
+			return nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, contentType string) error {
+			s3Key = key
+			s3ContentType = contentType
+			return nil
+		},
+	}
+
+	cfg := createTestConfig()
+	handler := New(mockRepo, mockStore, cfg, &mockActionLogRepo{})
+
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	// Non-canonical declared type and no extension
+	req, w, err := createMultipartRequest("photo", "image/jpg", "portfolio-image", testJPEGData())
+	if err != nil {
+		t.Fatalf("failed to create multipart request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if dbMimeType != "image/jpeg" {
+		t.Errorf("expected stored mime type image/jpeg, got %s", dbMimeType)
+	}
+	if s3ContentType != "image/jpeg" {
+		t.Errorf("expected S3 content type image/jpeg, got %s", s3ContentType)
+	}
+	if !strings.HasSuffix(s3Key, ".jpg") {
+		t.Errorf("expected key with canonical .jpg extension, got %s", s3Key)
+	}
+}
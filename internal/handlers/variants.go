@@ -0,0 +1,120 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/gin-gonic/gin"
+)
+
+// renderedVariant is an encoded image variant waiting to be stored
+type renderedVariant struct {
+	record repository.FileVariant
+	data   []byte
+}
+
+// renderVariants renders every configured variant of an uploaded image.
+// Nothing is stored, so a failure leaves no objects behind.
+func (h *Handler) renderVariants(src io.ReadSeeker, key, fileName, fileType, contentType string) ([]renderedVariant, error) {
+	data, err := io.ReadAll(io.LimitReader(src, h.cfg.FileTypes.MaxSize()+1))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image: %w", err)
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		return nil, fmt.Errorf("failed to rewind file: %w", err)
+	}
+
+	keyBase := strings.TrimSuffix(key, filepath.Ext(key))
+	nameBase := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+
+	variants := make([]renderedVariant, 0, len(h.cfg.ImageVariants))
+	for _, variant := range h.cfg.ImageVariants {
+		transform := variant.Transform
+		if transform.Format == "" {
+			if transform.Format, err = images.ParseFormat("", contentType); err != nil {
+				return nil, err
+			}
+		}
+
+		out, err := images.Render(data, transform)
+		if err != nil {
+			return nil, fmt.Errorf("failed to render %s variant: %w", variant.Name, err)
+		}
+		info, err := images.Inspect(out)
+		if err != nil {
+			return nil, err
+		}
+
+		ext := "." + string(transform.Format)
+		if transform.Format == images.FormatJPEG {
+			ext = ".jpg"
+		}
+		file := repository.StorageFile{
+			S3Key:    keyBase + "_" + variant.Name + ext,
+			FileName: nameBase + "_" + variant.Name + ext,
+			FileSize: int64(len(out)),
+			MimeType: transform.Format.ContentType(),
+			FileType: fileType,
+		}
+		file.SetImageInfo(toImageInfo(info))
+		hash, err := hashContent(bytes.NewReader(out))
+		if err != nil {
+			return nil, err
+		}
+		file.ContentHash = &hash
+
+		variants = append(variants, renderedVariant{
+			record: repository.FileVariant{
+				Name:   variant.Name,
+				Width:  info.Width,
+				Height: info.Height,
+				File:   file,
+			},
+			data: out,
+		})
+	}
+	return variants, nil
+}
+
+// storeVariants uploads rendered variants to the bucket of their records, skipping
+// those that share an existing object
+func (h *Handler) storeVariants(c *gin.Context, variants []renderedVariant) error {
+	for i := range variants {
+		file := &variants[i].record.File
+		if file.SharesObject {
+			continue
+		}
+		if err := h.storage.PutObject(c.Request.Context(), file.S3Bucket, file.S3Key, bytes.NewReader(variants[i].data), file.FileSize, file.MimeType); err != nil {
+			return fmt.Errorf("failed to upload %s variant: %w", variants[i].record.Name, err)
+		}
+	}
+	return nil
+}
+
+func variantRecords(variants []renderedVariant) []repository.FileVariant {
+	records := make([]repository.FileVariant, len(variants))
+	for i, variant := range variants {
+		records[i] = variant.record
+	}
+	return records
+}
+
+func variantResponse(fileType string, variants []repository.FileVariant) []gin.H {
+	response := make([]gin.H, len(variants))
+	for i, variant := range variants {
+		response[i] = gin.H{
+			"name":     variant.Name,
+			"url":      fmt.Sprintf("/api/v1/files/%s/%s", fileType, variant.File.S3Key),
+			"width":    variant.Width,
+			"height":   variant.Height,
+			"fileSize": variant.File.FileSize,
+			"mimeType": variant.File.MimeType,
+		}
+	}
+	return response
+}
@@ -0,0 +1,263 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/GunarsK-portfolio/files-api/internal/config"
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func createVariantsTestConfig() *config.Config {
+	cfg := createTestConfig()
+	cfg.ImageVariants = []images.Variant{
+		{Name: "thumbnail", Transform: images.Transform{Width: 2, Height: 2, Fit: images.FitCover}},
+		{Name: "medium", Transform: images.Transform{Width: 3, Fit: images.FitContain, Format: images.FormatJPEG}},
+	}
+	return cfg
+}
+
+// recordingStore records stored objects and deleted keys
+type recordingStore struct {
+	mu      sync.Mutex
+	stored  map[string]string
+	deleted []string
+}
+
+func (r *recordingStore) mock(failKeySuffix string) *mockStorage {
+	r.stored = make(map[string]string)
+	return &mockStorage{
+		putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, contentType string) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			if failKeySuffix != "" && strings.HasSuffix(key, failKeySuffix) {
+				return errors.New("s3 unavailable")
+			}
+			r.stored[key] = contentType
+			return nil
+		},
+		deleteObjectFunc: func(_ context.Context, _, key string) error {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			r.deleted = append(r.deleted, key)
+			return nil
+		},
+	}
+}
+
+// =============================================================================
+// Upload Variant Tests
+// =============================================================================
+
+func TestUploadFile_GeneratesVariants(t *testing.T) {
+	var createdFile *repository.StorageFile
+	var createdVariants []repository.FileVariant
+
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, _ *repository.StorageFile) error {
+			t.Error("expected CreateFileWithVariants instead of CreateFile")
+			return errors.New("unexpected call")
+		},
+		createFileWithVariantsFunc: func(_ context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+			file.ID = 10
+			for i := range variants {
+				variants[i].ID = int64(i + 1)
+				variants[i].File.ID = int64(11 + i)
+			}
+			createdFile = file
+			createdVariants = variants
+			return nil
+		},
+	}
+	store := &recordingStore{}
+
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if createdFile == nil || len(createdVariants) != 2 {
+		t.Fatalf("expected original and 2 variants to be recorded, got %v", createdVariants)
+	}
+
+	base := strings.TrimSuffix(createdFile.S3Key, ".png")
+	expected := map[string]struct {
+		key, mime, fileName string
+		width, height       int
+	}{
+		"thumbnail": {base + "_thumbnail.png", "image/png", "photo_thumbnail.png", 2, 2},
+		"medium":    {base + "_medium.jpg", "image/jpeg", "photo_medium.jpg", 3, 3},
+	}
+	for _, variant := range createdVariants {
+		want, ok := expected[variant.Name]
+		if !ok {
+			t.Errorf("unexpected variant %s", variant.Name)
+			continue
+		}
+		if variant.File.S3Key != want.key || variant.File.MimeType != want.mime || variant.File.FileName != want.fileName {
+			t.Errorf("variant %s: got key=%s mime=%s name=%s", variant.Name, variant.File.S3Key, variant.File.MimeType, variant.File.FileName)
+		}
+		if variant.File.S3Bucket != testImagesBucket || variant.File.FileType != "portfolio-image" {
+			t.Errorf("variant %s: got bucket=%s type=%s", variant.Name, variant.File.S3Bucket, variant.File.FileType)
+		}
+		if variant.Width != want.width || variant.Height != want.height {
+			t.Errorf("variant %s: expected %dx%d, got %dx%d", variant.Name, want.width, want.height, variant.Width, variant.Height)
+		}
+		if store.stored[want.key] != want.mime {
+			t.Errorf("expected %s to be stored as %s", want.key, want.mime)
+		}
+	}
+	if _, ok := store.stored[createdFile.S3Key]; !ok {
+		t.Error("expected original to be stored")
+	}
+
+	var resp struct {
+		ID       int64 `json:"id"`
+		Variants []struct {
+			Name string `json:"name"`
+			URL  string `json:"url"`
+		} `json:"variants"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if resp.ID != 10 || len(resp.Variants) != 2 {
+		t.Fatalf("unexpected response: %s", w.Body.String())
+	}
+	if resp.Variants[0].URL != "/api/v1/files/portfolio-image/"+expected[resp.Variants[0].Name].key {
+		t.Errorf("unexpected variant URL %s", resp.Variants[0].URL)
+	}
+}
+
+func TestUploadFile_NoVariantsForOtherFileTypes(t *testing.T) {
+	var createFileCalled bool
+	mockRepo := &mockRepository{
+		createFileFunc: func(_ context.Context, file *repository.StorageFile) error {
+			createFileCalled = true
+			file.ID = 1
+			return nil
+		},
+	}
+	store := &recordingStore{}
+
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("mini.png", "image/png", "miniature-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	if !createFileCalled {
+		t.Error("expected CreateFile to be called")
+	}
+	if len(store.stored) != 1 {
+		t.Errorf("expected only the original to be stored, got %v", store.stored)
+	}
+	if strings.Contains(w.Body.String(), "variants") {
+		t.Errorf("expected no variants in response, got %s", w.Body.String())
+	}
+}
+
+func TestUploadFile_VariantUploadError_CleansUp(t *testing.T) {
+	var created []string
+	var deletions []repository.PendingDeletion
+	mockRepo := &mockRepository{
+		createFileWithVariantsFunc: func(_ context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+			created = append(created, file.Status)
+			for _, variant := range variants {
+				created = append(created, variant.File.Status)
+			}
+			file.ID = 1
+			return nil
+		},
+		discardPendingFileFunc: func(_ context.Context, _ int64, d []repository.PendingDeletion) error {
+			deletions = d
+			return nil
+		},
+	}
+	store := &recordingStore{}
+
+	handler := New(mockRepo, store.mock("_medium.jpg"), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if strings.Join(created, ",") != "pending,pending,pending" {
+		t.Errorf("expected original and variants recorded as pending, got %v", created)
+	}
+	// Every object, stored or not, is handed to the deletion worker
+	if len(deletions) != 3 {
+		t.Fatalf("expected 3 deletions, got %+v", deletions)
+	}
+	for key := range store.stored {
+		found := false
+		for _, d := range deletions {
+			found = found || (d.S3Key == key && d.S3Bucket == testImagesBucket)
+		}
+		if !found {
+			t.Errorf("expected stored object %s to be scheduled for deletion", key)
+		}
+	}
+	if len(store.deleted) != 0 {
+		t.Errorf("expected no synchronous deletes, got %v", store.deleted)
+	}
+}
+
+func TestUploadFile_VariantsDBError_CleansUp(t *testing.T) {
+	mockRepo := &mockRepository{
+		createFileWithVariantsFunc: func(_ context.Context, _ *repository.StorageFile, _ []repository.FileVariant) error {
+			return errors.New("database error")
+		},
+	}
+	store := &recordingStore{}
+
+	handler := New(mockRepo, store.mock(""), createVariantsTestConfig(), &mockActionLogRepo{})
+	router := setupTestRouter()
+	router.POST("/api/v1/files", handler.UploadFile)
+
+	req, w, err := createMultipartRequest("photo.png", "image/png", "portfolio-image", testPNGData())
+	if err != nil {
+		t.Fatalf("failed to create request: %v", err)
+	}
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusInternalServerError, w.Code, w.Body.String())
+	}
+	if len(store.stored) != 0 {
+		t.Errorf("expected nothing stored without pending records, stored %v", store.stored)
+	}
+}
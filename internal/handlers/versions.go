@@ -0,0 +1,386 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+
+	"github.com/GunarsK-portfolio/files-api/internal/filetypes"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/audit"
+	commonHandlers "github.com/GunarsK-portfolio/portfolio-common/handlers"
+	"github.com/GunarsK-portfolio/portfolio-common/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+// Audit actions for content versions
+const (
+	actionFileVersionUpload  = "file_version_upload"
+	actionFileVersionRestore = "file_version_restore"
+)
+
+// fileVersionsResponse is the version history of a file, current version first
+type fileVersionsResponse struct {
+	FileID         int64                    `json:"fileId"`
+	CurrentVersion int64                    `json:"currentVersion"`
+	Versions       []repository.FileVersion `json:"versions"`
+}
+
+// UploadFileVersion godoc
+// @Summary Upload a new version of a file
+// @Description Replace the content of a file of a versioned file type, keeping its ID, URL and metadata.
+// @Description The new content must have the file's content type and passes the same checks as uploads.
+// @Description The replaced content is kept as an earlier version. If-Match is optional; when sent it must
+// @Description carry the current ETag. Edit permission only allows the user's own uploads. The new content
+// @Description counts towards the uploader's storage quotas next to the kept versions; uploads over them are
+// @Description rejected with 413. Takes a token from the upload rate limit. Audit logged as file_version_upload.
+// @Tags files
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path int true "File ID"
+// @Param file formData file true "New content"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Param Content-MD5 header string false "Base64 MD5 of the file"
+// @Param X-Checksum-SHA256 header string false "Hex or base64 SHA-256 of the file"
+// @Success 200 {object} repository.StorageFile
+// @Header 200 {string} ETag "New metadata version"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 413 {object} map[string]interface{}
+// @Failure 422 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/content [put]
+func (h *Handler) UploadFileVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+	file, err := c.FormFile("file")
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "file is required")
+		return
+	}
+
+	current, ft, expectedVersion, ok := h.loadVersionedFile(c, id)
+	if !ok {
+		return
+	}
+
+	if file.Size > ft.MaxSize {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("file too large (max %d bytes)", ft.MaxSize))
+		return
+	}
+	if !h.checkVersionQuota(c, ft, current, file.Size) {
+		return
+	}
+	checksums, err := parseUploadChecksums(c.Request.Header)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to open file")
+		return
+	}
+	defer src.Close()
+
+	if checksums != nil {
+		if err := checksums.verify(src); err != nil {
+			respondChecksumError(c, err)
+			return
+		}
+	}
+
+	// The file keeps its URL, whose extension fixes the content type
+	contentType, err := detectContentType(src)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to inspect file")
+		return
+	}
+	if contentType != normalizeMimeType(file.Header.Get("Content-Type")) {
+		commonHandlers.RespondError(c, http.StatusBadRequest, errContentTypeMismatch.Error())
+		return
+	}
+	if contentType != current.MimeType {
+		commonHandlers.RespondError(c, http.StatusBadRequest, fmt.Sprintf("a new version must have the file's content type (%s)", current.MimeType))
+		return
+	}
+
+	content, size, imageInfo, err := h.processUpload(src, file.Size, current.FileType, contentType)
+	if err != nil {
+		respondImageError(c, err)
+		return
+	}
+
+	key := uuid.New().String() + filepath.Ext(current.S3Key)
+	next := &repository.StorageFile{
+		S3Key:    key,
+		S3Bucket: ft.Bucket,
+		FileName: current.FileName,
+		FileSize: size,
+		MimeType: contentType,
+		FileType: current.FileType,
+	}
+	next.SetImageInfo(imageInfo)
+	if checksums != nil {
+		checksums.apply(next)
+	}
+
+	scanResult, err := h.scanContent(c.Request.Context(), content)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusServiceUnavailable, err, "virus scan unavailable")
+		return
+	}
+	applyScanResult(next, scanResult)
+	if scanResult != nil && scanResult.Infected {
+		h.rejectInfectedUpload(c, next, content, size)
+		return
+	}
+
+	hash, err := hashContent(content)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to inspect file")
+		return
+	}
+	next.ContentHash = &hash
+	next.ContentKey = &key
+	next.QuotaCheck = h.quotaCheck(current.UploadedBy, ft, size, 0)
+
+	// The new version gets an object of its own, so the replaced one is never touched.
+	// An object left behind by a crash before the file points at it is an orphan that
+	// reconciliation reports.
+	if err := h.storage.PutObject(c.Request.Context(), ft.Bucket, key, content, size, contentType); err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to upload file")
+		return
+	}
+
+	updated, err := h.repo.ReplaceFileContent(c.Request.Context(), id, expectedVersion, next)
+	if err != nil {
+		if cleanupErr := h.storage.DeleteObject(c.Request.Context(), ft.Bucket, key); cleanupErr != nil {
+			logger.GetLogger(c).Error("Failed to cleanup version object after database error",
+				"error", cleanupErr,
+				"bucket", ft.Bucket,
+				"key", key,
+			)
+		}
+		if refusal, ok := quotaRefusal(err); ok {
+			respondUploadError(c, refusal)
+			return
+		}
+		respondVersionError(c, err, "file not found", "failed to store new version")
+		return
+	}
+
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileVersionUpload, &resourceType, &id, &source, map[string]interface{}{
+		"file_type":        updated.FileType,
+		"content_version":  updated.ContentVersion,
+		"size":             updated.FileSize,
+		"sha256":           hash,
+		"previous_version": current.ContentVersion,
+		"previous_sha256":  derefString(current.ContentHash),
+	})
+
+	h.respondWithFile(c, updated)
+}
+
+// ListFileVersions godoc
+// @Summary List file versions
+// @Description Version history of a file's content, current version first. Earlier versions are kept
+// @Description until the file is purged and can be made current again with the restore endpoint.
+// @Tags files
+// @Produce json
+// @Param id path int true "File ID"
+// @Success 200 {object} fileVersionsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/versions [get]
+func (h *Handler) ListFileVersions(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return
+	}
+	earlier, err := h.repo.ListFileVersions(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to list file versions")
+		return
+	}
+
+	c.JSON(http.StatusOK, fileVersionsResponse{
+		FileID:         file.ID,
+		CurrentVersion: file.ContentVersion,
+		Versions:       append([]repository.FileVersion{file.CurrentVersion()}, earlier...),
+	})
+}
+
+// RestoreFileVersion godoc
+// @Summary Restore an earlier file version
+// @Description Make the content of an earlier version current again. It becomes a new version, so the
+// @Description history is kept. If-Match is optional; when sent it must carry the current ETag.
+// @Description Edit permission only allows the user's own uploads. The restored content counts towards
+// @Description the uploader's storage quotas like a new version; restores over them are rejected with 413.
+// @Description Audit logged as file_version_restore.
+// @Tags files
+// @Produce json
+// @Param id path int true "File ID"
+// @Param version path int true "Version to restore"
+// @Param If-Match header string false "ETag of the version being replaced"
+// @Success 200 {object} repository.StorageFile
+// @Header 200 {string} ETag "New metadata version"
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 412 {object} map[string]string
+// @Failure 413 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Security BearerAuth
+// @Router /files/{id}/versions/{version}/restore [post]
+func (h *Handler) RestoreFileVersion(c *gin.Context) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid file ID")
+		return
+	}
+	contentVersion, err := strconv.ParseInt(c.Param("version"), 10, 64)
+	if err != nil || contentVersion < 1 {
+		commonHandlers.RespondError(c, http.StatusBadRequest, "invalid version")
+		return
+	}
+
+	current, ft, expectedVersion, ok := h.loadVersionedFile(c, id)
+	if !ok {
+		return
+	}
+	if contentVersion == current.ContentVersion {
+		commonHandlers.RespondError(c, http.StatusConflict, fmt.Sprintf("version %d is already current", contentVersion))
+		return
+	}
+	check, ok := h.restoreQuotaCheck(c, ft, current, contentVersion)
+	if !ok {
+		return
+	}
+
+	updated, err := h.repo.RestoreFileVersion(c.Request.Context(), id, expectedVersion, contentVersion, check)
+	if err != nil {
+		if refusal, ok := quotaRefusal(err); ok {
+			respondUploadError(c, refusal)
+			return
+		}
+		respondVersionError(c, err, "version not found", "failed to restore version")
+		return
+	}
+
+	resourceType := audit.ResourceTypeFile
+	source := "files-api"
+	_ = audit.LogFromContext(c, h.actionLogRepo, actionFileVersionRestore, &resourceType, &id, &source, map[string]interface{}{
+		"file_type":        updated.FileType,
+		"content_version":  updated.ContentVersion,
+		"restored_version": contentVersion,
+		"previous_version": current.ContentVersion,
+	})
+
+	h.respondWithFile(c, updated)
+}
+
+// restoreQuotaCheck checks the quotas of the file's uploader for restoring contentVersion,
+// whose content counts like a new version uploaded again, and returns the check to repeat
+// while restoring. Failures are answered and ok is false.
+func (h *Handler) restoreQuotaCheck(c *gin.Context, ft *filetypes.FileType, file *repository.StorageFile, contentVersion int64) (repository.QuotaCheck, bool) {
+	if file.UploadedBy == nil || !h.hasQuota(ft) {
+		return nil, true
+	}
+	earlier, err := h.repo.ListFileVersions(c.Request.Context(), file.ID)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "failed to list file versions")
+		return nil, false
+	}
+	for _, version := range earlier {
+		if version.Version != contentVersion {
+			continue
+		}
+		if !h.checkVersionQuota(c, ft, file, version.FileSize) {
+			return nil, false
+		}
+		return h.quotaCheck(file.UploadedBy, ft, version.FileSize, 0), true
+	}
+	commonHandlers.RespondError(c, http.StatusNotFound, "version not found")
+	return nil, false
+}
+
+// loadVersionedFile fetches a file whose content is about to change and checks that the
+// user may change it and that it can get a new version. A sent If-Match must match; the
+// returned metadata version is then required to be unchanged when the content is
+// replaced, and zero otherwise. Failures are answered and ok is false.
+func (h *Handler) loadVersionedFile(c *gin.Context, id int64) (file *repository.StorageFile, ft *filetypes.FileType, version int64, ok bool) {
+	file, err := h.repo.GetFileByID(c.Request.Context(), id)
+	if err != nil {
+		commonHandlers.HandleRepositoryError(c, err, "file not found", "failed to fetch file")
+		return nil, nil, 0, false
+	}
+	if !authorizeFileChange(c, file) {
+		return nil, nil, 0, false
+	}
+	if isInfected(file) || file.IsPending() {
+		commonHandlers.RespondError(c, http.StatusNotFound, "file not found")
+		return nil, nil, 0, false
+	}
+	if file.IsTrashed() {
+		commonHandlers.RespondError(c, http.StatusGone, trashedFileMessage)
+		return nil, nil, 0, false
+	}
+	if ifMatch := c.GetHeader("If-Match"); ifMatch != "" {
+		if !ifMatchSatisfied(ifMatch, fileETag(file)) {
+			commonHandlers.RespondError(c, http.StatusPreconditionFailed, "file was modified; fetch it again and retry")
+			return nil, nil, 0, false
+		}
+		version = file.Version
+	}
+
+	ft, err = h.fileType(file.FileType)
+	if err != nil {
+		commonHandlers.LogAndRespondError(c, http.StatusInternalServerError, err, "file type is no longer configured")
+		return nil, nil, 0, false
+	}
+	if !ft.Versioned {
+		commonHandlers.RespondError(c, http.StatusConflict, fmt.Sprintf("%s files are not versioned", ft.Name))
+		return nil, nil, 0, false
+	}
+	return file, ft, version, true
+}
+
+// respondVersionError answers a failed content replacement
+func respondVersionError(c *gin.Context, err error, notFoundMessage, errorMessage string) {
+	if errors.Is(err, repository.ErrFileVersionConflict) {
+		commonHandlers.RespondError(c, http.StatusPreconditionFailed, "file was modified; fetch it again and retry")
+		return
+	}
+	commonHandlers.HandleRepositoryError(c, err, notFoundMessage, errorMessage)
+}
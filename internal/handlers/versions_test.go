@@ -0,0 +1,482 @@
+package handlers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/scanner"
+	commonRepo "github.com/GunarsK-portfolio/portfolio-common/repository"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func setupVersionsRouter(mockRepo *mockRepository, mockStore *mockStorage, actionLogRepo *mockActionLogRepo) *gin.Engine {
+	handler := New(mockRepo, mockStore, createTestConfig(), actionLogRepo)
+	router := setupTestRouter()
+	router.PUT("/api/v1/files/:id/content", asAdmin(), handler.UploadFileVersion)
+	router.GET("/api/v1/files/:id/versions", handler.ListFileVersions)
+	router.POST("/api/v1/files/:id/versions/:version/restore", asAdmin(), handler.RestoreFileVersion)
+	return router
+}
+
+// createTestDocument returns a versioned document at content version contentVersion
+func createTestDocument(contentVersion int64) *repository.StorageFile {
+	return &repository.StorageFile{
+		ID:             1,
+		S3Key:          "cv.pdf",
+		S3Bucket:       testDocsBucket,
+		FileName:       "cv.pdf",
+		FileSize:       int64(len(testPDFData)),
+		MimeType:       "application/pdf",
+		FileType:       "document",
+		CreatedAt:      time.Now().Add(-time.Hour),
+		Status:         repository.FileStatusActive,
+		Version:        2,
+		ContentVersion: contentVersion,
+	}
+}
+
+// performVersionUpload sends content as the new version of file 1
+func performVersionUpload(t *testing.T, router *gin.Engine, contentType string, content []byte, headers map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreatePart(textproto.MIMEHeader{
+		"Content-Disposition": {`form-data; name="file"; filename="cv-v2.pdf"`},
+		"Content-Type":        {contentType},
+	})
+	if err != nil {
+		t.Fatalf("failed to create form part: %v", err)
+	}
+	if _, err := part.Write(content); err != nil {
+		t.Fatalf("failed to write form part: %v", err)
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatalf("failed to close form: %v", err)
+	}
+
+	requestHeaders := map[string]string{"Content-Type": writer.FormDataContentType()}
+	for key, value := range headers {
+		requestHeaders[key] = value
+	}
+	return performRequest(router, http.MethodPut, "/api/v1/files/1/content", body, requestHeaders)
+}
+
+// =============================================================================
+// Upload File Version Tests
+// =============================================================================
+
+func TestUploadFileVersion_Success(t *testing.T) {
+	var stored string
+	var replaced *repository.StorageFile
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTestDocument(1), nil
+		},
+		replaceFileContentFunc: func(_ context.Context, id, version int64, content *repository.StorageFile) (*repository.StorageFile, error) {
+			if id != 1 || version != 2 {
+				t.Errorf("expected file 1 at metadata version 2, got %d at %d", id, version)
+			}
+			replaced = content
+			updated := createTestDocument(2)
+			updated.Version = 3
+			updated.ContentKey = content.ContentKey
+			updated.ContentHash = content.ContentHash
+			return updated, nil
+		},
+	}
+	mockStore := &mockStorage{
+		putObjectFunc: func(_ context.Context, bucket, key string, _ io.Reader, _ int64, _ string) error {
+			if bucket != testDocsBucket {
+				t.Errorf("expected new version in the documents bucket, got %s", bucket)
+			}
+			stored = key
+			return nil
+		},
+	}
+	var logged *commonRepo.ActionLog
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+
+	router := setupVersionsRouter(mockRepo, mockStore, actionLogRepo)
+	w := performVersionUpload(t, router, "application/pdf", testPDFData, map[string]string{"If-Match": `"1-2"`})
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	// The replaced object is kept; the new content gets an object of its own
+	if stored == "" || stored == "cv.pdf" || filepath.Ext(stored) != ".pdf" {
+		t.Errorf("expected a new .pdf object, got %q", stored)
+	}
+	if replaced == nil || replaced.ContentKey == nil || *replaced.ContentKey != stored {
+		t.Fatalf("expected the file pointed at the stored object, got %+v", replaced)
+	}
+	if replaced.ContentHash == nil || *replaced.ContentHash != sha256Hex(testPDFData) {
+		t.Errorf("expected content hash of the new version, got %v", replaced.ContentHash)
+	}
+	if etag := w.Header().Get("ETag"); etag != `"1-3"` {
+		t.Errorf("expected ETag %q, got %q", `"1-3"`, etag)
+	}
+
+	var response map[string]interface{}
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response["url"] != "/api/v1/files/document/cv.pdf" || response["versionUrl"] != "/api/v1/files/document/cv.pdf?v=2" {
+		t.Errorf("expected stable and pinned URLs, got %v and %v", response["url"], response["versionUrl"])
+	}
+	if response["contentVersion"] != float64(2) {
+		t.Errorf("expected content version 2, got %v", response["contentVersion"])
+	}
+
+	if logged == nil || logged.ActionType != actionFileVersionUpload {
+		t.Fatalf("expected version upload to be audit logged, got %+v", logged)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(logged.Metadata, &metadata); err != nil {
+		t.Fatalf("failed to unmarshal audit metadata: %v", err)
+	}
+	if metadata["content_version"] != float64(2) || metadata["previous_version"] != float64(1) {
+		t.Errorf("expected versions in audit metadata, got %v", metadata)
+	}
+}
+
+func TestUploadFileVersion_Rejected(t *testing.T) {
+	testCases := []struct {
+		name           string
+		file           func() *repository.StorageFile
+		contentType    string
+		content        []byte
+		headers        map[string]string
+		expectedStatus int
+	}{
+		{
+			name:           "file type not versioned",
+			file:           createTestFile,
+			contentType:    "application/pdf",
+			content:        testPDFData,
+			expectedStatus: http.StatusConflict,
+		},
+		{
+			name: "trashed file",
+			file: func() *repository.StorageFile {
+				file := createTestDocument(1)
+				deletedAt := time.Now()
+				file.DeletedAt = &deletedAt
+				return file
+			},
+			contentType:    "application/pdf",
+			content:        testPDFData,
+			expectedStatus: http.StatusGone,
+		},
+		{
+			name:           "stale If-Match",
+			file:           func() *repository.StorageFile { return createTestDocument(1) },
+			contentType:    "application/pdf",
+			content:        testPDFData,
+			headers:        map[string]string{"If-Match": `"1-1"`},
+			expectedStatus: http.StatusPreconditionFailed,
+		},
+		{
+			name:           "different content type",
+			file:           func() *repository.StorageFile { return createTestDocument(1) },
+			contentType:    "image/png",
+			content:        testPNGData(),
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return tc.file(), nil
+				},
+				replaceFileContentFunc: func(_ context.Context, _, _ int64, _ *repository.StorageFile) (*repository.StorageFile, error) {
+					t.Error("expected content not to be replaced")
+					return nil, nil
+				},
+			}
+			mockStore := &mockStorage{
+				putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+					t.Errorf("expected nothing stored, got %s", key)
+					return nil
+				},
+			}
+
+			w := performVersionUpload(t, setupVersionsRouter(mockRepo, mockStore, &mockActionLogRepo{}), tc.contentType, tc.content, tc.headers)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestUploadFileVersion_ReplaceError_DeletesObject(t *testing.T) {
+	testCases := []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"modified concurrently", repository.ErrFileVersionConflict, http.StatusPreconditionFailed},
+		{"file gone", gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"database error", errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stored, deleted string
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return createTestDocument(1), nil
+				},
+				replaceFileContentFunc: func(_ context.Context, id, _ int64, _ *repository.StorageFile) (*repository.StorageFile, error) {
+					return nil, fmt.Errorf("failed to replace content of file id %d: %w", id, tc.err)
+				},
+			}
+			mockStore := &mockStorage{
+				putObjectFunc: func(_ context.Context, _, key string, _ io.Reader, _ int64, _ string) error {
+					stored = key
+					return nil
+				},
+				deleteObjectFunc: func(_ context.Context, _, key string) error {
+					deleted = key
+					return nil
+				},
+			}
+
+			w := performVersionUpload(t, setupVersionsRouter(mockRepo, mockStore, &mockActionLogRepo{}), "application/pdf", testPDFData, nil)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+			if stored == "" || deleted != stored {
+				t.Errorf("expected the unused object %q to be deleted, got %q", stored, deleted)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// List File Versions Tests
+// =============================================================================
+
+func TestListFileVersions_Success(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTestDocument(3), nil
+		},
+		listFileVersionsFunc: func(_ context.Context, fileID int64) ([]repository.FileVersion, error) {
+			if fileID != 1 {
+				t.Errorf("expected versions of file 1, got %d", fileID)
+			}
+			return []repository.FileVersion{{Version: 2}, {Version: 1}}, nil
+		},
+	}
+
+	w := performRequest(setupVersionsRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodGet, "/api/v1/files/1/versions", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response fileVersionsResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.FileID != 1 || response.CurrentVersion != 3 || len(response.Versions) != 3 {
+		t.Fatalf("expected current version and history of file 1, got %+v", response)
+	}
+	for i, want := range []int64{3, 2, 1} {
+		if response.Versions[i].Version != want {
+			t.Errorf("expected version %d at position %d, got %d", want, i, response.Versions[i].Version)
+		}
+	}
+	if response.Versions[0].ReplacedAt != nil {
+		t.Error("expected the current version not to be replaced")
+	}
+}
+
+func TestListFileVersions_Errors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		getErr         error
+		listErr        error
+		expectedStatus int
+	}{
+		{"invalid ID", "/api/v1/files/abc/versions", nil, nil, http.StatusBadRequest},
+		{"not found", "/api/v1/files/1/versions", gorm.ErrRecordNotFound, nil, http.StatusNotFound},
+		{"list error", "/api/v1/files/1/versions", nil, errors.New("database error"), http.StatusInternalServerError},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					if tc.getErr != nil {
+						return nil, tc.getErr
+					}
+					return createTestDocument(1), nil
+				},
+				listFileVersionsFunc: func(_ context.Context, _ int64) ([]repository.FileVersion, error) {
+					return nil, tc.listErr
+				},
+			}
+
+			w := performRequest(setupVersionsRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodGet, tc.path, nil)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d", tc.expectedStatus, w.Code)
+			}
+		})
+	}
+}
+
+// =============================================================================
+// Restore File Version Tests
+// =============================================================================
+
+func TestRestoreFileVersion_Success(t *testing.T) {
+	mockRepo := &mockRepository{
+		getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+			return createTestDocument(2), nil
+		},
+		restoreFileVersionFunc: func(_ context.Context, id, version, contentVersion int64, _ repository.QuotaCheck) (*repository.StorageFile, error) {
+			if id != 1 || version != 0 || contentVersion != 1 {
+				t.Errorf("expected version 1 of file 1 restored unconditionally, got %d/%d/%d", id, version, contentVersion)
+			}
+			// Restoring makes the old content a new version
+			updated := createTestDocument(3)
+			updated.Version = 3
+			return updated, nil
+		},
+	}
+	var logged *commonRepo.ActionLog
+	actionLogRepo := &mockActionLogRepo{
+		logActionFunc: func(log *commonRepo.ActionLog) error {
+			logged = log
+			return nil
+		},
+	}
+
+	w := performRequest(setupVersionsRouter(mockRepo, &mockStorage{}, actionLogRepo), http.MethodPost, "/api/v1/files/1/versions/1/restore", nil)
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body.String())
+	}
+	var response repository.StorageFile
+	if err := json.Unmarshal(w.Body.Bytes(), &response); err != nil {
+		t.Fatalf("failed to unmarshal response: %v", err)
+	}
+	if response.ContentVersion != 3 || response.VersionURL != "/api/v1/files/document/cv.pdf?v=3" {
+		t.Errorf("expected content version 3, got %+v", response)
+	}
+
+	if logged == nil || logged.ActionType != actionFileVersionRestore {
+		t.Fatalf("expected restore to be audit logged, got %+v", logged)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(logged.Metadata, &metadata); err != nil {
+		t.Fatalf("failed to unmarshal audit metadata: %v", err)
+	}
+	if metadata["restored_version"] != float64(1) || metadata["previous_version"] != float64(2) {
+		t.Errorf("expected versions in audit metadata, got %v", metadata)
+	}
+}
+
+func TestRestoreFileVersion_Errors(t *testing.T) {
+	testCases := []struct {
+		name           string
+		path           string
+		restoreErr     error
+		expectedStatus int
+	}{
+		{"invalid version", "/api/v1/files/1/versions/0/restore", nil, http.StatusBadRequest},
+		{"already current", "/api/v1/files/1/versions/2/restore", nil, http.StatusConflict},
+		{"version not found", "/api/v1/files/1/versions/7/restore", gorm.ErrRecordNotFound, http.StatusNotFound},
+		{"modified concurrently", "/api/v1/files/1/versions/1/restore", repository.ErrFileVersionConflict, http.StatusPreconditionFailed},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					return createTestDocument(2), nil
+				},
+				restoreFileVersionFunc: func(_ context.Context, id, _, contentVersion int64, _ repository.QuotaCheck) (*repository.StorageFile, error) {
+					if tc.restoreErr == nil {
+						t.Error("expected no restore")
+						return nil, nil
+					}
+					return nil, fmt.Errorf("failed to restore version %d of file id %d: %w", contentVersion, id, tc.restoreErr)
+				},
+			}
+
+			w := performRequest(setupVersionsRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{}), http.MethodPost, tc.path, nil)
+
+			if w.Code != tc.expectedStatus {
+				t.Errorf("expected status %d, got %d: %s", tc.expectedStatus, w.Code, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestVersionedFile_HiddenFilesNotFound(t *testing.T) {
+	infected := scanner.VerdictInfected
+	tests := []struct {
+		name   string
+		change func(file *repository.StorageFile)
+	}{
+		{"pending", func(file *repository.StorageFile) { file.Status = repository.FileStatusPending }},
+		{"infected", func(file *repository.StorageFile) { file.ScanStatus = &infected }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mockRepo := &mockRepository{
+				getFileByIDFunc: func(_ context.Context, _ int64) (*repository.StorageFile, error) {
+					file := createTestDocument(2)
+					tt.change(file)
+					return file, nil
+				},
+				replaceFileContentFunc: func(_ context.Context, _, _ int64, _ *repository.StorageFile) (*repository.StorageFile, error) {
+					t.Error("expected no new version")
+					return nil, nil
+				},
+				restoreFileVersionFunc: func(_ context.Context, _, _, _ int64, _ repository.QuotaCheck) (*repository.StorageFile, error) {
+					t.Error("expected no restore")
+					return nil, nil
+				},
+			}
+			router := setupVersionsRouter(mockRepo, &mockStorage{}, &mockActionLogRepo{})
+
+			upload := performVersionUpload(t, router, "application/pdf", testPDFData, nil)
+			restore := performRequest(router, http.MethodPost, "/api/v1/files/1/versions/1/restore", nil)
+
+			if upload.Code != http.StatusNotFound || restore.Code != http.StatusNotFound {
+				t.Errorf("expected status %d for upload and restore, got %d and %d", http.StatusNotFound, upload.Code, restore.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,57 @@
+package images
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+)
+
+// Info describes an encoded image as read from its header
+type Info struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// Limits bounds the dimensions accepted for uploaded images
+type Limits struct {
+	MaxWidth  int
+	MaxHeight int
+	MaxPixels int64
+}
+
+// CanInspect reports whether headers of the MIME type can be decoded
+func CanInspect(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// Inspect reads the dimensions and format from an image header without decoding the pixels.
+// The dimensions of WebP images are those displayed, after their EXIF orientation.
+func Inspect(data []byte) (Info, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return Info{}, fmt.Errorf("%w: %v", ErrMalformedImage, err)
+	}
+	// Orientations 5 to 8 turn the image by 90 degrees
+	if format == "webp" && webpOrientation(data) >= 5 {
+		cfg.Width, cfg.Height = cfg.Height, cfg.Width
+	}
+	return Info{Width: cfg.Width, Height: cfg.Height, Format: format}, nil
+}
+
+// Check returns ErrImageTooLarge when the image exceeds any of the limits, so that
+// small files which decompress to huge bitmaps are rejected before decoding
+func (l Limits) Check(info Info) error {
+	if info.Width > l.MaxWidth || info.Height > l.MaxHeight {
+		return fmt.Errorf("%w: %dx%d exceeds %dx%d", ErrImageTooLarge, info.Width, info.Height, l.MaxWidth, l.MaxHeight)
+	}
+	if pixels := int64(info.Width) * int64(info.Height); pixels > l.MaxPixels {
+		return fmt.Errorf("%w: %d pixels exceeds %d", ErrImageTooLarge, pixels, l.MaxPixels)
+	}
+	return nil
+}
@@ -0,0 +1,56 @@
+package images
+
+import (
+	"errors"
+	"testing"
+)
+
+// =============================================================================
+// Inspect Tests
+// =============================================================================
+
+func TestInspect(t *testing.T) {
+	info, err := Inspect(testPNG(t, 6, 3))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if info.Width != 6 || info.Height != 3 || info.Format != "png" {
+		t.Errorf("expected 6x3 png, got %dx%d %s", info.Width, info.Height, info.Format)
+	}
+
+	info, err = Inspect(testJPEG(t, 2, 5))
+	if err != nil || info.Width != 2 || info.Height != 5 || info.Format != "jpeg" {
+		t.Errorf("expected 2x5 jpeg, got %+v, %v", info, err)
+	}
+
+	if _, err := Inspect([]byte("not an image")); !errors.Is(err, ErrMalformedImage) {
+		t.Errorf("expected ErrMalformedImage, got %v", err)
+	}
+}
+
+func TestLimitsCheck(t *testing.T) {
+	limits := Limits{MaxWidth: 100, MaxHeight: 50, MaxPixels: 2000}
+
+	tests := []struct {
+		name    string
+		info    Info
+		wantErr bool
+	}{
+		{"within limits", Info{Width: 40, Height: 50}, false},
+		{"too wide", Info{Width: 101, Height: 1}, true},
+		{"too tall", Info{Width: 1, Height: 51}, true},
+		{"too many pixels", Info{Width: 100, Height: 21}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := limits.Check(tt.info)
+			if tt.wantErr && !errors.Is(err, ErrImageTooLarge) {
+				t.Errorf("expected ErrImageTooLarge, got %v", err)
+			}
+			if !tt.wantErr && err != nil {
+				t.Errorf("unexpected error: %v", err)
+			}
+		})
+	}
+}
@@ -0,0 +1,442 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"image/jpeg"
+	"image/png"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// ErrMalformedImage reports an image whose container structure cannot be parsed
+var ErrMalformedImage = errors.New("malformed image")
+
+// sanitizeJPEGQuality is used when a JPEG has to be re-encoded to apply its orientation
+const sanitizeJPEGQuality = 92
+
+var (
+	pngSignature    = []byte("\x89PNG\r\n\x1a\n")
+	exifHeader      = []byte("Exif\x00\x00")
+	iccProfileLabel = []byte("ICC_PROFILE\x00")
+)
+
+// pngMetadataChunks are ancillary PNG chunks that can carry EXIF, free text or timestamps
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+// VP8X feature flags announcing EXIF and XMP chunks
+const (
+	webpFlagEXIF = 0x08
+	webpFlagXMP  = 0x04
+)
+
+// CanSanitize reports whether Sanitize understands images of the MIME type
+func CanSanitize(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp":
+		return true
+	default:
+		return false
+	}
+}
+
+// Sanitize removes EXIF, XMP, IPTC and text metadata from JPEG, PNG and WebP images.
+// Metadata is dropped without touching pixel data unless the EXIF orientation requires
+// rotation, in which case JPEG and PNG images are re-encoded upright. WebP cannot be
+// re-encoded, so rotated WebP images keep an EXIF chunk with only their orientation.
+// Other types are returned unchanged.
+func Sanitize(data []byte, mimeType string) ([]byte, error) {
+	switch mimeType {
+	case "image/jpeg":
+		return sanitizeJPEG(data)
+	case "image/png":
+		return sanitizePNG(data)
+	case "image/webp":
+		return sanitizeWebP(data)
+	default:
+		return data, nil
+	}
+}
+
+func malformed(reason string) error {
+	return fmt.Errorf("%w: %s", ErrMalformedImage, reason)
+}
+
+// =============================================================================
+// JPEG
+// =============================================================================
+
+func sanitizeJPEG(data []byte) ([]byte, error) {
+	if len(data) < 2 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, malformed("missing JPEG SOI marker")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, 0xFF, 0xD8)
+	orientation := 0
+	pos := 2
+
+	for {
+		if pos >= len(data) || data[pos] != 0xFF {
+			return nil, malformed("invalid JPEG marker")
+		}
+		// Markers may be preceded by any number of 0xFF fill bytes
+		for pos < len(data) && data[pos] == 0xFF {
+			pos++
+		}
+		if pos >= len(data) {
+			return nil, malformed("truncated JPEG marker")
+		}
+		marker := data[pos]
+		pos++
+
+		if marker == 0xD9 {
+			// Anything after EOI, such as appended preview or depth images, is dropped
+			out = append(out, 0xFF, 0xD9)
+			break
+		}
+		if isStandaloneJPEGMarker(marker) {
+			out = append(out, 0xFF, marker)
+			continue
+		}
+
+		if pos+2 > len(data) {
+			return nil, malformed("truncated JPEG segment")
+		}
+		length := int(binary.BigEndian.Uint16(data[pos:]))
+		if length < 2 || pos+length > len(data) {
+			return nil, malformed("invalid JPEG segment length")
+		}
+		payload := data[pos+2 : pos+length]
+
+		if marker == 0xE1 && orientation == 0 && bytes.HasPrefix(payload, exifHeader) {
+			orientation = exifOrientation(payload[len(exifHeader):])
+		}
+		if keepJPEGSegment(marker, payload) {
+			out = append(out, 0xFF, marker)
+			out = append(out, data[pos:pos+length]...)
+		}
+		pos += length
+
+		if marker == 0xDA {
+			// Entropy-coded data runs until the next marker that is neither a stuffed byte nor a restart marker
+			start := pos
+			for pos+1 < len(data) && (data[pos] != 0xFF || data[pos+1] == 0x00 || isStandaloneJPEGMarker(data[pos+1])) {
+				pos++
+			}
+			if pos+1 >= len(data) {
+				// Tolerate a missing EOI, as decoders do
+				out = append(out, data[start:]...)
+				break
+			}
+			out = append(out, data[start:pos]...)
+		}
+	}
+
+	if orientation > 1 {
+		return reorient(out, orientation, encodeJPEG)
+	}
+	return out, nil
+}
+
+// isStandaloneJPEGMarker reports markers without a length field (TEM and RST0-RST7)
+func isStandaloneJPEGMarker(marker byte) bool {
+	return marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7)
+}
+
+// keepJPEGSegment keeps everything needed to render the image faithfully: JFIF,
+// ICC profiles and the Adobe colour transform. Other APPn segments (EXIF, XMP,
+// IPTC, maker data) and comments are dropped.
+func keepJPEGSegment(marker byte, payload []byte) bool {
+	switch {
+	case marker == 0xE0, marker == 0xEE:
+		return true
+	case marker == 0xE2:
+		return bytes.HasPrefix(payload, iccProfileLabel)
+	case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+		return false
+	default:
+		return true
+	}
+}
+
+func encodeJPEG(w io.Writer, img image.Image) error {
+	return jpeg.Encode(w, img, &jpeg.Options{Quality: sanitizeJPEGQuality})
+}
+
+// =============================================================================
+// PNG
+// =============================================================================
+
+func sanitizePNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, malformed("missing PNG signature")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, pngSignature...)
+	orientation := 0
+	pos := len(pngSignature)
+
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, malformed("truncated PNG chunk")
+		}
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		chunkType := string(data[pos+4 : pos+8])
+		if length > len(data) || pos+12+length > len(data) {
+			return nil, malformed("invalid PNG chunk length")
+		}
+		end := pos + 12 + length
+
+		if chunkType == "eXIf" {
+			orientation = exifOrientation(data[pos+8 : pos+8+length])
+		}
+		if !pngMetadataChunks[chunkType] {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+
+		if chunkType == "IEND" {
+			break
+		}
+	}
+
+	if orientation > 1 {
+		return reorient(out, orientation, png.Encode)
+	}
+	return out, nil
+}
+
+// =============================================================================
+// WebP
+// =============================================================================
+
+// riffChunk is a chunk of a WebP RIFF container
+type riffChunk struct {
+	fourCC  string
+	payload []byte
+	// raw holds the chunk header, payload and padding as stored
+	raw []byte
+}
+
+// parseWebP splits a WebP file into the chunks following its RIFF header
+func parseWebP(data []byte) ([]riffChunk, error) {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return nil, malformed("missing WebP RIFF header")
+	}
+	riffEnd := 8 + int(binary.LittleEndian.Uint32(data[4:]))
+	if riffEnd < 12 || riffEnd > len(data) {
+		return nil, malformed("invalid WebP RIFF size")
+	}
+	data = data[:riffEnd]
+
+	var chunks []riffChunk
+	pos := 12
+	for pos < len(data) {
+		if pos+8 > len(data) {
+			return nil, malformed("truncated WebP chunk")
+		}
+		size := int(binary.LittleEndian.Uint32(data[pos+4:]))
+		if size > len(data) {
+			return nil, malformed("invalid WebP chunk size")
+		}
+		// Chunks are padded to an even size
+		end := pos + 8 + size + size&1
+		if end > len(data) {
+			return nil, malformed("invalid WebP chunk size")
+		}
+		chunks = append(chunks, riffChunk{
+			fourCC:  string(data[pos : pos+4]),
+			payload: data[pos+8 : pos+8+size],
+			raw:     data[pos:end],
+		})
+		pos = end
+	}
+	return chunks, nil
+}
+
+// sanitizeWebP drops the EXIF and XMP chunks. WebP pixels cannot be re-encoded here,
+// so a rotated image keeps a minimal EXIF chunk holding nothing but its orientation,
+// which browsers and Render apply when displaying it.
+func sanitizeWebP(data []byte) ([]byte, error) {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return nil, err
+	}
+	orientation := webpChunksOrientation(chunks)
+
+	out := make([]byte, 12, len(data))
+	copy(out, data[:12])
+	exifWritten := false
+	for _, chunk := range chunks {
+		switch chunk.fourCC {
+		case "EXIF":
+			if orientation > 1 && !exifWritten {
+				out = appendRIFFChunk(out, "EXIF", orientationEXIF(orientation))
+				exifWritten = true
+			}
+		case "XMP ":
+			// Metadata chunks are dropped
+		case "VP8X":
+			start := len(out)
+			out = append(out, chunk.raw...)
+			if len(chunk.payload) > 0 {
+				out[start+8] &^= webpFlagXMP
+				if orientation <= 1 {
+					out[start+8] &^= webpFlagEXIF
+				}
+			}
+		default:
+			out = append(out, chunk.raw...)
+		}
+	}
+
+	binary.LittleEndian.PutUint32(out[4:], uint32(len(out)-8))
+	return out, nil
+}
+
+// webpOrientation returns the EXIF orientation (1-8) of a WebP image, or 0 when it
+// has none or cannot be parsed
+func webpOrientation(data []byte) int {
+	chunks, err := parseWebP(data)
+	if err != nil {
+		return 0
+	}
+	return webpChunksOrientation(chunks)
+}
+
+func webpChunksOrientation(chunks []riffChunk) int {
+	for _, chunk := range chunks {
+		if chunk.fourCC == "EXIF" {
+			// Some writers keep the JPEG APP1 prefix in front of the TIFF data
+			return exifOrientation(bytes.TrimPrefix(chunk.payload, exifHeader))
+		}
+	}
+	return 0
+}
+
+func appendRIFFChunk(out []byte, fourCC string, payload []byte) []byte {
+	out = append(out, fourCC...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(payload)))
+	out = append(out, payload...)
+	if len(payload)&1 == 1 {
+		out = append(out, 0)
+	}
+	return out
+}
+
+// orientationEXIF builds little-endian TIFF data whose only tag is the orientation
+func orientationEXIF(orientation int) []byte {
+	const orientationTag, typeShort = 0x0112, 3
+
+	tiff := []byte("II")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0x002A)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 8)
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, orientationTag)
+	tiff = binary.LittleEndian.AppendUint16(tiff, typeShort)
+	tiff = binary.LittleEndian.AppendUint32(tiff, 1)
+	tiff = binary.LittleEndian.AppendUint16(tiff, uint16(orientation))
+	tiff = binary.LittleEndian.AppendUint16(tiff, 0)
+	// No further IFDs
+	return binary.LittleEndian.AppendUint32(tiff, 0)
+}
+
+// =============================================================================
+// Orientation
+// =============================================================================
+
+// exifOrientation reads the orientation tag (1-8) from TIFF-structured EXIF data,
+// returning 0 when it is absent or the data cannot be parsed
+func exifOrientation(tiff []byte) int {
+	const orientationTag = 0x0112
+
+	if len(tiff) < 8 {
+		return 0
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 0
+	}
+
+	offset := int64(order.Uint32(tiff[4:]))
+	if offset < 8 || offset+2 > int64(len(tiff)) {
+		return 0
+	}
+	count := int64(order.Uint16(tiff[offset:]))
+	for i := int64(0); i < count; i++ {
+		entry := offset + 2 + i*12
+		if entry+12 > int64(len(tiff)) {
+			return 0
+		}
+		if order.Uint16(tiff[entry:]) == orientationTag {
+			value := int(order.Uint16(tiff[entry+8:]))
+			if value < 1 || value > 8 {
+				return 0
+			}
+			return value
+		}
+	}
+	return 0
+}
+
+// reorient decodes a metadata-free image, applies the EXIF orientation and re-encodes it.
+// Re-encoding drops the remaining ancillary data, including ICC profiles.
+func reorient(data []byte, orientation int, encode func(io.Writer, image.Image) error) ([]byte, error) {
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedImage, err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrMalformedImage, err)
+	}
+
+	buf := &bytes.Buffer{}
+	if err := encode(buf, applyOrientation(img, orientation)); err != nil {
+		return nil, fmt.Errorf("failed to encode image: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// applyOrientation transforms img so that an EXIF orientation of 1 (upright) applies
+func applyOrientation(img image.Image, orientation int) image.Image {
+	switch orientation {
+	case 2:
+		return imaging.FlipH(img)
+	case 3:
+		return imaging.Rotate180(img)
+	case 4:
+		return imaging.FlipV(img)
+	case 5:
+		return imaging.Transpose(img)
+	case 6:
+		return imaging.Rotate270(img)
+	case 7:
+		return imaging.Transverse(img)
+	case 8:
+		return imaging.Rotate90(img)
+	default:
+		return img
+	}
+}
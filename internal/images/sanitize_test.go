@@ -0,0 +1,261 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+const testSecret = "GPS-SECRET"
+
+func testJPEG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x * 40), G: uint8(y * 40), B: 100, A: 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := jpeg.Encode(buf, img, nil); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// testEXIF builds big-endian TIFF data with an orientation tag and a Make string holding testSecret
+func testEXIF(orientation int) []byte {
+	const valueOffset = 8 + 2 + 2*12 + 4
+	makeValue := testSecret + "\x00"
+
+	buf := &bytes.Buffer{}
+	buf.WriteString("MM")
+	_ = binary.Write(buf, binary.BigEndian, []uint16{0x002A})
+	_ = binary.Write(buf, binary.BigEndian, uint32(8))
+	_ = binary.Write(buf, binary.BigEndian, uint16(2))
+	// Make (ASCII) stored after the IFD
+	_ = binary.Write(buf, binary.BigEndian, []uint16{0x010F, 2})
+	_ = binary.Write(buf, binary.BigEndian, []uint32{uint32(len(makeValue)), valueOffset})
+	// Orientation (SHORT) stored inline
+	_ = binary.Write(buf, binary.BigEndian, []uint16{0x0112, 3})
+	_ = binary.Write(buf, binary.BigEndian, []uint32{1})
+	_ = binary.Write(buf, binary.BigEndian, []uint16{uint16(orientation), 0})
+	_ = binary.Write(buf, binary.BigEndian, uint32(0))
+	buf.WriteString(makeValue)
+	return buf.Bytes()
+}
+
+func jpegSegment(marker byte, payload []byte) []byte {
+	segment := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(segment[2:], uint16(len(payload)+2))
+	return append(segment, payload...)
+}
+
+// withJPEGMetadata inserts an EXIF segment and a comment after SOI and appends trailing data
+func withJPEGMetadata(src []byte, orientation int) []byte {
+	out := append([]byte{}, src[:2]...)
+	out = append(out, jpegSegment(0xE1, append([]byte("Exif\x00\x00"), testEXIF(orientation)...))...)
+	out = append(out, jpegSegment(0xFE, []byte("taken at "+testSecret))...)
+	out = append(out, src[2:]...)
+	return append(out, []byte("appended "+testSecret)...)
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+// withPNGMetadata inserts text and EXIF chunks before IEND
+func withPNGMetadata(src []byte, orientation int) []byte {
+	iend := len(src) - 12
+	out := append([]byte{}, src[:iend]...)
+	out = append(out, pngChunk("tEXt", []byte("Comment\x00"+testSecret))...)
+	out = append(out, pngChunk("eXIf", testEXIF(orientation))...)
+	return append(out, src[iend:]...)
+}
+
+func webpChunk(fourCC string, data []byte) []byte {
+	chunk := []byte(fourCC)
+	chunk = binary.LittleEndian.AppendUint32(chunk, uint32(len(data)))
+	chunk = append(chunk, data...)
+	if len(data)%2 == 1 {
+		chunk = append(chunk, 0)
+	}
+	return chunk
+}
+
+func riff(chunks ...[]byte) []byte {
+	body := []byte("WEBP")
+	for _, chunk := range chunks {
+		body = append(body, chunk...)
+	}
+	out := []byte("RIFF")
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(body)))
+	return append(out, body...)
+}
+
+// =============================================================================
+// Sanitize Tests
+// =============================================================================
+
+func TestSanitize_JPEGStripsMetadata(t *testing.T) {
+	plain := testJPEG(t, 4, 2)
+
+	out, err := Sanitize(withJPEGMetadata(plain, 1), "image/jpeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	// Without rotation only metadata is removed, so the encoded image is unchanged
+	if !bytes.Equal(out, plain) {
+		t.Errorf("expected metadata-free JPEG to equal the original encoding (got %d bytes, want %d)", len(out), len(plain))
+	}
+}
+
+func TestSanitize_JPEGAppliesOrientation(t *testing.T) {
+	out, err := Sanitize(withJPEGMetadata(testJPEG(t, 4, 2), 6), "image/jpeg")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(out, []byte(testSecret)) || bytes.Contains(out, []byte("Exif")) {
+		t.Error("expected metadata to be removed")
+	}
+	width, height, format := decodeSize(t, out)
+	if width != 2 || height != 4 || format != "jpeg" {
+		t.Errorf("expected upright 2x4 jpeg, got %dx%d %s", width, height, format)
+	}
+}
+
+func TestSanitize_PNG(t *testing.T) {
+	plain := testPNG(t, 4, 2)
+
+	out, err := Sanitize(withPNGMetadata(plain, 1), "image/png")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !bytes.Equal(out, plain) {
+		t.Error("expected text and EXIF chunks to be removed without touching image data")
+	}
+
+	rotated, err := Sanitize(withPNGMetadata(plain, 8), "image/png")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if bytes.Contains(rotated, []byte(testSecret)) {
+		t.Error("expected metadata to be removed")
+	}
+	if width, height, _ := decodeSize(t, rotated); width != 2 || height != 4 {
+		t.Errorf("expected upright 2x4 png, got %dx%d", width, height)
+	}
+}
+
+func TestSanitize_WebP(t *testing.T) {
+	const flagAlpha = 0x10
+	bitstream := []byte{1, 2, 3}
+	xmp := webpChunk("XMP ", []byte("<x:xmpmeta>"+testSecret+"</x:xmpmeta>"))
+
+	testCases := []struct {
+		name        string
+		exif        []byte
+		wantFlags   byte
+		wantChunks  [][]byte
+		orientation int
+	}{
+		{
+			name:        "upright drops exif",
+			exif:        testEXIF(1),
+			wantFlags:   flagAlpha,
+			orientation: 0,
+		},
+		{
+			name:        "rotated keeps orientation only",
+			exif:        testEXIF(6),
+			wantFlags:   flagAlpha | webpFlagEXIF,
+			wantChunks:  [][]byte{webpChunk("EXIF", orientationEXIF(6))},
+			orientation: 6,
+		},
+		{
+			name:        "rotated with app1 prefix",
+			exif:        append([]byte("Exif\x00\x00"), testEXIF(8)...),
+			wantFlags:   flagAlpha | webpFlagEXIF,
+			wantChunks:  [][]byte{webpChunk("EXIF", orientationEXIF(8))},
+			orientation: 8,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			vp8x := []byte{flagAlpha | webpFlagEXIF | webpFlagXMP, 0, 0, 0, 3, 0, 0, 1, 0, 0}
+			src := riff(webpChunk("VP8X", vp8x), webpChunk("VP8 ", bitstream), webpChunk("EXIF", tc.exif), xmp)
+
+			out, err := Sanitize(src, "image/webp")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			vp8x[0] = tc.wantFlags
+			expected := riff(append([][]byte{webpChunk("VP8X", vp8x), webpChunk("VP8 ", bitstream)}, tc.wantChunks...)...)
+			if !bytes.Equal(out, expected) {
+				t.Errorf("unexpected sanitized WebP:\n got %x\nwant %x", out, expected)
+			}
+			if bytes.Contains(out, []byte(testSecret)) {
+				t.Error("expected metadata to be removed")
+			}
+			if got := webpOrientation(out); got != tc.orientation {
+				t.Errorf("expected orientation %d after sanitizing, got %d", tc.orientation, got)
+			}
+			info, err := Inspect(out)
+			if err != nil {
+				t.Fatalf("failed to inspect sanitized WebP: %v", err)
+			}
+			wantWidth, wantHeight := 4, 2
+			if tc.orientation >= 5 {
+				wantWidth, wantHeight = 2, 4
+			}
+			if info.Width != wantWidth || info.Height != wantHeight {
+				t.Errorf("expected displayed size %dx%d, got %dx%d", wantWidth, wantHeight, info.Width, info.Height)
+			}
+		})
+	}
+}
+
+func TestSanitize_MalformedAndOtherTypes(t *testing.T) {
+	malformedCases := []struct {
+		name     string
+		data     []byte
+		mimeType string
+	}{
+		{"jpeg without SOI", []byte("not a jpeg"), "image/jpeg"},
+		{"jpeg truncated segment", []byte{0xFF, 0xD8, 0xFF, 0xE1, 0x00, 0x40, 0x00}, "image/jpeg"},
+		{"png chunk past end", append(append([]byte{}, pngSignature...), 0, 0, 1, 0, 'I', 'H', 'D', 'R'), "image/png"},
+		{"webp riff size past end", []byte("RIFF\xff\x00\x00\x00WEBP"), "image/webp"},
+		{"webp chunk past end", riff([]byte("VP8 \x10\x00\x00\x00")), "image/webp"},
+	}
+	for _, tc := range malformedCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if _, err := Sanitize(tc.data, tc.mimeType); !errors.Is(err, ErrMalformedImage) {
+				t.Errorf("expected ErrMalformedImage, got %v", err)
+			}
+		})
+	}
+
+	pdf := []byte("%PDF-1.4 " + testSecret)
+	out, err := Sanitize(pdf, "application/pdf")
+	if err != nil || !bytes.Equal(out, pdf) {
+		t.Errorf("expected non-image content to be returned unchanged, got %q, %v", out, err)
+	}
+	if CanSanitize("image/gif") || !CanSanitize("image/webp") {
+		t.Error("unexpected CanSanitize result")
+	}
+}
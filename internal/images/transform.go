@@ -0,0 +1,180 @@
+package images
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"strings"
+
+	"github.com/disintegration/imaging"
+
+	// Register decoders for source formats not covered by imaging's imports
+	_ "golang.org/x/image/webp"
+)
+
+// Fit controls how an image is scaled into the requested box
+type Fit string
+
+const (
+	// FitContain scales the image to fit inside the box, keeping the aspect ratio
+	FitContain Fit = "contain"
+	// FitCover scales and center-crops the image to fill the box exactly
+	FitCover Fit = "cover"
+)
+
+// Format is an output encoding for derived images.
+// WebP output is only available once a WebP encoder is configured (see SetWebPEncoder).
+type Format string
+
+const (
+	FormatJPEG Format = "jpeg"
+	FormatPNG  Format = "png"
+	FormatWebP Format = "webp"
+)
+
+// jpegQuality and webpQuality balance size and quality for photos served on the portfolio sites
+const (
+	jpegQuality = 85
+	webpQuality = 80
+)
+
+// maxSourcePixels bounds the decoded size of a source image (about 160 MB as RGBA)
+const maxSourcePixels = 40_000_000
+
+var (
+	ErrUnsupportedFormat = errors.New("unsupported output format")
+	ErrImageTooLarge     = errors.New("source image dimensions too large")
+)
+
+// Transform describes a derived image. A zero Width or Height is derived from the aspect ratio.
+type Transform struct {
+	Width  int
+	Height int
+	Fit    Fit
+	Format Format
+}
+
+// Key returns the object name suffix identifying this transform, e.g. "w640-h480-cover.jpeg"
+func (t Transform) Key() string {
+	return fmt.Sprintf("w%d-h%d-%s.%s", t.Width, t.Height, t.Fit, t.Format)
+}
+
+// DerivedPrefix returns the key prefix under which all derived images of an original are stored
+func DerivedPrefix(originalKey string) string {
+	return "_derived/" + originalKey + "/"
+}
+
+// DerivedSource returns the key of the original a derived image was rendered from
+func DerivedSource(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, "_derived/")
+	if !ok {
+		return "", false
+	}
+	i := strings.LastIndex(rest, "/")
+	if i <= 0 {
+		return "", false
+	}
+	return rest[:i], true
+}
+
+// DerivedKey returns the object key for a derived image of an original
+func DerivedKey(originalKey string, t Transform) string {
+	return DerivedPrefix(originalKey) + t.Key()
+}
+
+// ParseFormat resolves a format name; an empty name yields the default for the source MIME type.
+// WebP is refused with ErrUnsupportedFormat while no WebP encoder is configured.
+func ParseFormat(name, sourceMimeType string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "":
+		switch {
+		case sourceMimeType == "image/png":
+			return FormatPNG, nil
+		case sourceMimeType == "image/webp" && WebPAvailable():
+			return FormatWebP, nil
+		}
+		return FormatJPEG, nil
+	case "jpeg", "jpg":
+		return FormatJPEG, nil
+	case "png":
+		return FormatPNG, nil
+	case "webp":
+		if !WebPAvailable() {
+			return "", fmt.Errorf("%w: %s (no WebP encoder configured)", ErrUnsupportedFormat, name)
+		}
+		return FormatWebP, nil
+	default:
+		return "", fmt.Errorf("%w: %s", ErrUnsupportedFormat, name)
+	}
+}
+
+// ContentType returns the MIME type of the encoded output
+func (f Format) ContentType() string {
+	return "image/" + string(f)
+}
+
+// Render decodes src, applies EXIF orientation and the transform, and encodes the result
+func Render(src []byte, t Transform) ([]byte, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(src))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read image header: %w", err)
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxSourcePixels {
+		return nil, ErrImageTooLarge
+	}
+
+	img, err := imaging.Decode(bytes.NewReader(src), imaging.AutoOrientation(true))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode image: %w", err)
+	}
+	// imaging only reads the orientation of JPEG images
+	if format == "webp" {
+		img = applyOrientation(img, webpOrientation(src))
+	}
+
+	img = resize(img, t)
+
+	buf := &bytes.Buffer{}
+	if err := encode(buf, img, t.Format); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func resize(img image.Image, t Transform) image.Image {
+	bounds := img.Bounds()
+	switch {
+	case t.Width == 0 && t.Height == 0:
+		return img
+	case t.Fit == FitCover && t.Width > 0 && t.Height > 0:
+		return imaging.Fill(img, t.Width, t.Height, imaging.Center, imaging.Lanczos)
+	case t.Width > 0 && t.Height > 0:
+		return imaging.Fit(img, t.Width, t.Height, imaging.Lanczos)
+	case t.Width > 0:
+		// Never upscale when only one dimension is constrained
+		if bounds.Dx() <= t.Width {
+			return img
+		}
+		return imaging.Resize(img, t.Width, 0, imaging.Lanczos)
+	default:
+		if bounds.Dy() <= t.Height {
+			return img
+		}
+		return imaging.Resize(img, 0, t.Height, imaging.Lanczos)
+	}
+}
+
+func encode(w io.Writer, img image.Image, format Format) error {
+	switch format {
+	case FormatJPEG:
+		return imaging.Encode(w, img, imaging.JPEG, imaging.JPEGQuality(jpegQuality))
+	case FormatPNG:
+		return imaging.Encode(w, img, imaging.PNG)
+	case FormatWebP:
+		return encodeWebP(w, img)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, format)
+	}
+}
@@ -0,0 +1,282 @@
+package images
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func testPNG(t *testing.T, width, height int) []byte {
+	t.Helper()
+	img := image.NewRGBA(image.Rect(0, 0, width, height))
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			img.Set(x, y, color.RGBA{R: uint8(x), G: uint8(y), B: 100, A: 255})
+		}
+	}
+	buf := &bytes.Buffer{}
+	if err := png.Encode(buf, img); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+	return buf.Bytes()
+}
+
+// fakeWebPEncoder installs a stand-in for cwebp that keeps its input in the returned
+// path and answers with a minimal WebP header
+func fakeWebPEncoder(t *testing.T) string {
+	t.Helper()
+	encoder := fakeWebPEncoderPath(t)
+	input := filepath.Join(filepath.Dir(encoder), "input.png")
+	if err := SetWebPEncoder(encoder, 1); err != nil {
+		t.Fatalf("failed to set webp encoder: %v", err)
+	}
+	t.Cleanup(func() { _ = SetWebPEncoder("", 0) })
+	return input
+}
+
+// fakeWebPEncoderPath writes the stand-in for cwebp without enabling it; it keeps its
+// input in input.png next to it
+func fakeWebPEncoderPath(t *testing.T) string {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("fake encoder is a shell script")
+	}
+	dir := t.TempDir()
+	input := filepath.Join(dir, "input.png")
+	script := "#!/bin/sh\ncat > '" + input + "'\nprintf 'RIFF\\000\\000\\000\\000WEBPVP8 '\n"
+	encoder := filepath.Join(dir, "cwebp")
+	if err := os.WriteFile(encoder, []byte(script), 0o755); err != nil {
+		t.Fatalf("failed to write fake encoder: %v", err)
+	}
+	return encoder
+}
+
+func decodeSize(t *testing.T, data []byte) (int, int, string) {
+	t.Helper()
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		t.Fatalf("failed to decode rendered image: %v", err)
+	}
+	return cfg.Width, cfg.Height, format
+}
+
+// =============================================================================
+// Render Tests
+// =============================================================================
+
+func TestRender_Resize(t *testing.T) {
+	src := testPNG(t, 200, 100)
+
+	testCases := []struct {
+		name       string
+		transform  Transform
+		wantWidth  int
+		wantHeight int
+	}{
+		{"contain keeps aspect ratio", Transform{Width: 50, Height: 50, Fit: FitContain, Format: FormatPNG}, 50, 25},
+		{"cover fills box", Transform{Width: 50, Height: 50, Fit: FitCover, Format: FormatPNG}, 50, 50},
+		{"width only", Transform{Width: 100, Fit: FitContain, Format: FormatPNG}, 100, 50},
+		{"height only", Transform{Height: 20, Fit: FitContain, Format: FormatPNG}, 40, 20},
+		{"width only never upscales", Transform{Width: 400, Fit: FitContain, Format: FormatPNG}, 200, 100},
+		{"contain never upscales", Transform{Width: 400, Height: 400, Fit: FitContain, Format: FormatPNG}, 200, 100},
+		{"format only", Transform{Fit: FitContain, Format: FormatPNG}, 200, 100},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			out, err := Render(src, tc.transform)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			width, height, _ := decodeSize(t, out)
+			if width != tc.wantWidth || height != tc.wantHeight {
+				t.Errorf("got %dx%d, want %dx%d", width, height, tc.wantWidth, tc.wantHeight)
+			}
+		})
+	}
+}
+
+func TestRender_ConvertsFormat(t *testing.T) {
+	out, err := Render(testPNG(t, 10, 10), Transform{Fit: FitContain, Format: FormatJPEG})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, _, format := decodeSize(t, out); format != "jpeg" {
+		t.Errorf("expected jpeg output, got %s", format)
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(out)); err != nil {
+		t.Errorf("output is not a valid JPEG: %v", err)
+	}
+}
+
+func TestRender_WebP(t *testing.T) {
+	input := fakeWebPEncoder(t)
+
+	out, err := Render(testPNG(t, 200, 100), Transform{Width: 50, Fit: FitContain, Format: FormatWebP})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !isWebP(out) {
+		t.Errorf("expected encoder output, got %q", out)
+	}
+	sent, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("failed to read encoder input: %v", err)
+	}
+	if width, height, format := decodeSize(t, sent); width != 50 || height != 25 || format != "png" {
+		t.Errorf("expected resized 50x25 png sent to encoder, got %dx%d %s", width, height, format)
+	}
+}
+
+func TestRender_WebPWithoutEncoder(t *testing.T) {
+	_, err := Render(testPNG(t, 10, 10), Transform{Fit: FitContain, Format: FormatWebP})
+	if !errors.Is(err, ErrUnsupportedFormat) {
+		t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+	}
+}
+
+func TestRender_WebPEncoderBusy(t *testing.T) {
+	fakeWebPEncoder(t)
+	queueTimeout := webpQueueTimeout
+	webpQueueTimeout = 10 * time.Millisecond
+	t.Cleanup(func() { webpQueueTimeout = queueTimeout })
+	// The only worker is taken by another render
+	webpSlots <- struct{}{}
+
+	_, err := Render(testPNG(t, 10, 10), Transform{Fit: FitContain, Format: FormatWebP})
+	if !errors.Is(err, ErrEncoderBusy) {
+		t.Fatalf("expected ErrEncoderBusy, got %v", err)
+	}
+
+	<-webpSlots
+	if _, err := Render(testPNG(t, 10, 10), Transform{Fit: FitContain, Format: FormatWebP}); err != nil {
+		t.Errorf("expected render once the worker is free, got %v", err)
+	}
+}
+
+func TestSetWebPEncoder_Invalid(t *testing.T) {
+	encoder := fakeWebPEncoderPath(t)
+	tests := []struct {
+		name    string
+		path    string
+		workers int
+	}{
+		{"missing encoder", filepath.Join(t.TempDir(), "cwebp"), 1},
+		{"no workers", encoder, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := SetWebPEncoder(tt.path, tt.workers); err == nil {
+				t.Error("expected error")
+			}
+			if WebPAvailable() {
+				t.Error("expected webp output to stay disabled")
+			}
+		})
+	}
+}
+
+func TestRender_RejectsOversizedSource(t *testing.T) {
+	// A GIF header claiming 65535x65535 pixels; only the header is needed to reject it
+	header := []byte("GIF89a")
+	header = binary.LittleEndian.AppendUint16(header, 65535)
+	header = binary.LittleEndian.AppendUint16(header, 65535)
+	header = append(header, 0, 0, 0)
+
+	_, err := Render(header, Transform{Width: 10, Fit: FitContain, Format: FormatPNG})
+	if !errors.Is(err, ErrImageTooLarge) {
+		t.Errorf("expected ErrImageTooLarge, got %v", err)
+	}
+}
+
+func TestRender_InvalidImage(t *testing.T) {
+	if _, err := Render([]byte("not an image"), Transform{Format: FormatPNG}); err == nil {
+		t.Error("expected error for invalid image data")
+	}
+}
+
+// =============================================================================
+// Format and Key Tests
+// =============================================================================
+
+func TestParseFormat(t *testing.T) {
+	testCases := []struct {
+		name       string
+		format     string
+		sourceType string
+		want       Format
+		wantErr    bool
+	}{
+		{"default for png", "", "image/png", FormatPNG, false},
+		{"default for jpeg", "", "image/jpeg", FormatJPEG, false},
+		{"default for webp", "", "image/webp", FormatJPEG, false},
+		{"jpg alias", "jpg", "image/png", FormatJPEG, false},
+		{"case insensitive", "PNG", "image/jpeg", FormatPNG, false},
+		{"webp not available", "webp", "image/png", "", true},
+		{"unknown", "tiff", "image/png", "", true},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := ParseFormat(tc.format, tc.sourceType)
+			if (err != nil) != tc.wantErr {
+				t.Fatalf("ParseFormat(%q) error = %v, wantErr %v", tc.format, err, tc.wantErr)
+			}
+			if tc.wantErr && !errors.Is(err, ErrUnsupportedFormat) {
+				t.Errorf("expected ErrUnsupportedFormat, got %v", err)
+			}
+			if got != tc.want {
+				t.Errorf("ParseFormat(%q) = %q, want %q", tc.format, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestParseFormat_WebPEncoder(t *testing.T) {
+	fakeWebPEncoder(t)
+
+	if got, err := ParseFormat("webp", "image/png"); err != nil || got != FormatWebP {
+		t.Errorf("ParseFormat(webp) = %q, %v; want %q", got, err, FormatWebP)
+	}
+	if got, _ := ParseFormat("", "image/webp"); got != FormatWebP {
+		t.Errorf("expected webp default for webp sources, got %q", got)
+	}
+	if got, _ := ParseFormat("", "image/jpeg"); got != FormatJPEG {
+		t.Errorf("expected jpeg default for jpeg sources, got %q", got)
+	}
+}
+
+func TestDerivedKey(t *testing.T) {
+	transform := Transform{Width: 640, Height: 480, Fit: FitCover, Format: FormatJPEG}
+	key := DerivedKey("abc.png", transform)
+
+	if key != "_derived/abc.png/w640-h480-cover.jpeg" {
+		t.Errorf("unexpected derived key %q", key)
+	}
+	if !strings.HasPrefix(key, DerivedPrefix("abc.png")) {
+		t.Error("expected derived key to start with derived prefix")
+	}
+	if source, ok := DerivedSource(key); !ok || source != "abc.png" {
+		t.Errorf("expected source abc.png, got %q", source)
+	}
+	for _, other := range []string{"abc.png", "_derived/abc.png", "_derived//w640.jpeg"} {
+		if _, ok := DerivedSource(other); ok {
+			t.Errorf("expected %q not to be a derived key", other)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package images
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Variant is a named image rendition generated at upload time
+type Variant struct {
+	Name      string
+	Transform Transform
+}
+
+// maxVariantSize caps variant dimensions, matching the limit on on-the-fly transforms
+const maxVariantSize = 4096
+
+var variantNamePattern = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// ParseVariants parses a comma-separated list of "name:WxH[:fit[:format]]" entries,
+// e.g. "thumbnail:320x320:cover,large:1920x1920". A zero width or height is
+// derived from the aspect ratio. An empty format follows the source image.
+func ParseVariants(spec string) ([]Variant, error) {
+	var variants []Variant
+	seen := make(map[string]bool)
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		fields := strings.Split(entry, ":")
+		if len(fields) < 2 || len(fields) > 4 {
+			return nil, fmt.Errorf("invalid variant %q: expected name:WxH[:fit[:format]]", entry)
+		}
+
+		name := fields[0]
+		if !variantNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid variant name %q: use lowercase letters, digits and dashes", name)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("duplicate variant name %q", name)
+		}
+		seen[name] = true
+
+		width, height, err := parseDimensions(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid variant %q: %w", entry, err)
+		}
+
+		transform := Transform{Width: width, Height: height, Fit: FitContain}
+		if len(fields) > 2 && fields[2] != "" {
+			transform.Fit = Fit(fields[2])
+		}
+		if transform.Fit != FitContain && transform.Fit != FitCover {
+			return nil, fmt.Errorf("invalid variant %q: fit must be %s or %s", entry, FitContain, FitCover)
+		}
+		if transform.Fit == FitCover && (width == 0 || height == 0) {
+			return nil, fmt.Errorf("invalid variant %q: fit=%s requires width and height", entry, FitCover)
+		}
+		if len(fields) > 3 && fields[3] != "" {
+			if transform.Format, err = ParseFormat(fields[3], ""); err != nil {
+				return nil, fmt.Errorf("invalid variant %q: %w", entry, err)
+			}
+		}
+
+		variants = append(variants, Variant{Name: name, Transform: transform})
+	}
+
+	return variants, nil
+}
+
+func parseDimensions(value string) (int, int, error) {
+	widthStr, heightStr, ok := strings.Cut(value, "x")
+	if !ok {
+		return 0, 0, fmt.Errorf("size must be WxH")
+	}
+	width, err := strconv.Atoi(widthStr)
+	if err != nil || width < 0 {
+		return 0, 0, fmt.Errorf("invalid width %q", widthStr)
+	}
+	height, err := strconv.Atoi(heightStr)
+	if err != nil || height < 0 {
+		return 0, 0, fmt.Errorf("invalid height %q", heightStr)
+	}
+	if width == 0 && height == 0 {
+		return 0, 0, fmt.Errorf("width or height is required")
+	}
+	if width > maxVariantSize || height > maxVariantSize {
+		return 0, 0, fmt.Errorf("width and height must not exceed %d", maxVariantSize)
+	}
+	return width, height, nil
+}
@@ -0,0 +1,74 @@
+package images
+
+import (
+	"testing"
+)
+
+// =============================================================================
+// Variant Parsing Tests
+// =============================================================================
+
+func TestParseVariants(t *testing.T) {
+	variants, err := ParseVariants(" thumbnail:320x320:cover , medium:1024x0, large:1920x1920::png,")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []Variant{
+		{Name: "thumbnail", Transform: Transform{Width: 320, Height: 320, Fit: FitCover}},
+		{Name: "medium", Transform: Transform{Width: 1024, Fit: FitContain}},
+		{Name: "large", Transform: Transform{Width: 1920, Height: 1920, Fit: FitContain, Format: FormatPNG}},
+	}
+	if len(variants) != len(expected) {
+		t.Fatalf("expected %d variants, got %d", len(expected), len(variants))
+	}
+	for i := range expected {
+		if variants[i] != expected[i] {
+			t.Errorf("variant %d: expected %+v, got %+v", i, expected[i], variants[i])
+		}
+	}
+
+	empty, err := ParseVariants("")
+	if err != nil || len(empty) != 0 {
+		t.Errorf("expected empty spec to disable variants, got %v, %v", empty, err)
+	}
+}
+
+func TestParseVariants_Invalid(t *testing.T) {
+	tests := []struct {
+		name string
+		spec string
+	}{
+		{"missing size", "thumbnail"},
+		{"bad name", "Thumb_Nail:10x10"},
+		{"duplicate name", "a:10x10,a:20x20"},
+		{"bad size", "a:10"},
+		{"negative width", "a:-1x10"},
+		{"zero size", "a:0x0"},
+		{"oversized", "a:5000x10"},
+		{"unknown fit", "a:10x10:stretch"},
+		{"cover without height", "a:10x0:cover"},
+		{"webp output without encoder", "a:10x10:contain:webp"},
+		{"too many fields", "a:10x10:contain:png:x"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := ParseVariants(tt.spec); err == nil {
+				t.Errorf("expected error for %q", tt.spec)
+			}
+		})
+	}
+}
+
+func TestParseVariants_WebPEncoder(t *testing.T) {
+	fakeWebPEncoder(t)
+
+	variants, err := ParseVariants("a:10x10:contain:webp")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if variants[0].Transform.Format != FormatWebP {
+		t.Errorf("expected webp variant, got %q", variants[0].Transform.Format)
+	}
+}
@@ -0,0 +1,114 @@
+package images
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// webpEncodeTimeout bounds a single cwebp run
+const webpEncodeTimeout = 30 * time.Second
+
+// webpQueueTimeout bounds the wait for a free encoder before giving up with
+// ErrEncoderBusy
+var webpQueueTimeout = 5 * time.Second
+
+// ErrEncoderBusy is returned when every WebP encoder stayed in use for webpQueueTimeout
+var ErrEncoderBusy = errors.New("webp encoder busy")
+
+// There is no pure-Go WebP encoder, so WebP output is produced by the cwebp tool
+// from libwebp, with at most as many runs at once as there are slots. Without it
+// WebP is accepted as a source only.
+var (
+	webpMu      sync.RWMutex
+	webpEncoder string
+	webpSlots   chan struct{}
+)
+
+// SetWebPEncoder enables WebP output using the cwebp binary at path, which may be a
+// name looked up in PATH, running at most workers encodings at once. An empty path
+// disables WebP output.
+func SetWebPEncoder(path string, workers int) error {
+	resolved := ""
+	if path != "" {
+		if workers < 1 {
+			return fmt.Errorf("webp encoder needs at least one worker, got %d", workers)
+		}
+		var err error
+		if resolved, err = exec.LookPath(path); err != nil {
+			return fmt.Errorf("webp encoder not found: %w", err)
+		}
+	}
+	webpMu.Lock()
+	defer webpMu.Unlock()
+	webpEncoder = resolved
+	webpSlots = nil
+	if resolved != "" {
+		webpSlots = make(chan struct{}, workers)
+	}
+	return nil
+}
+
+// WebPAvailable reports whether WebP output is enabled
+func WebPAvailable() bool {
+	webpMu.RLock()
+	defer webpMu.RUnlock()
+	return webpEncoder != ""
+}
+
+// encodeWebP hands the image to cwebp as a lossless PNG and copies its output to w
+func encodeWebP(w io.Writer, img image.Image) error {
+	webpMu.RLock()
+	encoder, slots := webpEncoder, webpSlots
+	webpMu.RUnlock()
+	if encoder == "" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, FormatWebP)
+	}
+
+	queued := time.NewTimer(webpQueueTimeout)
+	defer queued.Stop()
+	select {
+	case slots <- struct{}{}:
+		defer func() { <-slots }()
+	case <-queued.C:
+		return ErrEncoderBusy
+	}
+
+	input := &bytes.Buffer{}
+	if err := png.Encode(input, img); err != nil {
+		return fmt.Errorf("failed to prepare webp input: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), webpEncodeTimeout)
+	defer cancel()
+	// "-" reads the image from stdin and "-o -" writes the result to stdout
+	cmd := exec.CommandContext(ctx, encoder, "-quiet", "-q", strconv.Itoa(webpQuality), "-o", "-", "--", "-")
+	cmd.Stdin = input
+	output := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = output
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to encode webp: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	if !isWebP(output.Bytes()) {
+		return fmt.Errorf("failed to encode webp: encoder did not produce a WebP image")
+	}
+
+	_, err := w.Write(output.Bytes())
+	return err
+}
+
+// isWebP checks the RIFF container header of a WebP file
+func isWebP(data []byte) bool {
+	return len(data) >= 12 && string(data[0:4]) == "RIFF" && string(data[8:12]) == "WEBP"
+}
@@ -0,0 +1,198 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// fileDeletionsBatchSize limits how many pending deletions are attempted per run
+const fileDeletionsBatchSize = 100
+
+// DeletionRetry configures the backoff between attempts of a failed deletion and when
+// a deletion counts as stuck.
+type DeletionRetry struct {
+	Backoff    time.Duration
+	MaxBackoff time.Duration
+	StuckAfter time.Duration
+}
+
+// FileDeletions removes the objects of purged files. Each deletion is retried with
+// exponential backoff until it succeeds; ones pending longer than StuckAfter are
+// reported by the portfolio_files_deletions_stuck gauge.
+type FileDeletions struct {
+	repo    repository.Repository
+	storage storage.ObjectStore
+	retry   DeletionRetry
+	log     *slog.Logger
+
+	pending  prometheus.Gauge
+	stuck    prometheus.Gauge
+	failures prometheus.Counter
+}
+
+func NewFileDeletions(repo repository.Repository, storage storage.ObjectStore, retry DeletionRetry, reg prometheus.Registerer, log *slog.Logger) *FileDeletions {
+	factory := promauto.With(reg)
+	return &FileDeletions{
+		repo:    repo,
+		storage: storage,
+		retry:   retry,
+		log:     log,
+		pending: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "deletions_pending",
+			Help:      "Storage objects of purged files not yet deleted",
+		}),
+		stuck: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "deletions_stuck",
+			Help:      "Storage object deletions pending longer than the stuck threshold",
+		}),
+		failures: factory.NewCounter(prometheus.CounterOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "deletion_failures_total",
+			Help:      "Failed storage object deletion attempts",
+		}),
+	}
+}
+
+func (j *FileDeletions) Name() string {
+	return "file-deletions"
+}
+
+func (j *FileDeletions) Run(ctx context.Context) error {
+	now := time.Now()
+	deletions, err := j.repo.ListDueDeletions(ctx, now, fileDeletionsBatchSize)
+	if err != nil {
+		return errors.Join(err, j.updateStats(ctx, now))
+	}
+
+	var errs []error
+	deleted := 0
+	for i := range deletions {
+		if err := j.process(ctx, &deletions[i], now); err != nil {
+			errs = append(errs, fmt.Errorf("deletion %d: %w", deletions[i].ID, err))
+			continue
+		}
+		deleted++
+	}
+
+	if deleted > 0 {
+		j.log.Info("Purged file objects deleted", "count", deleted)
+	}
+
+	errs = append(errs, j.updateStats(ctx, now))
+	return errors.Join(errs...)
+}
+
+// process deletes the object and finalizes the deletion, or schedules the next
+// attempt. Storage failures are expected to be transient and are not returned.
+func (j *FileDeletions) process(ctx context.Context, deletion *repository.PendingDeletion, now time.Time) error {
+	var err error
+	if deletion.IsPrefix {
+		err = j.storage.DeletePrefix(ctx, deletion.S3Bucket, deletion.S3Key)
+	} else {
+		err = j.storage.DeleteObject(ctx, deletion.S3Bucket, deletion.S3Key)
+	}
+	if err == nil {
+		return j.repo.CompleteDeletion(ctx, deletion.ID)
+	}
+
+	j.failures.Inc()
+	deletion.Attempts++
+	deletion.NextAttemptAt = now.Add(j.backoff(deletion.Attempts))
+	lastError := err.Error()
+	deletion.LastError = &lastError
+
+	j.log.Warn("Failed to delete purged file object",
+		"error", err,
+		"bucket", deletion.S3Bucket,
+		"key", deletion.S3Key,
+		"file_id", deletion.FileID,
+		"attempts", deletion.Attempts,
+		"next_attempt_at", deletion.NextAttemptAt,
+	)
+	return j.repo.RetryDeletion(ctx, deletion)
+}
+
+// backoff doubles the delay with every failed attempt, up to MaxBackoff
+func (j *FileDeletions) backoff(attempts int) time.Duration {
+	delay := j.retry.Backoff
+	for i := 1; i < attempts && delay < j.retry.MaxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, j.retry.MaxBackoff)
+}
+
+func (j *FileDeletions) updateStats(ctx context.Context, now time.Time) error {
+	stats, err := j.repo.GetDeletionStats(ctx, now.Add(-j.retry.StuckAfter))
+	if err != nil {
+		return err
+	}
+	j.pending.Set(float64(stats.Pending))
+	j.stuck.Set(float64(stats.Stuck))
+	if stats.Stuck > 0 {
+		j.log.Warn("Purged file objects stuck pending deletion", "count", stats.Stuck)
+	}
+	return nil
+}
+
+// fileObjectDeletions lists the objects of a file, its earlier versions and its variants,
+// and their cached derived images, as deletions due now.
+func fileObjectDeletions(ctx context.Context, repo repository.Repository, file *repository.StorageFile) ([]repository.PendingDeletion, error) {
+	variants, err := repo.ListFileVariants(ctx, file.ID)
+	if err != nil {
+		return nil, err
+	}
+	versions, err := repo.ListFileVersions(ctx, file.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	deletion := func(bucket, key string, prefix bool) repository.PendingDeletion {
+		return repository.PendingDeletion{
+			FileID:        file.ID,
+			S3Bucket:      bucket,
+			S3Key:         key,
+			IsPrefix:      prefix,
+			NextAttemptAt: now,
+		}
+	}
+
+	// A restored version shares its object with the current content
+	keys := []string{file.ObjectKey()}
+	for _, version := range versions {
+		if !slices.Contains(keys, version.ContentKey) {
+			keys = append(keys, version.ContentKey)
+		}
+	}
+
+	deletions := make([]repository.PendingDeletion, 0, len(variants)+2*len(keys))
+	for _, variant := range variants {
+		deletions = append(deletions, deletion(variant.File.S3Bucket, variant.File.ObjectKey(), false))
+	}
+	for _, key := range keys {
+		deletions = append(deletions, deletion(file.S3Bucket, key, false))
+		if strings.HasPrefix(file.MimeType, "image/") {
+			// Derived images are kept as long as their source object
+			derived := deletion(file.S3Bucket, images.DerivedPrefix(key), true)
+			derived.FileKey = key
+			deletions = append(deletions, derived)
+		}
+	}
+	return deletions, nil
+}
@@ -0,0 +1,166 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// =============================================================================
+// File Deletions Tests
+// =============================================================================
+
+var testDeletionRetry = DeletionRetry{
+	Backoff:    time.Minute,
+	MaxBackoff: 10 * time.Minute,
+	StuckAfter: time.Hour,
+}
+
+func newTestFileDeletions(repo *mockRepository, store *mockStorage) *FileDeletions {
+	return NewFileDeletions(repo, store, testDeletionRetry, prometheus.NewRegistry(), testLogger())
+}
+
+func TestFileDeletions_DeletesDueObjects(t *testing.T) {
+	var deletedKeys, deletedPrefixes []string
+	var completed []int64
+	repo := &mockRepository{
+		listDueDeletionsFunc: func(_ context.Context, now time.Time, limit int) ([]repository.PendingDeletion, error) {
+			if time.Since(now) > time.Minute || limit != fileDeletionsBatchSize {
+				t.Errorf("unexpected query now=%v limit=%d", now, limit)
+			}
+			return []repository.PendingDeletion{
+				{ID: 1, S3Bucket: "images", S3Key: "a.png"},
+				{ID: 2, S3Bucket: "images", S3Key: "_derived/a.png/", IsPrefix: true},
+			}, nil
+		},
+		completeDeletionFunc: func(_ context.Context, id int64) error {
+			completed = append(completed, id)
+			return nil
+		},
+		retryDeletionFunc: func(_ context.Context, _ *repository.PendingDeletion) error {
+			t.Error("expected no retries")
+			return nil
+		},
+		getDeletionStatsFunc: func(_ context.Context, stuckBefore time.Time) (repository.DeletionStats, error) {
+			if cutoff := time.Now().Add(-time.Hour); stuckBefore.Sub(cutoff).Abs() > time.Minute {
+				t.Errorf("expected stuck cutoff one hour ago, got %v", stuckBefore)
+			}
+			return repository.DeletionStats{Pending: 5, Stuck: 2}, nil
+		},
+	}
+	store := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, bucket, key string) error {
+			deletedKeys = append(deletedKeys, bucket+"/"+key)
+			return nil
+		},
+		deletePrefixFunc: func(_ context.Context, bucket, prefix string) error {
+			deletedPrefixes = append(deletedPrefixes, bucket+"/"+prefix)
+			return nil
+		},
+	}
+
+	job := newTestFileDeletions(repo, store)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(deletedKeys) != 1 || deletedKeys[0] != "images/a.png" {
+		t.Errorf("expected object deleted, got %v", deletedKeys)
+	}
+	if len(deletedPrefixes) != 1 || deletedPrefixes[0] != "images/_derived/a.png/" {
+		t.Errorf("expected prefix deleted, got %v", deletedPrefixes)
+	}
+	if len(completed) != 2 {
+		t.Errorf("expected both deletions completed, got %v", completed)
+	}
+	if pending := testutil.ToFloat64(job.pending); pending != 5 {
+		t.Errorf("expected pending gauge 5, got %v", pending)
+	}
+	if stuck := testutil.ToFloat64(job.stuck); stuck != 2 {
+		t.Errorf("expected stuck gauge 2, got %v", stuck)
+	}
+}
+
+func TestFileDeletions_StorageError_SchedulesRetry(t *testing.T) {
+	var retried *repository.PendingDeletion
+	repo := &mockRepository{
+		listDueDeletionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.PendingDeletion, error) {
+			return []repository.PendingDeletion{{ID: 1, S3Bucket: "images", S3Key: "a.png", Attempts: 2}}, nil
+		},
+		completeDeletionFunc: func(_ context.Context, _ int64) error {
+			t.Error("expected deletion to stay pending")
+			return nil
+		},
+		retryDeletionFunc: func(_ context.Context, deletion *repository.PendingDeletion) error {
+			retried = deletion
+			return nil
+		},
+	}
+	store := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, _, _ string) error {
+			return errors.New("s3 unavailable")
+		},
+	}
+
+	job := newTestFileDeletions(repo, store)
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("expected storage failure to be retried, got error %v", err)
+	}
+
+	if retried == nil {
+		t.Fatal("expected deletion to be rescheduled")
+	}
+	if retried.Attempts != 3 || retried.LastError == nil || *retried.LastError != "s3 unavailable" {
+		t.Errorf("unexpected retry %+v", retried)
+	}
+	// Third attempt failed: 1m doubled twice
+	if wait := time.Until(retried.NextAttemptAt); wait < 3*time.Minute || wait > 4*time.Minute {
+		t.Errorf("expected next attempt in 4 minutes, got %v", wait)
+	}
+	if failures := testutil.ToFloat64(job.failures); failures != 1 {
+		t.Errorf("expected 1 failure counted, got %v", failures)
+	}
+}
+
+func TestFileDeletions_Backoff(t *testing.T) {
+	job := newTestFileDeletions(&mockRepository{}, &mockStorage{})
+
+	expected := map[int]time.Duration{
+		1:  time.Minute,
+		2:  2 * time.Minute,
+		4:  8 * time.Minute,
+		5:  10 * time.Minute,
+		60: 10 * time.Minute,
+	}
+	for attempts, want := range expected {
+		if got := job.backoff(attempts); got != want {
+			t.Errorf("attempt %d: expected backoff %v, got %v", attempts, want, got)
+		}
+	}
+}
+
+func TestFileDeletions_RepositoryErrors(t *testing.T) {
+	statsUpdated := false
+	repo := &mockRepository{
+		listDueDeletionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.PendingDeletion, error) {
+			return nil, errors.New("database error")
+		},
+		getDeletionStatsFunc: func(_ context.Context, _ time.Time) (repository.DeletionStats, error) {
+			statsUpdated = true
+			return repository.DeletionStats{Pending: 1}, nil
+		},
+	}
+
+	job := newTestFileDeletions(repo, &mockStorage{})
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("expected error when deletions cannot be listed")
+	}
+	if !statsUpdated {
+		t.Error("expected metrics to be updated even when listing fails")
+	}
+}
@@ -0,0 +1,38 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// IdempotencyKeyCleanup removes idempotency keys whose replay window has passed.
+type IdempotencyKeyCleanup struct {
+	repo repository.Repository
+	log  *slog.Logger
+}
+
+func NewIdempotencyKeyCleanup(repo repository.Repository, log *slog.Logger) *IdempotencyKeyCleanup {
+	return &IdempotencyKeyCleanup{
+		repo: repo,
+		log:  log,
+	}
+}
+
+func (j *IdempotencyKeyCleanup) Name() string {
+	return "idempotency-key-cleanup"
+}
+
+func (j *IdempotencyKeyCleanup) Run(ctx context.Context) error {
+	deleted, err := j.repo.DeleteExpiredIdempotencyKeys(ctx, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		j.log.Info("Expired idempotency keys cleaned up", "count", deleted)
+	}
+	return nil
+}
@@ -0,0 +1,42 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Idempotency Key Cleanup Tests
+// =============================================================================
+
+func TestIdempotencyKeyCleanup_DeletesExpiredKeys(t *testing.T) {
+	var cutoff time.Time
+	repo := &mockRepository{
+		deleteExpiredIdempotencyKeysFunc: func(_ context.Context, now time.Time) (int64, error) {
+			cutoff = now
+			return 3, nil
+		},
+	}
+
+	job := NewIdempotencyKeyCleanup(repo, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if cutoff.IsZero() || time.Since(cutoff) > time.Minute {
+		t.Errorf("expected current time cutoff, got %v", cutoff)
+	}
+}
+
+func TestIdempotencyKeyCleanup_ReturnsRepositoryError(t *testing.T) {
+	repo := &mockRepository{
+		deleteExpiredIdempotencyKeysFunc: func(_ context.Context, _ time.Time) (int64, error) {
+			return 0, errors.New("connection refused")
+		},
+	}
+
+	if err := NewIdempotencyKeyCleanup(repo, testLogger()).Run(context.Background()); err == nil {
+		t.Error("expected repository error to be returned")
+	}
+}
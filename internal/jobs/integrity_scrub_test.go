@@ -0,0 +1,153 @@
+package jobs
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// =============================================================================
+// Integrity Scrub Tests
+// =============================================================================
+
+const testReverifyAfter = 7 * 24 * time.Hour
+
+type scrubResult struct {
+	hash      string
+	corrupted bool
+}
+
+func sha256Hex(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+func TestIntegrityScrub_VerifiesObjects(t *testing.T) {
+	intact, tampered := sha256Hex("intact"), sha256Hex("original")
+	files := []repository.StorageFile{
+		{ID: 1, S3Bucket: "images", S3Key: "intact.png", ContentHash: &intact},
+		{ID: 2, S3Bucket: "images", S3Key: "tampered.png", ContentHash: &tampered},
+		{ID: 3, S3Bucket: "documents", S3Key: "legacy.pdf"},
+	}
+	objects := map[string]string{
+		"images/intact.png":    "intact",
+		"images/tampered.png":  "modified",
+		"documents/legacy.pdf": "legacy",
+	}
+	results := make(map[int64]scrubResult)
+
+	repo := &mockRepository{
+		listFilesToScrubFunc: func(_ context.Context, verifiedBefore time.Time, limit int) ([]repository.StorageFile, error) {
+			if cutoff := time.Now().Add(-testReverifyAfter); verifiedBefore.Sub(cutoff).Abs() > time.Minute {
+				t.Errorf("expected cutoff one reverify period ago, got %v", verifiedBefore)
+			}
+			if limit != 50 {
+				t.Errorf("expected batch size 50, got %d", limit)
+			}
+			return files, nil
+		},
+		recordScrubResultFunc: func(_ context.Context, id int64, hash string, corrupted bool, _ time.Time) error {
+			results[id] = scrubResult{hash, corrupted}
+			return nil
+		},
+		getIntegrityStatsFunc: func(_ context.Context) (repository.IntegrityStats, error) {
+			return repository.IntegrityStats{Files: 10, Verified: 7, Corrupted: 1}, nil
+		},
+	}
+	storage := &mockStorage{
+		getObjectFunc: func(_ context.Context, bucket, key string) (io.ReadCloser, error) {
+			return io.NopCloser(strings.NewReader(objects[bucket+"/"+key])), nil
+		},
+	}
+
+	job := NewIntegrityScrub(repo, storage, 50, testReverifyAfter, prometheus.NewRegistry(), testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[int64]scrubResult{
+		1: {intact, false},
+		2: {sha256Hex("modified"), true},
+		// Files stored without a hash take the computed one as their baseline
+		3: {sha256Hex("legacy"), false},
+	}
+	for id, want := range expected {
+		if results[id] != want {
+			t.Errorf("file %d: expected %+v, got %+v", id, want, results[id])
+		}
+	}
+
+	if got := testutil.ToFloat64(job.scrubbed); got != 3 {
+		t.Errorf("expected 3 objects scrubbed, got %v", got)
+	}
+	if got := testutil.ToFloat64(job.mismatches); got != 1 {
+		t.Errorf("expected 1 mismatch, got %v", got)
+	}
+	if got := testutil.ToFloat64(job.corrupted); got != 1 {
+		t.Errorf("expected corrupted gauge 1, got %v", got)
+	}
+	if got := testutil.ToFloat64(job.unverified); got != 3 {
+		t.Errorf("expected unverified gauge 3, got %v", got)
+	}
+}
+
+func TestIntegrityScrub_ReadErrors(t *testing.T) {
+	var recorded []int64
+	repo := &mockRepository{
+		listFilesToScrubFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return []repository.StorageFile{{ID: 1, S3Key: "gone.png"}, {ID: 2, S3Key: "ok.png"}}, nil
+		},
+		recordScrubResultFunc: func(_ context.Context, id int64, _ string, _ bool, _ time.Time) error {
+			recorded = append(recorded, id)
+			return nil
+		},
+	}
+	storage := &mockStorage{
+		getObjectFunc: func(_ context.Context, _, key string) (io.ReadCloser, error) {
+			if key == "gone.png" {
+				return nil, errors.New("object not found")
+			}
+			return io.NopCloser(strings.NewReader("content")), nil
+		},
+	}
+
+	job := NewIntegrityScrub(repo, storage, 50, testReverifyAfter, prometheus.NewRegistry(), testLogger())
+	// Unreadable objects are retried next run rather than failing it
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(recorded) != 1 || recorded[0] != 2 {
+		t.Errorf("expected only the readable file to be recorded, got %v", recorded)
+	}
+	if got := testutil.ToFloat64(job.readErrors); got != 1 {
+		t.Errorf("expected 1 read error, got %v", got)
+	}
+}
+
+func TestIntegrityScrub_RepositoryErrors(t *testing.T) {
+	repo := &mockRepository{
+		listFilesToScrubFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return nil, errors.New("database error")
+		},
+		getIntegrityStatsFunc: func(_ context.Context) (repository.IntegrityStats, error) {
+			return repository.IntegrityStats{Corrupted: 2}, nil
+		},
+	}
+
+	job := NewIntegrityScrub(repo, &mockStorage{}, 50, testReverifyAfter, prometheus.NewRegistry(), testLogger())
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("expected error when files cannot be listed")
+	}
+	if got := testutil.ToFloat64(job.corrupted); got != 2 {
+		t.Errorf("expected gauges updated despite the error, got %v", got)
+	}
+}
@@ -0,0 +1,205 @@
+package jobs
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+)
+
+// =============================================================================
+// Mock Repository
+// =============================================================================
+
+// mockRepository embeds the interface so only methods used by jobs need
+// implementations; calling any other method panics, flagging unexpected access.
+type mockRepository struct {
+	repository.Repository
+
+	listExpiredUploadSessionsFunc func(ctx context.Context, now time.Time, limit int) ([]repository.UploadSession, error)
+	deleteUploadSessionFunc       func(ctx context.Context, id string) error
+
+	listTrashedFilesFunc func(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error)
+	listFileVariantsFunc func(ctx context.Context, parentID int64) ([]repository.FileVariant, error)
+	listFileVersionsFunc func(ctx context.Context, fileID int64) ([]repository.FileVersion, error)
+	purgeFileFunc        func(ctx context.Context, id int64, deletions []repository.PendingDeletion) error
+
+	listStalePendingFilesFunc func(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error)
+	discardPendingFileFunc    func(ctx context.Context, id int64, deletions []repository.PendingDeletion) error
+
+	listDueDeletionsFunc func(ctx context.Context, now time.Time, limit int) ([]repository.PendingDeletion, error)
+	completeDeletionFunc func(ctx context.Context, id int64) error
+	retryDeletionFunc    func(ctx context.Context, deletion *repository.PendingDeletion) error
+	getDeletionStatsFunc func(ctx context.Context, stuckBefore time.Time) (repository.DeletionStats, error)
+
+	listFilesToScrubFunc  func(ctx context.Context, verifiedBefore time.Time, limit int) ([]repository.StorageFile, error)
+	recordScrubResultFunc func(ctx context.Context, id int64, hash string, corrupted bool, at time.Time) error
+	getIntegrityStatsFunc func(ctx context.Context) (repository.IntegrityStats, error)
+
+	deleteExpiredIdempotencyKeysFunc func(ctx context.Context, now time.Time) (int64, error)
+}
+
+func (m *mockRepository) ListExpiredUploadSessions(ctx context.Context, now time.Time, limit int) ([]repository.UploadSession, error) {
+	if m.listExpiredUploadSessionsFunc != nil {
+		return m.listExpiredUploadSessionsFunc(ctx, now, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) DeleteUploadSession(ctx context.Context, id string) error {
+	if m.deleteUploadSessionFunc != nil {
+		return m.deleteUploadSessionFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListTrashedFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	if m.listTrashedFilesFunc != nil {
+		return m.listTrashedFilesFunc(ctx, before, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListFileVariants(ctx context.Context, parentID int64) ([]repository.FileVariant, error) {
+	if m.listFileVariantsFunc != nil {
+		return m.listFileVariantsFunc(ctx, parentID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) ListFileVersions(ctx context.Context, fileID int64) ([]repository.FileVersion, error) {
+	if m.listFileVersionsFunc != nil {
+		return m.listFileVersionsFunc(ctx, fileID)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) PurgeFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	if m.purgeFileFunc != nil {
+		return m.purgeFileFunc(ctx, id, deletions)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListStalePendingFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	if m.listStalePendingFilesFunc != nil {
+		return m.listStalePendingFilesFunc(ctx, before, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) DiscardPendingFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	if m.discardPendingFileFunc != nil {
+		return m.discardPendingFileFunc(ctx, id, deletions)
+	}
+	return nil
+}
+
+func (m *mockRepository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]repository.PendingDeletion, error) {
+	if m.listDueDeletionsFunc != nil {
+		return m.listDueDeletionsFunc(ctx, now, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) CompleteDeletion(ctx context.Context, id int64) error {
+	if m.completeDeletionFunc != nil {
+		return m.completeDeletionFunc(ctx, id)
+	}
+	return nil
+}
+
+func (m *mockRepository) RetryDeletion(ctx context.Context, deletion *repository.PendingDeletion) error {
+	if m.retryDeletionFunc != nil {
+		return m.retryDeletionFunc(ctx, deletion)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetDeletionStats(ctx context.Context, stuckBefore time.Time) (repository.DeletionStats, error) {
+	if m.getDeletionStatsFunc != nil {
+		return m.getDeletionStatsFunc(ctx, stuckBefore)
+	}
+	return repository.DeletionStats{}, nil
+}
+
+func (m *mockRepository) ListFilesToScrub(ctx context.Context, verifiedBefore time.Time, limit int) ([]repository.StorageFile, error) {
+	if m.listFilesToScrubFunc != nil {
+		return m.listFilesToScrubFunc(ctx, verifiedBefore, limit)
+	}
+	return nil, nil
+}
+
+func (m *mockRepository) RecordScrubResult(ctx context.Context, id int64, hash string, corrupted bool, at time.Time) error {
+	if m.recordScrubResultFunc != nil {
+		return m.recordScrubResultFunc(ctx, id, hash, corrupted, at)
+	}
+	return nil
+}
+
+func (m *mockRepository) GetIntegrityStats(ctx context.Context) (repository.IntegrityStats, error) {
+	if m.getIntegrityStatsFunc != nil {
+		return m.getIntegrityStatsFunc(ctx)
+	}
+	return repository.IntegrityStats{}, nil
+}
+
+func (m *mockRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	if m.deleteExpiredIdempotencyKeysFunc != nil {
+		return m.deleteExpiredIdempotencyKeysFunc(ctx, now)
+	}
+	return 0, nil
+}
+
+// =============================================================================
+// Mock Storage
+// =============================================================================
+
+type mockStorage struct {
+	storage.ObjectStore
+
+	abortMultipartUploadFunc func(ctx context.Context, bucket, key, uploadID string) error
+	getObjectFunc            func(ctx context.Context, bucket, key string) (io.ReadCloser, error)
+	deleteObjectFunc         func(ctx context.Context, bucket, key string) error
+	deletePrefixFunc         func(ctx context.Context, bucket, prefix string) error
+}
+
+func (m *mockStorage) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	if m.abortMultipartUploadFunc != nil {
+		return m.abortMultipartUploadFunc(ctx, bucket, key, uploadID)
+	}
+	return nil
+}
+
+func (m *mockStorage) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
+	if m.getObjectFunc != nil {
+		return m.getObjectFunc(ctx, bucket, key)
+	}
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (m *mockStorage) DeleteObject(ctx context.Context, bucket, key string) error {
+	if m.deleteObjectFunc != nil {
+		return m.deleteObjectFunc(ctx, bucket, key)
+	}
+	return nil
+}
+
+func (m *mockStorage) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	if m.deletePrefixFunc != nil {
+		return m.deletePrefixFunc(ctx, bucket, prefix)
+	}
+	return nil
+}
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// pendingUploadSweepBatchSize limits how many stale pending uploads are discarded per run
+const pendingUploadSweepBatchSize = 100
+
+// PendingUploadSweep discards uploads left pending longer than the timeout, typically
+// because the process stopped between recording the upload and storing its objects.
+// Whatever objects were stored are handed to FileDeletions.
+type PendingUploadSweep struct {
+	repo    repository.Repository
+	timeout time.Duration
+	log     *slog.Logger
+}
+
+func NewPendingUploadSweep(repo repository.Repository, timeout time.Duration, log *slog.Logger) *PendingUploadSweep {
+	return &PendingUploadSweep{
+		repo:    repo,
+		timeout: timeout,
+		log:     log,
+	}
+}
+
+func (j *PendingUploadSweep) Name() string {
+	return "pending-upload-sweep"
+}
+
+func (j *PendingUploadSweep) Run(ctx context.Context) error {
+	files, err := j.repo.ListStalePendingFiles(ctx, time.Now().Add(-j.timeout), pendingUploadSweepBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	discarded := 0
+	for i := range files {
+		err := j.discard(ctx, &files[i])
+		switch {
+		case errors.Is(err, repository.ErrFileNotPending):
+			// Activated since it was listed
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("file %d: %w", files[i].ID, err))
+		default:
+			discarded++
+		}
+	}
+
+	if discarded > 0 {
+		j.log.Warn("Stale pending uploads discarded", "count", discarded)
+	}
+
+	return errors.Join(errs...)
+}
+
+func (j *PendingUploadSweep) discard(ctx context.Context, file *repository.StorageFile) error {
+	deletions, err := fileObjectDeletions(ctx, j.repo, file)
+	if err != nil {
+		return err
+	}
+	return j.repo.DiscardPendingFile(ctx, file.ID, deletions)
+}
@@ -0,0 +1,103 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Pending Upload Sweep Tests
+// =============================================================================
+
+const testPendingTimeout = time.Hour
+
+func TestPendingUploadSweep_DiscardsStaleUploads(t *testing.T) {
+	discarded := make(map[int64][]string)
+	repo := &mockRepository{
+		listStalePendingFilesFunc: func(_ context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+			if cutoff := time.Now().Add(-testPendingTimeout); before.Sub(cutoff).Abs() > time.Minute {
+				t.Errorf("expected cutoff one timeout ago, got %v", before)
+			}
+			if limit != pendingUploadSweepBatchSize {
+				t.Errorf("expected limit %d, got %d", pendingUploadSweepBatchSize, limit)
+			}
+			return []repository.StorageFile{
+				{ID: 1, S3Bucket: "images", S3Key: "a.png", MimeType: "image/png"},
+				{ID: 2, S3Bucket: "documents", S3Key: "b.pdf", MimeType: "application/pdf"},
+			}, nil
+		},
+		listFileVariantsFunc: func(_ context.Context, parentID int64) ([]repository.FileVariant, error) {
+			if parentID != 1 {
+				return nil, nil
+			}
+			return []repository.FileVariant{
+				{Name: "thumbnail", File: repository.StorageFile{S3Bucket: "images", S3Key: "a_thumbnail.png"}},
+			}, nil
+		},
+		discardPendingFileFunc: func(_ context.Context, id int64, deletions []repository.PendingDeletion) error {
+			for _, d := range deletions {
+				discarded[id] = append(discarded[id], d.S3Bucket+"/"+d.S3Key)
+			}
+			return nil
+		},
+	}
+
+	job := NewPendingUploadSweep(repo, testPendingTimeout, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[int64][]string{
+		1: {"images/a_thumbnail.png", "images/a.png", "images/_derived/a.png/"},
+		2: {"documents/b.pdf"},
+	}
+	if fmt.Sprint(discarded) != fmt.Sprint(expected) {
+		t.Errorf("expected discarded objects %v, got %v", expected, discarded)
+	}
+}
+
+func TestPendingUploadSweep_DiscardErrors(t *testing.T) {
+	var discardedIDs []int64
+	repo := &mockRepository{
+		listStalePendingFilesFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return []repository.StorageFile{{ID: 1}, {ID: 2}, {ID: 3}}, nil
+		},
+		discardPendingFileFunc: func(_ context.Context, id int64, _ []repository.PendingDeletion) error {
+			switch id {
+			case 1:
+				return fmt.Errorf("failed to discard pending file id 1: %w", repository.ErrFileNotPending)
+			case 2:
+				return errors.New("database error")
+			}
+			discardedIDs = append(discardedIDs, id)
+			return nil
+		},
+	}
+
+	err := NewPendingUploadSweep(repo, testPendingTimeout, testLogger()).Run(context.Background())
+	// An upload activated since it was listed is skipped; other failures are retried on the next run
+	if err == nil || errors.Is(err, repository.ErrFileNotPending) {
+		t.Errorf("expected only the database error, got %v", err)
+	}
+	if len(discardedIDs) != 1 || discardedIDs[0] != 3 {
+		t.Errorf("expected remaining uploads discarded, got %v", discardedIDs)
+	}
+}
+
+func TestPendingUploadSweep_ListError(t *testing.T) {
+	repo := &mockRepository{
+		listStalePendingFilesFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	job := NewPendingUploadSweep(repo, testPendingTimeout, testLogger())
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("expected error when pending uploads cannot be listed")
+	}
+}
@@ -0,0 +1,63 @@
+package jobs
+
+import (
+	"context"
+	"log/slog"
+	"time"
+)
+
+// Job is a unit of background work executed periodically.
+type Job interface {
+	Name() string
+	Run(ctx context.Context) error
+}
+
+// Locker keeps a job from running on more than one replica at a time.
+type Locker interface {
+	// TryLock takes the lock of the named job without waiting, reporting false when
+	// another replica holds it. unlock releases a lock that was taken.
+	TryLock(ctx context.Context, name string) (unlock func(), ok bool, err error)
+}
+
+// Start runs the job every interval in a background goroutine until ctx is cancelled.
+// With a locker, a run is skipped while another replica runs the job; without one the
+// job runs on every replica. Errors are logged and do not stop subsequent runs.
+func Start(ctx context.Context, job Job, interval time.Duration, locker Locker, log *slog.Logger) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		log.Info("Background job started", "job", job.Name(), "interval", interval.String())
+		for {
+			select {
+			case <-ctx.Done():
+				log.Info("Background job stopped", "job", job.Name())
+				return
+			case <-ticker.C:
+				runOnce(ctx, job, locker, log)
+			}
+		}
+	}()
+}
+
+func runOnce(ctx context.Context, job Job, locker Locker, log *slog.Logger) {
+	if locker != nil {
+		unlock, ok, err := locker.TryLock(ctx, job.Name())
+		if err != nil {
+			log.Error("Failed to lock background job", "job", job.Name(), "error", err)
+			return
+		}
+		if !ok {
+			log.Debug("Background job running on another replica, skipped", "job", job.Name())
+			return
+		}
+		defer unlock()
+	}
+
+	start := time.Now()
+	if err := job.Run(ctx); err != nil {
+		log.Error("Background job failed", "job", job.Name(), "error", err, "duration", time.Since(start).String())
+		return
+	}
+	log.Debug("Background job completed", "job", job.Name(), "duration", time.Since(start).String())
+}
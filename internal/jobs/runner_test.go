@@ -0,0 +1,82 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+// =============================================================================
+// Runner Tests
+// =============================================================================
+
+type stubJob struct{ runs int }
+
+func (j *stubJob) Name() string { return "stub" }
+
+func (j *stubJob) Run(context.Context) error {
+	j.runs++
+	return nil
+}
+
+type stubLocker struct {
+	ok       bool
+	err      error
+	name     string
+	unlocked bool
+}
+
+func (l *stubLocker) TryLock(_ context.Context, name string) (func(), bool, error) {
+	l.name = name
+	if l.err != nil || !l.ok {
+		return nil, false, l.err
+	}
+	return func() { l.unlocked = true }, true, nil
+}
+
+func TestRunOnce_RunsJobUnderItsLock(t *testing.T) {
+	job := &stubJob{}
+	locker := &stubLocker{ok: true}
+
+	runOnce(t.Context(), job, locker, testLogger())
+
+	if job.runs != 1 {
+		t.Errorf("expected the job to run once, ran %d times", job.runs)
+	}
+	if locker.name != "stub" {
+		t.Errorf("expected the job's own lock, got %q", locker.name)
+	}
+	if !locker.unlocked {
+		t.Error("expected the lock to be released after the run")
+	}
+}
+
+func TestRunOnce_SkipsJobLockedElsewhere(t *testing.T) {
+	job := &stubJob{}
+
+	runOnce(t.Context(), job, &stubLocker{ok: false}, testLogger())
+
+	if job.runs != 0 {
+		t.Errorf("expected the job to be skipped, ran %d times", job.runs)
+	}
+}
+
+func TestRunOnce_SkipsJobWhenLockFails(t *testing.T) {
+	job := &stubJob{}
+
+	runOnce(t.Context(), job, &stubLocker{err: errors.New("connection refused")}, testLogger())
+
+	if job.runs != 0 {
+		t.Errorf("expected the job to be skipped, ran %d times", job.runs)
+	}
+}
+
+func TestRunOnce_WithoutLocker(t *testing.T) {
+	job := &stubJob{}
+
+	runOnce(t.Context(), job, nil, testLogger())
+
+	if job.runs != 1 {
+		t.Errorf("expected the job to run once, ran %d times", job.runs)
+	}
+}
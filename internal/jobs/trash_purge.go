@@ -0,0 +1,71 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// trashPurgeBatchSize limits how many trashed files are purged per run
+const trashPurgeBatchSize = 100
+
+// TrashPurge permanently deletes files that have been in the trash longer than the retention period.
+// Records are deleted right away; their objects are handed to FileDeletions in the same transaction.
+type TrashPurge struct {
+	repo      repository.Repository
+	retention time.Duration
+	log       *slog.Logger
+}
+
+func NewTrashPurge(repo repository.Repository, retention time.Duration, log *slog.Logger) *TrashPurge {
+	return &TrashPurge{
+		repo:      repo,
+		retention: retention,
+		log:       log,
+	}
+}
+
+func (j *TrashPurge) Name() string {
+	return "trash-purge"
+}
+
+func (j *TrashPurge) Run(ctx context.Context) error {
+	files, err := j.repo.ListTrashedFiles(ctx, time.Now().Add(-j.retention), trashPurgeBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	purged := 0
+	for i := range files {
+		err := j.purge(ctx, &files[i])
+		switch {
+		case errors.Is(err, repository.ErrFileNotTrashed):
+			// Restored since it was listed
+			continue
+		case err != nil:
+			errs = append(errs, fmt.Errorf("file %d: %w", files[i].ID, err))
+		default:
+			purged++
+		}
+	}
+
+	if purged > 0 {
+		j.log.Info("Trashed files purged", "count", purged)
+	}
+
+	return errors.Join(errs...)
+}
+
+// purge deletes the file's records and schedules the deletion of its objects
+func (j *TrashPurge) purge(ctx context.Context, file *repository.StorageFile) error {
+	deletions, err := fileObjectDeletions(ctx, j.repo, file)
+	if err != nil {
+		return err
+	}
+	return j.repo.PurgeFile(ctx, file.ID, deletions)
+}
@@ -0,0 +1,123 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Trash Purge Tests
+// =============================================================================
+
+const testRetention = 30 * 24 * time.Hour
+
+func TestTrashPurge_SchedulesObjectDeletions(t *testing.T) {
+	restored := "b2.pdf"
+	files := []repository.StorageFile{
+		{ID: 1, S3Bucket: "images", S3Key: "a.png", MimeType: "image/png"},
+		{ID: 2, S3Bucket: "documents", S3Key: "b.pdf", ContentKey: &restored, MimeType: "application/pdf"},
+	}
+	scheduled := make(map[int64][]string)
+
+	repo := &mockRepository{
+		listTrashedFilesFunc: func(_ context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+			if cutoff := time.Now().Add(-testRetention); before.Sub(cutoff).Abs() > time.Minute {
+				t.Errorf("expected cutoff one retention period ago, got %v", before)
+			}
+			if limit != trashPurgeBatchSize {
+				t.Errorf("expected limit %d, got %d", trashPurgeBatchSize, limit)
+			}
+			return files, nil
+		},
+		listFileVariantsFunc: func(_ context.Context, parentID int64) ([]repository.FileVariant, error) {
+			if parentID != 1 {
+				return nil, nil
+			}
+			return []repository.FileVariant{
+				{Name: "thumbnail", File: repository.StorageFile{S3Bucket: "images", S3Key: "a_thumbnail.png"}},
+			}, nil
+		},
+		listFileVersionsFunc: func(_ context.Context, fileID int64) ([]repository.FileVersion, error) {
+			if fileID != 2 {
+				return nil, nil
+			}
+			// Version 2 was restored, so the current content shares its object
+			return []repository.FileVersion{
+				{Version: 3, S3Bucket: "documents", ContentKey: "b3.pdf"},
+				{Version: 2, S3Bucket: "documents", ContentKey: "b2.pdf"},
+				{Version: 1, S3Bucket: "documents", ContentKey: "b.pdf"},
+			}, nil
+		},
+		purgeFileFunc: func(_ context.Context, id int64, deletions []repository.PendingDeletion) error {
+			for _, d := range deletions {
+				if d.FileID != id || time.Since(d.NextAttemptAt) > time.Minute {
+					t.Errorf("expected deletion of file %d due now, got %+v", id, d)
+				}
+				if d.IsPrefix && d.FileKey != "a.png" {
+					t.Errorf("expected derived images to follow their source object, got %+v", d)
+				}
+				scheduled[id] = append(scheduled[id], fmt.Sprintf("%s/%s prefix=%t", d.S3Bucket, d.S3Key, d.IsPrefix))
+			}
+			return nil
+		},
+	}
+
+	job := NewTrashPurge(repo, testRetention, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := map[int64][]string{
+		1: {"images/a_thumbnail.png prefix=false", "images/a.png prefix=false", "images/_derived/a.png/ prefix=true"},
+		2: {"documents/b2.pdf prefix=false", "documents/b3.pdf prefix=false", "documents/b.pdf prefix=false"},
+	}
+	if fmt.Sprint(scheduled) != fmt.Sprint(expected) {
+		t.Errorf("expected deletions %v, got %v", expected, scheduled)
+	}
+}
+
+func TestTrashPurge_PurgeErrors(t *testing.T) {
+	var purgedIDs []int64
+	repo := &mockRepository{
+		listTrashedFilesFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return []repository.StorageFile{{ID: 1}, {ID: 2}, {ID: 3}}, nil
+		},
+		purgeFileFunc: func(_ context.Context, id int64, _ []repository.PendingDeletion) error {
+			switch id {
+			case 1:
+				return fmt.Errorf("failed to purge file id 1: %w", repository.ErrFileNotTrashed)
+			case 2:
+				return errors.New("database error")
+			}
+			purgedIDs = append(purgedIDs, id)
+			return nil
+		},
+	}
+
+	err := NewTrashPurge(repo, testRetention, testLogger()).Run(context.Background())
+	// A file restored since it was listed is skipped; other failures are retried on the next run
+	if err == nil || errors.Is(err, repository.ErrFileNotTrashed) {
+		t.Errorf("expected only the database error, got %v", err)
+	}
+	if len(purgedIDs) != 1 || purgedIDs[0] != 3 {
+		t.Errorf("expected remaining files purged, got %v", purgedIDs)
+	}
+}
+
+func TestTrashPurge_ListError(t *testing.T) {
+	repo := &mockRepository{
+		listTrashedFilesFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.StorageFile, error) {
+			return nil, errors.New("database error")
+		},
+	}
+
+	job := NewTrashPurge(repo, testRetention, testLogger())
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("expected error when trashed files cannot be listed")
+	}
+}
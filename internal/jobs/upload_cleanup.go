@@ -0,0 +1,87 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+)
+
+// uploadCleanupBatchSize limits how many expired sessions are processed per run
+const uploadCleanupBatchSize = 100
+
+// UploadCleanup discards expired tus and presigned uploads and removes their sessions.
+type UploadCleanup struct {
+	repo    repository.Repository
+	storage storage.ObjectStore
+	log     *slog.Logger
+}
+
+func NewUploadCleanup(repo repository.Repository, storage storage.ObjectStore, log *slog.Logger) *UploadCleanup {
+	return &UploadCleanup{
+		repo:    repo,
+		storage: storage,
+		log:     log,
+	}
+}
+
+func (j *UploadCleanup) Name() string {
+	return "upload-cleanup"
+}
+
+func (j *UploadCleanup) Run(ctx context.Context) error {
+	sessions, err := j.repo.ListExpiredUploadSessions(ctx, time.Now(), uploadCleanupBatchSize)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	discarded := 0
+	for _, session := range sessions {
+		// The session is removed first, so an upload completed meanwhile keeps its object
+		err := j.repo.DeleteUploadSession(ctx, session.ID)
+		if errors.Is(err, repository.ErrUploadSessionCompleted) {
+			continue
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("upload %s: %w", session.ID, err))
+			continue
+		}
+		j.discardObject(ctx, session)
+		discarded++
+	}
+
+	if discarded > 0 {
+		j.log.Info("Expired uploads cleaned up", "count", discarded)
+	}
+
+	return errors.Join(errs...)
+}
+
+// discardObject removes whatever the client uploaded before the session expired, once
+// its session is deleted.
+// Failures are not fatal: the object may already be gone and bucket lifecycle
+// rules remove stale multipart uploads eventually.
+func (j *UploadCleanup) discardObject(ctx context.Context, session repository.UploadSession) {
+	var err error
+	switch session.Protocol {
+	case repository.UploadProtocolPresigned:
+		// The client may have uploaded the object without completing the upload
+		err = j.storage.DeleteObject(ctx, session.S3Bucket, session.S3Key)
+	default:
+		err = j.storage.AbortMultipartUpload(ctx, session.S3Bucket, session.S3Key, session.S3UploadID)
+	}
+	if err != nil {
+		j.log.Warn("Failed to discard expired upload",
+			"error", err,
+			"protocol", session.Protocol,
+			"bucket", session.S3Bucket,
+			"key", session.S3Key,
+			"upload_id", session.ID,
+		)
+	}
+}
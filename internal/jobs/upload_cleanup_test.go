@@ -0,0 +1,176 @@
+package jobs
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+)
+
+// =============================================================================
+// Upload Cleanup Tests
+// =============================================================================
+
+func TestUploadCleanup_AbortsAndDeletesExpiredSessions(t *testing.T) {
+	sessions := []repository.UploadSession{
+		{ID: "a", Protocol: repository.UploadProtocolTus, S3Bucket: "images", S3Key: "a.png", S3UploadID: "upload-a"},
+		{ID: "b", Protocol: repository.UploadProtocolTus, S3Bucket: "documents", S3Key: "b.pdf", S3UploadID: "upload-b"},
+	}
+	var aborted, deleted []string
+
+	repo := &mockRepository{
+		listExpiredUploadSessionsFunc: func(_ context.Context, now time.Time, limit int) ([]repository.UploadSession, error) {
+			if time.Since(now) > time.Minute {
+				t.Errorf("expected current time cutoff, got %v", now)
+			}
+			if limit != uploadCleanupBatchSize {
+				t.Errorf("expected limit %d, got %d", uploadCleanupBatchSize, limit)
+			}
+			return sessions, nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, id string) error {
+			deleted = append(deleted, id)
+			return nil
+		},
+	}
+	store := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, uploadID string) error {
+			aborted = append(aborted, uploadID)
+			return nil
+		},
+	}
+
+	job := NewUploadCleanup(repo, store, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(aborted) != 2 || aborted[0] != "upload-a" || aborted[1] != "upload-b" {
+		t.Errorf("expected both multipart uploads aborted, got %v", aborted)
+	}
+	if len(deleted) != 2 {
+		t.Errorf("expected both sessions deleted, got %v", deleted)
+	}
+}
+
+func TestUploadCleanup_DeletesUnfinalizedPresignedObjects(t *testing.T) {
+	var deletedKey string
+	var aborted bool
+
+	repo := &mockRepository{
+		listExpiredUploadSessionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.UploadSession, error) {
+			return []repository.UploadSession{
+				{ID: "a", Protocol: repository.UploadProtocolPresigned, S3Bucket: "images", S3Key: "a.png"},
+			}, nil
+		},
+	}
+	store := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, _, key string) error {
+			deletedKey = key
+			return nil
+		},
+		abortMultipartUploadFunc: func(_ context.Context, _, _, _ string) error {
+			aborted = true
+			return nil
+		},
+	}
+
+	job := NewUploadCleanup(repo, store, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if deletedKey != "a.png" {
+		t.Errorf("expected uploaded object to be deleted, got %q", deletedKey)
+	}
+	if aborted {
+		t.Error("expected no multipart abort for presigned upload")
+	}
+}
+
+func TestUploadCleanup_AbortFailure_StillDeletesSession(t *testing.T) {
+	var deleted bool
+	repo := &mockRepository{
+		listExpiredUploadSessionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.UploadSession, error) {
+			return []repository.UploadSession{{ID: "a"}}, nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			deleted = true
+			return nil
+		},
+	}
+	store := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, _ string) error {
+			return errors.New("NoSuchUpload")
+		},
+	}
+
+	job := NewUploadCleanup(repo, store, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !deleted {
+		t.Error("expected session to be deleted even when abort fails")
+	}
+}
+
+func TestUploadCleanup_DeleteFailure_ReturnsError(t *testing.T) {
+	var aborted bool
+	repo := &mockRepository{
+		listExpiredUploadSessionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.UploadSession, error) {
+			return []repository.UploadSession{{ID: "a"}}, nil
+		},
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			return errors.New("database error")
+		},
+	}
+	store := &mockStorage{
+		abortMultipartUploadFunc: func(_ context.Context, _, _, _ string) error {
+			aborted = true
+			return nil
+		},
+	}
+
+	job := NewUploadCleanup(repo, store, testLogger())
+	if err := job.Run(context.Background()); err == nil {
+		t.Error("expected error when session deletion fails")
+	}
+	if aborted {
+		t.Error("expected the upload to be kept while its session remains")
+	}
+}
+
+func TestUploadCleanup_SessionAlreadyCompleted_KeepsObject(t *testing.T) {
+	var touched []string
+	repo := &mockRepository{
+		listExpiredUploadSessionsFunc: func(_ context.Context, _ time.Time, _ int) ([]repository.UploadSession, error) {
+			return []repository.UploadSession{
+				{ID: "a", Protocol: repository.UploadProtocolTus, S3Bucket: "images", S3Key: "a.png", S3UploadID: "upload-a"},
+				{ID: "b", Protocol: repository.UploadProtocolPresigned, S3Bucket: "images", S3Key: "b.png"},
+			}, nil
+		},
+		// Both uploads were completed after they were listed
+		deleteUploadSessionFunc: func(_ context.Context, _ string) error {
+			return repository.ErrUploadSessionCompleted
+		},
+	}
+	store := &mockStorage{
+		deleteObjectFunc: func(_ context.Context, _, key string) error {
+			touched = append(touched, key)
+			return nil
+		},
+		abortMultipartUploadFunc: func(_ context.Context, _, key, _ string) error {
+			touched = append(touched, key)
+			return nil
+		},
+	}
+
+	job := NewUploadCleanup(repo, store, testLogger())
+	if err := job.Run(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(touched) != 0 {
+		t.Errorf("expected the objects of completed uploads to be left alone, got %v", touched)
+	}
+}
@@ -0,0 +1,68 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// Memory keeps token buckets in process memory. Each replica counts on its own, so it
+// suits development and single-instance deployments.
+type Memory struct {
+	limit Limit
+	now   func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// Compile-time check that Memory implements Limiter.
+var _ Limiter = (*Memory)(nil)
+
+// NewMemory creates an in-memory limiter
+func NewMemory(limit Limit) *Memory {
+	return &Memory{
+		limit:   limit,
+		now:     time.Now,
+		buckets: make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key
+func (m *Memory) Allow(_ context.Context, key string) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	m.sweep(now)
+
+	b, ok := m.buckets[key]
+	if !ok {
+		b = &bucket{tokens: float64(m.limit.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	tokens, result := m.limit.take(b.tokens, now.Sub(b.updated))
+	b.tokens, b.updated = tokens, now
+	return result, nil
+}
+
+// sweep drops buckets that refilled completely, at most once per refill time, so keys
+// that stopped sending requests do not accumulate
+func (m *Memory) sweep(now time.Time) {
+	refill := m.limit.refillTime()
+	if now.Sub(m.lastSweep) < refill {
+		return
+	}
+	for key, b := range m.buckets {
+		if now.Sub(b.updated) >= refill {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
@@ -0,0 +1,100 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+// newTestMemory returns a limiter whose clock is advanced with the returned function
+func newTestMemory(limit Limit) (*Memory, func(time.Duration)) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	m := NewMemory(limit)
+	m.now = func() time.Time { return now }
+	return m, func(d time.Duration) { now = now.Add(d) }
+}
+
+func allow(t *testing.T, m *Memory, key string) Result {
+	t.Helper()
+	result, err := m.Allow(context.Background(), key)
+	if err != nil {
+		t.Fatalf("Allow() error = %v", err)
+	}
+	return result
+}
+
+// =============================================================================
+// Token Bucket Tests
+// =============================================================================
+
+func TestMemory_AllowsBurstThenRefills(t *testing.T) {
+	m, advance := newTestMemory(PerMinute(60, 3))
+
+	for i := 2; i >= 0; i-- {
+		result := allow(t, m, "user:1")
+		if !result.Allowed || result.Remaining != i {
+			t.Fatalf("expected allowed with %d remaining, got %+v", i, result)
+		}
+	}
+
+	result := allow(t, m, "user:1")
+	if result.Allowed {
+		t.Fatal("expected request over the burst to be refused")
+	}
+	if result.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s, got %v", result.RetryAfter)
+	}
+
+	advance(250 * time.Millisecond)
+	result = allow(t, m, "user:1")
+	if result.Allowed || result.RetryAfter != 750*time.Millisecond {
+		t.Errorf("expected refusal with retry after 750ms, got %+v", result)
+	}
+
+	advance(750 * time.Millisecond)
+	if result := allow(t, m, "user:1"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("expected one refilled token, got %+v", result)
+	}
+}
+
+func TestMemory_KeysHaveSeparateBuckets(t *testing.T) {
+	m, _ := newTestMemory(PerMinute(1, 1))
+
+	if result := allow(t, m, "user:1"); !result.Allowed {
+		t.Fatal("expected first request of user 1 to be allowed")
+	}
+	if result := allow(t, m, "user:1"); result.Allowed {
+		t.Fatal("expected second request of user 1 to be refused")
+	}
+	if result := allow(t, m, "user:2"); !result.Allowed {
+		t.Error("expected user 2 not to be limited by user 1")
+	}
+}
+
+func TestMemory_RefillDoesNotExceedBurst(t *testing.T) {
+	m, advance := newTestMemory(PerMinute(60, 2))
+
+	allow(t, m, "user:1")
+	advance(time.Hour)
+
+	if result := allow(t, m, "user:1"); result.Remaining != 1 {
+		t.Errorf("expected bucket capped at burst, got %d remaining", result.Remaining)
+	}
+}
+
+func TestMemory_DropsRefilledBuckets(t *testing.T) {
+	m, advance := newTestMemory(PerMinute(60, 2))
+
+	allow(t, m, "user:1")
+	advance(time.Second)
+	allow(t, m, "user:2")
+	advance(1500 * time.Millisecond)
+	allow(t, m, "user:3")
+
+	if _, ok := m.buckets["user:1"]; ok {
+		t.Error("expected refilled bucket to be dropped")
+	}
+	if _, ok := m.buckets["user:2"]; !ok {
+		t.Error("expected bucket still refilling to be kept")
+	}
+}
@@ -0,0 +1,52 @@
+package ratelimit
+
+import (
+	"context"
+	"math"
+	"time"
+)
+
+// Limit configures token buckets: each key may spend up to Burst tokens at once, and
+// its bucket refills at Rate tokens per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// PerMinute returns a limit refilling n tokens per minute with room for burst at once
+func PerMinute(n, burst int) Limit {
+	return Limit{Rate: float64(n) / 60, Burst: burst}
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Whole tokens left in the bucket after the request
+	Remaining int
+	// Time until the next token is available; zero for allowed requests
+	RetryAfter time.Duration
+}
+
+// Limiter takes tokens from per-key buckets.
+// This interface enables mocking for unit tests.
+type Limiter interface {
+	Allow(ctx context.Context, key string) (Result, error)
+}
+
+// take refills a bucket that held tokens elapsed ago and takes one token from it,
+// returning the tokens left. Buckets never seen before start full.
+func (l Limit) take(tokens float64, elapsed time.Duration) (float64, Result) {
+	tokens = math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate)
+	if tokens < 1 {
+		wait := time.Duration((1 - tokens) / l.Rate * float64(time.Second))
+		return tokens, Result{RetryAfter: wait}
+	}
+	tokens--
+	return tokens, Result{Allowed: true, Remaining: int(tokens)}
+}
+
+// refillTime is how long an empty bucket takes to fill up again; buckets untouched for
+// longer are full and need not be kept
+func (l Limit) refillTime() time.Duration {
+	return time.Duration(float64(l.Burst) / l.Rate * float64(time.Second))
+}
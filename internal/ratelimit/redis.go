@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// takeScript refills and takes from a bucket stored as a hash of tokens and the time
+// it was last updated. Time comes from the Redis server so replicas with skewed clocks
+// share one view of each bucket. Fractions are returned as strings, since Redis
+// truncates Lua numbers to integers.
+var takeScript = redis.NewScript(`
+local rate = tonumber(ARGV[1])
+local burst = tonumber(ARGV[2])
+local clock = redis.call('TIME')
+local now = tonumber(clock[1]) + tonumber(clock[2]) / 1000000
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'updated')
+local tokens = tonumber(state[1]) or burst
+local updated = tonumber(state[2]) or now
+tokens = math.min(burst, tokens + math.max(0, now - updated) * rate)
+
+local allowed = 0
+local wait = 0
+if tokens >= 1 then
+	tokens = tokens - 1
+	allowed = 1
+else
+	wait = (1 - tokens) / rate
+end
+
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'updated', tostring(now))
+redis.call('PEXPIRE', KEYS[1], math.ceil((burst - tokens) / rate * 1000) + 1000)
+return {allowed, tostring(tokens), tostring(wait)}
+`)
+
+// Redis keeps token buckets in Redis, shared by all replicas of the service. Each
+// request is one script call, so concurrent requests for a key cannot overspend it.
+type Redis struct {
+	client redis.Scripter
+	limit  Limit
+	prefix string
+}
+
+// Compile-time check that Redis implements Limiter.
+var _ Limiter = (*Redis)(nil)
+
+// NewRedis creates a limiter storing its buckets under keys starting with prefix.
+// Buckets expire once they would have refilled.
+func NewRedis(client redis.Scripter, limit Limit, prefix string) *Redis {
+	return &Redis{client: client, limit: limit, prefix: prefix}
+}
+
+// Allow takes a token from the bucket of key
+func (r *Redis) Allow(ctx context.Context, key string) (Result, error) {
+	reply, err := takeScript.Run(ctx, r.client, []string{r.prefix + key}, r.limit.Rate, r.limit.Burst).Slice()
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	if len(reply) != 3 {
+		return Result{}, fmt.Errorf("failed to take rate limit token: unexpected reply %v", reply)
+	}
+
+	allowed, _ := reply[0].(int64)
+	tokens, err := parseFloatReply(reply[1])
+	if err != nil {
+		return Result{}, err
+	}
+	wait, err := parseFloatReply(reply[2])
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{
+		Allowed:    allowed == 1,
+		Remaining:  int(tokens),
+		RetryAfter: time.Duration(wait * float64(time.Second)),
+	}, nil
+}
+
+func parseFloatReply(v interface{}) (float64, error) {
+	s, ok := v.(string)
+	if !ok {
+		return 0, fmt.Errorf("failed to take rate limit token: unexpected value %v", v)
+	}
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+	return f, nil
+}
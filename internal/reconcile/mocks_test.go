@@ -0,0 +1,108 @@
+package reconcile
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sort"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/minio/minio-go/v7"
+)
+
+// =============================================================================
+// Mock Repository
+// =============================================================================
+
+// mockRepository serves file records from memory in key order; calling any
+// method the reconciler does not use panics, flagging unexpected access.
+type mockRepository struct {
+	repository.Repository
+
+	files map[string][]repository.StorageFile
+	// known holds the keys referenced by uploads and scheduled deletions
+	known map[string]bool
+
+	pages     int
+	markedIDs []int64
+}
+
+func (m *mockRepository) ListBucketFiles(_ context.Context, bucket, afterKey string, afterID int64, limit int) ([]repository.StorageFile, error) {
+	m.pages++
+	files := m.files[bucket]
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].ObjectKey() != files[j].ObjectKey() {
+			return files[i].ObjectKey() < files[j].ObjectKey()
+		}
+		return files[i].ID < files[j].ID
+	})
+	var page []repository.StorageFile
+	for _, file := range files {
+		after := file.ObjectKey() > afterKey || (file.ObjectKey() == afterKey && file.ID > afterID)
+		if after && len(page) < limit {
+			page = append(page, file)
+		}
+	}
+	return page, nil
+}
+
+func (m *mockRepository) FindKnownObjectKeys(_ context.Context, bucket string, keys []string) (map[string]bool, error) {
+	known := make(map[string]bool)
+	for _, key := range keys {
+		if m.known[key] {
+			known[key] = true
+		}
+		for _, file := range m.files[bucket] {
+			if file.ObjectKey() == key {
+				known[key] = true
+			}
+		}
+	}
+	return known, nil
+}
+
+func (m *mockRepository) MarkFilesMissing(_ context.Context, ids []int64) (int64, error) {
+	m.markedIDs = append(m.markedIDs, ids...)
+	return int64(len(ids)), nil
+}
+
+// =============================================================================
+// Mock Storage
+// =============================================================================
+
+// mockStorage lists objects from memory in key order, as S3 does
+type mockStorage struct {
+	storage.ObjectStore
+
+	objects     map[string][]minio.ObjectInfo
+	listErrs    map[string]error
+	deletedKeys []string
+}
+
+func (m *mockStorage) ListObjects(_ context.Context, bucket string, fn func(minio.ObjectInfo) error) error {
+	if err := m.listErrs[bucket]; err != nil {
+		return err
+	}
+	objects := m.objects[bucket]
+	sort.Slice(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	for _, object := range objects {
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *mockStorage) DeleteObject(_ context.Context, bucket, key string) error {
+	m.deletedKeys = append(m.deletedKeys, bucket+"/"+key)
+	return nil
+}
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+func testLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
@@ -0,0 +1,206 @@
+// Package reconcile compares the objects in storage with the file records in the database.
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/files-api/internal/storage"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Modes; in repair mode orphaned objects are deleted and files missing their object are
+// marked missing, which stops them being served.
+const (
+	ModeReport = "report"
+	ModeRepair = "repair"
+)
+
+// batchSize limits how many records are loaded and keys looked up per query
+const batchSize = 500
+
+// maxListedIssues caps the orphaned objects and missing files listed per bucket in a report
+const maxListedIssues = 100
+
+// ErrRunning is returned when a reconciliation is requested while one is in progress
+var ErrRunning = errors.New("reconciliation already running")
+
+// Config selects the buckets to walk and whether problems are repaired
+type Config struct {
+	Buckets []string
+	Mode    string
+	// Objects and records younger than this are skipped, as their upload may be in progress
+	GracePeriod time.Duration
+}
+
+// Report is the outcome of one reconciliation
+type Report struct {
+	Mode       string         `json:"mode"`
+	StartedAt  time.Time      `json:"startedAt"`
+	FinishedAt time.Time      `json:"finishedAt"`
+	Buckets    []BucketReport `json:"buckets"`
+}
+
+// BucketReport lists what was found in one bucket; the lists are capped, the counts are not
+type BucketReport struct {
+	Bucket          string           `json:"bucket"`
+	ObjectsScanned  int64            `json:"objectsScanned"`
+	RecordsScanned  int64            `json:"recordsScanned"`
+	OrphanedCount   int64            `json:"orphanedCount"`
+	MissingCount    int64            `json:"missingCount"`
+	OrphanedObjects []OrphanedObject `json:"orphanedObjects"`
+	MissingObjects  []MissingObject  `json:"missingObjects"`
+	OrphansDeleted  int64            `json:"orphansDeleted"`
+	FilesMarked     int64            `json:"filesMarked"`
+	Error           string           `json:"error,omitempty"`
+}
+
+// OrphanedObject is an object no file, upload or scheduled deletion refers to
+type OrphanedObject struct {
+	Key          string    `json:"key"`
+	Size         int64     `json:"size"`
+	LastModified time.Time `json:"lastModified"`
+}
+
+// MissingObject is a file record whose object does not exist
+type MissingObject struct {
+	FileID   int64  `json:"fileId"`
+	Key      string `json:"key"`
+	FileType string `json:"fileType"`
+	Status   string `json:"status"`
+}
+
+// Reconciler walks each bucket alongside its file records. It runs as a background
+// job and on demand; the latest report is kept for the admin endpoint.
+type Reconciler struct {
+	repo    repository.Repository
+	storage storage.ObjectStore
+	cfg     Config
+	log     *slog.Logger
+
+	running sync.Mutex
+	mu      sync.RWMutex
+	last    *Report
+
+	orphaned    *prometheus.GaugeVec
+	missing     *prometheus.GaugeVec
+	lastSuccess prometheus.Gauge
+}
+
+func New(repo repository.Repository, storage storage.ObjectStore, cfg Config, reg prometheus.Registerer, log *slog.Logger) *Reconciler {
+	factory := promauto.With(reg)
+	return &Reconciler{
+		repo:    repo,
+		storage: storage,
+		cfg:     cfg,
+		log:     log,
+		orphaned: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "reconcile_orphaned_objects",
+			Help:      "Objects without a file record found by the last reconciliation",
+		}, []string{"bucket"}),
+		missing: factory.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "reconcile_missing_objects",
+			Help:      "File records without an object found by the last reconciliation",
+		}, []string{"bucket"}),
+		lastSuccess: factory.NewGauge(prometheus.GaugeOpts{
+			Namespace: "portfolio",
+			Subsystem: "files",
+			Name:      "reconcile_last_success_timestamp_seconds",
+			Help:      "Time the last reconciliation of every bucket completed",
+		}),
+	}
+}
+
+func (r *Reconciler) Name() string {
+	return "storage-reconcile"
+}
+
+func (r *Reconciler) Run(ctx context.Context) error {
+	_, err := r.Reconcile(ctx)
+	return err
+}
+
+// LastReport returns the report of the latest reconciliation, or nil before the first
+func (r *Reconciler) LastReport() *Report {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.last
+}
+
+// Reconcile walks every bucket and returns the report. A bucket that fails is recorded
+// in the report and the error returned after the remaining buckets are walked.
+func (r *Reconciler) Reconcile(ctx context.Context) (*Report, error) {
+	if !r.running.TryLock() {
+		return nil, ErrRunning
+	}
+	defer r.running.Unlock()
+
+	report := &Report{Mode: r.cfg.Mode, StartedAt: time.Now()}
+	var errs []error
+	for _, bucket := range r.cfg.Buckets {
+		bucketReport, err := r.reconcileBucket(ctx, bucket, report.StartedAt)
+		if err != nil {
+			bucketReport.Error = err.Error()
+			errs = append(errs, fmt.Errorf("bucket %s: %w", bucket, err))
+		} else {
+			r.orphaned.WithLabelValues(bucket).Set(float64(bucketReport.OrphanedCount))
+			r.missing.WithLabelValues(bucket).Set(float64(bucketReport.MissingCount))
+		}
+		report.Buckets = append(report.Buckets, bucketReport)
+
+		if bucketReport.OrphanedCount > 0 || bucketReport.MissingCount > 0 {
+			r.log.Warn("Storage and database out of sync",
+				"bucket", bucket,
+				"orphaned_objects", bucketReport.OrphanedCount,
+				"missing_objects", bucketReport.MissingCount,
+				"orphans_deleted", bucketReport.OrphansDeleted,
+				"files_marked", bucketReport.FilesMarked,
+			)
+		}
+	}
+	report.FinishedAt = time.Now()
+	if len(errs) == 0 {
+		r.lastSuccess.Set(float64(report.FinishedAt.Unix()))
+	}
+
+	r.mu.Lock()
+	r.last = report
+	r.mu.Unlock()
+
+	return report, errors.Join(errs...)
+}
+
+func (r *Reconciler) reconcileBucket(ctx context.Context, bucket string, now time.Time) (BucketReport, error) {
+	w := &bucketWalk{
+		r:      r,
+		bucket: bucket,
+		cutoff: now.Add(-r.cfg.GracePeriod),
+		report: BucketReport{
+			Bucket:          bucket,
+			OrphanedObjects: []OrphanedObject{},
+			MissingObjects:  []MissingObject{},
+		},
+	}
+	err := r.storage.ListObjects(ctx, bucket, func(object minio.ObjectInfo) error {
+		return w.object(ctx, object)
+	})
+	if err == nil {
+		err = w.finish(ctx)
+	}
+	return w.report, err
+}
+
+func (r *Reconciler) repair() bool {
+	return r.cfg.Mode == ModeRepair
+}
@@ -0,0 +1,264 @@
+package reconcile
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/minio/minio-go/v7"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// =============================================================================
+// Test Helpers
+// =============================================================================
+
+const testGracePeriod = time.Hour
+
+var old = time.Now().Add(-24 * time.Hour)
+
+func testObject(key string, modified time.Time) minio.ObjectInfo {
+	return minio.ObjectInfo{Key: key, Size: 10, LastModified: modified}
+}
+
+func testRecord(id int64, key, status string, created time.Time) repository.StorageFile {
+	return repository.StorageFile{ID: id, S3Bucket: "images", S3Key: key, FileType: "image", Status: status, CreatedAt: created}
+}
+
+// testBucket holds one of each case the reconciler distinguishes
+func testBucket() (*mockRepository, *mockStorage) {
+	repo := &mockRepository{
+		files: map[string][]repository.StorageFile{
+			"images": {
+				testRecord(1, "a.png", repository.FileStatusActive, old),
+				testRecord(2, "b.png", repository.FileStatusActive, old),
+				testRecord(3, "c.png", repository.FileStatusPending, old),
+				testRecord(4, "d.png", repository.FileStatusActive, time.Now()),
+				testRecord(5, "e.png", repository.FileStatusMissing, old),
+			},
+		},
+		known: map[string]bool{"session.png": true, "deleting.png": true},
+	}
+	storage := &mockStorage{
+		objects: map[string][]minio.ObjectInfo{
+			"images": {
+				testObject("a.png", old),
+				testObject("_derived/a.png/w100.webp", old),
+				testObject("_derived/gone.png/w100.webp", old),
+				testObject("deleting.png", old),
+				testObject("orphan.png", old),
+				testObject("session.png", old),
+				testObject("uploading.png", time.Now()),
+			},
+		},
+	}
+	return repo, storage
+}
+
+func newTestReconciler(repo *mockRepository, storage *mockStorage, mode string, reg *prometheus.Registry, buckets ...string) *Reconciler {
+	cfg := Config{Buckets: buckets, Mode: mode, GracePeriod: testGracePeriod}
+	return New(repo, storage, cfg, reg, testLogger())
+}
+
+func orphanedKeys(report BucketReport) []string {
+	var keys []string
+	for _, object := range report.OrphanedObjects {
+		keys = append(keys, object.Key)
+	}
+	return keys
+}
+
+func missingIDs(report BucketReport) []int64 {
+	var ids []int64
+	for _, file := range report.MissingObjects {
+		ids = append(ids, file.FileID)
+	}
+	return ids
+}
+
+// =============================================================================
+// Reconcile Tests
+// =============================================================================
+
+func TestReconcile_ReportMode(t *testing.T) {
+	repo, storage := testBucket()
+	reg := prometheus.NewRegistry()
+	r := newTestReconciler(repo, storage, ModeReport, reg, "images")
+
+	report, err := r.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if r.LastReport() != report {
+		t.Error("expected report to be kept as the last report")
+	}
+
+	bucket := report.Buckets[0]
+	if bucket.ObjectsScanned != 7 || bucket.RecordsScanned != 5 {
+		t.Errorf("expected 7 objects and 5 records scanned, got %d and %d", bucket.ObjectsScanned, bucket.RecordsScanned)
+	}
+	// Derived images of an existing original, uploads, scheduled deletions and young objects are not orphans
+	if got := fmt.Sprint(orphanedKeys(bucket)); got != "[_derived/gone.png/w100.webp orphan.png]" || bucket.OrphanedCount != 2 {
+		t.Errorf("unexpected orphaned objects %s", got)
+	}
+	// Pending and young records are skipped; records already marked missing are still reported
+	if got := fmt.Sprint(missingIDs(bucket)); got != "[2 5]" || bucket.MissingCount != 2 {
+		t.Errorf("unexpected missing files %s", got)
+	}
+
+	if len(storage.deletedKeys) != 0 || len(repo.markedIDs) != 0 {
+		t.Errorf("expected nothing repaired in report mode, deleted %v marked %v", storage.deletedKeys, repo.markedIDs)
+	}
+	if got := testutil.ToFloat64(r.orphaned.WithLabelValues("images")); got != 2 {
+		t.Errorf("expected orphaned gauge 2, got %v", got)
+	}
+	if got := testutil.ToFloat64(r.missing.WithLabelValues("images")); got != 2 {
+		t.Errorf("expected missing gauge 2, got %v", got)
+	}
+	if testutil.ToFloat64(r.lastSuccess) == 0 {
+		t.Error("expected last success time to be set")
+	}
+}
+
+func TestReconcile_RepairMode(t *testing.T) {
+	repo, storage := testBucket()
+	r := newTestReconciler(repo, storage, ModeRepair, prometheus.NewRegistry(), "images")
+
+	report, err := r.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bucket := report.Buckets[0]
+	if got := fmt.Sprint(storage.deletedKeys); got != "[images/_derived/gone.png/w100.webp images/orphan.png]" {
+		t.Errorf("expected orphans deleted, got %s", got)
+	}
+	// Only active files are marked; file 5 is already missing
+	if got := fmt.Sprint(repo.markedIDs); got != "[2]" {
+		t.Errorf("expected file 2 marked missing, got %s", got)
+	}
+	if bucket.OrphansDeleted != 2 || bucket.FilesMarked != 1 {
+		t.Errorf("expected 2 orphans deleted and 1 file marked, got %d and %d", bucket.OrphansDeleted, bucket.FilesMarked)
+	}
+}
+
+func TestReconcile_AcrossBatches(t *testing.T) {
+	repo := &mockRepository{files: map[string][]repository.StorageFile{}}
+	storage := &mockStorage{objects: map[string][]minio.ObjectInfo{}}
+
+	// Every third key has only a record and every fifth only an object
+	const keys = 3 * batchSize
+	var wantMissing, wantOrphaned int64
+	for i := range keys {
+		key := fmt.Sprintf("%05d.png", i)
+		switch {
+		case i%3 == 0:
+			repo.files["images"] = append(repo.files["images"], testRecord(int64(i), key, repository.FileStatusActive, old))
+			wantMissing++
+		case i%5 == 0:
+			storage.objects["images"] = append(storage.objects["images"], testObject(key, old))
+			wantOrphaned++
+		default:
+			repo.files["images"] = append(repo.files["images"], testRecord(int64(i), key, repository.FileStatusActive, old))
+			storage.objects["images"] = append(storage.objects["images"], testObject(key, old))
+		}
+	}
+
+	r := newTestReconciler(repo, storage, ModeRepair, prometheus.NewRegistry(), "images")
+	report, err := r.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bucket := report.Buckets[0]
+	if bucket.MissingCount != wantMissing || bucket.OrphanedCount != wantOrphaned {
+		t.Errorf("expected %d missing and %d orphaned, got %d and %d", wantMissing, wantOrphaned, bucket.MissingCount, bucket.OrphanedCount)
+	}
+	if bucket.RecordsScanned != int64(len(repo.files["images"])) || repo.pages < 3 {
+		t.Errorf("expected all records scanned in batches, got %d records in %d pages", bucket.RecordsScanned, repo.pages)
+	}
+	if len(bucket.MissingObjects) != maxListedIssues || bucket.FilesMarked != wantMissing {
+		t.Errorf("expected %d listed and all marked, got %d listed and %d marked", maxListedIssues, len(bucket.MissingObjects), bucket.FilesMarked)
+	}
+	if !sort.SliceIsSorted(repo.markedIDs, func(i, j int) bool { return repo.markedIDs[i] < repo.markedIDs[j] }) {
+		t.Error("expected files marked in key order")
+	}
+}
+
+func TestReconcile_SharedObjects(t *testing.T) {
+	repo := &mockRepository{files: map[string][]repository.StorageFile{}}
+	storage := &mockStorage{objects: map[string][]minio.ObjectInfo{}}
+
+	// The files sharing an object straddle the boundary between two batches
+	for i := range batchSize - 1 {
+		key := fmt.Sprintf("a%05d.png", i)
+		repo.files["images"] = append(repo.files["images"], testRecord(int64(i+10), key, repository.FileStatusActive, old))
+		storage.objects["images"] = append(storage.objects["images"], testObject(key, old))
+	}
+	// Each file has its own URL key; the object is found through its content key
+	shared := "shared.png"
+	for _, id := range []int64{3, 1, 2} {
+		record := testRecord(id, fmt.Sprintf("z%d.png", id), repository.FileStatusActive, old)
+		record.ContentKey = &shared
+		repo.files["images"] = append(repo.files["images"], record)
+	}
+	storage.objects["images"] = append(storage.objects["images"], testObject("shared.png", old))
+
+	r := newTestReconciler(repo, storage, ModeRepair, prometheus.NewRegistry(), "images")
+	report, err := r.Reconcile(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	bucket := report.Buckets[0]
+	if bucket.RecordsScanned != batchSize+2 {
+		t.Errorf("expected every file sharing the object scanned, got %d records", bucket.RecordsScanned)
+	}
+	if bucket.MissingCount != 0 || bucket.OrphanedCount != 0 {
+		t.Errorf("expected bucket in sync, got %d missing and %d orphaned", bucket.MissingCount, bucket.OrphanedCount)
+	}
+}
+
+func TestReconcile_BucketError(t *testing.T) {
+	repo, storage := testBucket()
+	storage.listErrs = map[string]error{"documents": errors.New("access denied")}
+	r := newTestReconciler(repo, storage, ModeReport, prometheus.NewRegistry(), "documents", "images")
+
+	report, err := r.Reconcile(context.Background())
+	if err == nil {
+		t.Fatal("expected error when a bucket cannot be listed")
+	}
+	if len(report.Buckets) != 2 || report.Buckets[0].Error == "" || report.Buckets[1].Error != "" {
+		t.Fatalf("expected failure recorded for documents only, got %+v", report.Buckets)
+	}
+	if report.Buckets[1].OrphanedCount != 2 {
+		t.Error("expected remaining buckets to be reconciled")
+	}
+	if testutil.ToFloat64(r.lastSuccess) != 0 {
+		t.Error("expected last success time not to be set")
+	}
+	if r.LastReport() != report {
+		t.Error("expected failed report to be kept")
+	}
+}
+
+func TestReconcile_AlreadyRunning(t *testing.T) {
+	repo, storage := testBucket()
+	r := newTestReconciler(repo, storage, ModeReport, prometheus.NewRegistry(), "images")
+
+	r.running.Lock()
+	_, err := r.Reconcile(context.Background())
+	r.running.Unlock()
+
+	if !errors.Is(err, ErrRunning) {
+		t.Errorf("expected ErrRunning, got %v", err)
+	}
+	if r.LastReport() != nil {
+		t.Error("expected no report from a rejected run")
+	}
+}
@@ -0,0 +1,195 @@
+package reconcile
+
+import (
+	"context"
+	"time"
+
+	"github.com/GunarsK-portfolio/files-api/internal/images"
+	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/minio/minio-go/v7"
+)
+
+// bucketWalk merges the objects of a bucket with its file records, both in key order.
+// Records passed over without a matching object are missing their object; objects
+// without a record are orphaned unless an upload or scheduled deletion refers to them.
+type bucketWalk struct {
+	r      *Reconciler
+	bucket string
+	cutoff time.Time
+	report BucketReport
+
+	records   []repository.StorageFile
+	afterKey  string
+	afterID   int64
+	exhausted bool
+
+	candidates []minio.ObjectInfo
+	missingIDs []int64
+}
+
+// object handles the next object listed from the bucket
+func (w *bucketWalk) object(ctx context.Context, object minio.ObjectInfo) error {
+	w.report.ObjectsScanned++
+
+	matched := false
+	for {
+		record, err := w.peek(ctx)
+		if err != nil {
+			return err
+		}
+		if record == nil || record.ObjectKey() > object.Key {
+			break
+		}
+		w.records = w.records[1:]
+		if record.ObjectKey() == object.Key {
+			matched = true
+			continue
+		}
+		if err := w.missingObject(ctx, record); err != nil {
+			return err
+		}
+	}
+	if matched || object.LastModified.After(w.cutoff) {
+		return nil
+	}
+
+	w.candidates = append(w.candidates, object)
+	if len(w.candidates) >= batchSize {
+		return w.flushCandidates(ctx)
+	}
+	return nil
+}
+
+// finish reports the records after the last object and flushes pending batches
+func (w *bucketWalk) finish(ctx context.Context) error {
+	for {
+		record, err := w.peek(ctx)
+		if err != nil {
+			return err
+		}
+		if record == nil {
+			break
+		}
+		w.records = w.records[1:]
+		if err := w.missingObject(ctx, record); err != nil {
+			return err
+		}
+	}
+	if err := w.flushCandidates(ctx); err != nil {
+		return err
+	}
+	return w.flushMissing(ctx)
+}
+
+// peek returns the next record, loading the next batch when needed, or nil at the end
+func (w *bucketWalk) peek(ctx context.Context) (*repository.StorageFile, error) {
+	if len(w.records) == 0 && !w.exhausted {
+		records, err := w.r.repo.ListBucketFiles(ctx, w.bucket, w.afterKey, w.afterID, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		w.report.RecordsScanned += int64(len(records))
+		w.exhausted = len(records) < batchSize
+		if len(records) > 0 {
+			last := records[len(records)-1]
+			w.afterKey, w.afterID = last.ObjectKey(), last.ID
+		}
+		w.records = records
+	}
+	if len(w.records) == 0 {
+		return nil, nil
+	}
+	return &w.records[0], nil
+}
+
+func (w *bucketWalk) missingObject(ctx context.Context, record *repository.StorageFile) error {
+	// Pending uploads are left to the pending upload sweep
+	if record.IsPending() || record.CreatedAt.After(w.cutoff) {
+		return nil
+	}
+
+	w.report.MissingCount++
+	if len(w.report.MissingObjects) < maxListedIssues {
+		w.report.MissingObjects = append(w.report.MissingObjects, MissingObject{
+			FileID:   record.ID,
+			Key:      record.ObjectKey(),
+			FileType: record.FileType,
+			Status:   record.Status,
+		})
+	}
+
+	if !w.r.repair() || record.Status != repository.FileStatusActive {
+		return nil
+	}
+	w.missingIDs = append(w.missingIDs, record.ID)
+	if len(w.missingIDs) >= batchSize {
+		return w.flushMissing(ctx)
+	}
+	return nil
+}
+
+// flushCandidates looks up the objects without a file record and reports the orphans
+func (w *bucketWalk) flushCandidates(ctx context.Context) error {
+	if len(w.candidates) == 0 {
+		return nil
+	}
+
+	// Derived images belong to their original
+	lookup := make([]string, len(w.candidates))
+	for i, object := range w.candidates {
+		lookup[i] = object.Key
+		if source, ok := images.DerivedSource(object.Key); ok {
+			lookup[i] = source
+		}
+	}
+	known, err := w.r.repo.FindKnownObjectKeys(ctx, w.bucket, lookup)
+	if err != nil {
+		return err
+	}
+
+	for i, object := range w.candidates {
+		if known[lookup[i]] {
+			continue
+		}
+		w.orphanedObject(ctx, object)
+	}
+	w.candidates = w.candidates[:0]
+	return nil
+}
+
+func (w *bucketWalk) orphanedObject(ctx context.Context, object minio.ObjectInfo) {
+	w.report.OrphanedCount++
+	if len(w.report.OrphanedObjects) < maxListedIssues {
+		w.report.OrphanedObjects = append(w.report.OrphanedObjects, OrphanedObject{
+			Key:          object.Key,
+			Size:         object.Size,
+			LastModified: object.LastModified,
+		})
+	}
+
+	if !w.r.repair() {
+		return
+	}
+	if err := w.r.storage.DeleteObject(ctx, w.bucket, object.Key); err != nil {
+		w.r.log.Warn("Failed to delete orphaned object",
+			"error", err,
+			"bucket", w.bucket,
+			"key", object.Key,
+		)
+		return
+	}
+	w.report.OrphansDeleted++
+}
+
+func (w *bucketWalk) flushMissing(ctx context.Context) error {
+	if len(w.missingIDs) == 0 {
+		return nil
+	}
+	marked, err := w.r.repo.MarkFilesMissing(ctx, w.missingIDs)
+	if err != nil {
+		return err
+	}
+	w.report.FilesMarked += marked
+	w.missingIDs = w.missingIDs[:0]
+	return nil
+}
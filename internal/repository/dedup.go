@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Objects are reference counted by the file rows and file versions pointing at them: a
+// file uploaded with the same content as an existing one gets its own row and URL but
+// shares the existing object, and the object is only deleted with the last reference.
+
+// objectKeyColumn is the key of the object holding a file row's content (see ObjectKey)
+const objectKeyColumn = "COALESCE(content_key, s3_key)"
+
+// shareObject points a file with a content hash at the object of an active file with the
+// same content in its bucket and sets SharesObject. Objects the integrity scrub found
+// corrupted are never shared, so the new content is stored itself. The matched row is
+// locked until the transaction commits, so a concurrent purge either waits and then sees
+// the new reference, or deletes the row first and no object is shared.
+func shareObject(tx *gorm.DB, file *StorageFile) error {
+	if file.ContentHash == nil {
+		return nil
+	}
+
+	var existing StorageFile
+	err := tx.Clauses(clause.Locking{Strength: "SHARE"}).
+		Select("id", "s3_key", "content_key").
+		Where("s3_bucket = ? AND sha256 = ? AND status = ? AND corrupted_at IS NULL", file.S3Bucket, *file.ContentHash, FileStatusActive).
+		Order("id").
+		Limit(1).
+		Find(&existing).Error
+	if err != nil {
+		return err
+	}
+	if existing.ID != 0 {
+		key := existing.ObjectKey()
+		file.ContentKey = &key
+		file.SharesObject = true
+	}
+	return nil
+}
+
+// unsharedDeletions drops the deletions of objects other files or file versions still
+// refer to. It runs after the deleted rows are gone, so the remaining rows are exactly
+// the other references.
+func unsharedDeletions(tx *gorm.DB, deletions []PendingDeletion) ([]PendingDeletion, error) {
+	keysByBucket := make(map[string][]string)
+	for i := range deletions {
+		bucket := deletions[i].S3Bucket
+		keysByBucket[bucket] = append(keysByBucket[bucket], deletions[i].fileKey())
+	}
+
+	shared := make(map[string]map[string]bool, len(keysByBucket))
+	for bucket, keys := range keysByBucket {
+		files := tx.Model(&StorageFile{}).Select(objectKeyColumn).
+			Where("s3_bucket = ? AND "+objectKeyColumn+" IN ?", bucket, keys)
+		versions := tx.Model(&FileVersion{}).Select("content_key").
+			Where("s3_bucket = ? AND content_key IN ?", bucket, keys)
+
+		var found []string
+		if err := tx.Raw("? UNION ?", files, versions).Scan(&found).Error; err != nil {
+			return nil, err
+		}
+		shared[bucket] = make(map[string]bool, len(found))
+		for _, key := range found {
+			shared[bucket][key] = true
+		}
+	}
+
+	kept := make([]PendingDeletion, 0, len(deletions))
+	for _, deletion := range deletions {
+		if !shared[deletion.S3Bucket][deletion.fileKey()] {
+			kept = append(kept, deletion)
+		}
+	}
+	return kept, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// storedObject is an active file with the content being uploaded, as the fake database
+// holds it
+type storedObject struct {
+	id        int64
+	key       string
+	corrupted bool
+}
+
+// respondWithObjects answers the shareObject lookup with the objects the query's
+// conditions let through
+func respondWithObjects(objects ...storedObject) func(string, []driver.NamedValue) fakeResult {
+	return func(query string, _ []driver.NamedValue) fakeResult {
+		result := fakeResult{columns: []string{"id", "s3_key", "content_key"}}
+		for _, object := range objects {
+			if object.corrupted && strings.Contains(query, "corrupted_at IS NULL") {
+				continue
+			}
+			result.rows = append(result.rows, []driver.Value{object.id, object.key, nil})
+		}
+		return result
+	}
+}
+
+// =============================================================================
+// Deduplication Tests
+// =============================================================================
+
+func TestShareObject(t *testing.T) {
+	tests := []struct {
+		name        string
+		objects     []storedObject
+		expectedKey string
+	}{
+		{
+			name:        "shares the object of an identical file",
+			objects:     []storedObject{{id: 3, key: "existing.png"}},
+			expectedKey: "existing.png",
+		},
+		{
+			name:    "stores its own object when the only match is corrupted",
+			objects: []storedObject{{id: 3, key: "corrupted.png", corrupted: true}},
+		},
+		{
+			name:    "stores its own object without a match",
+			objects: nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := openFakeDB(t, respondWithObjects(tt.objects...))
+			hash := strings.Repeat("ab", 32)
+			file := &StorageFile{S3Key: "new.png", S3Bucket: "images", ContentHash: &hash, CreatedAt: time.Now()}
+
+			if err := shareObject(db, file); err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if tt.expectedKey == "" {
+				if file.SharesObject || file.ContentKey != nil {
+					t.Errorf("expected the upload to store its own object, got %+v", file)
+				}
+				return
+			}
+			if !file.SharesObject || file.ContentKey == nil || *file.ContentKey != tt.expectedKey {
+				t.Errorf("expected the upload to share %s, got %+v", tt.expectedKey, file)
+			}
+		})
+	}
+}
@@ -0,0 +1,144 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// PendingDeletion is a storage object, or a key prefix, still to be removed after its
+// file record was deleted. Rows are written in the transaction that deletes the record,
+// so an object is never left behind, and removed once the object is gone.
+type PendingDeletion struct {
+	ID       int64  `gorm:"column:id;primaryKey"`
+	FileID   int64  `gorm:"column:file_id"`
+	S3Bucket string `gorm:"column:s3_bucket"`
+	// Object key, or a key prefix when IsPrefix is set
+	S3Key    string `gorm:"column:s3_key"`
+	IsPrefix bool   `gorm:"column:is_prefix"`
+	// Key of the file object a prefix belongs to, such as the source of derived images;
+	// the deletion is dropped while another file shares that object. Defaults to S3Key.
+	FileKey string `gorm:"-"`
+
+	Attempts      int       `gorm:"column:attempts"`
+	NextAttemptAt time.Time `gorm:"column:next_attempt_at"`
+	LastError     *string   `gorm:"column:last_error"`
+	CreatedAt     time.Time `gorm:"column:created_at"`
+}
+
+func (PendingDeletion) TableName() string {
+	return "storage.pending_deletions"
+}
+
+func (d *PendingDeletion) fileKey() string {
+	if d.FileKey != "" {
+		return d.FileKey
+	}
+	return d.S3Key
+}
+
+// DeletionStats counts pending deletions; Stuck ones were scheduled before the cutoff
+type DeletionStats struct {
+	Pending int64
+	Stuck   int64
+}
+
+// PurgeFile deletes a trashed file, its variant files and links and its versions, and
+// schedules the deletions of their objects in one transaction. ErrFileNotTrashed is
+// returned and nothing is changed if the file was restored in the meantime.
+func (r *repository) PurgeFile(ctx context.Context, id int64, deletions []PendingDeletion) error {
+	if err := r.deleteFileRecords(ctx, id, "deleted_at IS NOT NULL", nil, ErrFileNotTrashed, deletions); err != nil {
+		return fmt.Errorf("failed to purge file id %d: %w", id, err)
+	}
+	return nil
+}
+
+// deleteFileRecords deletes a file matching the condition together with its variant
+// files and links, its earlier versions and its share links, and records the deletions
+// of objects nothing else refers to, returning errNotMatched if the file does not match.
+func (r *repository) deleteFileRecords(ctx context.Context, id int64, condition string, args []interface{}, errNotMatched error, deletions []PendingDeletion) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var variantFileIDs []int64
+		if err := tx.Model(&FileVariant{}).Where("parent_file_id = ?", id).Pluck("file_id", &variantFileIDs).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("parent_file_id = ?", id).Delete(&FileVariant{}).Error; err != nil {
+			return err
+		}
+		if len(variantFileIDs) > 0 {
+			if err := tx.Delete(&StorageFile{}, variantFileIDs).Error; err != nil {
+				return err
+			}
+		}
+		if err := tx.Where("file_id = ?", id).Delete(&FileVersion{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("file_id = ?", id).Delete(&FileShare{}).Error; err != nil {
+			return err
+		}
+
+		// Rolls back the variant, version and share deletions above if the file no longer matches
+		result := tx.Where(condition, args...).Delete(&StorageFile{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return errNotMatched
+		}
+
+		deletions, err := unsharedDeletions(tx, deletions)
+		if err != nil || len(deletions) == 0 {
+			return err
+		}
+		return tx.Create(&deletions).Error
+	})
+}
+
+// ListDueDeletions returns deletions whose next attempt is due, longest waiting first
+func (r *repository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]PendingDeletion, error) {
+	var deletions []PendingDeletion
+	err := r.db.WithContext(ctx).
+		Where("next_attempt_at <= ?", now).
+		Order("next_attempt_at, id").
+		Limit(limit).
+		Find(&deletions).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list due deletions: %w", err)
+	}
+	return deletions, nil
+}
+
+// CompleteDeletion removes a deletion whose object is gone
+func (r *repository) CompleteDeletion(ctx context.Context, id int64) error {
+	if err := r.db.WithContext(ctx).Delete(&PendingDeletion{}, id).Error; err != nil {
+		return fmt.Errorf("failed to complete deletion %d: %w", id, err)
+	}
+	return nil
+}
+
+// RetryDeletion stores the attempt count, next attempt time and error of a failed deletion
+func (r *repository) RetryDeletion(ctx context.Context, deletion *PendingDeletion) error {
+	err := r.db.WithContext(ctx).Model(deletion).Updates(map[string]interface{}{
+		"attempts":        deletion.Attempts,
+		"next_attempt_at": deletion.NextAttemptAt,
+		"last_error":      deletion.LastError,
+	}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reschedule deletion %d: %w", deletion.ID, err)
+	}
+	return nil
+}
+
+// GetDeletionStats counts pending deletions and those scheduled before stuckBefore
+func (r *repository) GetDeletionStats(ctx context.Context, stuckBefore time.Time) (DeletionStats, error) {
+	var stats DeletionStats
+	err := r.db.WithContext(ctx).Model(&PendingDeletion{}).
+		Select("COUNT(*) AS pending, COUNT(*) FILTER (WHERE created_at < ?) AS stuck", stuckBefore).
+		Scan(&stats).Error
+	if err != nil {
+		return DeletionStats{}, fmt.Errorf("failed to count pending deletions: %w", err)
+	}
+	return stats, nil
+}
@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// File listing sort fields
+const (
+	FileSortCreatedAt = "createdAt"
+	FileSortFileName  = "fileName"
+	FileSortFileSize  = "fileSize"
+)
+
+// Columns a file listing can be sorted by, keyed by their API names
+var fileSortColumns = map[string]string{
+	FileSortCreatedAt: "created_at",
+	FileSortFileName:  "file_name",
+	FileSortFileSize:  "file_size",
+}
+
+// FileFilter narrows a file listing; zero values apply no filter
+type FileFilter struct {
+	FileType string
+	MimeType string
+	// Case-insensitive substring of the original filename
+	FileName    string
+	MinSize     *int64
+	MaxSize     *int64
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+	// List the trash instead of live files
+	Trashed bool
+}
+
+// FileListQuery selects one page of files. Sort is one of the FileSort constants;
+// ties are broken by ID so pages are stable.
+type FileListQuery struct {
+	FileFilter
+	Sort       string
+	Descending bool
+	Limit      int
+	Offset     int
+}
+
+// ListFiles returns a page of original files (variants are listed with their parent)
+// matching the query, and the number of files matching the filter
+func (r *repository) ListFiles(ctx context.Context, query FileListQuery) ([]StorageFile, int64, error) {
+	column, ok := fileSortColumns[query.Sort]
+	if !ok {
+		return nil, 0, fmt.Errorf("unsupported sort field %q", query.Sort)
+	}
+	direction := "ASC"
+	if query.Descending {
+		direction = "DESC"
+	}
+
+	filtered := r.filterFiles(r.db.WithContext(ctx).Model(&StorageFile{}), query.FileFilter)
+
+	var total int64
+	if err := filtered.Count(&total).Error; err != nil {
+		return nil, 0, fmt.Errorf("failed to count files: %w", err)
+	}
+
+	var files []StorageFile
+	err := filtered.
+		Order(fmt.Sprintf("%s %s, id %s", column, direction, direction)).
+		Limit(query.Limit).
+		Offset(query.Offset).
+		Find(&files).Error
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to list files: %w", err)
+	}
+	return files, total, nil
+}
+
+func (r *repository) filterFiles(db *gorm.DB, filter FileFilter) *gorm.DB {
+	db = r.originalsOnly(db).Where("status = ?", FileStatusActive)
+	if filter.Trashed {
+		db = db.Where("deleted_at IS NOT NULL")
+	} else {
+		db = db.Where("deleted_at IS NULL")
+	}
+
+	if filter.FileType != "" {
+		db = db.Where("file_type = ?", filter.FileType)
+	}
+	if filter.MimeType != "" {
+		db = db.Where("mime_type = ?", filter.MimeType)
+	}
+	if filter.FileName != "" {
+		db = db.Where("file_name ILIKE ?", "%"+escapeLike(filter.FileName)+"%")
+	}
+	if filter.MinSize != nil {
+		db = db.Where("file_size >= ?", *filter.MinSize)
+	}
+	if filter.MaxSize != nil {
+		db = db.Where("file_size <= ?", *filter.MaxSize)
+	}
+	if filter.CreatedFrom != nil {
+		db = db.Where("created_at >= ?", *filter.CreatedFrom)
+	}
+	if filter.CreatedTo != nil {
+		db = db.Where("created_at <= ?", *filter.CreatedTo)
+	}
+	return db.Session(&gorm.Session{})
+}
+
+// originalsOnly excludes variant files, which are listed and deleted with their original
+func (r *repository) originalsOnly(db *gorm.DB) *gorm.DB {
+	return db.Where("NOT EXISTS (?)",
+		r.db.Model(&FileVariant{}).Select("1").Where("file_variants.file_id = files.id"))
+}
+
+// likeEscaper escapes LIKE wildcards using Postgres' default backslash escape
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+func escapeLike(s string) string {
+	return likeEscaper.Replace(s)
+}
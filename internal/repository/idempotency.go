@@ -0,0 +1,165 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Idempotency key states
+const (
+	IdempotencyStatusProcessing = "processing"
+	IdempotencyStatusCompleted  = "completed"
+)
+
+// ResponseHeaders is stored as a JSONB object of header values
+type ResponseHeaders http.Header
+
+func (h ResponseHeaders) Value() (driver.Value, error) {
+	if h == nil {
+		return "{}", nil
+	}
+	b, err := json.Marshal(h)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (h *ResponseHeaders) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*h = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for ResponseHeaders: %T", value)
+	}
+	return json.Unmarshal(data, h)
+}
+
+// IdempotencyKey records a request sent with an Idempotency-Key header, so retries of
+// it get the original response instead of repeating its effect. Keys belong to the user
+// who sent them. Fingerprint identifies the request whose response was stored; it is
+// only known once that request read its whole body.
+type IdempotencyKey struct {
+	UserID      int64  `gorm:"column:user_id;primaryKey"`
+	Key         string `gorm:"column:idempotency_key;primaryKey"`
+	Fingerprint string `gorm:"column:fingerprint"`
+
+	// Processing while the first request runs; a processing key whose LockedUntil passed
+	// belongs to a request that never finished and may be claimed again. LockedUntil also
+	// tells claims apart, so a request can only complete or release its own.
+	Status      string    `gorm:"column:status"`
+	LockedUntil time.Time `gorm:"column:locked_until"`
+
+	// Response of the completed request, replayed for retries
+	ResponseStatus  int             `gorm:"column:response_status"`
+	ResponseHeaders ResponseHeaders `gorm:"column:response_headers;type:jsonb"`
+	ResponseBody    []byte          `gorm:"column:response_body"`
+
+	CreatedAt time.Time `gorm:"column:created_at"`
+	ExpiresAt time.Time `gorm:"column:expires_at"`
+}
+
+func (IdempotencyKey) TableName() string {
+	return "storage.idempotency_keys"
+}
+
+// IsCompleted reports whether the key holds a response to replay
+func (k *IdempotencyKey) IsCompleted() bool {
+	return k.Status == IdempotencyStatusCompleted
+}
+
+// ClaimIdempotencyKey records key as processing for the request it describes. A key
+// already in use is only taken over once it expired or its lock ran out at now; false
+// is returned when it is still held, so concurrent requests with one key cannot both
+// run.
+func (r *repository) ClaimIdempotencyKey(ctx context.Context, key *IdempotencyKey, now time.Time) (bool, error) {
+	key.Status = IdempotencyStatusProcessing
+	// Postgres keeps microseconds, and the claim is later matched on what it stored
+	key.LockedUntil = key.LockedUntil.Truncate(time.Microsecond)
+	result := r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "idempotency_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"fingerprint":      gorm.Expr("excluded.fingerprint"),
+			"status":           IdempotencyStatusProcessing,
+			"locked_until":     gorm.Expr("excluded.locked_until"),
+			"response_status":  0,
+			"response_headers": nil,
+			"response_body":    nil,
+			"created_at":       gorm.Expr("excluded.created_at"),
+			"expires_at":       gorm.Expr("excluded.expires_at"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{
+			gorm.Expr("idempotency_keys.expires_at <= ? OR (idempotency_keys.status = ? AND idempotency_keys.locked_until <= ?)",
+				now, IdempotencyStatusProcessing, now),
+		}},
+	}).Create(key)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to claim idempotency key: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// GetIdempotencyKey returns the user's key, or gorm.ErrRecordNotFound when they have not
+// used it
+func (r *repository) GetIdempotencyKey(ctx context.Context, userID int64, key string) (*IdempotencyKey, error) {
+	var record IdempotencyKey
+	if err := r.db.WithContext(ctx).Where("user_id = ? AND idempotency_key = ?", userID, key).First(&record).Error; err != nil {
+		return nil, fmt.Errorf("failed to get idempotency key of user id %d: %w", userID, err)
+	}
+	return &record, nil
+}
+
+// CompleteIdempotencyKey stores the fingerprint and response of the request that
+// claimed the key. Nothing changes when the claim was taken over since.
+func (r *repository) CompleteIdempotencyKey(ctx context.Context, key *IdempotencyKey) error {
+	err := r.db.WithContext(ctx).Model(&IdempotencyKey{}).
+		Where("user_id = ? AND idempotency_key = ? AND status = ? AND locked_until = ?",
+			key.UserID, key.Key, IdempotencyStatusProcessing, key.LockedUntil).
+		Updates(map[string]interface{}{
+			"fingerprint":      key.Fingerprint,
+			"status":           IdempotencyStatusCompleted,
+			"response_status":  key.ResponseStatus,
+			"response_headers": key.ResponseHeaders,
+			"response_body":    key.ResponseBody,
+		}).Error
+	if err != nil {
+		return fmt.Errorf("failed to complete idempotency key of user id %d: %w", key.UserID, err)
+	}
+	key.Status = IdempotencyStatusCompleted
+	return nil
+}
+
+// ReleaseIdempotencyKey forgets a key whose request failed, so it can be retried.
+// Nothing changes when the claim was taken over since.
+func (r *repository) ReleaseIdempotencyKey(ctx context.Context, key *IdempotencyKey) error {
+	err := r.db.WithContext(ctx).
+		Where("user_id = ? AND idempotency_key = ? AND status = ? AND locked_until = ?",
+			key.UserID, key.Key, IdempotencyStatusProcessing, key.LockedUntil).
+		Delete(&IdempotencyKey{}).Error
+	if err != nil {
+		return fmt.Errorf("failed to release idempotency key of user id %d: %w", key.UserID, err)
+	}
+	return nil
+}
+
+// DeleteExpiredIdempotencyKeys removes keys that expired before now, returning how many
+func (r *repository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).Where("expires_at <= ?", now).Delete(&IdempotencyKey{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
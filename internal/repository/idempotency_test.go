@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Idempotency Key Tests
+// =============================================================================
+
+func TestIdempotencyKey_OnlyTheClaimChangesIt(t *testing.T) {
+	lockedUntil := time.Date(2026, 1, 2, 3, 4, 5, 6000, time.UTC)
+	tests := []struct {
+		name string
+		run  func(r *repository, key *IdempotencyKey) error
+	}{
+		{"complete", func(r *repository, key *IdempotencyKey) error {
+			return r.CompleteIdempotencyKey(t.Context(), key)
+		}},
+		{"release", func(r *repository, key *IdempotencyKey) error {
+			return r.ReleaseIdempotencyKey(t.Context(), key)
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var matched bool
+			db, _ := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResult {
+				if !strings.Contains(query, "locked_until = ") || !strings.Contains(query, "status = ") {
+					t.Errorf("expected the statement to match the claim, got %s", query)
+				}
+				for _, arg := range args {
+					if at, ok := arg.Value.(time.Time); ok && at.Equal(lockedUntil) {
+						matched = true
+					}
+				}
+				// A taken over key matches no row
+				return fakeResult{}
+			})
+			key := &IdempotencyKey{UserID: 1, Key: "key-1", LockedUntil: lockedUntil}
+
+			if err := tt.run(&repository{db: db}, key); err != nil {
+				t.Fatalf("expected a taken over key to be left alone without error, got %v", err)
+			}
+			if !matched {
+				t.Error("expected the claim's locked_until to be bound")
+			}
+		})
+	}
+}
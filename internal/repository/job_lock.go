@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"fmt"
+	"hash/fnv"
+
+	"gorm.io/gorm"
+)
+
+// jobLockClass keys the advisory locks of background jobs, so they cannot collide with
+// other advisory locks on the same database
+const jobLockClass = 0x6a6f6273
+
+// JobLocks takes Postgres advisory locks for background jobs, so replicas sharing the
+// database run each job one at a time. A lock is held on a connection of its own; should
+// the replica die, Postgres releases it with the connection.
+type JobLocks struct {
+	db *gorm.DB
+}
+
+func NewJobLocks(db *gorm.DB) *JobLocks {
+	return &JobLocks{db: db}
+}
+
+// TryLock takes the lock of the named job without waiting, reporting false when another
+// session holds it
+func (l *JobLocks) TryLock(ctx context.Context, name string) (func(), bool, error) {
+	sqlDB, err := l.db.DB()
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock job %s: %w", name, err)
+	}
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to lock job %s: %w", name, err)
+	}
+	key := jobLockKey(name)
+
+	var locked bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1, $2)", jobLockClass, key).Scan(&locked); err != nil {
+		_ = conn.Close()
+		return nil, false, fmt.Errorf("failed to lock job %s: %w", name, err)
+	}
+	if !locked {
+		_ = conn.Close()
+		return nil, false, nil
+	}
+
+	unlock := func() {
+		// Unlocked even when the job stopped because its context was cancelled
+		if _, err := conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1, $2)", jobLockClass, key); err != nil {
+			// A connection still holding the lock must not go back to the pool
+			_ = conn.Raw(func(any) error { return driver.ErrBadConn })
+		}
+		_ = conn.Close()
+	}
+	return unlock, true, nil
+}
+
+// jobLockKey hashes a job name into the key of its advisory lock
+func jobLockKey(name string) int32 {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(name))
+	return int32(h.Sum32())
+}
@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Job Lock Tests
+// =============================================================================
+
+func TestJobLocks_TryLock(t *testing.T) {
+	tests := []struct {
+		name   string
+		locked bool
+	}{
+		{"lock taken", true},
+		{"lock held elsewhere", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var key driver.Value
+			db, fake := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResult {
+				if strings.Contains(query, "pg_try_advisory_lock") {
+					key = args[1].Value
+					return fakeResult{columns: []string{"pg_try_advisory_lock"}, rows: [][]driver.Value{{tt.locked}}}
+				}
+				return fakeResult{}
+			})
+
+			unlock, ok, err := NewJobLocks(db).TryLock(t.Context(), "trash-purge")
+
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if ok != tt.locked {
+				t.Fatalf("expected ok %v, got %v", tt.locked, ok)
+			}
+			if key != int64(jobLockKey("trash-purge")) {
+				t.Errorf("expected the job's key, got %v", key)
+			}
+			if !ok {
+				return
+			}
+			unlock()
+			if !slices.ContainsFunc(fake.ran(), func(query string) bool { return strings.Contains(query, "pg_advisory_unlock") }) {
+				t.Errorf("expected the lock to be released, ran %v", fake.ran())
+			}
+		})
+	}
+}
+
+func TestJobLockKey_DiffersPerJob(t *testing.T) {
+	if jobLockKey("trash-purge") == jobLockKey("upload-cleanup") {
+		t.Error("expected different jobs to take different locks")
+	}
+	if jobLockKey("trash-purge") != jobLockKey("trash-purge") {
+		t.Error("expected a job to take the same lock on every replica")
+	}
+}
@@ -0,0 +1,56 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// File statuses; multipart uploads are recorded as pending before their objects are stored
+const (
+	FileStatusPending = "pending"
+	FileStatusActive  = "active"
+)
+
+// ErrFileNotPending is returned when a pending upload was already activated or discarded
+var ErrFileNotPending = errors.New("file is not pending")
+
+// ActivateFile marks a pending file and its variant files as active once their objects are stored
+func (r *repository) ActivateFile(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).Model(&StorageFile{}).
+		Where("id = ? OR id IN (?)", id, r.variantFileIDs(id)).
+		Where("status = ?", FileStatusPending).
+		Update("status", FileStatusActive)
+	if result.Error != nil {
+		return fmt.Errorf("failed to activate file id %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrFileNotPending
+	}
+	return nil
+}
+
+// DiscardPendingFile deletes a pending file with its variant files and links, and
+// schedules the deletion of whatever objects were stored, in one transaction.
+func (r *repository) DiscardPendingFile(ctx context.Context, id int64, deletions []PendingDeletion) error {
+	err := r.deleteFileRecords(ctx, id, "status = ?", []interface{}{FileStatusPending}, ErrFileNotPending, deletions)
+	if err != nil {
+		return fmt.Errorf("failed to discard pending file id %d: %w", id, err)
+	}
+	return nil
+}
+
+// ListStalePendingFiles returns original files still pending since before the cutoff, oldest first
+func (r *repository) ListStalePendingFiles(ctx context.Context, before time.Time, limit int) ([]StorageFile, error) {
+	var files []StorageFile
+	err := r.originalsOnly(r.db.WithContext(ctx)).
+		Where("status = ? AND created_at < ?", FileStatusPending, before).
+		Order("created_at").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list stale pending files: %w", err)
+	}
+	return files, nil
+}
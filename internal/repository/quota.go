@@ -0,0 +1,82 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// quotaLockClass keys the advisory locks on a user's storage usage, so they cannot
+// collide with other advisory locks on the same database
+const quotaLockClass = 0x66696c65
+
+// StorageUsage totals what one user stores, across all file types and of one file type
+type StorageUsage struct {
+	Files         int64
+	Bytes         int64
+	FileTypeFiles int64
+	FileTypeBytes int64
+}
+
+// QuotaCheck refuses an upload given what its uploader stores without it. Records
+// carrying one are created only if it returns nil; its error is returned unchanged
+// inside the create error.
+type QuotaCheck func(usage StorageUsage) error
+
+// GetStorageUsage totals the files uploaded by the user that are not in the trash,
+// including the earlier versions of their content. Upload sessions still open at now
+// count with their declared length, so resumable and presigned uploads started in
+// parallel cannot get around a quota. Variants are generated by the service and do not
+// count.
+func (r *repository) GetStorageUsage(ctx context.Context, userID int64, fileType string, now time.Time) (StorageUsage, error) {
+	usage, err := r.storageUsage(r.db.WithContext(ctx), userID, fileType, now)
+	if err != nil {
+		return StorageUsage{}, fmt.Errorf("failed to total storage of user id %d: %w", userID, err)
+	}
+	return usage, nil
+}
+
+func (r *repository) storageUsage(db *gorm.DB, userID int64, fileType string, now time.Time) (StorageUsage, error) {
+	files := r.originalsOnly(db.Model(&StorageFile{})).
+		Select("file_type, file_size AS size, 1 AS files").
+		Where("uploaded_by = ? AND deleted_at IS NULL", userID)
+	// Versions count once per object, and not at all when the file's current content
+	// is an earlier version restored
+	versions := db.Table("storage.file_versions AS v").
+		Joins("JOIN storage.files AS f ON f.id = v.file_id").
+		Select("f.file_type, MAX(v.file_size) AS size, 0 AS files").
+		Where("f.uploaded_by = ? AND f.deleted_at IS NULL", userID).
+		Where("v.content_key <> COALESCE(f.content_key, f.s3_key)").
+		Group("f.id, f.file_type, v.content_key")
+	sessions := db.Model(&UploadSession{}).
+		Select("file_type, length AS size, 1 AS files").
+		Where("user_id = ? AND expires_at > ?", userID, now)
+
+	var usage StorageUsage
+	err := db.Raw(`SELECT COALESCE(SUM(files), 0) AS files, COALESCE(SUM(size), 0) AS bytes,
+		COALESCE(SUM(files) FILTER (WHERE file_type = ?), 0) AS file_type_files,
+		COALESCE(SUM(size) FILTER (WHERE file_type = ?), 0) AS file_type_bytes
+		FROM (? UNION ALL ? UNION ALL ?) AS uploads`, fileType, fileType, files, versions, sessions).
+		Scan(&usage).Error
+	return usage, err
+}
+
+// checkQuota runs check against the usage of userID within tx, holding a lock on it
+// until tx ends. Uploads of one user are so checked one at a time, each seeing the
+// records the ones before it created. Nothing is checked without a check or a user.
+func (r *repository) checkQuota(tx *gorm.DB, check QuotaCheck, userID *int64, fileType string) error {
+	if check == nil || userID == nil {
+		return nil
+	}
+	// IDs past the int range wrap around, which only makes some users share a lock
+	if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", quotaLockClass, int32(*userID)).Error; err != nil {
+		return err
+	}
+	usage, err := r.storageUsage(tx, *userID, fileType, time.Now())
+	if err != nil {
+		return err
+	}
+	return check(usage)
+}
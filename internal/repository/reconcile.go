@@ -0,0 +1,63 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+)
+
+// FileStatusMissing marks files whose object was found missing from storage
+const FileStatusMissing = "missing"
+
+// ListBucketFiles returns the files of a bucket after the given object key and ID, ordered
+// by the key of their object in byte order, the order S3 lists objects in, so both can be
+// walked side by side. Files sharing an object are ordered by ID.
+func (r *repository) ListBucketFiles(ctx context.Context, bucket, afterKey string, afterID int64, limit int) ([]StorageFile, error) {
+	var files []StorageFile
+	err := r.db.WithContext(ctx).
+		Where(`s3_bucket = ? AND (`+objectKeyColumn+` COLLATE "C", id) > (?, ?)`, bucket, afterKey, afterID).
+		Order(objectKeyColumn + ` COLLATE "C", id`).
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list files in bucket %s: %w", bucket, err)
+	}
+	return files, nil
+}
+
+// FindKnownObjectKeys returns which of the keys belong to a file, an earlier file version,
+// an upload in progress or a scheduled deletion
+func (r *repository) FindKnownObjectKeys(ctx context.Context, bucket string, keys []string) (map[string]bool, error) {
+	known := make(map[string]bool, len(keys))
+	if len(keys) == 0 {
+		return known, nil
+	}
+
+	db := r.db.WithContext(ctx)
+	files := db.Model(&StorageFile{}).Select(objectKeyColumn).Where("s3_bucket = ? AND "+objectKeyColumn+" IN ?", bucket, keys)
+	versions := db.Model(&FileVersion{}).Select("content_key").Where("s3_bucket = ? AND content_key IN ?", bucket, keys)
+	sessions := db.Model(&UploadSession{}).Select("s3_key").Where("s3_bucket = ? AND s3_key IN ?", bucket, keys)
+	deletions := db.Model(&PendingDeletion{}).Select("s3_key").Where("s3_bucket = ? AND s3_key IN ? AND NOT is_prefix", bucket, keys)
+
+	var found []string
+	if err := db.Raw("? UNION ? UNION ? UNION ?", files, versions, sessions, deletions).Scan(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up object keys in bucket %s: %w", bucket, err)
+	}
+	for _, key := range found {
+		known[key] = true
+	}
+	return known, nil
+}
+
+// MarkFilesMissing flags active files whose object no longer exists; they are no longer served
+func (r *repository) MarkFilesMissing(ctx context.Context, ids []int64) (int64, error) {
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	result := r.db.WithContext(ctx).Model(&StorageFile{}).
+		Where("id IN ? AND status = ?", ids, FileStatusActive).
+		Update("status", FileStatusMissing)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to mark %d files missing: %w", len(ids), result.Error)
+	}
+	return result.RowsAffected, nil
+}
@@ -2,17 +2,79 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"time"
 
-	commonModels "github.com/GunarsK-portfolio/portfolio-common/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
+// ErrFileVersionConflict is returned when a file was modified after the caller read it
+var ErrFileVersionConflict = errors.New("file version conflict")
+
 type Repository interface {
-	CreateFile(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*StorageFile, error)
+	CreateFile(ctx context.Context, file *StorageFile) error
 	GetFileByID(ctx context.Context, id int64) (*StorageFile, error)
 	GetFileByKey(ctx context.Context, bucket, key string) (*StorageFile, error)
-	DeleteFile(ctx context.Context, id int64) error
+	UpdateFileMetadata(ctx context.Context, id, version int64, update FileMetadataUpdate) (*StorageFile, error)
+	ListFiles(ctx context.Context, query FileListQuery) ([]StorageFile, int64, error)
+	GetStorageUsage(ctx context.Context, userID int64, fileType string, now time.Time) (StorageUsage, error)
+
+	ReplaceFileContent(ctx context.Context, id, version int64, content *StorageFile) (*StorageFile, error)
+	RestoreFileVersion(ctx context.Context, id, version, contentVersion int64, check QuotaCheck) (*StorageFile, error)
+	ListFileVersions(ctx context.Context, fileID int64) ([]FileVersion, error)
+
+	CreateFileShare(ctx context.Context, share *FileShare) error
+	GetFileShare(ctx context.Context, fileID, id int64) (*FileShare, error)
+	GetFileShareByToken(ctx context.Context, tokenHash string) (*FileShare, error)
+	ListFileShares(ctx context.Context, fileID int64) ([]FileShare, error)
+	UpdateFileShare(ctx context.Context, share *FileShare) error
+	DeleteFileShare(ctx context.Context, fileID, id int64) error
+	ClaimShareDownload(ctx context.Context, id int64, now time.Time) (*FileShare, error)
+	ReleaseShareDownload(ctx context.Context, id int64) error
+	ClaimSharePasswordAttempt(ctx context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*FileShare, error)
+	ResetSharePasswordFailures(ctx context.Context, id int64) error
+
+	TrashFile(ctx context.Context, id int64, at time.Time) error
+	RestoreFile(ctx context.Context, id int64) error
+	ListTrashedFiles(ctx context.Context, before time.Time, limit int) ([]StorageFile, error)
+	PurgeFile(ctx context.Context, id int64, deletions []PendingDeletion) error
+
+	ActivateFile(ctx context.Context, id int64) error
+	DiscardPendingFile(ctx context.Context, id int64, deletions []PendingDeletion) error
+	ListStalePendingFiles(ctx context.Context, before time.Time, limit int) ([]StorageFile, error)
+
+	ListBucketFiles(ctx context.Context, bucket, afterKey string, afterID int64, limit int) ([]StorageFile, error)
+	FindKnownObjectKeys(ctx context.Context, bucket string, keys []string) (map[string]bool, error)
+	MarkFilesMissing(ctx context.Context, ids []int64) (int64, error)
+
+	ListFilesToScrub(ctx context.Context, verifiedBefore time.Time, limit int) ([]StorageFile, error)
+	RecordScrubResult(ctx context.Context, id int64, hash string, corrupted bool, at time.Time) error
+	GetIntegrityStats(ctx context.Context) (IntegrityStats, error)
+	ListCorruptedFiles(ctx context.Context, limit int) ([]StorageFile, error)
+
+	ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]PendingDeletion, error)
+	CompleteDeletion(ctx context.Context, id int64) error
+	RetryDeletion(ctx context.Context, deletion *PendingDeletion) error
+	GetDeletionStats(ctx context.Context, stuckBefore time.Time) (DeletionStats, error)
+
+	CreateFileWithVariants(ctx context.Context, file *StorageFile, variants []FileVariant) error
+	ListFileVariants(ctx context.Context, parentID int64) ([]FileVariant, error)
+
+	CreateUploadSession(ctx context.Context, session *UploadSession) error
+	GetUploadSession(ctx context.Context, id string) (*UploadSession, error)
+	ClaimUploadPart(ctx context.Context, session *UploadSession) (int, error)
+	UpdateUploadSessionProgress(ctx context.Context, session *UploadSession, expectedOffset int64) error
+	CompleteUploadSession(ctx context.Context, session *UploadSession, file *StorageFile) error
+	DeleteUploadSession(ctx context.Context, id string) error
+	ListExpiredUploadSessions(ctx context.Context, now time.Time, limit int) ([]UploadSession, error)
+
+	ClaimIdempotencyKey(ctx context.Context, key *IdempotencyKey, now time.Time) (bool, error)
+	GetIdempotencyKey(ctx context.Context, userID int64, key string) (*IdempotencyKey, error)
+	CompleteIdempotencyKey(ctx context.Context, key *IdempotencyKey) error
+	ReleaseIdempotencyKey(ctx context.Context, key *IdempotencyKey) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error)
 }
 
 type repository struct {
@@ -23,44 +85,229 @@ func New(db *gorm.DB) Repository {
 	return &repository{db: db}
 }
 
-type StorageFile = commonModels.StorageFile
+// StorageFile mirrors the shared storage.files model plus the columns owned by this service
+type StorageFile struct {
+	ID        int64     `json:"id" gorm:"primaryKey"`
+	S3Key     string    `json:"-" gorm:"column:s3_key"`
+	S3Bucket  string    `json:"-" gorm:"column:s3_bucket"`
+	FileName  string    `json:"fileName" gorm:"column:file_name"`
+	FileSize  int64     `json:"fileSize" gorm:"column:file_size"`
+	MimeType  string    `json:"mimeType" gorm:"column:mime_type"`
+	FileType  string    `json:"fileType" gorm:"column:file_type"`
+	URL       string    `json:"url,omitempty" gorm:"-"` // Computed field
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at"`
+
+	// User who uploaded the file; NULL for files uploaded before uploaders were recorded
+	UploadedBy *int64 `json:"uploadedBy,omitempty" gorm:"column:uploaded_by"`
+
+	// Computed for versioned file types: URL pinned to the current content version
+	VersionURL string `json:"versionUrl,omitempty" gorm:"-"`
+
+	// Decoded image properties; NULL for files that are not images
+	Width       *int    `json:"width,omitempty" gorm:"column:width"`
+	Height      *int    `json:"height,omitempty" gorm:"column:height"`
+	ImageFormat *string `json:"imageFormat,omitempty" gorm:"column:image_format"`
+
+	// Antivirus verdict (clean or infected); NULL when scanning is disabled
+	ScanStatus    *string `json:"scanStatus,omitempty" gorm:"column:scan_status"`
+	ScanSignature *string `json:"-" gorm:"column:scan_signature"`
+
+	// Display metadata edited after upload; NULL when not set
+	AltText *string `json:"altText,omitempty" gorm:"column:alt_text"`
+	Caption *string `json:"caption,omitempty" gorm:"column:caption"`
+	Title   *string `json:"title,omitempty" gorm:"column:title"`
+
+	// Incremented by every metadata update for optimistic concurrency
+	Version   int64     `json:"version" gorm:"column:version;default:1"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at"`
+
+	// Number of the current content version, incremented by every new version, and when
+	// a new version last replaced the content; NULL while the content is the original
+	ContentVersion   int64      `json:"contentVersion" gorm:"column:content_version;default:1"`
+	ContentUpdatedAt *time.Time `json:"contentUpdatedAt,omitempty" gorm:"column:content_updated_at"`
+
+	// Set while the file is in the trash; trashed files are purged after the retention period
+	DeletedAt *time.Time `json:"deletedAt,omitempty" gorm:"column:deleted_at"`
+
+	// Public or private; private files need read access or a signed URL to download
+	Visibility string `json:"visibility" gorm:"column:visibility;default:public"`
+
+	// Pending while a multipart upload is being stored, missing once reconciliation found
+	// the object gone; only active files are served
+	Status string `json:"-" gorm:"column:status;default:active"`
+
+	// Hex SHA-256 of the stored content; files with the same content in a bucket share
+	// one object. NULL for files stored without a hash.
+	ContentHash *string `json:"sha256,omitempty" gorm:"column:sha256"`
+	// Key of the object holding the content when it is not stored under S3Key, because it
+	// is shared with another file or is a later version. S3Key stays the download URL.
+	ContentKey *string `json:"-" gorm:"column:content_key"`
+	// Set on create when the file was pointed at an existing object with the same content
+	SharesObject bool `json:"-" gorm:"-"`
+	// Checked against the uploader's usage when the file is created, or when it is
+	// content for ReplaceFileContent
+	QuotaCheck QuotaCheck `json:"-" gorm:"-"`
+
+	// Hex checksums the client sent with the upload, verified against the content as received
+	UploadMD5    *string `json:"uploadMd5,omitempty" gorm:"column:upload_md5"`
+	UploadSHA256 *string `json:"uploadSha256,omitempty" gorm:"column:upload_sha256"`
+
+	// Set by the integrity scrubber when it last re-hashed the object, and while the
+	// object did not match the stored SHA-256
+	VerifiedAt  *time.Time `json:"verifiedAt,omitempty" gorm:"column:verified_at"`
+	CorruptedAt *time.Time `json:"corruptedAt,omitempty" gorm:"column:corrupted_at"`
+}
+
+func (StorageFile) TableName() string {
+	return "storage.files"
+}
 
-func (r *repository) CreateFile(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*StorageFile, error) {
-	file := &StorageFile{
-		S3Key:    key,
-		S3Bucket: bucket,
-		FileName: fileName,
-		FileSize: fileSize,
-		MimeType: mimeType,
-		FileType: fileType,
+// FileMetadataUpdate lists the metadata to change; nil fields are left unchanged
+// and empty alt text, caption or title clear the value
+type FileMetadataUpdate struct {
+	FileName *string
+	AltText  *string
+	Caption  *string
+	Title    *string
+	// Also applied to the file's variants
+	Visibility *string
+}
+
+// ImageInfo holds the dimensions and format decoded from an image header
+type ImageInfo struct {
+	Width  int
+	Height int
+	Format string
+}
+
+// SetImageInfo records decoded image properties; a nil info leaves the file unchanged
+func (f *StorageFile) SetImageInfo(info *ImageInfo) {
+	if info == nil {
+		return
 	}
+	f.Width = &info.Width
+	f.Height = &info.Height
+	f.ImageFormat = &info.Format
+}
 
-	if err := r.db.WithContext(ctx).Create(file).Error; err != nil {
-		return nil, fmt.Errorf("failed to create file %s in bucket %s: %w", key, bucket, err)
+// ObjectKey returns the key of the object holding the file's current content
+func (f *StorageFile) ObjectKey() string {
+	if f.ContentKey != nil {
+		return *f.ContentKey
 	}
+	return f.S3Key
+}
+
+// IsPending reports whether the file's upload has not been completed
+func (f *StorageFile) IsPending() bool {
+	return f.Status == FileStatusPending
+}
 
-	return file, nil
+// IsTrashed reports whether the file has been deleted and awaits purging
+func (f *StorageFile) IsTrashed() bool {
+	return f.DeletedAt != nil
+}
+
+// IsUploadedBy reports whether the user with userID uploaded the file; nil matches no one
+func (f *StorageFile) IsUploadedBy(userID *int64) bool {
+	return f.UploadedBy != nil && userID != nil && *f.UploadedBy == *userID
+}
+
+// SetScanResult records the antivirus verdict and, for infected files, the detected signature
+func (f *StorageFile) SetScanResult(verdict, signature string) {
+	f.ScanStatus = &verdict
+	f.ScanSignature = nil
+	if signature != "" {
+		f.ScanSignature = &signature
+	}
+}
+
+// CreateFile creates the file record, if its QuotaCheck allows it. A file with a content
+// hash is pointed at an existing object with the same content in its bucket instead
+// (see shareObject).
+func (r *repository) CreateFile(ctx context.Context, file *StorageFile) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.checkQuota(tx, file.QuotaCheck, file.UploadedBy, file.FileType); err != nil {
+			return err
+		}
+		if err := shareObject(tx, file); err != nil {
+			return err
+		}
+		return tx.Create(file).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create file %s in bucket %s: %w", file.S3Key, file.S3Bucket, err)
+	}
+	return nil
 }
 
 func (r *repository) GetFileByID(ctx context.Context, id int64) (*StorageFile, error) {
 	var file StorageFile
-	if err := r.db.WithContext(ctx).First(&file, id).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("status = ?", FileStatusActive).First(&file, id).Error; err != nil {
 		return nil, fmt.Errorf("failed to get file by id %d: %w", id, err)
 	}
 	return &file, nil
 }
 
+// GetFileByKey returns the file downloaded under key; its content may be stored under
+// another key (see ObjectKey)
 func (r *repository) GetFileByKey(ctx context.Context, bucket, key string) (*StorageFile, error) {
 	var file StorageFile
-	if err := r.db.WithContext(ctx).Where("s3_bucket = ? AND s3_key = ?", bucket, key).First(&file).Error; err != nil {
+	err := r.db.WithContext(ctx).
+		Where("s3_bucket = ? AND s3_key = ? AND status = ?", bucket, key, FileStatusActive).
+		First(&file).Error
+	if err != nil {
 		return nil, fmt.Errorf("failed to get file by key %s in bucket %s: %w", key, bucket, err)
 	}
 	return &file, nil
 }
 
-func (r *repository) DeleteFile(ctx context.Context, id int64) error {
-	if err := r.db.WithContext(ctx).Delete(&StorageFile{}, id).Error; err != nil {
-		return fmt.Errorf("failed to delete file id %d: %w", id, err)
+// UpdateFileMetadata applies the update if the file is still at version and returns
+// the updated file. ErrFileVersionConflict is returned when the version has moved on.
+func (r *repository) UpdateFileMetadata(ctx context.Context, id, version int64, update FileMetadataUpdate) (*StorageFile, error) {
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
 	}
-	return nil
+	if update.FileName != nil {
+		updates["file_name"] = *update.FileName
+	}
+	setOptionalText(updates, "alt_text", update.AltText)
+	setOptionalText(updates, "caption", update.Caption)
+	setOptionalText(updates, "title", update.Title)
+	if update.Visibility != nil {
+		updates["visibility"] = *update.Visibility
+	}
+
+	var file StorageFile
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&file).Clauses(clause.Returning{}).
+			Where("id = ? AND version = ?", id, version).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFileVersionConflict
+		}
+		if update.Visibility != nil {
+			return setVariantsVisibility(tx, id, *update.Visibility)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to update file id %d: %w", id, err)
+	}
+	return &file, nil
+}
+
+// setOptionalText stores an empty value as NULL
+func setOptionalText(updates map[string]interface{}, column string, value *string) {
+	if value == nil {
+		return
+	}
+	if *value == "" {
+		updates[column] = nil
+		return
+	}
+	updates[column] = *value
 }
@@ -0,0 +1,195 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrShareUnavailable is returned when a share link expired or used up its downloads
+	// before a download could be counted
+	ErrShareUnavailable = errors.New("share link unavailable")
+	// ErrShareLocked is returned when too many wrong passwords locked a share link
+	// before a password attempt could be counted
+	ErrShareLocked = errors.New("share link locked")
+)
+
+// FileShare is a link giving anyone who has it access to one file until it expires or
+// runs out of downloads. Only the SHA-256 of its token is stored, so the link cannot be
+// recovered from the database; an optional password is stored as a bcrypt hash.
+type FileShare struct {
+	ID           int64   `json:"id" gorm:"primaryKey"`
+	FileID       int64   `json:"fileId" gorm:"column:file_id"`
+	TokenHash    string  `json:"-" gorm:"column:token_hash"`
+	PasswordHash *string `json:"-" gorm:"column:password_hash"`
+
+	// Downloads are refused after ExpiresAt and once DownloadCount reaches MaxDownloads;
+	// a nil MaxDownloads allows any number
+	ExpiresAt     time.Time `json:"expiresAt" gorm:"column:expires_at"`
+	MaxDownloads  *int64    `json:"maxDownloads,omitempty" gorm:"column:max_downloads"`
+	DownloadCount int64     `json:"downloadCount" gorm:"column:download_count;default:0"`
+
+	// Password attempts since the last correct one; reaching the limit locks the link
+	// until LockedUntil
+	FailedAttempts int        `json:"failedAttempts" gorm:"column:failed_attempts;default:0"`
+	LockedUntil    *time.Time `json:"lockedUntil,omitempty" gorm:"column:locked_until"`
+
+	CreatedBy *int64    `json:"createdBy,omitempty" gorm:"column:created_by"`
+	CreatedAt time.Time `json:"createdAt" gorm:"column:created_at"`
+	UpdatedAt time.Time `json:"updatedAt" gorm:"column:updated_at"`
+}
+
+func (FileShare) TableName() string {
+	return "storage.file_shares"
+}
+
+// HasPassword reports whether downloads need the share's password
+func (s *FileShare) HasPassword() bool {
+	return s.PasswordHash != nil
+}
+
+// IsLocked reports whether too many wrong passwords locked the share at now
+func (s *FileShare) IsLocked(now time.Time) bool {
+	return s.LockedUntil != nil && now.Before(*s.LockedUntil)
+}
+
+// IsExhausted reports whether the share has no downloads left
+func (s *FileShare) IsExhausted() bool {
+	return s.MaxDownloads != nil && s.DownloadCount >= *s.MaxDownloads
+}
+
+func (r *repository) CreateFileShare(ctx context.Context, share *FileShare) error {
+	if err := r.db.WithContext(ctx).Create(share).Error; err != nil {
+		return fmt.Errorf("failed to create share of file id %d: %w", share.FileID, err)
+	}
+	return nil
+}
+
+// GetFileShare returns a share of the file, or gorm.ErrRecordNotFound when the file has
+// no share with that ID
+func (r *repository) GetFileShare(ctx context.Context, fileID, id int64) (*FileShare, error) {
+	var share FileShare
+	if err := r.db.WithContext(ctx).Where("id = ? AND file_id = ?", id, fileID).First(&share).Error; err != nil {
+		return nil, fmt.Errorf("failed to get share id %d of file id %d: %w", id, fileID, err)
+	}
+	return &share, nil
+}
+
+func (r *repository) GetFileShareByToken(ctx context.Context, tokenHash string) (*FileShare, error) {
+	var share FileShare
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&share).Error; err != nil {
+		return nil, fmt.Errorf("failed to get share by token: %w", err)
+	}
+	return &share, nil
+}
+
+// ListFileShares returns the shares of a file, newest first
+func (r *repository) ListFileShares(ctx context.Context, fileID int64) ([]FileShare, error) {
+	var shares []FileShare
+	if err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Order("created_at DESC, id DESC").Find(&shares).Error; err != nil {
+		return nil, fmt.Errorf("failed to list shares of file id %d: %w", fileID, err)
+	}
+	return shares, nil
+}
+
+// UpdateFileShare saves the expiry, download limit, password and lock of a share
+func (r *repository) UpdateFileShare(ctx context.Context, share *FileShare) error {
+	result := r.db.WithContext(ctx).Model(share).
+		Where("file_id = ?", share.FileID).
+		Select("expires_at", "max_downloads", "password_hash", "failed_attempts", "locked_until", "updated_at").
+		Updates(share)
+	if result.Error != nil {
+		return fmt.Errorf("failed to update share id %d: %w", share.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to update share id %d: %w", share.ID, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// DeleteFileShare revokes a share of the file
+func (r *repository) DeleteFileShare(ctx context.Context, fileID, id int64) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND file_id = ?", id, fileID).Delete(&FileShare{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete share id %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return fmt.Errorf("failed to delete share id %d: %w", id, gorm.ErrRecordNotFound)
+	}
+	return nil
+}
+
+// ClaimShareDownload counts a download of a share that has not expired at now and has
+// downloads left, returning the updated share. The check and the count are one
+// statement, so concurrent downloads cannot exceed the limit; ErrShareUnavailable is
+// returned when the share no longer allows a download.
+func (r *repository) ClaimShareDownload(ctx context.Context, id int64, now time.Time) (*FileShare, error) {
+	var share FileShare
+	result := r.db.WithContext(ctx).Model(&share).Clauses(clause.Returning{}).
+		Where("id = ? AND expires_at > ?", id, now).
+		Where("max_downloads IS NULL OR download_count < max_downloads").
+		Update("download_count", gorm.Expr("download_count + 1"))
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to count download of share id %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrShareUnavailable
+	}
+	return &share, nil
+}
+
+// ReleaseShareDownload gives back a download counted by ClaimShareDownload when the
+// file could not be sent
+func (r *repository) ReleaseShareDownload(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Model(&FileShare{}).
+		Where("id = ? AND download_count > 0", id).
+		Update("download_count", gorm.Expr("download_count - 1")).Error
+	if err != nil {
+		return fmt.Errorf("failed to release download of share id %d: %w", id, err)
+	}
+	return nil
+}
+
+// ClaimSharePasswordAttempt counts a password attempt on a share that is not locked at
+// now, before the password is compared, and returns the updated share. Reaching
+// maxAttempts locks it until now plus lockout and starts the count over. The check and
+// the count are one statement, so concurrent guesses cannot exceed maxAttempts; a
+// locked share is returned with ErrShareLocked.
+func (r *repository) ClaimSharePasswordAttempt(ctx context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*FileShare, error) {
+	var share FileShare
+	// Both assignments see the attempts counted before this one
+	result := r.db.WithContext(ctx).Model(&share).Clauses(clause.Returning{}).
+		Where("id = ?", id).
+		Where("locked_until IS NULL OR locked_until <= ?", now).
+		Updates(map[string]interface{}{
+			"failed_attempts": gorm.Expr("CASE WHEN failed_attempts + 1 >= ? THEN 0 ELSE failed_attempts + 1 END", maxAttempts),
+			"locked_until":    gorm.Expr("CASE WHEN failed_attempts + 1 >= ? THEN ? ELSE locked_until END", maxAttempts, now.Add(lockout)),
+		})
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to count password attempt of share id %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		if err := r.db.WithContext(ctx).First(&share, id).Error; err != nil {
+			return nil, fmt.Errorf("failed to count password attempt of share id %d: %w", id, err)
+		}
+		return &share, ErrShareLocked
+	}
+	return &share, nil
+}
+
+// ResetSharePasswordFailures clears the password attempts counted for a share after a
+// correct one
+func (r *repository) ResetSharePasswordFailures(ctx context.Context, id int64) error {
+	err := r.db.WithContext(ctx).Model(&FileShare{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{"failed_attempts": 0, "locked_until": nil}).Error
+	if err != nil {
+		return fmt.Errorf("failed to reset password failures of share id %d: %w", id, err)
+	}
+	return nil
+}
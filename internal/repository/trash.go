@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// ErrFileNotTrashed is returned when restoring a file that is not in the trash
+var ErrFileNotTrashed = errors.New("file is not in the trash")
+
+// TrashFile moves the file and its variant files to the trash
+func (r *repository) TrashFile(ctx context.Context, id int64, at time.Time) error {
+	err := r.db.WithContext(ctx).Model(&StorageFile{}).
+		Where("id = ? OR id IN (?)", id, r.variantFileIDs(id)).
+		Where("deleted_at IS NULL").
+		Update("deleted_at", at).Error
+	if err != nil {
+		return fmt.Errorf("failed to trash file id %d: %w", id, err)
+	}
+	return nil
+}
+
+// RestoreFile takes the file and its variant files out of the trash
+func (r *repository) RestoreFile(ctx context.Context, id int64) error {
+	result := r.db.WithContext(ctx).Model(&StorageFile{}).
+		Where("id = ? OR id IN (?)", id, r.variantFileIDs(id)).
+		Where("deleted_at IS NOT NULL").
+		Update("deleted_at", nil)
+	if result.Error != nil {
+		return fmt.Errorf("failed to restore file id %d: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrFileNotTrashed
+	}
+	return nil
+}
+
+// ListTrashedFiles returns original files trashed before the cutoff, oldest first
+func (r *repository) ListTrashedFiles(ctx context.Context, before time.Time, limit int) ([]StorageFile, error) {
+	var files []StorageFile
+	err := r.originalsOnly(r.db.WithContext(ctx)).
+		Where("deleted_at < ?", before).
+		Order("deleted_at").
+		Limit(limit).
+		Find(&files).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to list trashed files: %w", err)
+	}
+	return files, nil
+}
+
+// variantFileIDs selects the IDs of the variant files generated from a file
+func (r *repository) variantFileIDs(parentID int64) *gorm.DB {
+	return r.db.Model(&FileVariant{}).Select("file_id").Where("parent_file_id = ?", parentID)
+}
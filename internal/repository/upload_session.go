@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"database/sql/driver"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Upload session protocols
+const (
+	UploadProtocolTus       = "tus"
+	UploadProtocolPresigned = "presigned"
+)
+
+// ErrUploadOffsetConflict is returned when an upload session was modified concurrently
+var ErrUploadOffsetConflict = errors.New("upload offset conflict")
+
+// ErrUploadSessionCompleted is returned when another request completed or removed the
+// upload session first
+var ErrUploadSessionCompleted = errors.New("upload session already completed")
+
+// UploadPart records a single committed part of an S3 multipart upload
+type UploadPart struct {
+	Number int    `json:"number"`
+	ETag   string `json:"etag"`
+	Size   int64  `json:"size"`
+}
+
+// UploadParts is stored as a JSONB array on the upload session
+type UploadParts []UploadPart
+
+func (p UploadParts) Value() (driver.Value, error) {
+	if p == nil {
+		return "[]", nil
+	}
+	b, err := json.Marshal(p)
+	if err != nil {
+		return nil, err
+	}
+	return string(b), nil
+}
+
+func (p *UploadParts) Scan(value interface{}) error {
+	var data []byte
+	switch v := value.(type) {
+	case nil:
+		*p = nil
+		return nil
+	case []byte:
+		data = v
+	case string:
+		data = []byte(v)
+	default:
+		return fmt.Errorf("unsupported type for UploadParts: %T", value)
+	}
+	return json.Unmarshal(data, p)
+}
+
+// UploadSession tracks a tus or presigned upload until its StorageFile row is created.
+// Presigned sessions have no multipart upload, so S3UploadID, Offset and Parts stay empty.
+type UploadSession struct {
+	ID         string      `gorm:"column:id;primaryKey"`
+	Protocol   string      `gorm:"column:protocol"`
+	S3Bucket   string      `gorm:"column:s3_bucket"`
+	S3Key      string      `gorm:"column:s3_key"`
+	S3UploadID string      `gorm:"column:s3_upload_id"`
+	FileName   string      `gorm:"column:file_name"`
+	FileType   string      `gorm:"column:file_type"`
+	MimeType   string      `gorm:"column:mime_type"`
+	Visibility string      `gorm:"column:visibility;default:public"`
+	Length     int64       `gorm:"column:length"`
+	Offset     int64       `gorm:"column:upload_offset"`
+	Parts      UploadParts `gorm:"column:parts;type:jsonb"`
+	UserID     *int64      `gorm:"column:user_id"`
+	ExpiresAt  time.Time   `gorm:"column:expires_at"`
+	CreatedAt  time.Time   `gorm:"column:created_at"`
+	UpdatedAt  time.Time   `gorm:"column:updated_at"`
+	// Checked against the uploader's usage when the session is created
+	QuotaCheck QuotaCheck `gorm:"-"`
+}
+
+func (UploadSession) TableName() string {
+	return "storage.upload_sessions"
+}
+
+// CreateUploadSession creates the session, if its QuotaCheck allows it
+func (r *repository) CreateUploadSession(ctx context.Context, session *UploadSession) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.checkQuota(tx, session.QuotaCheck, session.UserID, session.FileType); err != nil {
+			return err
+		}
+		return tx.Create(session).Error
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create upload session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+func (r *repository) GetUploadSession(ctx context.Context, id string) (*UploadSession, error) {
+	var session UploadSession
+	if err := r.db.WithContext(ctx).Where("id = ?", id).First(&session).Error; err != nil {
+		return nil, fmt.Errorf("failed to get upload session %s: %w", id, err)
+	}
+	return &session, nil
+}
+
+// UpdateUploadSessionProgress persists the new offset and parts only if the stored
+// offset still equals expectedOffset, guarding against concurrent PATCH requests.
+func (r *repository) UpdateUploadSessionProgress(ctx context.Context, session *UploadSession, expectedOffset int64) error {
+	result := r.db.WithContext(ctx).Model(&UploadSession{}).
+		Where("id = ? AND upload_offset = ?", session.ID, expectedOffset).
+		Updates(map[string]interface{}{
+			"upload_offset": session.Offset,
+			"parts":         session.Parts,
+			"mime_type":     session.MimeType,
+			"updated_at":    time.Now(),
+		})
+	if result.Error != nil {
+		return fmt.Errorf("failed to update upload session %s: %w", session.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadOffsetConflict
+	}
+	return nil
+}
+
+// ClaimUploadPart hands out the S3 part number for the next chunk of a tus upload, if the
+// stored offset still equals session.Offset. Every call gets a number of its own, so
+// PATCH requests racing at the same offset never write to the same part; only the part
+// of the one whose progress is recorded first is completed, and S3 drops the others.
+// It returns ErrUploadOffsetConflict once the offset moved on.
+func (r *repository) ClaimUploadPart(ctx context.Context, session *UploadSession) (int, error) {
+	var number int
+	// Numbers continue after the recorded parts for sessions started before the counter
+	result := r.db.WithContext(ctx).Raw(`UPDATE storage.upload_sessions
+		SET last_part_number = GREATEST(last_part_number, ?) + 1
+		WHERE id = ? AND upload_offset = ?
+		RETURNING last_part_number`, len(session.Parts), session.ID, session.Offset).
+		Scan(&number)
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to claim part of upload session %s: %w", session.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return 0, ErrUploadOffsetConflict
+	}
+	return number, nil
+}
+
+// NewFile returns the StorageFile row described by the session
+func (s *UploadSession) NewFile() *StorageFile {
+	return &StorageFile{
+		S3Key:      s.S3Key,
+		S3Bucket:   s.S3Bucket,
+		FileName:   s.FileName,
+		FileSize:   s.Length,
+		MimeType:   s.MimeType,
+		FileType:   s.FileType,
+		Visibility: s.Visibility,
+		UploadedBy: s.UserID,
+	}
+}
+
+// CompleteUploadSession removes the session and creates the StorageFile row in one transaction.
+// The session is deleted first, so of two concurrent completions only one creates a file;
+// the other gets ErrUploadSessionCompleted. A file with a content hash is pointed at an
+// existing object with the same content (see shareObject) and the uploaded object is
+// handed to the deletion worker.
+func (r *repository) CompleteUploadSession(ctx context.Context, session *UploadSession, file *StorageFile) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("id = ?", session.ID).Delete(&UploadSession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrUploadSessionCompleted
+		}
+		if err := shareObject(tx, file); err != nil {
+			return err
+		}
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		if !file.SharesObject {
+			return nil
+		}
+		return tx.Create(&PendingDeletion{
+			FileID:        file.ID,
+			S3Bucket:      file.S3Bucket,
+			S3Key:         file.S3Key,
+			NextAttemptAt: time.Now(),
+		}).Error
+	})
+	if errors.Is(err, ErrUploadSessionCompleted) {
+		return err
+	}
+	if err != nil {
+		return fmt.Errorf("failed to complete upload session %s: %w", session.ID, err)
+	}
+	return nil
+}
+
+// DeleteUploadSession removes the session, claiming its upload like CompleteUploadSession
+// does: it returns ErrUploadSessionCompleted when the session is already gone, in which
+// case its object may belong to a file and must be left alone.
+func (r *repository) DeleteUploadSession(ctx context.Context, id string) error {
+	result := r.db.WithContext(ctx).Where("id = ?", id).Delete(&UploadSession{})
+	if result.Error != nil {
+		return fmt.Errorf("failed to delete upload session %s: %w", id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrUploadSessionCompleted
+	}
+	return nil
+}
+
+func (r *repository) ListExpiredUploadSessions(ctx context.Context, now time.Time, limit int) ([]UploadSession, error) {
+	var sessions []UploadSession
+	if err := r.db.WithContext(ctx).Where("expires_at < ?", now).Order("expires_at").Limit(limit).Find(&sessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list expired upload sessions: %w", err)
+	}
+	return sessions, nil
+}
@@ -0,0 +1,51 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Upload Part Claim Tests
+// =============================================================================
+
+func TestClaimUploadPart(t *testing.T) {
+	tests := []struct {
+		name        string
+		storedAt    int64
+		lastPart    int64
+		expected    int
+		expectedErr error
+	}{
+		{name: "next number", lastPart: 2, expected: 3},
+		{name: "continues after recorded parts", lastPart: 0, expected: 2},
+		{name: "offset moved on", storedAt: 1024, lastPart: 2, expectedErr: ErrUploadOffsetConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			db, _ := openFakeDB(t, func(query string, args []driver.NamedValue) fakeResult {
+				if !strings.Contains(query, "upload_offset = $3") {
+					t.Errorf("expected the claim to be conditional on the offset, got %s", query)
+				}
+				result := fakeResult{columns: []string{"last_part_number"}}
+				if args[2].Value.(int64) == tt.storedAt {
+					result.rows = [][]driver.Value{{max(tt.lastPart, args[0].Value.(int64)) + 1}}
+				}
+				return result
+			})
+			session := &UploadSession{ID: "session", Parts: UploadParts{{Number: 1, Size: 1024}}}
+
+			number, err := (&repository{db: db}).ClaimUploadPart(t.Context(), session)
+
+			if !errors.Is(err, tt.expectedErr) {
+				t.Fatalf("expected error %v, got %v", tt.expectedErr, err)
+			}
+			if number != tt.expected {
+				t.Errorf("expected part number %d, got %d", tt.expected, number)
+			}
+		})
+	}
+}
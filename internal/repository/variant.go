@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// FileVariant links a generated image rendition, stored as its own StorageFile row, to the original
+type FileVariant struct {
+	ID           int64       `gorm:"column:id;primaryKey"`
+	ParentFileID int64       `gorm:"column:parent_file_id"`
+	FileID       int64       `gorm:"column:file_id"`
+	Name         string      `gorm:"column:name"`
+	Width        int         `gorm:"column:width"`
+	Height       int         `gorm:"column:height"`
+	CreatedAt    time.Time   `gorm:"column:created_at"`
+	File         StorageFile `gorm:"foreignKey:FileID"`
+}
+
+func (FileVariant) TableName() string {
+	return "storage.file_variants"
+}
+
+// CreateFileWithVariants creates the original file, its variant files and the links in one transaction.
+// Each variant's File must be populated; IDs are filled in on success. Like CreateFile, the original's
+// QuotaCheck must allow it and files with a content hash share existing objects with the same content.
+func (r *repository) CreateFileWithVariants(ctx context.Context, file *StorageFile, variants []FileVariant) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := r.checkQuota(tx, file.QuotaCheck, file.UploadedBy, file.FileType); err != nil {
+			return err
+		}
+		if err := shareObject(tx, file); err != nil {
+			return err
+		}
+		if err := tx.Create(file).Error; err != nil {
+			return err
+		}
+		for i := range variants {
+			variant := &variants[i]
+			if err := shareObject(tx, &variant.File); err != nil {
+				return err
+			}
+			if err := tx.Create(&variant.File).Error; err != nil {
+				return err
+			}
+			variant.ParentFileID = file.ID
+			variant.FileID = variant.File.ID
+			if err := tx.Omit("File").Create(variant).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("failed to create file %s with variants: %w", file.S3Key, err)
+	}
+	return nil
+}
+
+func (r *repository) ListFileVariants(ctx context.Context, parentID int64) ([]FileVariant, error) {
+	var variants []FileVariant
+	if err := r.db.WithContext(ctx).Preload("File").Where("parent_file_id = ?", parentID).Order("id").Find(&variants).Error; err != nil {
+		return nil, fmt.Errorf("failed to list variants of file id %d: %w", parentID, err)
+	}
+	return variants, nil
+}
@@ -0,0 +1,188 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// FileVersion is content a file had before a new version replaced it. Each version is
+// stored under a key of its own and the file row pointed at it, so replaced objects are
+// kept unchanged and the file's ID and URL stay the same.
+type FileVersion struct {
+	ID         int64  `json:"-" gorm:"primaryKey"`
+	FileID     int64  `json:"-" gorm:"column:file_id"`
+	Version    int64  `json:"version" gorm:"column:version"`
+	S3Bucket   string `json:"-" gorm:"column:s3_bucket"`
+	ContentKey string `json:"-" gorm:"column:content_key"`
+	FileSize   int64  `json:"fileSize" gorm:"column:file_size"`
+	MimeType   string `json:"mimeType" gorm:"column:mime_type"`
+
+	ContentHash *string `json:"sha256,omitempty" gorm:"column:sha256"`
+	Width       *int    `json:"width,omitempty" gorm:"column:width"`
+	Height      *int    `json:"height,omitempty" gorm:"column:height"`
+	ImageFormat *string `json:"imageFormat,omitempty" gorm:"column:image_format"`
+	ScanStatus  *string `json:"scanStatus,omitempty" gorm:"column:scan_status"`
+
+	// When the content was uploaded, and when a later version replaced it; nil for the
+	// current version
+	UploadedAt time.Time  `json:"uploadedAt" gorm:"column:uploaded_at"`
+	ReplacedAt *time.Time `json:"replacedAt,omitempty" gorm:"column:replaced_at"`
+}
+
+func (FileVersion) TableName() string {
+	return "storage.file_versions"
+}
+
+// CurrentVersion describes the file's current content as a version
+func (f *StorageFile) CurrentVersion() FileVersion {
+	uploadedAt := f.CreatedAt
+	if f.ContentUpdatedAt != nil {
+		uploadedAt = *f.ContentUpdatedAt
+	}
+	return FileVersion{
+		FileID:      f.ID,
+		Version:     f.ContentVersion,
+		S3Bucket:    f.S3Bucket,
+		ContentKey:  f.ObjectKey(),
+		FileSize:    f.FileSize,
+		MimeType:    f.MimeType,
+		ContentHash: f.ContentHash,
+		Width:       f.Width,
+		Height:      f.Height,
+		ImageFormat: f.ImageFormat,
+		ScanStatus:  f.ScanStatus,
+		UploadedAt:  uploadedAt,
+	}
+}
+
+// content describes the version as new content for its file
+func (v *FileVersion) content() *StorageFile {
+	return &StorageFile{
+		ContentKey:  &v.ContentKey,
+		FileSize:    v.FileSize,
+		MimeType:    v.MimeType,
+		ContentHash: v.ContentHash,
+		Width:       v.Width,
+		Height:      v.Height,
+		ImageFormat: v.ImageFormat,
+		ScanStatus:  v.ScanStatus,
+	}
+}
+
+// ReplaceFileContent makes content the new version of an active file outside the trash
+// and keeps the replaced content as an earlier version. The object must already be
+// stored under content.ContentKey; its size, MIME type, hash, image properties, scan
+// result and upload checksums are taken from content, whose QuotaCheck must allow it
+// for the file's uploader. A non-zero version must match the file's metadata version,
+// or ErrFileVersionConflict is returned.
+func (r *repository) ReplaceFileContent(ctx context.Context, id, version int64, content *StorageFile) (*StorageFile, error) {
+	var file *StorageFile
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if content.QuotaCheck != nil {
+			// The usage is locked before the file row, in the order uploads take the locks
+			var owner StorageFile
+			if err := tx.Select("uploaded_by", "file_type").First(&owner, id).Error; err != nil {
+				return err
+			}
+			if err := r.checkQuota(tx, content.QuotaCheck, owner.UploadedBy, owner.FileType); err != nil {
+				return err
+			}
+		}
+		var err error
+		file, err = replaceContent(tx, id, version, content)
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to replace content of file id %d: %w", id, err)
+	}
+	return file, nil
+}
+
+// RestoreFileVersion makes the content of an earlier version current again as a new
+// version, so the history is kept. version is checked as in ReplaceFileContent, and a
+// non-nil check must allow the restore for the file's uploader.
+func (r *repository) RestoreFileVersion(ctx context.Context, id, version, contentVersion int64, check QuotaCheck) (*StorageFile, error) {
+	var file *StorageFile
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var earlier FileVersion
+		if err := tx.Where("file_id = ? AND version = ?", id, contentVersion).First(&earlier).Error; err != nil {
+			return err
+		}
+		if check != nil {
+			// Locked before the file row, as in ReplaceFileContent
+			var owner StorageFile
+			if err := tx.Select("uploaded_by", "file_type").First(&owner, id).Error; err != nil {
+				return err
+			}
+			if err := r.checkQuota(tx, check, owner.UploadedBy, owner.FileType); err != nil {
+				return err
+			}
+		}
+		var err error
+		file, err = replaceContent(tx, id, version, earlier.content())
+		return err
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to restore version %d of file id %d: %w", contentVersion, id, err)
+	}
+	return file, nil
+}
+
+// ListFileVersions returns the earlier versions of a file, newest first
+func (r *repository) ListFileVersions(ctx context.Context, fileID int64) ([]FileVersion, error) {
+	var versions []FileVersion
+	if err := r.db.WithContext(ctx).Where("file_id = ?", fileID).Order("version DESC").Find(&versions).Error; err != nil {
+		return nil, fmt.Errorf("failed to list versions of file id %d: %w", fileID, err)
+	}
+	return versions, nil
+}
+
+// replaceContent locks the file row, so concurrent versions are applied one at a time,
+// records its current content as a version and points the row at content
+func replaceContent(tx *gorm.DB, id, version int64, content *StorageFile) (*StorageFile, error) {
+	var file StorageFile
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Where("status = ? AND deleted_at IS NULL", FileStatusActive).
+		First(&file, id).Error
+	if err != nil {
+		return nil, err
+	}
+	if version != 0 && file.Version != version {
+		return nil, ErrFileVersionConflict
+	}
+
+	now := time.Now()
+	replaced := file.CurrentVersion()
+	replaced.ReplacedAt = &now
+	if err := tx.Create(&replaced).Error; err != nil {
+		return nil, err
+	}
+
+	// The new content has not been verified by the integrity scrubber yet
+	err = tx.Model(&file).Clauses(clause.Returning{}).Updates(map[string]interface{}{
+		"content_key":        content.ContentKey,
+		"file_size":          content.FileSize,
+		"mime_type":          content.MimeType,
+		"sha256":             content.ContentHash,
+		"width":              content.Width,
+		"height":             content.Height,
+		"image_format":       content.ImageFormat,
+		"scan_status":        content.ScanStatus,
+		"scan_signature":     content.ScanSignature,
+		"upload_md5":         content.UploadMD5,
+		"upload_sha256":      content.UploadSHA256,
+		"verified_at":        nil,
+		"corrupted_at":       nil,
+		"content_version":    file.ContentVersion + 1,
+		"content_updated_at": now,
+		"version":            gorm.Expr("version + 1"),
+	}).Error
+	if err != nil {
+		return nil, err
+	}
+	return &file, nil
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"database/sql/driver"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// =============================================================================
+// Version Restore Tests
+// =============================================================================
+
+func TestRestoreFileVersion_QuotaCheck(t *testing.T) {
+	refused := errors.New("over quota")
+	db, fake := openFakeDB(t, func(query string, _ []driver.NamedValue) fakeResult {
+		switch {
+		case strings.Contains(query, `FROM "storage"."file_versions"`):
+			return fakeResult{columns: []string{"version", "content_key", "file_size"}, rows: [][]driver.Value{{int64(1), "v1.pdf", int64(1000)}}}
+		case strings.Contains(query, `SELECT "uploaded_by","file_type"`):
+			return fakeResult{columns: []string{"uploaded_by", "file_type"}, rows: [][]driver.Value{{int64(7), "document"}}}
+		case strings.Contains(query, "UNION ALL"):
+			return fakeResult{columns: []string{"files", "bytes"}, rows: [][]driver.Value{{int64(4), int64(9500)}}}
+		}
+		return fakeResult{}
+	})
+	var checked StorageUsage
+	check := func(usage StorageUsage) error {
+		checked = usage
+		return refused
+	}
+
+	_, err := (&repository{db: db}).RestoreFileVersion(t.Context(), 1, 0, 1, check)
+
+	if !errors.Is(err, refused) {
+		t.Fatalf("expected the check's refusal, got %v", err)
+	}
+	if checked.Bytes != 9500 {
+		t.Errorf("expected the uploader's usage to be checked, got %+v", checked)
+	}
+	ran := fake.ran()
+	locked := slices.IndexFunc(ran, func(query string) bool { return strings.Contains(query, "pg_advisory_xact_lock") })
+	if locked < 0 {
+		t.Errorf("expected the usage to be locked, ran %v", ran)
+	}
+	if slices.ContainsFunc(ran, func(query string) bool {
+		return strings.Contains(query, "FOR UPDATE") || strings.HasPrefix(query, "UPDATE")
+	}) {
+		t.Errorf("expected a refused restore to leave the file alone, ran %v", ran)
+	}
+}
@@ -0,0 +1,26 @@
+package repository
+
+import "gorm.io/gorm"
+
+// File visibilities; private files are only served to callers with read access to files
+// or with a signed download URL
+const (
+	FileVisibilityPublic  = "public"
+	FileVisibilityPrivate = "private"
+)
+
+// IsPrivate reports whether downloads of the file need authorization
+func (f *StorageFile) IsPrivate() bool {
+	return f.Visibility == FileVisibilityPrivate
+}
+
+// setVariantsVisibility gives the variants of a file its visibility, so a private
+// original cannot be downloaded through one of its renditions. Their versions are bumped
+// like the original's, so the ETags of the variant records change too.
+func setVariantsVisibility(tx *gorm.DB, parentID int64, visibility string) error {
+	variantIDs := tx.Model(&FileVariant{}).Select("file_id").Where("parent_file_id = ?", parentID)
+	return tx.Model(&StorageFile{}).Where("id IN (?)", variantIDs).Updates(map[string]interface{}{
+		"visibility": visibility,
+		"version":    gorm.Expr("version + 1"),
+	}).Error
+}
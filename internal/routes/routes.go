@@ -2,6 +2,8 @@ package routes
 
 import (
 	"log"
+	"strconv"
+	"strings"
 
 	"github.com/GunarsK-portfolio/files-api/docs"
 	"github.com/GunarsK-portfolio/files-api/internal/config"
@@ -20,8 +22,8 @@ func Setup(router *gin.Engine, handler *handlers.Handler, cfg *config.Config, me
 	// Security middleware with CORS validation
 	securityMiddleware := common.NewSecurityMiddleware(
 		cfg.AllowedOrigins,
-		"GET,POST,DELETE,OPTIONS",
-		"Content-Type,Authorization",
+		"GET,HEAD,POST,PUT,PATCH,DELETE,OPTIONS",
+		"Content-Type,Authorization,Range,If-Match,If-None-Match,If-Modified-Since,If-Range,Idempotency-Key,Tus-Resumable,Upload-Length,Upload-Offset,Upload-Metadata",
 		true,
 	)
 	router.Use(securityMiddleware.Apply())
@@ -34,20 +36,84 @@ func Setup(router *gin.Engine, handler *handlers.Handler, cfg *config.Config, me
 	// API v1 routes
 	v1 := router.Group("/api/v1")
 	{
-		// Public routes (no auth)
-		v1.GET("/files/:fileType/*key", handler.DownloadFile)
-
-		// Protected routes (JWT required)
 		jwtService, err := jwt.NewValidatorOnly(cfg.JWTSecret)
 		if err != nil {
 			log.Fatalf("Failed to create JWT service: %v", err)
 		}
 		authMiddleware := common.NewAuthMiddleware(jwtService)
+
+		// Public routes (no auth; private files need a token or a signed URL). The version
+		// history and share links of a file are read through the download route.
+		readFile := func(handler gin.HandlerFunc) []gin.HandlerFunc {
+			return []gin.HandlerFunc{authMiddleware.ValidateToken(), common.RequirePermission(common.ResourceFiles, common.LevelRead), handler}
+		}
+		v1.GET("/files/:fileType/*key", withSubroutes(
+			[]fileSubroute{
+				{"/versions", readFile(handler.ListFileVersions)},
+				{"/shares", readFile(handler.ListFileShares)},
+				{"/shares/:shareId", readFile(handler.GetFileShare)},
+			},
+			optionalAuth(jwtService), handler.DownloadFile,
+		)...)
+		v1.HEAD("/files/:fileType/*key", optionalAuth(jwtService), handler.DownloadFile)
+
+		// Share links; the link is the credential, checked by the handler
+		v1.GET("/s/:token", handler.DownloadSharedFile)
+		v1.HEAD("/s/:token", handler.DownloadSharedFile)
+
+		// Protected routes (JWT required)
 		protected := v1.Group("/")
 		protected.Use(authMiddleware.ValidateToken())
 		{
-			protected.POST("/files", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UploadFile)
-			protected.DELETE("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelDelete), handler.DeleteFile)
+			// Starting an upload takes a token from the user's upload rate limit
+			limitUploads := handler.RateLimitUploads()
+			// Requests with an Idempotency-Key header can be retried without repeating their effect
+			idempotent := handler.Idempotent()
+
+			protected.GET("/files", common.RequirePermission(common.ResourceFiles, common.LevelRead), handler.ListFiles)
+			protected.POST("/files", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, limitUploads, handler.UploadFile)
+			// Batches take a token from the upload rate limit per file, in the handler
+			protected.POST("/files/batch", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.UploadFiles)
+			// gin needs the download route's wildcard name in this segment, so the ID is aliased
+			protected.GET("/files/:fileType", common.RequirePermission(common.ResourceFiles, common.LevelRead), aliasParam("fileType", "id"), handler.GetFile)
+			protected.PATCH("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UpdateFile)
+			// Edit access only changes and deletes the user's own uploads; the handlers check ownership
+			protected.DELETE("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.DeleteFile)
+			protected.POST("/files/:id/restore", common.RequirePermission(common.ResourceFiles, common.LevelDelete), idempotent, handler.RestoreFile)
+			protected.POST("/files/:id/signed-url", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.CreateSignedURL)
+
+			// Content versions
+			protected.PUT("/files/:id/content", common.RequirePermission(common.ResourceFiles, common.LevelEdit), limitUploads, handler.UploadFileVersion)
+			protected.POST("/files/:id/versions/:version/restore", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.RestoreFileVersion)
+
+			// Share links
+			protected.POST("/files/:id/shares", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.CreateFileShare)
+			protected.PATCH("/files/:id/shares/:shareId", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UpdateFileShare)
+			protected.DELETE("/files/:id/shares/:shareId", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.DeleteFileShare)
+
+			// Direct-to-S3 uploads via presigned URLs
+			protected.POST("/files/uploads", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, limitUploads, handler.CreatePresignedUpload)
+			protected.POST("/files/uploads/:id/complete", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.CompletePresignedUpload)
+
+			// Resumable uploads (tus 1.0)
+			tus := protected.Group("/files/tus")
+			// All tus endpoints require edit permission; protocol checks run after authorization
+			tus.Use(common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.TusProtocol())
+			{
+				tus.POST("", limitUploads, handler.CreateTusUpload)
+				tus.HEAD("/:id", handler.GetTusUploadStatus)
+				tus.PATCH("/:id", handler.PatchTusUpload)
+				tus.DELETE("/:id", handler.TerminateTusUpload)
+			}
+
+			// Storage and database reconciliation
+			admin := protected.Group("/admin")
+			admin.Use(common.RequirePermission(common.ResourceFiles, common.LevelDelete))
+			{
+				admin.GET("/reconciliation", handler.GetReconciliationReport)
+				admin.POST("/reconciliation", handler.RunReconciliation)
+				admin.GET("/integrity", handler.GetIntegrityReport)
+			}
 		}
 	}
 
@@ -57,3 +123,111 @@ func Setup(router *gin.Engine, handler *handlers.Handler, cfg *config.Config, me
 		router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 	}
 }
+
+// fileSubroute is a GET route below /files/{id} that gin cannot register next to the
+// download wildcard
+type fileSubroute struct {
+	// Path below the file; segments starting with : are parameters
+	path     string
+	handlers []gin.HandlerFunc
+}
+
+// subrouteKey holds the index of the subroute a download route request matched
+const subrouteKey = "files_subroute"
+
+// downloadSubroute marks download route requests that are downloads
+const downloadSubroute = -1
+
+// withSubroutes builds the download route's handlers. gin cannot register GET routes
+// below /files/{id} next to the download wildcard, so the route serves them too: each
+// subroute's handlers only run for its path, with the file ID as the id parameter, and
+// the download handlers for all other requests.
+func withSubroutes(subroutes []fileSubroute, download ...gin.HandlerFunc) []gin.HandlerFunc {
+	chain := []gin.HandlerFunc{func(c *gin.Context) {
+		c.Set(subrouteKey, matchSubroute(c, subroutes))
+	}}
+	for i, route := range subroutes {
+		for _, handler := range route.handlers {
+			chain = append(chain, onlyFor(i, handler))
+		}
+	}
+	for _, handler := range download {
+		chain = append(chain, onlyFor(downloadSubroute, handler))
+	}
+	return chain
+}
+
+// onlyFor runs handler for requests that matched the subroute with the given index
+func onlyFor(subroute int, handler gin.HandlerFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.GetInt(subrouteKey) == subroute {
+			handler(c)
+		}
+	}
+}
+
+// matchSubroute returns the index of the subroute a download route request is for, adding
+// its parameters, or downloadSubroute when it is a download
+func matchSubroute(c *gin.Context, subroutes []fileSubroute) int {
+	id := c.Param("fileType")
+	if _, err := strconv.ParseInt(id, 10, 64); err != nil {
+		return downloadSubroute
+	}
+	segments := strings.Split(strings.TrimPrefix(c.Param("key"), "/"), "/")
+	for i, route := range subroutes {
+		pattern := strings.Split(strings.TrimPrefix(route.path, "/"), "/")
+		if len(pattern) != len(segments) {
+			continue
+		}
+		params := gin.Params{{Key: "id", Value: id}}
+		for j, part := range pattern {
+			if name, ok := strings.CutPrefix(part, ":"); ok {
+				params = append(params, gin.Param{Key: name, Value: segments[j]})
+			} else if part != segments[j] {
+				params = nil
+				break
+			}
+		}
+		if params != nil {
+			c.Params = append(c.Params, params...)
+			return i
+		}
+	}
+	return downloadSubroute
+}
+
+// optionalAuth stores the claims of a valid token like ValidateToken but lets requests
+// without one through, so public downloads need no token while private ones can check
+// its scopes. An invalid or expired token is ignored rather than rejected.
+func optionalAuth(jwtService jwt.Service) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if token := requestToken(c); token != "" {
+			if claims, err := jwtService.ValidateToken(token); err == nil && claims.GetTTL() > 0 {
+				c.Set("user_id", claims.UserID)
+				c.Set("username", claims.Username)
+				c.Set("scopes", claims.Scopes)
+			}
+		}
+		c.Next()
+	}
+}
+
+// requestToken finds the access token where ValidateToken looks for it: the
+// access_token cookie, then a Bearer Authorization header
+func requestToken(c *gin.Context) string {
+	if cookie, err := c.Cookie("access_token"); err == nil && cookie != "" {
+		return cookie
+	}
+	if token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer "); ok {
+		return token
+	}
+	return ""
+}
+
+// aliasParam makes the path parameter from also available as to
+func aliasParam(from, to string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.AddParam(to, c.Param(from))
+		c.Next()
+	}
+}
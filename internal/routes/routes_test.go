@@ -1,20 +1,26 @@
 package routes
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"io"
+	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/GunarsK-portfolio/files-api/internal/config"
 	"github.com/GunarsK-portfolio/files-api/internal/handlers"
 	"github.com/GunarsK-portfolio/files-api/internal/repository"
+	"github.com/GunarsK-portfolio/portfolio-common/jwt"
 	common "github.com/GunarsK-portfolio/portfolio-common/middleware"
 	commonrepo "github.com/GunarsK-portfolio/portfolio-common/repository"
 	"github.com/gin-gonic/gin"
 	"github.com/minio/minio-go/v7"
+	"gorm.io/gorm"
 )
 
 func init() {
@@ -26,24 +32,25 @@ func init() {
 // =============================================================================
 
 type mockRepository struct {
-	createFileFunc   func(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*repository.StorageFile, error)
+	createFileFunc   func(ctx context.Context, file *repository.StorageFile) error
 	getFileByIDFunc  func(ctx context.Context, id int64) (*repository.StorageFile, error)
 	getFileByKeyFunc func(ctx context.Context, bucket, key string) (*repository.StorageFile, error)
-	deleteFileFunc   func(ctx context.Context, id int64) error
 }
 
-func (m *mockRepository) CreateFile(ctx context.Context, bucket, key, fileName, fileType string, fileSize int64, mimeType string) (*repository.StorageFile, error) {
+func (m *mockRepository) CreateFile(ctx context.Context, file *repository.StorageFile) error {
 	if m.createFileFunc != nil {
-		return m.createFileFunc(ctx, bucket, key, fileName, fileType, fileSize, mimeType)
+		return m.createFileFunc(ctx, file)
 	}
-	return &repository.StorageFile{ID: 1}, nil
+	file.ID = 1
+	return nil
 }
 
 func (m *mockRepository) GetFileByID(ctx context.Context, id int64) (*repository.StorageFile, error) {
 	if m.getFileByIDFunc != nil {
 		return m.getFileByIDFunc(ctx, id)
 	}
-	return &repository.StorageFile{ID: id, S3Bucket: "test", S3Key: "test.jpg"}, nil
+	uploadedBy := testUserID
+	return &repository.StorageFile{ID: id, S3Bucket: "test", S3Key: "test.jpg", UploadedBy: &uploadedBy}, nil
 }
 
 func (m *mockRepository) GetFileByKey(ctx context.Context, bucket, key string) (*repository.StorageFile, error) {
@@ -53,25 +60,211 @@ func (m *mockRepository) GetFileByKey(ctx context.Context, bucket, key string) (
 	return &repository.StorageFile{ID: 1, S3Bucket: bucket, S3Key: key}, nil
 }
 
-func (m *mockRepository) DeleteFile(ctx context.Context, id int64) error {
-	if m.deleteFileFunc != nil {
-		return m.deleteFileFunc(ctx, id)
-	}
+func (m *mockRepository) UpdateFileMetadata(ctx context.Context, id, version int64, update repository.FileMetadataUpdate) (*repository.StorageFile, error) {
+	return &repository.StorageFile{ID: id, Version: version + 1}, nil
+}
+
+func (m *mockRepository) TrashFile(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+
+func (m *mockRepository) RestoreFile(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) ListTrashedFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) PurgeFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	return nil
+}
+
+func (m *mockRepository) ActivateFile(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) DiscardPendingFile(ctx context.Context, id int64, deletions []repository.PendingDeletion) error {
+	return nil
+}
+
+func (m *mockRepository) ListStalePendingFiles(ctx context.Context, before time.Time, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListBucketFiles(ctx context.Context, bucket, afterKey string, afterID int64, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) FindKnownObjectKeys(ctx context.Context, bucket string, keys []string) (map[string]bool, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) MarkFilesMissing(ctx context.Context, ids []int64) (int64, error) {
+	return 0, nil
+}
+
+func (m *mockRepository) ListFilesToScrub(ctx context.Context, verifiedBefore time.Time, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) RecordScrubResult(ctx context.Context, id int64, hash string, corrupted bool, at time.Time) error {
+	return nil
+}
+
+func (m *mockRepository) GetIntegrityStats(ctx context.Context) (repository.IntegrityStats, error) {
+	return repository.IntegrityStats{}, nil
+}
+
+func (m *mockRepository) ListCorruptedFiles(ctx context.Context, limit int) ([]repository.StorageFile, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ListDueDeletions(ctx context.Context, now time.Time, limit int) ([]repository.PendingDeletion, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) CompleteDeletion(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) RetryDeletion(ctx context.Context, deletion *repository.PendingDeletion) error {
+	return nil
+}
+
+func (m *mockRepository) GetDeletionStats(ctx context.Context, stuckBefore time.Time) (repository.DeletionStats, error) {
+	return repository.DeletionStats{}, nil
+}
+
+func (m *mockRepository) ListFiles(ctx context.Context, query repository.FileListQuery) ([]repository.StorageFile, int64, error) {
+	return nil, 0, nil
+}
+
+func (m *mockRepository) GetStorageUsage(ctx context.Context, userID int64, fileType string, now time.Time) (repository.StorageUsage, error) {
+	return repository.StorageUsage{}, nil
+}
+
+func (m *mockRepository) CreateFileWithVariants(ctx context.Context, file *repository.StorageFile, variants []repository.FileVariant) error {
+	return nil
+}
+
+func (m *mockRepository) ListFileVariants(ctx context.Context, parentID int64) ([]repository.FileVariant, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ReplaceFileContent(ctx context.Context, id, version int64, content *repository.StorageFile) (*repository.StorageFile, error) {
+	return &repository.StorageFile{ID: id}, nil
+}
+
+func (m *mockRepository) RestoreFileVersion(ctx context.Context, id, version, contentVersion int64, check repository.QuotaCheck) (*repository.StorageFile, error) {
+	return &repository.StorageFile{ID: id}, nil
+}
+
+func (m *mockRepository) ListFileVersions(ctx context.Context, fileID int64) ([]repository.FileVersion, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) CreateFileShare(ctx context.Context, share *repository.FileShare) error {
+	return nil
+}
+
+func (m *mockRepository) GetFileShare(ctx context.Context, fileID, id int64) (*repository.FileShare, error) {
+	return &repository.FileShare{ID: id, FileID: fileID}, nil
+}
+
+func (m *mockRepository) GetFileShareByToken(ctx context.Context, tokenHash string) (*repository.FileShare, error) {
+	return nil, gorm.ErrRecordNotFound
+}
+
+func (m *mockRepository) ListFileShares(ctx context.Context, fileID int64) ([]repository.FileShare, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) UpdateFileShare(ctx context.Context, share *repository.FileShare) error {
+	return nil
+}
+
+func (m *mockRepository) DeleteFileShare(ctx context.Context, fileID, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) ClaimShareDownload(ctx context.Context, id int64, now time.Time) (*repository.FileShare, error) {
+	return nil, repository.ErrShareUnavailable
+}
+
+func (m *mockRepository) ReleaseShareDownload(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) ClaimSharePasswordAttempt(ctx context.Context, id int64, maxAttempts int, lockout time.Duration, now time.Time) (*repository.FileShare, error) {
+	return &repository.FileShare{ID: id}, nil
+}
+
+func (m *mockRepository) ResetSharePasswordFailures(ctx context.Context, id int64) error {
+	return nil
+}
+
+func (m *mockRepository) CreateUploadSession(ctx context.Context, session *repository.UploadSession) error {
+	return nil
+}
+
+func (m *mockRepository) GetUploadSession(ctx context.Context, id string) (*repository.UploadSession, error) {
+	return &repository.UploadSession{ID: id, Protocol: repository.UploadProtocolTus, Length: 1, ExpiresAt: time.Now().Add(time.Hour)}, nil
+}
+
+func (m *mockRepository) ClaimUploadPart(ctx context.Context, session *repository.UploadSession) (int, error) {
+	return len(session.Parts) + 1, nil
+}
+
+func (m *mockRepository) UpdateUploadSessionProgress(ctx context.Context, session *repository.UploadSession, expectedOffset int64) error {
+	return nil
+}
+
+func (m *mockRepository) CompleteUploadSession(ctx context.Context, session *repository.UploadSession, file *repository.StorageFile) error {
+	file.ID = 1
+	return nil
+}
+
+func (m *mockRepository) DeleteUploadSession(ctx context.Context, id string) error {
 	return nil
 }
 
+func (m *mockRepository) ListExpiredUploadSessions(ctx context.Context, now time.Time, limit int) ([]repository.UploadSession, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) ClaimIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey, now time.Time) (bool, error) {
+	return true, nil
+}
+
+func (m *mockRepository) GetIdempotencyKey(ctx context.Context, userID int64, key string) (*repository.IdempotencyKey, error) {
+	return nil, nil
+}
+
+func (m *mockRepository) CompleteIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey) error {
+	return nil
+}
+
+func (m *mockRepository) ReleaseIdempotencyKey(ctx context.Context, key *repository.IdempotencyKey) error {
+	return nil
+}
+
+func (m *mockRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
 // =============================================================================
 // Mock Storage
 // =============================================================================
 
 type mockStorage struct {
-	getObjectFunc    func(ctx context.Context, bucket, key string) (*minio.Object, error)
+	getObjectFunc    func(ctx context.Context, bucket, key string) (io.ReadCloser, error)
 	putObjectFunc    func(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) error
 	deleteObjectFunc func(ctx context.Context, bucket, key string) error
 	statObjectFunc   func(ctx context.Context, bucket, key string) (minio.ObjectInfo, error)
 }
 
-func (m *mockStorage) GetObject(ctx context.Context, bucket, key string) (*minio.Object, error) {
+func (m *mockStorage) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	if m.getObjectFunc != nil {
 		return m.getObjectFunc(ctx, bucket, key)
 	}
@@ -92,6 +285,10 @@ func (m *mockStorage) DeleteObject(ctx context.Context, bucket, key string) erro
 	return nil
 }
 
+func (m *mockStorage) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	return nil
+}
+
 func (m *mockStorage) StatObject(ctx context.Context, bucket, key string) (minio.ObjectInfo, error) {
 	if m.statObjectFunc != nil {
 		return m.statObjectFunc(ctx, bucket, key)
@@ -99,6 +296,38 @@ func (m *mockStorage) StatObject(ctx context.Context, bucket, key string) (minio
 	return minio.ObjectInfo{}, nil
 }
 
+func (m *mockStorage) NewMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	return "upload-id", nil
+}
+
+func (m *mockStorage) PutObjectPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (string, error) {
+	return "etag", nil
+}
+
+func (m *mockStorage) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) error {
+	return nil
+}
+
+func (m *mockStorage) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return nil
+}
+
+func (m *mockStorage) GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	return io.NopCloser(strings.NewReader("")), nil
+}
+
+func (m *mockStorage) PresignPutObject(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error) {
+	return "https://s3.example.com/" + bucket + "/" + key, nil
+}
+
+func (m *mockStorage) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	return nil
+}
+
+func (m *mockStorage) ListObjects(ctx context.Context, bucket string, fn func(minio.ObjectInfo) error) error {
+	return nil
+}
+
 // =============================================================================
 // Mock Action Log Repository
 // =============================================================================
@@ -129,8 +358,14 @@ func (m *mockActionLogRepo) CountActionsByResource(resourceType string, resource
 // Test Helpers
 // =============================================================================
 
+// testUserID is the user the routes are called as; the mock repository's files are theirs
+const testUserID = int64(7)
+
+var testUserIDValue = testUserID
+
 func injectScopes(scopes map[string]string) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		c.Set("user_id", testUserID)
 		c.Set("scopes", scopes)
 		c.Next()
 	}
@@ -138,16 +373,72 @@ func injectScopes(scopes map[string]string) gin.HandlerFunc {
 
 func setupRouterWithScopes(t *testing.T, scopes map[string]string) *gin.Engine {
 	t.Helper()
+	return setupRouterWithRepo(t, scopes, &mockRepository{})
+}
+
+func setupRouterWithRepo(t *testing.T, scopes map[string]string, repo *mockRepository) *gin.Engine {
+	t.Helper()
 
 	router := gin.New()
-	cfg := &config.Config{}
-	handler := handlers.New(&mockRepository{}, &mockStorage{}, cfg, &mockActionLogRepo{})
+	cfg := &config.Config{SignedURLSecret: "test-signed-url-secret-of-32-bytes-min"}
+	handler := handlers.New(repo, &mockStorage{}, cfg, &mockActionLogRepo{})
 
 	v1 := router.Group("/api/v1")
 	v1.Use(injectScopes(scopes))
 	{
-		v1.POST("/files", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UploadFile)
-		v1.DELETE("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelDelete), handler.DeleteFile)
+		limitUploads := handler.RateLimitUploads()
+		idempotent := handler.Idempotent()
+
+		v1.GET("/files", common.RequirePermission(common.ResourceFiles, common.LevelRead), handler.ListFiles)
+		v1.POST("/files", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, limitUploads, handler.UploadFile)
+		v1.POST("/files/batch", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.UploadFiles)
+		v1.GET("/files/:fileType", common.RequirePermission(common.ResourceFiles, common.LevelRead), aliasParam("fileType", "id"), handler.GetFile)
+		v1.PATCH("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UpdateFile)
+		v1.DELETE("/files/:id", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.DeleteFile)
+		v1.POST("/files/:id/restore", common.RequirePermission(common.ResourceFiles, common.LevelDelete), idempotent, handler.RestoreFile)
+		v1.POST("/files/:id/signed-url", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.CreateSignedURL)
+
+		// Content versions and share links; their reads share the public download route
+		readFile := func(handler gin.HandlerFunc) []gin.HandlerFunc {
+			return []gin.HandlerFunc{common.RequirePermission(common.ResourceFiles, common.LevelRead), handler}
+		}
+		v1.GET("/files/:fileType/*key", withSubroutes(
+			[]fileSubroute{
+				{"/versions", readFile(handler.ListFileVersions)},
+				{"/shares", readFile(handler.ListFileShares)},
+				{"/shares/:shareId", readFile(handler.GetFileShare)},
+			},
+			handler.DownloadFile,
+		)...)
+		v1.PUT("/files/:id/content", common.RequirePermission(common.ResourceFiles, common.LevelEdit), limitUploads, handler.UploadFileVersion)
+		v1.POST("/files/:id/versions/:version/restore", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.RestoreFileVersion)
+		v1.POST("/files/:id/shares", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.CreateFileShare)
+		v1.PATCH("/files/:id/shares/:shareId", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.UpdateFileShare)
+		v1.DELETE("/files/:id/shares/:shareId", common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.DeleteFileShare)
+		v1.GET("/s/:token", handler.DownloadSharedFile)
+		v1.HEAD("/s/:token", handler.DownloadSharedFile)
+
+		// Direct-to-S3 uploads via presigned URLs
+		v1.POST("/files/uploads", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, limitUploads, handler.CreatePresignedUpload)
+		v1.POST("/files/uploads/:id/complete", common.RequirePermission(common.ResourceFiles, common.LevelEdit), idempotent, handler.CompletePresignedUpload)
+
+		tus := v1.Group("/files/tus")
+		// All tus endpoints require edit permission; protocol checks run after authorization
+		tus.Use(common.RequirePermission(common.ResourceFiles, common.LevelEdit), handler.TusProtocol())
+		{
+			tus.POST("", limitUploads, handler.CreateTusUpload)
+			tus.HEAD("/:id", handler.GetTusUploadStatus)
+			tus.PATCH("/:id", handler.PatchTusUpload)
+			tus.DELETE("/:id", handler.TerminateTusUpload)
+		}
+
+		admin := v1.Group("/admin")
+		admin.Use(common.RequirePermission(common.ResourceFiles, common.LevelDelete))
+		{
+			admin.GET("/reconciliation", handler.GetReconciliationReport)
+			admin.POST("/reconciliation", handler.RunReconciliation)
+			admin.GET("/integrity", handler.GetIntegrityReport)
+		}
 	}
 
 	return router
@@ -176,8 +467,31 @@ type routePermission struct {
 }
 
 var protectedRoutes = []routePermission{
+	{"GET", "/api/v1/files", common.ResourceFiles, common.LevelRead},
 	{"POST", "/api/v1/files", common.ResourceFiles, common.LevelEdit},
-	{"DELETE", "/api/v1/files/1", common.ResourceFiles, common.LevelDelete},
+	{"POST", "/api/v1/files/batch", common.ResourceFiles, common.LevelEdit},
+	{"GET", "/api/v1/files/1", common.ResourceFiles, common.LevelRead},
+	{"PATCH", "/api/v1/files/1", common.ResourceFiles, common.LevelEdit},
+	{"DELETE", "/api/v1/files/1", common.ResourceFiles, common.LevelEdit},
+	{"POST", "/api/v1/files/1/restore", common.ResourceFiles, common.LevelDelete},
+	{"POST", "/api/v1/files/1/signed-url", common.ResourceFiles, common.LevelEdit},
+	{"PUT", "/api/v1/files/1/content", common.ResourceFiles, common.LevelEdit},
+	{"GET", "/api/v1/files/1/versions", common.ResourceFiles, common.LevelRead},
+	{"POST", "/api/v1/files/1/versions/1/restore", common.ResourceFiles, common.LevelEdit},
+	{"GET", "/api/v1/files/1/shares", common.ResourceFiles, common.LevelRead},
+	{"POST", "/api/v1/files/1/shares", common.ResourceFiles, common.LevelEdit},
+	{"GET", "/api/v1/files/1/shares/1", common.ResourceFiles, common.LevelRead},
+	{"PATCH", "/api/v1/files/1/shares/1", common.ResourceFiles, common.LevelEdit},
+	{"DELETE", "/api/v1/files/1/shares/1", common.ResourceFiles, common.LevelEdit},
+	{"POST", "/api/v1/files/uploads", common.ResourceFiles, common.LevelEdit},
+	{"POST", "/api/v1/files/uploads/abc/complete", common.ResourceFiles, common.LevelEdit},
+	{"POST", "/api/v1/files/tus", common.ResourceFiles, common.LevelEdit},
+	{"HEAD", "/api/v1/files/tus/abc", common.ResourceFiles, common.LevelEdit},
+	{"PATCH", "/api/v1/files/tus/abc", common.ResourceFiles, common.LevelEdit},
+	{"DELETE", "/api/v1/files/tus/abc", common.ResourceFiles, common.LevelEdit},
+	{"GET", "/api/v1/admin/reconciliation", common.ResourceFiles, common.LevelDelete},
+	{"POST", "/api/v1/admin/reconciliation", common.ResourceFiles, common.LevelDelete},
+	{"GET", "/api/v1/admin/integrity", common.ResourceFiles, common.LevelDelete},
 }
 
 // =============================================================================
@@ -243,12 +557,24 @@ func TestPermissionHierarchy(t *testing.T) {
 		path       string
 		wantAccess bool
 	}{
-		{"delete grants delete", common.LevelDelete, common.LevelDelete, "DELETE", "/api/v1/files/1", true},
+		{"delete grants delete", common.LevelDelete, common.LevelEdit, "DELETE", "/api/v1/files/1", true},
 		{"delete grants edit", common.LevelDelete, common.LevelEdit, "POST", "/api/v1/files", true},
 		{"edit grants edit", common.LevelEdit, common.LevelEdit, "POST", "/api/v1/files", true},
-		{"edit denies delete", common.LevelEdit, common.LevelDelete, "DELETE", "/api/v1/files/1", false},
+		{"edit grants delete of own file", common.LevelEdit, common.LevelEdit, "DELETE", "/api/v1/files/1", true},
+		{"edit denies restore", common.LevelEdit, common.LevelDelete, "POST", "/api/v1/files/1/restore", false},
+		{"edit denies reconciliation", common.LevelEdit, common.LevelDelete, "POST", "/api/v1/admin/reconciliation", false},
+		{"read grants read", common.LevelRead, common.LevelRead, "GET", "/api/v1/files", true},
+		{"none denies read", common.LevelNone, common.LevelRead, "GET", "/api/v1/files", false},
 		{"read denies edit", common.LevelRead, common.LevelEdit, "POST", "/api/v1/files", false},
-		{"read denies delete", common.LevelRead, common.LevelDelete, "DELETE", "/api/v1/files/1", false},
+		{"read denies metadata edit", common.LevelRead, common.LevelEdit, "PATCH", "/api/v1/files/1", false},
+		{"read denies delete", common.LevelRead, common.LevelEdit, "DELETE", "/api/v1/files/1", false},
+		{"read denies signed URL", common.LevelRead, common.LevelEdit, "POST", "/api/v1/files/1/signed-url", false},
+		{"read grants version history", common.LevelRead, common.LevelRead, "GET", "/api/v1/files/1/versions", true},
+		{"read denies new version", common.LevelRead, common.LevelEdit, "PUT", "/api/v1/files/1/content", false},
+		{"read denies version restore", common.LevelRead, common.LevelEdit, "POST", "/api/v1/files/1/versions/1/restore", false},
+		{"read grants share links", common.LevelRead, common.LevelRead, "GET", "/api/v1/files/1/shares", true},
+		{"read denies share link", common.LevelRead, common.LevelEdit, "POST", "/api/v1/files/1/shares", false},
+		{"edit grants share revocation", common.LevelEdit, common.LevelEdit, "DELETE", "/api/v1/files/1/shares/1", true},
 		{"none denies edit", common.LevelNone, common.LevelEdit, "POST", "/api/v1/files", false},
 	}
 
@@ -267,6 +593,159 @@ func TestPermissionHierarchy(t *testing.T) {
 	}
 }
 
+// =============================================================================
+// Ownership Tests
+// =============================================================================
+
+func TestFileOwnership_PermissionMatrix(t *testing.T) {
+	otherUserID := testUserID + 1
+	// Bodies the handlers accept, so they get as far as the ownership check
+	upload := &bytes.Buffer{}
+	form := multipart.NewWriter(upload)
+	part, err := form.CreateFormFile("file", "a.pdf")
+	if err != nil {
+		t.Fatalf("failed to build upload: %v", err)
+	}
+	_, _ = part.Write([]byte("%PDF-1.4"))
+	if err := form.Close(); err != nil {
+		t.Fatalf("failed to build upload: %v", err)
+	}
+	routes := []struct {
+		method      string
+		path        string
+		body        []byte
+		contentType string
+	}{
+		{"PATCH", "/api/v1/files/1", []byte(`{"title": "New title"}`), "application/json"},
+		{"DELETE", "/api/v1/files/1", nil, ""},
+		{"PUT", "/api/v1/files/1/content", upload.Bytes(), form.FormDataContentType()},
+		{"POST", "/api/v1/files/1/versions/1/restore", nil, ""},
+		{"POST", "/api/v1/files/1/shares", nil, ""},
+		{"PATCH", "/api/v1/files/1/shares/1", []byte(`{"maxDownloads": 3}`), "application/json"},
+		{"DELETE", "/api/v1/files/1/shares/1", nil, ""},
+		{"POST", "/api/v1/files/1/signed-url", nil, ""},
+	}
+	tests := []struct {
+		name       string
+		granted    string
+		uploadedBy *int64
+		wantAccess bool
+	}{
+		{"none on own file", common.LevelNone, &testUserIDValue, false},
+		{"read on own file", common.LevelRead, &testUserIDValue, false},
+		{"edit on own file", common.LevelEdit, &testUserIDValue, true},
+		{"edit on other user's file", common.LevelEdit, &otherUserID, false},
+		{"edit on file without uploader", common.LevelEdit, nil, false},
+		{"delete on own file", common.LevelDelete, &testUserIDValue, true},
+		{"delete on other user's file", common.LevelDelete, &otherUserID, true},
+		{"delete on file without uploader", common.LevelDelete, nil, true},
+	}
+
+	for _, tt := range tests {
+		for _, route := range routes {
+			t.Run(tt.name+" "+route.method+" "+route.path, func(t *testing.T) {
+				repo := &mockRepository{
+					getFileByIDFunc: func(_ context.Context, id int64) (*repository.StorageFile, error) {
+						return &repository.StorageFile{ID: id, S3Bucket: "test", S3Key: "test.jpg", UploadedBy: tt.uploadedBy}, nil
+					},
+				}
+				router := setupRouterWithRepo(t, map[string]string{common.ResourceFiles: tt.granted}, repo)
+				req := httptest.NewRequest(route.method, route.path, bytes.NewReader(route.body))
+				if route.contentType != "" {
+					req.Header.Set("Content-Type", route.contentType)
+				}
+				req.Header.Set("If-Match", `"1-0"`)
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, req)
+
+				gotAccess := w.Code != http.StatusForbidden
+				if gotAccess != tt.wantAccess {
+					t.Errorf("granted=%s: gotAccess=%v wantAccess=%v (status=%d)", tt.granted, gotAccess, tt.wantAccess, w.Code)
+				}
+			})
+		}
+	}
+}
+
+func TestDownloadRoute_StaysPublicBesideVersions(t *testing.T) {
+	// The version history and share links share the download route; downloads must not require scopes
+	for _, path := range []string{"/api/v1/files/images/1/versions", "/api/v1/files/images/a.png", "/api/v1/files/1/a/versions", "/api/v1/files/images/shares/1", "/api/v1/files/1/shares/1/a"} {
+		t.Run(path, func(t *testing.T) {
+			router := setupRouterWithScopes(t, map[string]string{})
+			w := performRequest(t, router, "GET", path)
+
+			if w.Code == http.StatusForbidden {
+				t.Errorf("got 403 Forbidden for public download %s", path)
+			}
+		})
+	}
+}
+
+func TestShareRoute_IsPublic(t *testing.T) {
+	// The share token is the credential; no scopes are needed to reach the handler
+	for _, method := range []string{"GET", "HEAD"} {
+		t.Run(method, func(t *testing.T) {
+			router := setupRouterWithScopes(t, nil)
+			w := performRequest(t, router, method, "/api/v1/s/abc")
+
+			if w.Code != http.StatusNotFound {
+				t.Errorf("status = %d, want %d for an unknown share link", w.Code, http.StatusNotFound)
+			}
+		})
+	}
+}
+
+func TestOptionalAuth(t *testing.T) {
+	const secret = "test-secret-that-is-at-least-32-bytes-long"
+	jwtService, err := jwt.NewService(secret, time.Hour, time.Hour)
+	if err != nil {
+		t.Fatalf("failed to create JWT service: %v", err)
+	}
+	token, err := jwtService.GenerateAccessToken(7, "admin", map[string]string{common.ResourceFiles: common.LevelRead})
+	if err != nil {
+		t.Fatalf("failed to generate token: %v", err)
+	}
+	otherService, _ := jwt.NewService(strings.Repeat("x", 32), time.Hour, time.Hour)
+	foreignToken, _ := otherService.GenerateAccessToken(7, "admin", map[string]string{common.ResourceFiles: common.LevelRead})
+
+	router := gin.New()
+	router.GET("/download", optionalAuth(jwtService), func(c *gin.Context) {
+		scopes, _ := c.Get("scopes")
+		scopesMap, _ := scopes.(map[string]string)
+		c.String(http.StatusOK, scopesMap[common.ResourceFiles])
+	})
+
+	testCases := []struct {
+		name     string
+		header   string
+		cookie   string
+		expected string
+	}{
+		{"no token", "", "", ""},
+		{"bearer token", "Bearer " + token, "", common.LevelRead},
+		{"cookie token", "", token, common.LevelRead},
+		// Public downloads must keep working with a stale or foreign token
+		{"invalid token", "Bearer " + foreignToken, "", ""},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, _ := http.NewRequest(http.MethodGet, "/download", nil)
+			if tc.header != "" {
+				req.Header.Set("Authorization", tc.header)
+			}
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "access_token", Value: tc.cookie})
+			}
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK || w.Body.String() != tc.expected {
+				t.Errorf("status = %d, scopes = %q, want 200 and %q", w.Code, w.Body.String(), tc.expected)
+			}
+		})
+	}
+}
+
 // =============================================================================
 // Middleware Error Handling Tests
 // =============================================================================
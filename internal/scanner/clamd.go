@@ -0,0 +1,230 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/GunarsK-portfolio/portfolio-common/health"
+)
+
+// clamdChunkSize is the largest chunk streamed to clamd in one INSTREAM frame
+const clamdChunkSize = 64 << 10
+
+// ErrScanFailed reports a reply from clamd that is neither clean nor infected,
+// such as a stream exceeding StreamMaxLength
+var ErrScanFailed = errors.New("virus scan failed")
+
+// Clamd scans files with a ClamAV daemon using the INSTREAM command
+type Clamd struct {
+	network string
+	address string
+	timeout time.Duration
+}
+
+// Compile-time check that Clamd implements Scanner.
+var _ Scanner = (*Clamd)(nil)
+
+// NewClamd creates a clamd client for tcp://host:port or unix:///path/to/clamd.sock.
+// The timeout bounds each scan, including the time spent streaming the file.
+func NewClamd(address string, timeout time.Duration) (*Clamd, error) {
+	u, err := url.Parse(address)
+	if err != nil {
+		return nil, fmt.Errorf("invalid clamd address %q: %w", address, err)
+	}
+
+	switch u.Scheme {
+	case "tcp":
+		if u.Host == "" {
+			return nil, fmt.Errorf("invalid clamd address %q: missing host", address)
+		}
+		return &Clamd{network: "tcp", address: u.Host, timeout: timeout}, nil
+	case "unix":
+		if u.Path == "" {
+			return nil, fmt.Errorf("invalid clamd address %q: missing socket path", address)
+		}
+		return &Clamd{network: "unix", address: u.Path, timeout: timeout}, nil
+	default:
+		return nil, fmt.Errorf("invalid clamd address %q: scheme must be tcp or unix", address)
+	}
+}
+
+// Scan streams r to clamd and parses its verdict
+func (c *Clamd) Scan(ctx context.Context, r io.Reader) (Result, error) {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return Result{}, err
+	}
+	defer conn.Close()
+
+	if err := writeStream(conn, r); err != nil {
+		// clamd replies and hangs up when a stream exceeds its size limit
+		var opErr *net.OpError
+		if errors.As(err, &opErr) {
+			if reply, readErr := readReply(conn); readErr == nil {
+				return parseReply(reply)
+			}
+		}
+		return Result{}, fmt.Errorf("failed to stream file to clamd: %w", err)
+	}
+
+	reply, err := readReply(conn)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	return parseReply(reply)
+}
+
+// Ping checks that clamd is reachable and answering commands
+func (c *Clamd) Ping(ctx context.Context) error {
+	conn, err := c.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("zPING\x00")); err != nil {
+		return fmt.Errorf("failed to send PING to clamd: %w", err)
+	}
+	reply, err := readReply(conn)
+	if err != nil {
+		return fmt.Errorf("failed to read clamd reply: %w", err)
+	}
+	if reply != "PONG" {
+		return fmt.Errorf("unexpected clamd PING reply %q", reply)
+	}
+	return nil
+}
+
+// dial connects to clamd, closing the connection early if ctx is cancelled
+func (c *Clamd) dial(ctx context.Context) (net.Conn, error) {
+	ctx, cancel := context.WithTimeout(ctx, c.timeout)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, c.network, c.address)
+	if err != nil {
+		cancel()
+		return nil, fmt.Errorf("failed to connect to clamd: %w", err)
+	}
+
+	// Unblock reads and writes once the scan times out or the request goes away
+	stop := context.AfterFunc(ctx, func() {
+		_ = conn.SetDeadline(time.Unix(1, 0))
+	})
+	return &clamdConn{Conn: conn, release: func() {
+		stop()
+		cancel()
+	}}, nil
+}
+
+// clamdConn releases the connection's context when closed
+type clamdConn struct {
+	net.Conn
+	release func()
+}
+
+func (c *clamdConn) Close() error {
+	c.release()
+	return c.Conn.Close()
+}
+
+// writeStream sends the INSTREAM command followed by length-prefixed chunks
+// and the zero-length terminator
+func writeStream(w io.Writer, r io.Reader) error {
+	bw := bufio.NewWriterSize(w, clamdChunkSize+4)
+	if _, err := bw.WriteString("zINSTREAM\x00"); err != nil {
+		return err
+	}
+
+	buf := make([]byte, clamdChunkSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			if werr := binary.Write(bw, binary.BigEndian, uint32(n)); werr != nil {
+				return werr
+			}
+			if _, werr := bw.Write(buf[:n]); werr != nil {
+				return werr
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	if err := binary.Write(bw, binary.BigEndian, uint32(0)); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+// readReply reads a null-terminated clamd reply. clamd may also close the
+// connection without the terminator, e.g. after a size limit error.
+func readReply(r io.Reader) (string, error) {
+	reply, err := bufio.NewReader(r).ReadBytes(0)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return "", err
+	}
+	reply = bytes.TrimRight(reply, "\x00")
+	if len(reply) == 0 {
+		return "", io.ErrUnexpectedEOF
+	}
+	return strings.TrimSpace(string(reply)), nil
+}
+
+// parseReply interprets "stream: OK", "stream: <signature> FOUND" and error replies
+func parseReply(reply string) (Result, error) {
+	verdict := strings.TrimPrefix(reply, "stream: ")
+	switch {
+	case verdict == "OK":
+		return Result{}, nil
+	case strings.HasSuffix(verdict, " FOUND"):
+		return Result{Infected: true, Signature: strings.TrimSuffix(verdict, " FOUND")}, nil
+	default:
+		return Result{}, fmt.Errorf("%w: %s", ErrScanFailed, reply)
+	}
+}
+
+// =============================================================================
+// Health Check
+// =============================================================================
+
+// clamdChecker reports whether clamd answers PING
+type clamdChecker struct {
+	clamd *Clamd
+}
+
+// NewClamdChecker creates a health checker for the clamd daemon
+func NewClamdChecker(clamd *Clamd) health.Checker {
+	return &clamdChecker{clamd: clamd}
+}
+
+func (c *clamdChecker) Name() string {
+	return "clamd"
+}
+
+func (c *clamdChecker) Check(ctx context.Context) health.CheckResult {
+	start := time.Now()
+	if err := c.clamd.Ping(ctx); err != nil {
+		return health.CheckResult{
+			Status:  health.StatusUnhealthy,
+			Latency: time.Since(start).String(),
+			Error:   err.Error(),
+		}
+	}
+	return health.CheckResult{
+		Status:  health.StatusHealthy,
+		Latency: time.Since(start).String(),
+	}
+}
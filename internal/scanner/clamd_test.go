@@ -0,0 +1,196 @@
+package scanner
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// =============================================================================
+// Fake clamd
+// =============================================================================
+
+// eicar is the standard antivirus test string
+const eicar = `X5O!P%@AP[4\PZX54(P^)7CC)7}$EICAR-STANDARD-ANTIVIRUS-TEST-FILE!$H+H*`
+
+// fakeClamd speaks enough of the clamd protocol to answer PING and INSTREAM,
+// reporting any stream containing the EICAR string as infected
+type fakeClamd struct {
+	listener  net.Listener
+	maxStream int
+	reply     string
+	received  chan []byte
+}
+
+// startFakeClamd serves f, configured before it starts, on a new listener
+func startFakeClamd(t *testing.T, network, address string, f *fakeClamd) *fakeClamd {
+	t.Helper()
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		t.Fatalf("failed to start fake clamd: %v", err)
+	}
+	f.listener = listener
+	f.received = make(chan []byte, 1)
+	t.Cleanup(func() { _ = listener.Close() })
+	go f.serve()
+	return f
+}
+
+func (f *fakeClamd) address() string {
+	return f.listener.Addr().Network() + "://" + f.listener.Addr().String()
+}
+
+func (f *fakeClamd) serve() {
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			return
+		}
+		go f.handle(conn)
+	}
+}
+
+func (f *fakeClamd) handle(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	command, err := r.ReadString(0)
+	if err != nil {
+		return
+	}
+
+	switch command {
+	case "zPING\x00":
+		_, _ = conn.Write([]byte("PONG\x00"))
+	case "zINSTREAM\x00":
+		var data []byte
+		for {
+			var size uint32
+			if err := binary.Read(r, binary.BigEndian, &size); err != nil {
+				return
+			}
+			if size == 0 {
+				break
+			}
+			if f.maxStream > 0 && len(data)+int(size) > f.maxStream {
+				_, _ = conn.Write([]byte("INSTREAM size limit exceeded. ERROR\x00"))
+				return
+			}
+			chunk := make([]byte, size)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return
+			}
+			data = append(data, chunk...)
+		}
+		f.received <- data
+
+		switch {
+		case f.reply != "":
+			_, _ = conn.Write([]byte(f.reply + "\x00"))
+		case bytes.Contains(data, []byte(eicar)):
+			_, _ = conn.Write([]byte("stream: Eicar-Test-Signature FOUND\x00"))
+		default:
+			_, _ = conn.Write([]byte("stream: OK\x00"))
+		}
+	}
+}
+
+// =============================================================================
+// Clamd Tests
+// =============================================================================
+
+func TestClamd_Scan(t *testing.T) {
+	fake := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{})
+	clamd, err := NewClamd(fake.address(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// Larger than one chunk to exercise framing
+	clean := bytes.Repeat([]byte("%PDF-1.4 harmless "), clamdChunkSize/8)
+	result, err := clamd.Scan(context.Background(), bytes.NewReader(clean))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if result.Infected || result.Verdict() != VerdictClean {
+		t.Errorf("expected clean verdict, got %+v", result)
+	}
+	if received := <-fake.received; !bytes.Equal(received, clean) {
+		t.Errorf("expected clamd to receive %d bytes, got %d", len(clean), len(received))
+	}
+
+	result, err = clamd.Scan(context.Background(), strings.NewReader("%PDF-1.4 "+eicar))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !result.Infected || result.Signature != "Eicar-Test-Signature" || result.Verdict() != VerdictInfected {
+		t.Errorf("expected EICAR detection, got %+v", result)
+	}
+}
+
+func TestClamd_UnixSocketAndPing(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "clamd.sock")
+	fake := startFakeClamd(t, "unix", socket, &fakeClamd{})
+	clamd, err := NewClamd("unix://"+socket, 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if err := clamd.Ping(context.Background()); err != nil {
+		t.Errorf("expected PING to succeed, got %v", err)
+	}
+	if result := NewClamdChecker(clamd).Check(context.Background()); result.Status != "healthy" {
+		t.Errorf("expected healthy check, got %+v", result)
+	}
+
+	result, err := clamd.Scan(context.Background(), strings.NewReader(eicar))
+	if err != nil || !result.Infected {
+		t.Errorf("expected infected result over unix socket, got %+v, %v", result, err)
+	}
+	<-fake.received
+}
+
+func TestClamd_Errors(t *testing.T) {
+	limited := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{maxStream: 16})
+	clamd, err := NewClamd(limited.address(), 5*time.Second)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := clamd.Scan(context.Background(), bytes.NewReader(make([]byte, 3*clamdChunkSize))); !errors.Is(err, ErrScanFailed) {
+		t.Errorf("expected ErrScanFailed for size limit, got %v", err)
+	}
+
+	failing := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{reply: "stream: Can't allocate memory ERROR"})
+	clamd, _ = NewClamd(failing.address(), 5*time.Second)
+	if _, err := clamd.Scan(context.Background(), strings.NewReader("data")); !errors.Is(err, ErrScanFailed) {
+		t.Errorf("expected ErrScanFailed for error reply, got %v", err)
+	}
+	<-failing.received
+
+	// Nothing listens on a closed listener's address
+	closed := startFakeClamd(t, "tcp", "127.0.0.1:0", &fakeClamd{})
+	_ = closed.listener.Close()
+	unreachable, _ := NewClamd(closed.address(), time.Second)
+	if _, err := unreachable.Scan(context.Background(), strings.NewReader("data")); err == nil {
+		t.Error("expected error when clamd is unreachable")
+	}
+	if result := NewClamdChecker(unreachable).Check(context.Background()); result.Status != "unhealthy" {
+		t.Errorf("expected unhealthy check, got %+v", result)
+	}
+}
+
+func TestNewClamd_InvalidAddress(t *testing.T) {
+	addresses := []string{"clamav:3310", "http://clamav:3310", "tcp://", "unix://", "tcp://%zz"}
+	for _, address := range addresses {
+		if _, err := NewClamd(address, time.Second); err == nil {
+			t.Errorf("expected error for %q", address)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package scanner
+
+import (
+	"context"
+	"io"
+)
+
+// Verdicts stored on the file record
+const (
+	VerdictClean    = "clean"
+	VerdictInfected = "infected"
+)
+
+// Result is the outcome of scanning a single file
+type Result struct {
+	Infected bool
+	// Signature names the detected malware; empty for clean files
+	Signature string
+}
+
+// Verdict returns the value stored on the file record for the result
+func (r Result) Verdict() string {
+	if r.Infected {
+		return VerdictInfected
+	}
+	return VerdictClean
+}
+
+// Scanner checks file content for malware.
+// This interface enables mocking for unit tests.
+type Scanner interface {
+	Scan(ctx context.Context, r io.Reader) (Result, error)
+}
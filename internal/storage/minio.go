@@ -3,7 +3,9 @@ package storage
 import (
 	"context"
 	"io"
+	"net/http"
 	"strings"
+	"time"
 
 	"github.com/GunarsK-portfolio/files-api/internal/config"
 	"github.com/minio/minio-go/v7"
@@ -13,15 +15,35 @@ import (
 // ObjectStore defines the interface for object storage operations.
 // This interface enables mocking for unit tests.
 type ObjectStore interface {
-	GetObject(ctx context.Context, bucket, key string) (*minio.Object, error)
+	GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error)
 	PutObject(ctx context.Context, bucket, key string, reader io.Reader, size int64, contentType string) error
 	DeleteObject(ctx context.Context, bucket, key string) error
+	CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error
 	StatObject(ctx context.Context, bucket, key string) (minio.ObjectInfo, error)
+	NewMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error)
+	PutObjectPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (string, error)
+	CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) error
+	AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error
+	GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error)
+	PresignPutObject(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error)
+	DeletePrefix(ctx context.Context, bucket, prefix string) error
+	ListObjects(ctx context.Context, bucket string, fn func(minio.ObjectInfo) error) error
+}
+
+// MinPartSize is the smallest part S3 accepts in a multipart upload (except the last part).
+const MinPartSize = 5 << 20
+
+// IsNotFound reports whether err means the object does not exist.
+func IsNotFound(err error) bool {
+	return minio.ToErrorResponse(err).Code == "NoSuchKey"
 }
 
 // Storage implements ObjectStore using MinIO client.
 type Storage struct {
 	client *minio.Client
+	core   minio.Core
+	// presignClient signs URLs handed to browsers; it may target a public endpoint
+	presignClient *minio.Client
 }
 
 // Compile-time check that Storage implements ObjectStore.
@@ -30,8 +52,7 @@ var _ ObjectStore = (*Storage)(nil)
 //nolint:staticcheck // Embedded field name required for clarity
 func New(cfg *config.Config) (*Storage, error) {
 	// Strip protocol from endpoint (MinIO client expects just hostname:port)
-	endpoint := strings.TrimPrefix(cfg.S3Config.Endpoint, "http://")
-	endpoint = strings.TrimPrefix(endpoint, "https://")
+	endpoint := stripScheme(cfg.S3Config.Endpoint)
 
 	// Choose credentials provider based on configuration
 	// If AccessKey/SecretKey are provided (MinIO/local dev), use static credentials
@@ -53,12 +74,36 @@ func New(cfg *config.Config) (*Storage, error) {
 		return nil, err
 	}
 
+	// Presigned URLs embed the host they were signed for, so browsers need
+	// a URL for the public endpoint when the API reaches S3 over an internal one.
+	// The region is fixed to avoid a bucket location lookup against that endpoint.
+	presignClient := client
+	if cfg.S3PublicEndpoint != "" {
+		presignClient, err = minio.New(stripScheme(cfg.S3PublicEndpoint), &minio.Options{
+			Creds:  creds,
+			Secure: strings.HasPrefix(cfg.S3PublicEndpoint, "https://"),
+			Region: cfg.S3Region,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	return &Storage{
-		client: client,
+		client:        client,
+		core:          minio.Core{Client: client},
+		presignClient: presignClient,
 	}, nil
 }
 
-func (s *Storage) GetObject(ctx context.Context, bucket, key string) (*minio.Object, error) {
+func stripScheme(endpoint string) string {
+	endpoint = strings.TrimPrefix(endpoint, "http://")
+	return strings.TrimPrefix(endpoint, "https://")
+}
+
+// GetObject returns a reader for the whole object.
+// The request is lazy, so errors such as a missing key may only surface on the first Read.
+func (s *Storage) GetObject(ctx context.Context, bucket, key string) (io.ReadCloser, error) {
 	return s.client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
 }
 
@@ -73,10 +118,94 @@ func (s *Storage) DeleteObject(ctx context.Context, bucket, key string) error {
 	return s.client.RemoveObject(ctx, bucket, key, minio.RemoveObjectOptions{})
 }
 
+// CopyObject copies an object server-side, e.g. to move it into another bucket.
+func (s *Storage) CopyObject(ctx context.Context, srcBucket, srcKey, dstBucket, dstKey string) error {
+	_, err := s.client.CopyObject(ctx,
+		minio.CopyDestOptions{Bucket: dstBucket, Object: dstKey},
+		minio.CopySrcOptions{Bucket: srcBucket, Object: srcKey},
+	)
+	return err
+}
+
 func (s *Storage) StatObject(ctx context.Context, bucket, key string) (minio.ObjectInfo, error) {
 	return s.client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
 }
 
+func (s *Storage) NewMultipartUpload(ctx context.Context, bucket, key, contentType string) (string, error) {
+	return s.core.NewMultipartUpload(ctx, bucket, key, minio.PutObjectOptions{
+		ContentType: contentType,
+	})
+}
+
+// PutObjectPart uploads a single part and returns its ETag.
+func (s *Storage) PutObjectPart(ctx context.Context, bucket, key, uploadID string, partNumber int, reader io.Reader, size int64) (string, error) {
+	part, err := s.core.PutObjectPart(ctx, bucket, key, uploadID, partNumber, reader, size, minio.PutObjectPartOptions{})
+	if err != nil {
+		return "", err
+	}
+	return part.ETag, nil
+}
+
+func (s *Storage) CompleteMultipartUpload(ctx context.Context, bucket, key, uploadID string, parts []minio.CompletePart) error {
+	_, err := s.core.CompleteMultipartUpload(ctx, bucket, key, uploadID, parts, minio.PutObjectOptions{})
+	return err
+}
+
+func (s *Storage) AbortMultipartUpload(ctx context.Context, bucket, key, uploadID string) error {
+	return s.core.AbortMultipartUpload(ctx, bucket, key, uploadID)
+}
+
+// GetObjectRange returns a reader for length bytes starting at offset.
+func (s *Storage) GetObjectRange(ctx context.Context, bucket, key string, offset, length int64) (io.ReadCloser, error) {
+	opts := minio.GetObjectOptions{}
+	if err := opts.SetRange(offset, offset+length-1); err != nil {
+		return nil, err
+	}
+	return s.client.GetObject(ctx, bucket, key, opts)
+}
+
+// PresignPutObject returns a URL the client can PUT the object to directly.
+// The Content-Type header is part of the signature, so S3 rejects uploads declaring another type.
+func (s *Storage) PresignPutObject(ctx context.Context, bucket, key, contentType string, expiry time.Duration) (string, error) {
+	headers := http.Header{}
+	headers.Set("Content-Type", contentType)
+	u, err := s.presignClient.PresignHeader(ctx, http.MethodPut, bucket, key, expiry, nil, headers)
+	if err != nil {
+		return "", err
+	}
+	return u.String(), nil
+}
+
+// DeletePrefix removes every object whose key starts with prefix.
+func (s *Storage) DeletePrefix(ctx context.Context, bucket, prefix string) error {
+	objects := s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Prefix: prefix, Recursive: true})
+	// Drain all results so the removal goroutine is not left blocked
+	var firstErr error
+	for result := range s.client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{}) {
+		if result.Err != nil && firstErr == nil {
+			firstErr = result.Err
+		}
+	}
+	return firstErr
+}
+
+// ListObjects calls fn for every object in the bucket in key order, stopping at the first error.
+func (s *Storage) ListObjects(ctx context.Context, bucket string, fn func(minio.ObjectInfo) error) error {
+	// Cancelling stops the listing goroutine when fn returns early
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	for object := range s.client.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			return object.Err
+		}
+		if err := fn(object); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // Client returns the underlying MinIO client for health checks.
 func (s *Storage) Client() *minio.Client {
 	return s.client